@@ -38,9 +38,6 @@ func newGin(conf *config.Config, log logger.AppLogger) *gin.Engine {
 	// set lang
 	r.Use(i18n.SetLocal)
 
-	r.Static("/static", "./public/static")
-	r.Static("/public/static", "./public/static")
-
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
 
 	return r
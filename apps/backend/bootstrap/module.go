@@ -8,4 +8,7 @@ var Module = fx.Options(
 	fx.Provide(newGin),
 	fx.Provide(NewCarbin),
 	fx.Invoke(useGin),
+	fx.Invoke(useFileCollector),
+	fx.Invoke(useFileScanner),
+	fx.Invoke(useStockAlerts),
 )
@@ -0,0 +1,94 @@
+package bootstrap
+
+import (
+	"context"
+	"time"
+
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/internal/service"
+	"github.com/suttapak/starter/logger"
+	"go.uber.org/fx"
+)
+
+// schedule runs job every interval in the background until the app stops.
+func schedule(lc fx.Lifecycle, interval time.Duration, job func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				ticker := time.NewTicker(interval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+						job(ctx)
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+			}
+			return nil
+		},
+	})
+}
+
+func useFileCollector(
+	lc fx.Lifecycle,
+	conf *config.Config,
+	collector service.FileCollector,
+	log logger.AppLogger,
+) {
+	if !conf.FILE_GC.ENABLE || conf.FILE_GC.INTERVAL <= 0 {
+		return
+	}
+	schedule(lc, conf.FILE_GC.INTERVAL, func(ctx context.Context) {
+		if _, err := collector.Collect(ctx, conf.FILE_GC.DRY_RUN); err != nil {
+			log.Error(err)
+		}
+	})
+}
+
+func useFileScanner(
+	lc fx.Lifecycle,
+	conf *config.Config,
+	scan service.FileScanService,
+	log logger.AppLogger,
+) {
+	if conf.SCANNER.RESCAN_INTERVAL <= 0 {
+		return
+	}
+	schedule(lc, conf.SCANNER.RESCAN_INTERVAL, func(ctx context.Context) {
+		if _, err := scan.RescanPending(ctx); err != nil {
+			log.Error(err)
+		}
+	})
+}
+
+func useStockAlerts(
+	lc fx.Lifecycle,
+	conf *config.Config,
+	alert service.StockAlertService,
+	log logger.AppLogger,
+) {
+	if !conf.ALERT.ENABLE || conf.ALERT.INTERVAL <= 0 {
+		return
+	}
+	schedule(lc, conf.ALERT.INTERVAL, func(ctx context.Context) {
+		if _, err := alert.Evaluate(ctx, 0); err != nil {
+			log.Error(err)
+		}
+		if _, err := alert.SendDigests(ctx, time.Now()); err != nil {
+			log.Error(err)
+		}
+	})
+}
@@ -1,8 +1,6 @@
 package main
 
 import (
-	"time"
-
 	"github.com/suttapak/starter/bootstrap"
 	"github.com/suttapak/starter/domain/config"
 	"github.com/suttapak/starter/domain/database"
@@ -19,14 +17,6 @@ import (
 	"go.uber.org/fx"
 )
 
-func init() {
-	ict, err := time.LoadLocation("Asia/Bangkok")
-	if err != nil {
-		panic(err)
-	}
-	time.Local = ict
-}
-
 //	@title			Swagger Example API
 //	@version		1.0
 //	@description	This is a sample server caller server.
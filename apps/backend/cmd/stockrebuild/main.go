@@ -0,0 +1,58 @@
+// Command stockrebuild sets the cached remaining quantity of product lots and
+// their stock per location back from the stock ledger. Lots received before the
+// ledger existed are opened with their current remaining quantity first, stock
+// recorded before locations existed is moved into the default location of its
+// team. Run it while no stock is posted:
+//
+//	go run ./cmd/stockrebuild -team 1
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/domain/database"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/i18n"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/internal/service"
+	"github.com/suttapak/starter/logger"
+
+	"go.uber.org/fx"
+)
+
+func main() {
+	teamId := flag.Uint("team", 0, "rebuild the lots of this team only, 0 rebuilds every team")
+	flag.Parse()
+
+	var ledger service.StockLedgerService
+	app := fx.New(
+		helpers.Module,
+		logger.Module,
+		config.Module,
+		i18n.Module,
+		database.Module,
+		repository.Module,
+		fx.Provide(service.NewStockLedgerService),
+		fx.Provide(service.NewWarehouseService),
+		fx.Provide(service.NewUomService),
+		fx.Provide(service.NewExcelService),
+		fx.Populate(&ledger),
+		fx.NopLogger,
+	)
+	if err := app.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	res, err := ledger.RebuildCache(ctx, *teamId)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("opened %d lots, relocated %d lots, updated %d lots, wrote %d lot stocks\n", res.Opened, res.Relocated, res.Updated, res.LotStocks)
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -20,6 +21,11 @@ type (
 		HOST_NAME string `yaml:"HOST_NAME" `
 		HOST      string `yaml:"HOST"`
 		PORT      string `yaml:"PORT"`
+		// TIME_ZONE is the IANA zone of the business day, e.g. Asia/Bangkok.
+		// Document numbers, date filters and alert digests follow it.
+		TIME_ZONE string `yaml:"TIME_ZONE"`
+		// LOCATION is TIME_ZONE loaded.
+		LOCATION *time.Location `yaml:"-" mapstructure:"-"`
 	}
 
 	DB struct {
@@ -51,16 +57,72 @@ type (
 		Url   string `yaml:"URL"`
 		Token string `yaml:"TOKEN"`
 	}
+	STORAGE struct {
+		DRIVER         string        `yaml:"DRIVER"` // "local" or "s3"
+		SIGNING_SECRET string        `yaml:"SIGNING_SECRET"`
+		LOCAL          STORAGE_LOCAL `yaml:"LOCAL"`
+		S3             STORAGE_S3    `yaml:"S3"`
+	}
+	STORAGE_LOCAL struct {
+		ROOT     string `yaml:"ROOT"`
+		BASE_URL string `yaml:"BASE_URL"`
+	}
+	STORAGE_S3 struct {
+		ENDPOINT   string `yaml:"ENDPOINT"`
+		REGION     string `yaml:"REGION"`
+		BUCKET     string `yaml:"BUCKET"`
+		ACCESS_KEY string `yaml:"ACCESS_KEY"`
+		SECRET_KEY string `yaml:"SECRET_KEY"`
+		USE_SSL    bool   `yaml:"USE_SSL"`
+	}
+	FILE_GC struct {
+		ENABLE    bool          `yaml:"ENABLE"`
+		DRY_RUN   bool          `yaml:"DRY_RUN"`
+		INTERVAL  time.Duration `yaml:"INTERVAL"`
+		RETENTION time.Duration `yaml:"RETENTION"` // files younger than this are never collected
+	}
+	TUS struct {
+		EXPIRE time.Duration `yaml:"EXPIRE"` // unfinished uploads are removed after this long without a chunk
+	}
+	SCANNER struct {
+		DRIVER          string        `yaml:"DRIVER"`  // "noop" or "clamd"
+		ADDRESS         string        `yaml:"ADDRESS"` // clamd TCP address, host:port
+		TIMEOUT         time.Duration `yaml:"TIMEOUT"`
+		RESCAN_INTERVAL time.Duration `yaml:"RESCAN_INTERVAL"` // retry of files the scanner failed on
+	}
+	UPLOAD struct {
+		MAX_BYTES   int64    `yaml:"MAX_BYTES"`   // per file, 0 means no limit
+		IMAGE_TYPES []string `yaml:"IMAGE_TYPES"` // MIME types sniffed from the content
+		FILE_TYPES  []string `yaml:"FILE_TYPES"`  // for attachments, empty allows every type
+		MAX_WIDTH   int      `yaml:"MAX_WIDTH"`   // pixels
+		MAX_HEIGHT  int      `yaml:"MAX_HEIGHT"`  // pixels
+		USER_QUOTA  int64    `yaml:"USER_QUOTA"`  // bytes per user, 0 means no limit
+	}
+	APPROVAL struct {
+		LINK_EXPIRE time.Duration `yaml:"LINK_EXPIRE"` // lifetime of the approve and reject links in emails
+	}
+	ALERT struct {
+		ENABLE      bool          `yaml:"ENABLE"`
+		INTERVAL    time.Duration `yaml:"INTERVAL"`    // between evaluations of the alert rules
+		DIGEST_HOUR int           `yaml:"DIGEST_HOUR"` // hour of SERVER.TIME_ZONE from which the daily digest is sent
+	}
 
 	Config struct {
-		SERVER SERVER `yaml:"SERVER"`
-		DB     DB     `yaml:"DB"`
-		CORS   CORS   `yaml:"CORS"`
-		JWT    JWT    `yaml:"JWT"`
-		PPROF  PPROF  `yaml:"PPROF"`
-		MAIL   MAIL   `yaml:"mail"`
-		CARBIN CARBIN `yaml:"CARBIN"`
-		LabODT LabODT `yaml:"LabODT"`
+		SERVER   SERVER   `yaml:"SERVER"`
+		DB       DB       `yaml:"DB"`
+		CORS     CORS     `yaml:"CORS"`
+		JWT      JWT      `yaml:"JWT"`
+		PPROF    PPROF    `yaml:"PPROF"`
+		MAIL     MAIL     `yaml:"mail"`
+		CARBIN   CARBIN   `yaml:"CARBIN"`
+		LabODT   LabODT   `yaml:"LabODT"`
+		STORAGE  STORAGE  `yaml:"STORAGE"`
+		FILE_GC  FILE_GC  `yaml:"FILE_GC"`
+		TUS      TUS      `yaml:"TUS"`
+		UPLOAD   UPLOAD   `yaml:"UPLOAD"`
+		SCANNER  SCANNER  `yaml:"SCANNER"`
+		APPROVAL APPROVAL `yaml:"APPROVAL"`
+		ALERT    ALERT    `yaml:"ALERT"`
 	}
 )
 
@@ -80,6 +142,7 @@ func newAppConfig() *Config {
 
 	// default config
 	viper.SetDefault("SERVER", SERVER{})
+	viper.SetDefault("SERVER.TIME_ZONE", "Asia/Bangkok")
 	viper.SetDefault("DB", DB{})
 	viper.SetDefault("CORS", CORS{})
 	viper.SetDefault("JWT", JWT{})
@@ -87,6 +150,24 @@ func newAppConfig() *Config {
 	viper.SetDefault("MAIL", MAIL{})
 	viper.SetDefault("CARBIN", CARBIN{})
 	viper.SetDefault("LabODT", LabODT{})
+	viper.SetDefault("STORAGE.DRIVER", "local")
+	viper.SetDefault("STORAGE.LOCAL.ROOT", "./public/static")
+	viper.SetDefault("FILE_GC.INTERVAL", "24h")
+	viper.SetDefault("FILE_GC.RETENTION", "720h")
+	viper.SetDefault("TUS.EXPIRE", "24h")
+	viper.SetDefault("UPLOAD.MAX_BYTES", 100<<20)
+	viper.SetDefault("UPLOAD.IMAGE_TYPES", []string{"image/png", "image/jpeg", "image/gif"})
+	viper.SetDefault("UPLOAD.MAX_WIDTH", 10000)
+	viper.SetDefault("UPLOAD.MAX_HEIGHT", 10000)
+	viper.SetDefault("UPLOAD.USER_QUOTA", 1<<30)
+	viper.SetDefault("SCANNER.DRIVER", "noop")
+	viper.SetDefault("SCANNER.ADDRESS", "localhost:3310")
+	viper.SetDefault("SCANNER.TIMEOUT", "1m")
+	viper.SetDefault("SCANNER.RESCAN_INTERVAL", "10m")
+	viper.SetDefault("APPROVAL.LINK_EXPIRE", "72h")
+	viper.SetDefault("ALERT.ENABLE", true)
+	viper.SetDefault("ALERT.INTERVAL", "1h")
+	viper.SetDefault("ALERT.DIGEST_HOUR", 8)
 
 	if err := viper.WriteConfig(); err != nil {
 		panic(err)
@@ -95,5 +176,13 @@ func newAppConfig() *Config {
 	if err := viper.Unmarshal(conf); err != nil {
 		panic(err)
 	}
+	if conf.SERVER.LOCATION, err = time.LoadLocation(conf.SERVER.TIME_ZONE); err != nil {
+		panic("panic in config parser : " + err.Error())
+	}
+	// times printed without a location, logs included, are of the business too
+	time.Local = conf.SERVER.LOCATION
+	if conf.STORAGE.LOCAL.BASE_URL == "" {
+		conf.STORAGE.LOCAL.BASE_URL = conf.SERVER.HOST_NAME + "/api/v1/files/signed"
+	}
 	return conf
 }
@@ -2,6 +2,7 @@ package database
 
 import (
 	"fmt"
+	"time"
 
 	gormadapter "github.com/casbin/gorm-adapter/v3"
 	"github.com/suttapak/starter/domain/config"
@@ -22,14 +23,221 @@ func NewGorm(conf *config.Config) (*gorm.DB, error) {
 	return db, nil
 }
 
-func MigrateDb(db *gorm.DB) error {
+func MigrateDb(db *gorm.DB, conf *config.Config) error {
+	if err := dedupeSequences(db); err != nil {
+		return err
+	}
 	err := db.AutoMigrate(
 		&model.AutoIncrementSequence{},
 		&model.Image{},
+		&model.Blob{},
+		&model.Upload{},
+		&model.UploadPart{},
+		&model.Attachment{},
 		&model.Role{},
 		&model.User{},
 		&model.ProfileImage{},
+		&model.Uom{},
+		&model.ProductCategory{},
+		&model.Product{},
+		&model.ProductProductCategory{},
+		&model.ProductImage{},
+		&model.ProductUom{},
+		&model.ProductLot{},
+		&model.Partner{},
+		&model.PartnerAddress{},
+		&model.PartnerContact{},
+		&model.Transaction{},
+		&model.TransactionItem{},
+		&model.ApprovalStep{},
+		&model.TransactionApproval{},
+		&model.StockMovement{},
+		&model.Warehouse{},
+		&model.Location{},
+		&model.LotStock{},
+		&model.StockTransfer{},
+		&model.StockTransferItem{},
+		&model.StockCount{},
+		&model.StockCountItem{},
+		&model.StockAdjustment{},
+		&model.StockAdjustmentItem{},
+		&model.AlertRule{},
+		&model.Alert{},
+		&model.AlertSubscriber{},
+		&model.InventorySetting{},
+		&model.CostLayer{},
+		&model.ProductCost{},
+		&model.CostEntry{},
+		&model.NumberingScheme{},
+		&model.SequenceAudit{},
 		&gormadapter.CasbinRule{},
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	if err := migrateUoms(db); err != nil {
+		return err
+	}
+	if err := migrateValuation(db); err != nil {
+		return err
+	}
+	if err := migrateSequencePeriods(db, conf.SERVER.LOCATION); err != nil {
+		return err
+	}
+	if err := migrateImageUrls(db); err != nil {
+		return err
+	}
+	return migrateStockLedger(db)
+}
+
+// dedupeSequences keeps the latest of the sequences the racy counting used to
+// insert twice, so idx_sequence_key can be created.
+func dedupeSequences(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&model.AutoIncrementSequence{}) {
+		return nil
+	}
+	return db.Exec(`
+DELETE FROM auto_increment_sequences AS a
+USING auto_increment_sequences AS b
+WHERE a.entity_type = b.entity_type AND a.team_id = b.team_id AND a.entity_id = b.entity_id
+AND (a.updated_at, a.sequence, a.id) < (b.updated_at, b.sequence, b.id)
+`).Error
+}
+
+// migrateUoms adds the free text units of products created before the unit
+// catalog to the catalog of their team and makes them the base units.
+func migrateUoms(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+INSERT INTO uoms (team_id, name, description, created_at, updated_at)
+SELECT DISTINCT team_id, uom, '', NOW(), NOW() FROM products
+WHERE base_uom_id IS NULL AND uom <> ''
+ON CONFLICT (team_id, name) DO NOTHING
+`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`
+UPDATE products AS p SET base_uom_id = u.id
+FROM uoms AS u
+WHERE p.base_uom_id IS NULL AND u.team_id = p.team_id AND u.name = p.uom
+`).Error
+	})
+}
+
+// migrateValuation opens a cost layer, with its cost entry, for the remaining
+// quantity of lots received before stock was valued at their unit cost, and the
+// moving average of their products from the layers. The cost journal is
+// append-only like the stock ledger.
+func migrateValuation(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+INSERT INTO cost_entries (team_id, product_id, product_lot_id, method, quantity, amount, reason, ref_type, posted_at, created_at, updated_at)
+SELECT l.team_id, l.product_id, l.id, '', l.remaining_qty, l.remaining_qty * l.unit_cost, 'opening', '', l.created_at, NOW(), NOW()
+FROM product_lots AS l
+WHERE l.remaining_qty > 0
+AND NOT EXISTS (SELECT 1 FROM cost_layers AS c WHERE c.product_lot_id = l.id)
+`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`
+INSERT INTO cost_layers (team_id, product_id, product_lot_id, quantity, remaining_qty, unit_cost, received_at, created_at, updated_at)
+SELECT l.team_id, l.product_id, l.id, l.remaining_qty, l.remaining_qty, l.unit_cost, l.created_at, NOW(), NOW()
+FROM product_lots AS l
+WHERE l.remaining_qty > 0
+AND NOT EXISTS (SELECT 1 FROM cost_layers AS c WHERE c.product_lot_id = l.id)
+`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`
+INSERT INTO product_costs (team_id, product_id, quantity, value, created_at, updated_at)
+SELECT team_id, product_id, SUM(remaining_qty), SUM(remaining_qty * unit_cost), NOW(), NOW()
+FROM cost_layers
+GROUP BY team_id, product_id
+ON CONFLICT (product_id) DO NOTHING
+`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`
+CREATE OR REPLACE FUNCTION cost_entries_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'cost_entries is append-only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS cost_entries_append_only ON cost_entries;
+CREATE TRIGGER cost_entries_append_only
+	BEFORE UPDATE OR DELETE ON cost_entries
+	FOR EACH ROW EXECUTE FUNCTION cost_entries_append_only();
+`).Error
+	})
+}
+
+// migrateSequencePeriods gives the daily sequences counted before they had a
+// period key the business day of their last number, so today's documents keep
+// counting. The key matches service.CodeService's daily period in loc.
+func migrateSequencePeriods(db *gorm.DB, loc *time.Location) error {
+	var sequences []model.AutoIncrementSequence
+	err := db.
+		Where("period = '' AND entity_type IN ?", []model.EntityType{
+			model.EntityTypeTransactionSale,
+			model.EntityTypeTransactionReturn,
+			model.EntityTypeTransactionPurchase,
+			model.EntityTypeStockTransfer,
+			model.EntityTypeStockCount,
+			model.EntityTypeStockAdjustment,
+		}).
+		Find(&sequences).Error
+	if err != nil {
+		return err
+	}
+	for _, s := range sequences {
+		err := db.Model(&model.AutoIncrementSequence{}).
+			Where("id = ?", s.ID).
+			UpdateColumn("period", s.UpdatedAt.In(loc).Format(time.DateOnly)).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateImageUrls points the images stored before the storage backend at the
+// routes serving them. Their path was relative to the working directory and
+// their url the bare file name, service.imageUrl and service.productImageUrl
+// build the url from the id now.
+func migrateImageUrls(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(`
+UPDATE images SET path = substr(path, length('public/static/') + 1)
+WHERE path LIKE 'public/static/%'
+`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`
+UPDATE images AS i SET url = '/teams/' || p.team_id || '/products/' || p.id || '/images/' || pi.id
+FROM product_images AS pi
+JOIN products AS p ON p.id = pi.product_id
+WHERE pi.image_id = i.id AND i.url NOT LIKE '/%'
+`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(`
+UPDATE images SET url = '/files/images/' || id
+WHERE url NOT LIKE '/%'
+`).Error
+	})
+}
+
+// migrateStockLedger makes the stock ledger append-only, corrections are new movements.
+func migrateStockLedger(db *gorm.DB) error {
+	return db.Exec(`
+CREATE OR REPLACE FUNCTION stock_movements_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'stock_movements is append-only';
+END;
+$$ LANGUAGE plpgsql;
+DROP TRIGGER IF EXISTS stock_movements_append_only ON stock_movements;
+CREATE TRIGGER stock_movements_append_only
+	BEFORE UPDATE OR DELETE ON stock_movements
+	FOR EACH ROW EXECUTE FUNCTION stock_movements_append_only();
+`).Error
 }
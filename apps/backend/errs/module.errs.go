@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
 )
 
 var (
@@ -23,6 +24,7 @@ var (
 	ErrGenerateJWTFail              = New(http.StatusInternalServerError, "บางอย่างผิดพลาดในการออก token")
 	ErrUsernameOrPasswordIncorrect  = New(http.StatusUnauthorized, "username หรือ password ไม่ถูกต้อง")
 	ErrVerifyEmail                  = New(http.StatusBadRequest, "ข้อมูลการยืนยันตัวตนไม่ถูกต้อง")
+	ErrPasswordLink                 = New(http.StatusBadRequest, "ลิงก์ตั้งรหัสผ่านไม่ถูกต้อง หมดอายุ หรือถูกใช้ไปแล้ว")
 	ErrTeamUsernameIsUsed           = New(http.StatusBadRequest, "username นี้มีการใช้งานแล้วกรุณาใช้ username อื่น")
 	ErrDuplicatedKey                = New(http.StatusBadRequest, "มีข้อมูลนี้แล้ว ข้อมูลซ่ำโปรดลองกรอกข้อมูลอื่นๆ")
 	ErrNotActiveTeamId              = New(http.StatusUnauthorized, "กรุณาระบุแผนกที่ทำงานอยู่")
@@ -47,6 +49,66 @@ var (
 	ErrFileImageCanNotSaveToDisk    = New(http.StatusBadRequest, "ไม่สามารถบันทึกไฟล์รูปภาพลงดิสก์ได้")
 	ErrFileUploadNotFound           = New(http.StatusBadRequest, "ไม่พบไฟล์ที่อัปโหลด")
 	ErrFileUploadNoFile             = New(http.StatusBadRequest, "ไม่พบไฟล์ที่อัปโหลด")
+	ErrSignedUrlInvalid             = New(http.StatusForbidden, "ลิงก์ดาวน์โหลดไม่ถูกต้อง")
+	ErrSignedUrlExpired             = New(http.StatusGone, "ลิงก์ดาวน์โหลดหมดอายุแล้ว")
+	ErrTusVersionNotSupported       = New(http.StatusPreconditionFailed, "ไม่รองรับเวอร์ชันของ tus ที่ระบุ")
+	ErrUploadNotFound               = New(http.StatusNotFound, "ไม่พบข้อมูลการอัปโหลด")
+	ErrUploadExpired                = New(http.StatusGone, "การอัปโหลดหมดอายุแล้ว กรุณาเริ่มอัปโหลดใหม่")
+	ErrUploadOffsetMismatch         = New(http.StatusConflict, "ตำแหน่งของข้อมูลที่อัปโหลดไม่ตรงกัน")
+	ErrUploadTooLarge               = New(http.StatusRequestEntityTooLarge, "ไฟล์ที่อัปโหลดมีขนาดใหญ่เกินกำหนด")
+	ErrUploadInvalidContentType     = New(http.StatusUnsupportedMediaType, "Content-Type ของข้อมูลที่อัปโหลดไม่ถูกต้อง")
+	ErrUploadInvalidLength          = New(http.StatusBadRequest, "ขนาดของไฟล์ที่อัปโหลดไม่ถูกต้อง")
+	ErrUploadInvalidMetadata        = New(http.StatusBadRequest, "ข้อมูล Upload-Metadata ไม่ถูกต้อง")
+	ErrUploadTargetNotSupported     = New(http.StatusBadRequest, "ไม่รองรับปลายทางของการอัปโหลดที่ระบุ")
+	ErrUploadTypeNotAllowed         = New(http.StatusUnsupportedMediaType, "ไม่อนุญาตให้อัปโหลดไฟล์ประเภทนี้")
+	ErrUploadImageTooLarge          = New(http.StatusBadRequest, "รูปภาพมีความกว้างหรือความสูงเกินกำหนด")
+	ErrUploadQuotaExceeded          = New(http.StatusForbidden, "พื้นที่จัดเก็บไฟล์ของผู้ใช้งานเต็มแล้ว")
+	ErrFileInfected                 = New(http.StatusUnprocessableEntity, "ตรวจพบมัลแวร์ในไฟล์ที่อัปโหลด ไฟล์ถูกกักกันแล้ว")
+	ErrFileNotScanned               = New(http.StatusLocked, "ไฟล์ยังไม่ผ่านการตรวจสอบมัลแวร์ กรุณาลองใหม่ภายหลัง")
+	ErrAttachmentEntityNotSupported = New(http.StatusBadRequest, "ไม่รองรับการแนบไฟล์กับข้อมูลประเภทนี้")
+	ErrProductCategoryNotFound      = New(http.StatusBadRequest, "ไม่พบหมวดหมู่สินค้าที่ระบุในแผนกนี้")
+	ErrProductLotInvalidDates       = New(http.StatusBadRequest, "วันหมดอายุต้องไม่ก่อนวันผลิต")
+	ErrProductImageOrderInvalid     = New(http.StatusBadRequest, "ลำดับรูปภาพต้องระบุรูปภาพทั้งหมดของสินค้า รูปละหนึ่งครั้ง")
+	ErrApprovalNotCurrent           = New(http.StatusConflict, "รายการอนุมัตินี้ได้รับการพิจารณาแล้วหรือยังไม่ถึงลำดับการอนุมัติ")
+	ErrApprovalStepNotFound         = New(http.StatusBadRequest, "ไม่พบขั้นตอนการอนุมัติที่ระบุในแผนกนี้")
+	ErrApprovalLinkInvalid          = New(http.StatusForbidden, "ลิงก์อนุมัติไม่ถูกต้อง")
+	ErrApprovalLinkExpired          = New(http.StatusGone, "ลิงก์อนุมัติหมดอายุแล้ว")
+	ErrWarehouseNotFound            = New(http.StatusBadRequest, "ไม่พบคลังสินค้าที่ระบุในแผนกนี้")
+	ErrLocationNotFound             = New(http.StatusBadRequest, "ไม่พบตำแหน่งจัดเก็บที่ระบุในแผนกนี้")
+	ErrLocationInUse                = New(http.StatusConflict, "ไม่สามารถลบได้เนื่องจากยังมีสินค้าคงเหลือหรือเป็นตำแหน่งจัดเก็บหลักของระบบ")
+	ErrStockTransferNotFound        = New(http.StatusBadRequest, "ไม่พบใบโอนสินค้าที่ระบุ")
+	ErrStockTransferStatus          = New(http.StatusBadRequest, "ไม่สามารถดำเนินการได้ในสถานะปัจจุบันของใบโอนสินค้า")
+	ErrStockTransferSameLocation    = New(http.StatusBadRequest, "ตำแหน่งต้นทางและปลายทางต้องไม่ซ้ำกัน")
+	ErrStockCountNotFound           = New(http.StatusBadRequest, "ไม่พบใบตรวจนับสต็อกที่ระบุ")
+	ErrStockCountStatus             = New(http.StatusBadRequest, "ไม่สามารถดำเนินการได้ในสถานะปัจจุบันของใบตรวจนับสต็อก")
+	ErrStockCountReasonRequired     = New(http.StatusBadRequest, "กรุณาระบุเหตุผลของทุกรายการที่มีผลต่างจากยอดในระบบ")
+	ErrStockCountSheetInvalid       = New(http.StatusBadRequest, "ไฟล์ผลการตรวจนับไม่ถูกต้อง กรุณาตรวจสอบรหัสสินค้า รหัส Lot และจำนวนที่นับได้")
+	ErrStockAdjustmentNotFound      = New(http.StatusBadRequest, "ไม่พบใบปรับปรุงสต็อกที่ระบุ")
+	ErrUomNotFound                  = New(http.StatusBadRequest, "ไม่พบหน่วยนับที่ระบุในแผนกนี้")
+	ErrUomInUse                     = New(http.StatusConflict, "ไม่สามารถลบหน่วยนับที่มีสินค้าหรือรายการเอกสารใช้งานอยู่")
+	ErrProductUomInvalid            = New(http.StatusBadRequest, "หน่วยนับของสินค้าต้องไม่ซ้ำกันและไม่ซ้ำกับหน่วยนับหลัก")
+	ErrProductUomNotFound           = New(http.StatusBadRequest, "สินค้าไม่มีหน่วยนับที่ระบุ")
+	ErrProductBaseUomLocked         = New(http.StatusConflict, "ไม่สามารถเปลี่ยนหน่วยนับหลักของสินค้าที่มีการรับสินค้าแล้ว")
+	ErrAlertNotFound                = New(http.StatusNotFound, "ไม่พบการแจ้งเตือนที่ระบุ หรือการแจ้งเตือนถูกปิดไปแล้ว")
+	ErrAlertRuleType                = New(http.StatusBadRequest, "ประเภทการแจ้งเตือนไม่ถูกต้อง")
+	ErrPartnerNotFound              = New(http.StatusBadRequest, "ไม่พบคู่ค้าที่ระบุในแผนกนี้")
+	ErrPartnerInUse                 = New(http.StatusConflict, "ไม่สามารถลบคู่ค้าที่มีเอกสารอ้างอิงอยู่")
+	ErrPartnerTypeMismatch          = New(http.StatusBadRequest, "ประเภทคู่ค้าไม่ตรงกับเอกสาร ใบสั่งซื้อต้องเป็นผู้ขาย ใบขายและใบคืนต้องเป็นลูกค้า")
+	ErrPartnerSheetInvalid          = New(http.StatusBadRequest, "ไฟล์รายชื่อคู่ค้าไม่ถูกต้อง")
+	ErrBarcodeTooSmall              = New(http.StatusBadRequest, "ขนาดบาร์โค้ดเล็กเกินไปสำหรับรหัสนี้")
+	ErrBarcodeContent               = New(http.StatusBadRequest, "ไม่สามารถสร้างบาร์โค้ดจากรหัสนี้ได้")
+	ErrLabelSheetTooLarge           = New(http.StatusBadRequest, "จำนวนฉลากเกินกว่าที่พิมพ์ได้ในครั้งเดียว")
+	ErrScanCodeNotFound             = New(http.StatusNotFound, "ไม่พบสินค้าหรือ Lot ที่ตรงกับรหัสที่สแกน")
+	ErrScanCodeAmbiguous            = New(http.StatusConflict, "รหัสที่สแกนตรงกับ Lot ของสินค้าหลายรายการ")
+	ErrNumberingTemplateInvalid     = New(http.StatusBadRequest, "รูปแบบเลขที่เอกสารไม่ถูกต้อง ต้องมี {SEQ} หนึ่งครั้ง และมีวันที่ครบตามรอบการเริ่มนับใหม่")
+	ErrNumberingTemplateLotEntity   = New(http.StatusBadRequest, "รูปแบบเลขที่ Lot ต้องมี {ENTITY} และต้องคั่น {ENTITY} กับ {SEQ} ด้วยตัวอักษร")
+	ErrNumberingTemplateDuplicate   = New(http.StatusConflict, "รูปแบบเลขที่เอกสารซ้ำกับเอกสารประเภทอื่นของแผนกนี้")
+	ErrNumberingEntityType          = New(http.StatusBadRequest, "ประเภทเอกสารไม่รองรับการกำหนดเลขที่")
+	ErrSequenceLotProduct           = New(http.StatusBadRequest, "กรุณาระบุสินค้าของลำดับเลขที่ Lot")
+	ErrSequenceResetConfirm         = New(http.StatusConflict, "การเริ่มนับใหม่จะออกเลขที่ที่ใช้ไปแล้วในรอบนี้ซ้ำ กรุณายืนยันการเริ่มนับใหม่")
+	ErrSequenceTaken                = New(http.StatusConflict, "เลขที่นี้ถูกใช้ไปแล้ว กำหนดเลขที่ถัดไปได้เฉพาะเลขที่มากกว่าเลขที่ล่าสุด")
+	ErrImportFileInvalid            = New(http.StatusBadRequest, "ไฟล์นำเข้าข้อมูลไม่ถูกต้อง รองรับเฉพาะไฟล์ .xlsx และ .csv ที่มีแถวหัวตาราง")
+	ErrImportTooManyRows            = New(http.StatusBadRequest, "จำนวนแถวในไฟล์นำเข้าข้อมูลเกินกำหนด กรุณาแบ่งไฟล์")
 )
 
 type AppError struct {
@@ -63,7 +125,7 @@ func HandleSqlErr(err error, defaultError ...AppError) error {
 		return nil
 	}
 	switch {
-	case errors.Is(err, sql.ErrNoRows):
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, gorm.ErrRecordNotFound):
 		return ErrNotFound
 	default:
 		if errPg, ok := err.(*pgconn.PgError); ok {
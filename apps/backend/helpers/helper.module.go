@@ -5,5 +5,6 @@ import "go.uber.org/fx"
 var (
 	Module = fx.Options(
 		fx.Provide(NewHelper),
+		fx.Provide(NewURLSigner),
 	)
 )
@@ -0,0 +1,89 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/suttapak/starter/domain/config"
+)
+
+const (
+	signedUrlExpiresKey   = "expires"
+	signedUrlSignatureKey = "signature"
+)
+
+var (
+	ErrSignedUrlInvalid = errors.New("signed url: invalid signature")
+	ErrSignedUrlExpired = errors.New("signed url: expired")
+)
+
+type (
+	// URLSigner signs a path together with its query parameters so links can be
+	// handed out (e.g. in emails) without a session and stop working after expires.
+	URLSigner interface {
+		Sign(path string, params url.Values, expires time.Time) url.Values
+		Verify(path string, query url.Values, now time.Time) error
+	}
+	urlSigner struct {
+		secret []byte
+	}
+)
+
+// Sign implements URLSigner. The returned values contain params plus the expiry and signature.
+func (u *urlSigner) Sign(path string, params url.Values, expires time.Time) url.Values {
+	q := url.Values{}
+	for k, v := range params {
+		q[k] = append([]string(nil), v...)
+	}
+	q.Set(signedUrlExpiresKey, strconv.FormatInt(expires.Unix(), 10))
+	q.Del(signedUrlSignatureKey)
+	q.Set(signedUrlSignatureKey, u.signature(path, q))
+	return q
+}
+
+// Verify implements URLSigner.
+func (u *urlSigner) Verify(path string, query url.Values, now time.Time) error {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	sig, err := hex.DecodeString(q.Get(signedUrlSignatureKey))
+	if err != nil || len(sig) == 0 {
+		return ErrSignedUrlInvalid
+	}
+	q.Del(signedUrlSignatureKey)
+	expected, _ := hex.DecodeString(u.signature(path, q))
+	if !hmac.Equal(sig, expected) {
+		return ErrSignedUrlInvalid
+	}
+	expires, err := strconv.ParseInt(q.Get(signedUrlExpiresKey), 10, 64)
+	if err != nil {
+		return ErrSignedUrlInvalid
+	}
+	if now.Unix() > expires {
+		return ErrSignedUrlExpired
+	}
+	return nil
+}
+
+// signature is the hex HMAC-SHA256 of path and the sorted query without the signature.
+func (u *urlSigner) signature(path string, q url.Values) string {
+	mac := hmac.New(sha256.New, u.secret)
+	mac.Write([]byte(path))
+	mac.Write([]byte{'?'})
+	mac.Write([]byte(q.Encode()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func NewURLSigner(conf *config.Config) URLSigner {
+	secret := conf.STORAGE.SIGNING_SECRET
+	if secret == "" {
+		secret = conf.JWT.EMAIL_SECRET
+	}
+	return &urlSigner{secret: []byte(secret)}
+}
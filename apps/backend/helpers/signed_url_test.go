@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/suttapak/starter/domain/config"
+)
+
+func TestURLSigner(t *testing.T) {
+	signer := NewURLSigner(&config.Config{STORAGE: config.STORAGE{SIGNING_SECRET: "secret"}})
+	other := NewURLSigner(&config.Config{STORAGE: config.STORAGE{SIGNING_SECRET: "other"}})
+	now := time.Unix(1_700_000_000, 0)
+	path := "/images/ab/cd.png"
+	signed := signer.Sign(path, url.Values{
+		"name":        {"report.pdf"},
+		"disposition": {"attachment"},
+		"tag":         {"a", "b"},
+	}, now.Add(time.Hour))
+	// the query as a client sends it back, parameters in any order
+	raw := "tag=a&tag=b&signature=" + signed.Get("signature") + "&expires=" + signed.Get("expires") +
+		"&disposition=attachment&name=report.pdf"
+
+	tests := []struct {
+		name   string
+		signer URLSigner
+		path   string
+		query  string
+		now    time.Time
+		want   error
+	}{
+		{name: "valid", path: path, query: signed.Encode(), now: now},
+		{name: "parameters reordered", path: path, query: raw, now: now},
+		{name: "valid until expires", path: path, query: signed.Encode(), now: now.Add(time.Hour)},
+		{name: "tampered path", path: "/images/ab/ce.png", query: signed.Encode(), now: now, want: ErrSignedUrlInvalid},
+		{name: "tampered parameter", path: path, query: replaceQuery(signed, "disposition", "inline"), now: now, want: ErrSignedUrlInvalid},
+		{name: "added parameter", path: path, query: signed.Encode() + "&extra=1", now: now, want: ErrSignedUrlInvalid},
+		{name: "values reordered", path: path, query: replaceQuery(signed, "tag", "b", "a"), now: now, want: ErrSignedUrlInvalid},
+		{name: "extended expiry", path: path, query: replaceQuery(signed, "expires", "9999999999"), now: now, want: ErrSignedUrlInvalid},
+		{name: "missing signature", path: path, query: replaceQuery(signed, "signature"), now: now, want: ErrSignedUrlInvalid},
+		{name: "malformed signature", path: path, query: replaceQuery(signed, "signature", "zz"), now: now, want: ErrSignedUrlInvalid},
+		{name: "expired", path: path, query: signed.Encode(), now: now.Add(time.Hour + time.Second), want: ErrSignedUrlExpired},
+		{name: "wrong key", signer: other, path: path, query: signed.Encode(), now: now, want: ErrSignedUrlInvalid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.signer
+			if s == nil {
+				s = signer
+			}
+			query, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s.Verify(tt.path, query, tt.now))
+		})
+	}
+}
+
+func TestURLSignerFallbackSecret(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	conf := &config.Config{JWT: config.JWT{EMAIL_SECRET: "email"}}
+	signed := NewURLSigner(conf).Sign("/a", nil, now.Add(time.Minute))
+
+	conf.STORAGE.SIGNING_SECRET = "email"
+	assert.NoError(t, NewURLSigner(conf).Verify("/a", signed, now))
+}
+
+// replaceQuery is q with key set to values, removed when there are none.
+func replaceQuery(q url.Values, key string, values ...string) string {
+	c := url.Values{}
+	for k, v := range q {
+		c[k] = v
+	}
+	if len(values) == 0 {
+		c.Del(key)
+	} else {
+		c[key] = values
+	}
+	return c.Encode()
+}
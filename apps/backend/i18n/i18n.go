@@ -4,6 +4,7 @@ import (
 	"context"
 	"embed"
 	"encoding/json"
+	"slices"
 
 	"github.com/gin-gonic/gin"
 	goI8n "github.com/nicksnyder/go-i18n/v2/i18n"
@@ -46,6 +47,9 @@ func (i Local) IsValid() bool {
 type (
 	I18N interface {
 		GetMessage(local Local, id string) string
+		// Translations returns the message id in every language that has it,
+		// once each.
+		Translations(id string) []string
 	}
 	i18n struct {
 		i18n *goI8n.Bundle
@@ -64,6 +68,20 @@ func (i *i18n) GetMessage(local Local, id string) string {
 	return message
 }
 
+// Translations implements I18N.
+func (i *i18n) Translations(id string) []string {
+	var res []string
+	for _, local := range []Local{TH, EN} {
+		localizer := goI8n.NewLocalizer(i.i18n, string(local))
+		message, tag, err := localizer.LocalizeWithTag(&goI8n.LocalizeConfig{MessageID: id})
+		if err != nil || tag != language.Make(string(local)) || slices.Contains(res, message) {
+			continue
+		}
+		res = append(res, message)
+	}
+	return res
+}
+
 func NewI18N(conf *config.Config, log logger.AppLogger) (I18N, error) {
 	bundle, err := newI18n(conf, log)
 	if err != nil {
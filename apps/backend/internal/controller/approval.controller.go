@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/internal/service"
+	"github.com/suttapak/starter/mtemplate"
+)
+
+type (
+	// Approval serves the approve and reject links emailed to approvers. Opening
+	// a link only shows the transaction, the decision is posted from that page
+	// so link previews and mail scanners cannot decide on the approver's behalf.
+	Approval interface {
+		Confirm(c *gin.Context)
+		Decide(c *gin.Context)
+	}
+	approval struct {
+		transactionService service.TransactionService
+		page               *template.Template
+	}
+
+	approvalPage struct {
+		Approval *service.TransactionApprovalResponse
+		Approve  bool
+		Action   template.URL
+		Done     bool
+		Error    string
+	}
+)
+
+// Confirm implements Approval.
+//
+//	@Tags		approvals
+//	@Produce	html
+//	@Param		approval_id	path	int		true	"Approval ID"
+//	@Param		action		path	string	true	"approve or reject"
+//	@Param		expires		query	int		true	"Expiry of the link"
+//	@Param		signature	query	string	true	"Signature of the link"
+//	@Success	200
+//	@Failure	403
+//	@Failure	410
+//	@Router		/approvals/{approval_id}/{action} [get]
+func (a *approval) Confirm(c *gin.Context) {
+	approvalId, approve, err := getApprovalAction(c)
+	if err != nil {
+		a.render(c, approvalPage{}, err)
+		return
+	}
+	query := c.Request.URL.Query()
+	res, err := a.transactionService.FindApprovalByLink(c, approvalId, approve, query)
+	a.render(c, approvalPage{
+		Approval: res,
+		Approve:  approve,
+		Action:   template.URL("?" + query.Encode()),
+	}, err)
+}
+
+// Decide implements Approval.
+//
+//	@Tags		approvals
+//	@Accept		x-www-form-urlencoded
+//	@Produce	html
+//	@Param		approval_id	path		int		true	"Approval ID"
+//	@Param		action		path		string	true	"approve or reject"
+//	@Param		expires		query		int		true	"Expiry of the link"
+//	@Param		signature	query		string	true	"Signature of the link"
+//	@Param		remark		formData	string	false	"Remark"
+//	@Success	200
+//	@Failure	403
+//	@Failure	409
+//	@Failure	410
+//	@Router		/approvals/{approval_id}/{action} [post]
+func (a *approval) Decide(c *gin.Context) {
+	approvalId, approve, err := getApprovalAction(c)
+	if err != nil {
+		a.render(c, approvalPage{}, err)
+		return
+	}
+	var body service.DecideTransactionRequest
+	if err := c.ShouldBind(&body); err != nil {
+		a.render(c, approvalPage{}, err)
+		return
+	}
+	res, err := a.transactionService.DecideByLink(c, approvalId, approve, c.Request.URL.Query(), body)
+	a.render(c, approvalPage{
+		Approval: res,
+		Approve:  approve,
+		Done:     true,
+	}, err)
+}
+
+func (a *approval) render(c *gin.Context, data approvalPage, err error) {
+	status := http.StatusOK
+	if err != nil {
+		status = http.StatusBadRequest
+		data.Error = "Something went wrong"
+		var appErr errs.AppError
+		if errors.As(err, &appErr) {
+			status = appErr.Code
+			data.Error = appErr.Message
+		}
+	}
+	var buf strings.Builder
+	if err := a.page.Execute(&buf, data); err != nil {
+		handlerError(c, err)
+		return
+	}
+	c.Data(status, "text/html; charset=utf-8", []byte(buf.String()))
+}
+
+// getApprovalAction returns the approval id and whether the link approves or rejects it.
+func getApprovalAction(c *gin.Context) (uint, bool, error) {
+	approvalId, err := getApprovalId(c)
+	if err != nil {
+		return 0, false, err
+	}
+	switch c.Param("action") {
+	case service.ApprovalActionApprove:
+		return approvalId, true, nil
+	case service.ApprovalActionReject:
+		return approvalId, false, nil
+	default:
+		return 0, false, errs.ErrNotFound
+	}
+}
+
+func NewApproval(transactionService service.TransactionService) Approval {
+	return &approval{
+		transactionService: transactionService,
+		page:               template.Must(template.ParseFS(mtemplate.PageTemplateFS, "page/approval.html")),
+	}
+}
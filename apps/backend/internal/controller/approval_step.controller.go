@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	ApprovalStep interface {
+		FindAll(c *gin.Context)
+		FindById(c *gin.Context)
+		Create(c *gin.Context)
+		Update(c *gin.Context)
+		Delete(c *gin.Context)
+	}
+	approvalStep struct {
+		stepService service.ApprovalStepService
+	}
+)
+
+// FindAll implements ApprovalStep.
+//
+//	@Tags		approval_steps
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int	true	"Team ID"
+//	@Success	200		{object}	Response[[]service.ApprovalStepResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/approval-steps [get]
+func (a *approvalStep) FindAll(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := a.stepService.FindAll(c, teamId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// FindById implements ApprovalStep.
+//
+//	@Tags		approval_steps
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id				path		int	true	"Team ID"
+//	@Param		approval_step_id	path		int	true	"Approval Step ID"
+//	@Success	200					{object}	Response[service.ApprovalStepResponse]
+//	@Failure	400					{object}	Response[any]
+//	@Failure	500					{object}	Response[any]
+//	@Router		/teams/{team_id}/approval-steps/{approval_step_id} [get]
+func (a *approvalStep) FindById(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	stepId, err := getApprovalStepId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := a.stepService.FindById(c, teamId, stepId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Create implements ApprovalStep.
+//
+//	@Tags		approval_steps
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int							true	"Team ID"
+//	@Param		data	body		service.ApprovalStepRequest	true	"Approval step"
+//	@Success	201		{object}	Response[service.ApprovalStepResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	404		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/approval-steps [post]
+func (a *approvalStep) Create(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.ApprovalStepRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := a.stepService.Create(c, teamId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Update implements ApprovalStep.
+//
+//	@Tags		approval_steps
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id				path		int							true	"Team ID"
+//	@Param		approval_step_id	path		int							true	"Approval Step ID"
+//	@Param		data				body		service.ApprovalStepRequest	true	"Approval step"
+//	@Success	201					{object}	Response[service.ApprovalStepResponse]
+//	@Failure	400					{object}	Response[any]
+//	@Failure	404					{object}	Response[any]
+//	@Failure	500					{object}	Response[any]
+//	@Router		/teams/{team_id}/approval-steps/{approval_step_id} [put]
+func (a *approvalStep) Update(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	stepId, err := getApprovalStepId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.ApprovalStepRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := a.stepService.Update(c, teamId, stepId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Delete implements ApprovalStep.
+//
+//	@Tags		approval_steps
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id				path		int	true	"Team ID"
+//	@Param		approval_step_id	path		int	true	"Approval Step ID"
+//	@Success	201					{object}	Response[any]
+//	@Failure	400					{object}	Response[any]
+//	@Failure	500					{object}	Response[any]
+//	@Router		/teams/{team_id}/approval-steps/{approval_step_id} [delete]
+func (a *approvalStep) Delete(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	stepId, err := getApprovalStepId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	if err := a.stepService.Delete(c, teamId, stepId); err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, nil)
+}
+
+func NewApprovalStep(stepService service.ApprovalStepService) ApprovalStep {
+	return &approvalStep{
+		stepService: stepService,
+	}
+}
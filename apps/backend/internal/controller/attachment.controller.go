@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	Attachment interface {
+		FindAll(c *gin.Context)
+		Create(c *gin.Context)
+		GetFile(c *gin.Context)
+		Delete(c *gin.Context)
+	}
+	attachment struct {
+		attachmentService service.AttachmentService
+		uploadPolicy      service.UploadPolicy
+	}
+)
+
+// FindAll implements Attachment.
+//
+//	@Tags		attachments
+//	@Produce	json
+//	@Param		entity_type	path		string	true	"Entity type, users or teams"
+//	@Param		entity_id	path		int		true	"Entity ID"
+//	@Success	200			{object}	Response[[]service.AttachmentResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	403			{object}	Response[any]
+//	@Router		/attachments/{entity_type}/{entity_id} [get]
+func (a *attachment) FindAll(c *gin.Context) {
+	entityId, err := getEntityId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := a.attachmentService.FindAll(c, c.Param("entity_type"), entityId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Create implements Attachment.
+//
+//	@Tags		attachments
+//	@Accept		multipart/form-data
+//	@Produce	json
+//	@Param		entity_type	path		string	true	"Entity type, users or teams"
+//	@Param		entity_id	path		int		true	"Entity ID"
+//	@Param		file		formData	file	true	"File of any type"
+//	@Success	201			{object}	Response[service.AttachmentResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	403			{object}	Response[any]
+//	@Failure	413			{object}	Response[any]
+//	@Failure	415			{object}	Response[any]
+//	@Failure	422			{object}	Response[any]
+//	@Router		/attachments/{entity_type}/{entity_id} [post]
+func (a *attachment) Create(c *gin.Context) {
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	entityId, err := getEntityId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	file, err := getFormFile(c, "file", a.uploadPolicy.MaxBytes())
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := a.attachmentService.Create(c, userId, c.Param("entity_type"), entityId, file)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// GetFile implements Attachment.
+//
+//	@Tags		attachments
+//	@Produce	octet-stream
+//	@Param		entity_type		path	string	true	"Entity type, users or teams"
+//	@Param		entity_id		path	int		true	"Entity ID"
+//	@Param		attachment_id	path	int		true	"Attachment ID"
+//	@Param		download		query	bool	false	"Download as attachment"
+//	@Success	200
+//	@Success	206
+//	@Failure	403	{object}	Response[any]
+//	@Failure	404	{object}	Response[any]
+//	@Failure	423	{object}	Response[any]
+//	@Router		/attachments/{entity_type}/{entity_id}/{attachment_id} [get]
+func (a *attachment) GetFile(c *gin.Context) {
+	entityId, err := getEntityId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	attachmentId, err := getAttachmentId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	download, _ := strconv.ParseBool(c.Query("download"))
+	r, info, err := a.attachmentService.Open(c, c.Param("entity_type"), entityId, attachmentId, download)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	defer r.Close() //nolint:errcheck
+	serveFile(c, r, info)
+}
+
+// Delete implements Attachment.
+//
+//	@Tags		attachments
+//	@Produce	json
+//	@Param		entity_type		path		string	true	"Entity type, users or teams"
+//	@Param		entity_id		path		int		true	"Entity ID"
+//	@Param		attachment_id	path		int		true	"Attachment ID"
+//	@Success	201				{object}	Response[any]
+//	@Failure	403				{object}	Response[any]
+//	@Failure	404				{object}	Response[any]
+//	@Router		/attachments/{entity_type}/{entity_id}/{attachment_id} [delete]
+func (a *attachment) Delete(c *gin.Context) {
+	entityId, err := getEntityId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	attachmentId, err := getAttachmentId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	if err := a.attachmentService.Delete(c, c.Param("entity_type"), entityId, attachmentId); err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, nil)
+}
+
+func NewAttachment(attachmentService service.AttachmentService, uploadPolicy service.UploadPolicy) Attachment {
+	return &attachment{
+		attachmentService: attachmentService,
+		uploadPolicy:      uploadPolicy,
+	}
+}
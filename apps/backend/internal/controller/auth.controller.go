@@ -15,6 +15,8 @@ type (
 		Logout(c *gin.Context)
 		VerifyEmail(c *gin.Context)
 		SendVerifyEmail(c *gin.Context)
+		ForgotPassword(c *gin.Context)
+		SetPassword(c *gin.Context)
 		RefreshToken(c *gin.Context)
 	}
 
@@ -75,6 +77,53 @@ func (a *auth) SendVerifyEmail(c *gin.Context) {
 	handleJsonResponse(c, nil)
 }
 
+// ForgotPassword mails a link to set the password, it answers the same
+// whether the email has an account or not.
+//
+//	@Tags		auth
+//	@Accept		json
+//	@Produce	json
+//	@Param		data	body		service.ForgotPasswordDto	true	"body data".
+//	@Success	201		{object}	Response[any]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/auth/password/forgot [post]
+func (a *auth) ForgotPassword(c *gin.Context) {
+	var body service.ForgotPasswordDto
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	if err := a.authService.ForgotPassword(c, body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, nil)
+}
+
+// SetPassword sets the password with the token of a mailed link.
+//
+//	@Tags		auth
+//	@Accept		json
+//	@Produce	json
+//	@Param		data	body		service.SetPasswordDto	true	"body data".
+//	@Success	201		{object}	Response[any]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/auth/password/set [post]
+func (a *auth) SetPassword(c *gin.Context) {
+	var body service.SetPasswordDto
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	if err := a.authService.SetPassword(c, body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, nil)
+}
+
 // Logout implements Auth.
 func (a *auth) Logout(c *gin.Context) {
 	c.SetCookie("session", "", -1, "/", a.conf.SERVER.HOST, false, true)
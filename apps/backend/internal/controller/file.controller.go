@@ -0,0 +1,172 @@
+package controller
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	File interface {
+		GetImage(c *gin.Context)
+		CreateImageSignedUrl(c *gin.Context)
+		ServeSigned(c *gin.Context)
+		CollectGarbage(c *gin.Context)
+	}
+	file struct {
+		fileService   service.FileService
+		fileCollector service.FileCollector
+	}
+)
+
+// GetImage implements File.
+//
+//	@Tags		files
+//	@Produce	octet-stream
+//	@Param		image_id	path	int		true	"Image ID"
+//	@Param		download	query	bool	false	"Download as attachment"
+//	@Success	200
+//	@Success	206
+//	@Failure	403	{object}	Response[any]
+//	@Failure	404	{object}	Response[any]
+//	@Router		/files/images/{image_id} [get]
+func (f *file) GetImage(c *gin.Context) {
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	imageId, err := getImageId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	download, _ := strconv.ParseBool(c.Query("download"))
+	r, info, err := f.fileService.OpenImage(c, userId, imageId, download)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	defer r.Close() //nolint:errcheck
+	serveFile(c, r, info)
+}
+
+// CreateImageSignedUrl implements File.
+//
+//	@Tags		files
+//	@Accept		json
+//	@Produce	json
+//	@Param		image_id	path		int							true	"Image ID"
+//	@Param		data		body		service.CreateSignedUrlDto	true	"body data"
+//	@Success	201			{object}	Response[service.SignedUrlResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	403			{object}	Response[any]
+//	@Failure	404			{object}	Response[any]
+//	@Router		/files/images/{image_id}/signed-url [post]
+func (f *file) CreateImageSignedUrl(c *gin.Context) {
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	imageId, err := getImageId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.CreateSignedUrlDto
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := f.fileService.CreateImageSignedUrl(c, userId, imageId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// ServeSigned implements File.
+//
+//	@Tags		files
+//	@Produce	octet-stream
+//	@Param		filepath	path	string	true	"Storage key"
+//	@Param		expires		query	int		true	"Unix time the URL expires"
+//	@Param		signature	query	string	true	"URL signature"
+//	@Success	200
+//	@Success	206
+//	@Failure	403	{object}	Response[any]
+//	@Failure	404	{object}	Response[any]
+//	@Failure	410	{object}	Response[any]
+//	@Router		/files/signed/{filepath} [get]
+func (f *file) ServeSigned(c *gin.Context) {
+	r, info, err := f.fileService.OpenSigned(c, c.Param("filepath"), c.Request.URL.Query())
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	defer r.Close() //nolint:errcheck
+	serveFile(c, r, info)
+}
+
+// CollectGarbage implements File.
+//
+//	@Tags		files
+//	@Produce	json
+//	@Param		dry_run	query		bool	false	"Only report what would be removed"
+//	@Success	201		{object}	Response[service.FileCollectReport]
+//	@Failure	401		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/files/gc [post]
+func (f *file) CollectGarbage(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	res, err := f.fileCollector.Collect(c, dryRun)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// inlineContentTypes are the types a browser may show in place, the others
+// could run script in the origin of the API, e.g. HTML and SVG.
+var inlineContentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"image/avif":      true,
+	"image/bmp":       true,
+	"application/pdf": true,
+}
+
+// serveFile writes the file with range, conditional request and cache support.
+// Only inlineContentTypes are served inline, any other file is downloaded.
+func serveFile(c *gin.Context, r io.ReadSeeker, info *service.FileInfo) {
+	disposition := info.Disposition
+	if mediaType, _, err := mime.ParseMediaType(info.ContentType); err != nil || !inlineContentTypes[mediaType] {
+		disposition = service.DispositionAttachment
+	}
+	c.Header("Content-Type", info.ContentType)
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": info.Filename}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "sandbox")
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", int(info.MaxAge.Seconds())))
+	if info.ETag != "" {
+		c.Header("ETag", fmt.Sprintf("%q", info.ETag))
+	}
+	http.ServeContent(c.Writer, c.Request, info.Filename, info.LastModified, r)
+}
+
+func NewFile(fileService service.FileService, fileCollector service.FileCollector) File {
+	return &file{
+		fileService:   fileService,
+		fileCollector: fileCollector,
+	}
+}
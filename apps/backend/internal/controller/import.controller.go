@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	Import interface {
+		Users(c *gin.Context)
+		UserReport(c *gin.Context)
+		UserTemplate(c *gin.Context)
+		Products(c *gin.Context)
+		ProductReport(c *gin.Context)
+		ProductTemplate(c *gin.Context)
+	}
+	importController struct {
+		importService service.ImportService
+	}
+)
+
+// Users implements Import.
+//
+//	@Tags		imports
+//	@Accept		multipart/form-data
+//	@Produce	json
+//	@Param		file	formData	file	true	"Users sheet (.xlsx or .csv)"
+//	@Param		dry_run	query		bool	false	"Check every row and roll back"
+//	@Success	201		{object}	Response[service.ImportResult]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	413		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/imports/users [post]
+func (i *importController) Users(c *gin.Context) {
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	withImportFile(c, func(r io.Reader) {
+		res, err := i.importService.Users(c, r, dryRun)
+		if err != nil {
+			handlerError(c, err)
+			return
+		}
+		handleJsonResponse(c, res)
+	})
+}
+
+// UserReport implements Import.
+//
+//	@Tags		imports
+//	@Accept		multipart/form-data
+//	@Produce	application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Param		file	formData	file	true	"Users sheet (.xlsx or .csv)"
+//	@Success	200		{file}		file
+//	@Failure	400		{object}	Response[any]
+//	@Failure	413		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/imports/users/report [post]
+func (i *importController) UserReport(c *gin.Context) {
+	withImportFile(c, func(r io.Reader) {
+		res, err := i.importService.UserReport(c, r)
+		if err != nil {
+			handlerError(c, err)
+			return
+		}
+		handleExcelResponse(c, res.Filename, res.Content)
+	})
+}
+
+// UserTemplate implements Import.
+//
+//	@Tags		imports
+//	@Produce	application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Success	200	{file}		file
+//	@Failure	500	{object}	Response[any]
+//	@Router		/imports/users/template [get]
+func (i *importController) UserTemplate(c *gin.Context) {
+	res, err := i.importService.Template(c, service.ImportTargetUser)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleExcelResponse(c, res.Filename, res.Content)
+}
+
+// Products implements Import.
+//
+//	@Tags		imports
+//	@Accept		multipart/form-data
+//	@Produce	json
+//	@Param		team_id	path		int		true	"Team ID"
+//	@Param		file	formData	file	true	"Products sheet (.xlsx or .csv)"
+//	@Param		dry_run	query		bool	false	"Check every row and roll back"
+//	@Success	201		{object}	Response[service.ImportResult]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	413		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/imports/products [post]
+func (i *importController) Products(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	withImportFile(c, func(r io.Reader) {
+		res, err := i.importService.Products(c, teamId, r, dryRun)
+		if err != nil {
+			handlerError(c, err)
+			return
+		}
+		handleJsonResponse(c, res)
+	})
+}
+
+// ProductReport implements Import.
+//
+//	@Tags		imports
+//	@Accept		multipart/form-data
+//	@Produce	application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Param		team_id	path		int		true	"Team ID"
+//	@Param		file	formData	file	true	"Products sheet (.xlsx or .csv)"
+//	@Success	200		{file}		file
+//	@Failure	400		{object}	Response[any]
+//	@Failure	413		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/imports/products/report [post]
+func (i *importController) ProductReport(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	withImportFile(c, func(r io.Reader) {
+		res, err := i.importService.ProductReport(c, teamId, r)
+		if err != nil {
+			handlerError(c, err)
+			return
+		}
+		handleExcelResponse(c, res.Filename, res.Content)
+	})
+}
+
+// ProductTemplate implements Import.
+//
+//	@Tags		imports
+//	@Produce	application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Param		team_id	path		int	true	"Team ID"
+//	@Success	200		{file}		file
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/imports/products/template [get]
+func (i *importController) ProductTemplate(c *gin.Context) {
+	res, err := i.importService.Template(c, service.ImportTargetProduct)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleExcelResponse(c, res.Filename, res.Content)
+}
+
+// withImportFile opens the "file" field of the form for fn.
+func withImportFile(c *gin.Context, fn func(r io.Reader)) {
+	fh, err := getFormFile(c, "file", excelMaxBytes)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	file, err := fh.Open()
+	if err != nil {
+		handlerError(c, errs.ErrFileUploadNotFound)
+		return
+	}
+	defer file.Close() //nolint:errcheck
+	fn(file)
+}
+
+func NewImport(importService service.ImportService) Import {
+	return &importController{
+		importService: importService,
+	}
+}
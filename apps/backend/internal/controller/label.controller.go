@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	Label interface {
+		ProductBarcode(c *gin.Context)
+		LotBarcode(c *gin.Context)
+		Sheet(c *gin.Context)
+		Scan(c *gin.Context)
+	}
+	label struct {
+		labelService service.LabelService
+	}
+)
+
+// ProductBarcode implements Label.
+//
+//	@Tags		labels
+//	@Produce	image/png,image/svg+xml
+//	@Param		team_id		path		int		true	"Team ID"
+//	@Param		products_id	path		int		true	"Product ID"
+//	@Param		symbology	query		string	false	"code128 or qr"
+//	@Param		format		query		string	false	"png or svg"
+//	@Param		width		query		int		false	"Largest width in pixels"
+//	@Param		height		query		int		false	"Code128 height in pixels"
+//	@Success	200			{file}		file
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/products/{products_id}/barcode [get]
+func (l *label) ProductBarcode(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	productId, err := getProductId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.BarcodeFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := l.labelService.ProductBarcode(c, teamId, productId, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleInlineResponse(c, res.Filename, res.ContentType, res.Content)
+}
+
+// LotBarcode implements Label.
+//
+//	@Tags		labels
+//	@Produce	image/png,image/svg+xml
+//	@Param		team_id		path		int		true	"Team ID"
+//	@Param		products_id	path		int		true	"Product ID"
+//	@Param		lot_id		path		int		true	"Lot ID"
+//	@Param		symbology	query		string	false	"code128 or qr"
+//	@Param		format		query		string	false	"png or svg"
+//	@Param		width		query		int		false	"Largest width in pixels"
+//	@Param		height		query		int		false	"Code128 height in pixels"
+//	@Success	200			{file}		file
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/products/{products_id}/lots/{lot_id}/barcode [get]
+func (l *label) LotBarcode(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	productId, err := getProductId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	lotId, err := getProductLotId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.BarcodeFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := l.labelService.LotBarcode(c, teamId, productId, lotId, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleInlineResponse(c, res.Filename, res.ContentType, res.Content)
+}
+
+// Sheet implements Label.
+//
+//	@Tags		labels
+//	@Accept		json
+//	@Produce	application/pdf
+//	@Param		team_id	path		int							true	"Team ID"
+//	@Param		data	body		service.LabelSheetRequest	true	"Labels"
+//	@Success	200		{file}		file
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/labels [post]
+func (l *label) Sheet(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.LabelSheetRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := l.labelService.Sheet(c, teamId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleInlineResponse(c, res.Filename, "application/pdf", res.Content)
+}
+
+// Scan implements Label.
+//
+//	@Tags		labels
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int		true	"Team ID"
+//	@Param		code	query		string	true	"Scanned product or lot code"
+//	@Success	200		{object}	Response[service.ScanResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	404		{object}	Response[any]
+//	@Failure	409		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/scan [get]
+func (l *label) Scan(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.ScanFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := l.labelService.Scan(c, teamId, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+func NewLabel(labelService service.LabelService) Label {
+	return &label{
+		labelService: labelService,
+	}
+}
@@ -8,6 +8,27 @@ import (
 var Module = fx.Options(
 	fx.Provide(NewAuth),
 	fx.Provide(NewUser),
+	fx.Provide(NewFile),
+	fx.Provide(NewUpload),
+	fx.Provide(NewAttachment),
+	fx.Provide(NewProductCategory),
+	fx.Provide(NewProduct),
+	fx.Provide(NewUom),
+	fx.Provide(NewPartner),
+	fx.Provide(NewValuation),
+	fx.Provide(NewLabel),
+	fx.Provide(NewNumbering),
+	fx.Provide(NewSequence),
+	fx.Provide(NewProductLot),
+	fx.Provide(NewTransaction),
+	fx.Provide(NewApprovalStep),
+	fx.Provide(NewApproval),
+	fx.Provide(NewStock),
+	fx.Provide(NewWarehouse),
+	fx.Provide(NewStockTransfer),
+	fx.Provide(NewStockCount),
+	fx.Provide(NewStockAlert),
+	fx.Provide(NewImport),
 )
 
 type (
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	Numbering interface {
+		FindAll(c *gin.Context)
+		Save(c *gin.Context)
+		Delete(c *gin.Context)
+		Preview(c *gin.Context)
+	}
+	numbering struct {
+		numberingService service.NumberingService
+	}
+)
+
+// FindAll implements Numbering.
+//
+//	@Tags		numbering
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int	true	"Team ID"
+//	@Success	200		{object}	Response[[]service.NumberingSchemeResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/numbering [get]
+func (n *numbering) FindAll(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := n.numberingService.FindAll(c, teamId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Save implements Numbering.
+//
+//	@Tags		numbering
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int								true	"Team ID"
+//	@Param		entity_type	path		string							true	"SO, CN, PO, TR, SC, ADJ, product, lot or partner"
+//	@Param		data		body		service.NumberingSchemeRequest	true	"Numbering scheme"
+//	@Success	201			{object}	Response[service.NumberingSchemeResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	409			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/numbering/{entity_type} [put]
+func (n *numbering) Save(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.NumberingSchemeRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := n.numberingService.Save(c, teamId, model.EntityType(c.Param("entity_type")), body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Delete implements Numbering.
+//
+//	@Tags		numbering
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int		true	"Team ID"
+//	@Param		entity_type	path		string	true	"SO, CN, PO, TR, SC, ADJ, product, lot or partner"
+//	@Success	201			{object}	Response[any]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/numbering/{entity_type} [delete]
+func (n *numbering) Delete(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	if err := n.numberingService.Delete(c, teamId, model.EntityType(c.Param("entity_type"))); err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, nil)
+}
+
+// Preview implements Numbering.
+//
+//	@Tags		numbering
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id				path		int		true	"Team ID"
+//	@Param		entity_type			path		string	true	"SO, CN, PO, TR, SC, ADJ, product, lot or partner"
+//	@Param		count				query		int		false	"Number of codes, 5 when empty"
+//	@Param		entity_id			query		int		false	"Product ID of lot codes"
+//	@Param		template			query		string	false	"Draft template, the current scheme when empty"
+//	@Param		reset_period		query		string	false	"never, daily, monthly, yearly or fiscal_year"
+//	@Param		fiscal_year_start	query		int		false	"Month the fiscal year starts in"
+//	@Success	200					{object}	Response[service.NumberingPreviewResponse]
+//	@Failure	400					{object}	Response[any]
+//	@Failure	500					{object}	Response[any]
+//	@Router		/teams/{team_id}/numbering/{entity_type}/preview [get]
+func (n *numbering) Preview(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.NumberingPreviewFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := n.numberingService.Preview(c, teamId, model.EntityType(c.Param("entity_type")), f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+func NewNumbering(numberingService service.NumberingService) Numbering {
+	return &numbering{
+		numberingService: numberingService,
+	}
+}
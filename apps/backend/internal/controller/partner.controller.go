@@ -0,0 +1,260 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	Partner interface {
+		FindAll(c *gin.Context)
+		FindById(c *gin.Context)
+		Create(c *gin.Context)
+		Update(c *gin.Context)
+		Delete(c *gin.Context)
+		Export(c *gin.Context)
+		Import(c *gin.Context)
+	}
+	partner struct {
+		partnerService service.PartnerService
+	}
+)
+
+// FindAll implements Partner.
+//
+//	@Tags		partners
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int		true	"Team ID"
+//	@Param		page	query		int		false	"Page"
+//	@Param		limit	query		int		false	"Limit"
+//	@Param		search	query		string	false	"Code, name or tax id"
+//	@Param		type	query		string	false	"supplier, customer or both"
+//	@Success	200		{object}	ResponsePagination[[]service.PartnerResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/partners [get]
+func (p *partner) FindAll(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	pg, err := helpers.NewPaginate(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.PartnerFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.partnerService.FindAll(c, teamId, pg, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handlePaginationJsonResponse(c, res, pg)
+}
+
+// FindById implements Partner.
+//
+//	@Tags		partners
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int	true	"Team ID"
+//	@Param		partner_id	path		int	true	"Partner ID"
+//	@Success	200			{object}	Response[service.PartnerResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/partners/{partner_id} [get]
+func (p *partner) FindById(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	partnerId, err := getPartnerId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.partnerService.FindById(c, teamId, partnerId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Create implements Partner.
+//
+//	@Tags		partners
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int						true	"Team ID"
+//	@Param		data	body		service.PartnerRequest	true	"Partner"
+//	@Success	201		{object}	Response[service.PartnerResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/partners [post]
+func (p *partner) Create(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.PartnerRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.partnerService.Create(c, teamId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Update implements Partner.
+//
+//	@Tags		partners
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int						true	"Team ID"
+//	@Param		partner_id	path		int						true	"Partner ID"
+//	@Param		data		body		service.PartnerRequest	true	"Partner"
+//	@Success	201			{object}	Response[service.PartnerResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/partners/{partner_id} [put]
+func (p *partner) Update(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	partnerId, err := getPartnerId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.PartnerRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.partnerService.Update(c, teamId, partnerId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Delete implements Partner.
+//
+//	@Tags		partners
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int	true	"Team ID"
+//	@Param		partner_id	path		int	true	"Partner ID"
+//	@Success	201			{object}	Response[any]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	409			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/partners/{partner_id} [delete]
+func (p *partner) Delete(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	partnerId, err := getPartnerId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	if err := p.partnerService.Delete(c, teamId, partnerId); err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, nil)
+}
+
+// Export implements Partner.
+//
+//	@Tags		partners
+//	@Produce	application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Param		team_id	path	int		true	"Team ID"
+//	@Param		search	query	string	false	"Code, name or tax id"
+//	@Param		type	query	string	false	"supplier, customer or both"
+//	@Success	200		{file}	file
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/partners/export [get]
+func (p *partner) Export(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.PartnerFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.partnerService.Export(c, teamId, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleExcelResponse(c, res.Filename, res.Content)
+}
+
+// Import implements Partner.
+//
+//	@Tags		partners
+//	@Accept		multipart/form-data
+//	@Produce	json
+//	@Param		team_id	path		int		true	"Team ID"
+//	@Param		file	formData	file	true	"Partner sheet"
+//	@Success	201		{object}	Response[service.PartnerImportResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/partners/import [post]
+func (p *partner) Import(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	fh, err := getFormFile(c, "file", excelMaxBytes)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	file, err := fh.Open()
+	if err != nil {
+		handlerError(c, errs.ErrFileUploadNotFound)
+		return
+	}
+	defer file.Close() //nolint:errcheck
+	res, err := p.partnerService.Import(c, teamId, file)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+func NewPartner(partnerService service.PartnerService) Partner {
+	return &partner{
+		partnerService: partnerService,
+	}
+}
@@ -0,0 +1,361 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	Product interface {
+		FindAll(c *gin.Context)
+		FindById(c *gin.Context)
+		Create(c *gin.Context)
+		Update(c *gin.Context)
+		Delete(c *gin.Context)
+		UploadImage(c *gin.Context)
+		ReorderImages(c *gin.Context)
+		GetImage(c *gin.Context)
+		DeleteImage(c *gin.Context)
+	}
+	product struct {
+		productService service.ProductService
+		uploadPolicy   service.UploadPolicy
+	}
+)
+
+// FindAll implements Product.
+//
+//	@Tags		products
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int		true	"Team ID"
+//	@Param		page	query		int		false	"Page"
+//	@Param		limit	query		int		false	"Limit"
+//	@Param		code	query		string	false	"Code"
+//	@Param		name	query		string	false	"Name"
+//	@Param		uom		query		string	false	"UOM"
+//	@Success	200		{object}	ResponsePagination[[]service.ProductResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	404		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/products [get]
+func (p *product) FindAll(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	pg, err := helpers.NewPaginate(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.ProductFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.productService.FindAll(c, teamId, pg, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handlePaginationJsonResponse(c, res, pg)
+}
+
+// FindById implements Product.
+//
+//	@Tags		products
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int	true	"Team ID"
+//	@Param		products_id	path		int	true	"Products ID"
+//	@Success	200			{object}	Response[service.ProductResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	404			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/products/{products_id} [get]
+func (p *product) FindById(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	productId, err := getProductId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.productService.FindById(c, teamId, productId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Create implements Product.
+//
+//	@Tags		products
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int								true	"Team ID"
+//	@Param		data	body		service.CreateProductRequest	true	"CreateProductRequest"
+//	@Success	201		{object}	Response[service.ProductResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	404		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/products [post]
+func (p *product) Create(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.CreateProductRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.productService.Create(c, teamId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Update implements Product.
+//
+//	@Tags		products
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int								true	"Team ID"
+//	@Param		products_id	path		int								true	"Products ID"
+//	@Param		data		body		service.UpdateProductRequest	true	"UpdateProductRequest"
+//	@Success	201			{object}	Response[service.ProductResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	404			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/products/{products_id} [put]
+func (p *product) Update(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	productId, err := getProductId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.UpdateProductRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.productService.Update(c, teamId, productId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Delete implements Product.
+//
+//	@Tags		products
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int	true	"Team ID"
+//	@Param		products_id	path		int	true	"Products ID"
+//	@Success	201			{object}	Response[any]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	404			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/products/{products_id} [delete]
+func (p *product) Delete(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	productId, err := getProductId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	if err := p.productService.Delete(c, teamId, productId); err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, nil)
+}
+
+// UploadImage implements Product.
+//
+//	@Tags		products
+//	@Accept		multipart/form-data
+//	@Produce	json
+//	@Param		team_id		path		int		true	"Team ID"
+//	@Param		products_id	path		int		true	"Products ID"
+//	@Param		files		formData	file	true	"Product image files"
+//	@Success	201			{object}	Response[service.ProductResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	404			{object}	Response[any]
+//	@Failure	413			{object}	Response[any]
+//	@Failure	415			{object}	Response[any]
+//	@Failure	422			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/products/{products_id}/upload_image [post]
+func (p *product) UploadImage(c *gin.Context) {
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	productId, err := getProductId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	files, err := getFiles(c, p.uploadPolicy.MaxBytes())
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.productService.UploadImages(c, userId, teamId, productId, files)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// ReorderImages implements Product.
+//
+//	@Tags		products
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int									true	"Team ID"
+//	@Param		products_id	path		int									true	"Products ID"
+//	@Param		data		body		service.ReorderProductImageRequest	true	"Every product image ID in the new order"
+//	@Success	201			{object}	Response[service.ProductResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	404			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/products/{products_id}/images/order [put]
+func (p *product) ReorderImages(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	productId, err := getProductId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.ReorderProductImageRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.productService.ReorderImages(c, teamId, productId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// GetImage implements Product.
+//
+//	@Tags		products
+//	@Produce	octet-stream
+//	@Param		team_id				path	int		true	"Team ID"
+//	@Param		products_id			path	int		true	"Products ID"
+//	@Param		product_image_id	path	int		true	"Product Image ID"
+//	@Param		download			query	bool	false	"Download as attachment"
+//	@Success	200
+//	@Success	206
+//	@Failure	404	{object}	Response[any]
+//	@Failure	423	{object}	Response[any]
+//	@Router		/teams/{team_id}/products/{products_id}/images/{product_image_id} [get]
+func (p *product) GetImage(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	productId, err := getProductId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	productImageId, err := getProductImageId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	download, _ := strconv.ParseBool(c.Query("download"))
+	r, info, err := p.productService.OpenImage(c, teamId, productId, productImageId, download)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	defer r.Close() //nolint:errcheck
+	serveFile(c, r, info)
+}
+
+// DeleteImage implements Product.
+//
+//	@Tags		products
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id				path		int	true	"Team ID"
+//	@Param		products_id			path		int	true	"Products ID"
+//	@Param		product_image_id	path		int	true	"Product Image ID"
+//	@Success	201					{object}	Response[any]
+//	@Failure	400					{object}	Response[any]
+//	@Failure	404					{object}	Response[any]
+//	@Failure	500					{object}	Response[any]
+//	@Router		/teams/{team_id}/products/{products_id}/images/{product_image_id} [delete]
+func (p *product) DeleteImage(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	productId, err := getProductId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	productImageId, err := getProductImageId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	if err := p.productService.DeleteImage(c, teamId, productId, productImageId); err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, nil)
+}
+
+func NewProduct(productService service.ProductService, uploadPolicy service.UploadPolicy) Product {
+	return &product{
+		productService: productService,
+		uploadPolicy:   uploadPolicy,
+	}
+}
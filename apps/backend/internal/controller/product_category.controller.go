@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	ProductCategory interface {
+		FindAll(c *gin.Context)
+		FindById(c *gin.Context)
+		Create(c *gin.Context)
+		Update(c *gin.Context)
+		Delete(c *gin.Context)
+	}
+	productCategory struct {
+		categoryService service.ProductCategoryService
+	}
+)
+
+// FindAll implements ProductCategory.
+//
+//	@Tags		product_category
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int		true	"Team ID"
+//	@Param		page	query		int		false	"Page"
+//	@Param		limit	query		int		false	"Limit"
+//	@Param		name	query		string	false	"Name"
+//	@Success	200		{object}	ResponsePagination[[]service.ProductCategoryResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	404		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/product_category [get]
+func (p *productCategory) FindAll(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	pg, err := helpers.NewPaginate(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.ProductCategoryFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.categoryService.FindAll(c, teamId, pg, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handlePaginationJsonResponse(c, res, pg)
+}
+
+// FindById implements ProductCategory.
+//
+//	@Tags		product_category
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id				path		int	true	"Team ID"
+//	@Param		product_category_id	path		int	true	"Product Category ID"
+//	@Success	200					{object}	Response[service.ProductCategoryResponse]
+//	@Failure	400					{object}	Response[any]
+//	@Failure	404					{object}	Response[any]
+//	@Failure	500					{object}	Response[any]
+//	@Router		/teams/{team_id}/product_category/{product_category_id} [get]
+func (p *productCategory) FindById(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	categoryId, err := getProductCategoryId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.categoryService.FindById(c, teamId, categoryId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Create implements ProductCategory.
+//
+//	@Tags		product_category
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int										true	"Team ID"
+//	@Param		data	body		service.CreateProductCategoryRequest	true	"Product category"
+//	@Success	201		{object}	Response[service.ProductCategoryResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	404		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/product_category [post]
+func (p *productCategory) Create(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.CreateProductCategoryRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.categoryService.Create(c, teamId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Update implements ProductCategory.
+//
+//	@Tags		product_category
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id				path		int										true	"Team ID"
+//	@Param		product_category_id	path		int										true	"Product Category ID"
+//	@Param		data				body		service.UpdateProductCategoryRequest	true	"Product category"
+//	@Success	201					{object}	Response[service.ProductCategoryResponse]
+//	@Failure	400					{object}	Response[any]
+//	@Failure	404					{object}	Response[any]
+//	@Failure	500					{object}	Response[any]
+//	@Router		/teams/{team_id}/product_category/{product_category_id} [put]
+func (p *productCategory) Update(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	categoryId, err := getProductCategoryId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.UpdateProductCategoryRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.categoryService.Update(c, teamId, categoryId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Delete implements ProductCategory.
+//
+//	@Tags		product_category
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id				path		int	true	"Team ID"
+//	@Param		product_category_id	path		int	true	"Product Category ID"
+//	@Success	201					{object}	Response[any]
+//	@Failure	400					{object}	Response[any]
+//	@Failure	404					{object}	Response[any]
+//	@Failure	500					{object}	Response[any]
+//	@Router		/teams/{team_id}/product_category/{product_category_id} [delete]
+func (p *productCategory) Delete(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	categoryId, err := getProductCategoryId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	if err := p.categoryService.Delete(c, teamId, categoryId); err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, nil)
+}
+
+func NewProductCategory(categoryService service.ProductCategoryService) ProductCategory {
+	return &productCategory{
+		categoryService: categoryService,
+	}
+}
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	ProductLot interface {
+		FindAll(c *gin.Context)
+		FindById(c *gin.Context)
+		Receive(c *gin.Context)
+		Issue(c *gin.Context)
+	}
+	productLot struct {
+		lotService service.ProductLotService
+	}
+)
+
+// FindAll implements ProductLot.
+//
+//	@Tags		product_lots
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int		true	"Team ID"
+//	@Param		products_id	path		int		true	"Products ID"
+//	@Param		page		query		int		false	"Page"
+//	@Param		limit		query		int		false	"Limit"
+//	@Param		code		query		string	false	"Code"
+//	@Param		in_stock	query		bool	false	"Only lots with remaining quantity"
+//	@Param		expired		query		bool	false	"Only expired lots, or only usable lots when false"
+//	@Success	200			{object}	ResponsePagination[[]service.ProductLotResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	404			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/products/{products_id}/lots [get]
+func (p *productLot) FindAll(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	productId, err := getProductId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	pg, err := helpers.NewPaginate(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.ProductLotFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.lotService.FindAll(c, teamId, productId, pg, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handlePaginationJsonResponse(c, res, pg)
+}
+
+// FindById implements ProductLot.
+//
+//	@Tags		product_lots
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int	true	"Team ID"
+//	@Param		products_id	path		int	true	"Products ID"
+//	@Param		lot_id		path		int	true	"Lot ID"
+//	@Success	200			{object}	Response[service.ProductLotResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	404			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/products/{products_id}/lots/{lot_id} [get]
+func (p *productLot) FindById(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	productId, err := getProductId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	lotId, err := getProductLotId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.lotService.FindById(c, teamId, productId, lotId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Receive implements ProductLot.
+//
+//	@Tags		product_lots
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int									true	"Team ID"
+//	@Param		products_id	path		int									true	"Products ID"
+//	@Param		data		body		service.ReceiveProductLotRequest	true	"Received lot"
+//	@Success	201			{object}	Response[service.ProductLotResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	404			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/products/{products_id}/lots [post]
+func (p *productLot) Receive(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	productId, err := getProductId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.ReceiveProductLotRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.lotService.Receive(c, teamId, productId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Issue implements ProductLot.
+//
+//	@Tags		product_lots
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int								true	"Team ID"
+//	@Param		products_id	path		int								true	"Products ID"
+//	@Param		data		body		service.IssueProductLotRequest	true	"Quantity to issue"
+//	@Success	201			{object}	Response[[]service.LotAllocation]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	404			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/products/{products_id}/lots/issue [post]
+func (p *productLot) Issue(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	productId, err := getProductId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.IssueProductLotRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := p.lotService.Issue(c, teamId, productId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+func NewProductLot(lotService service.ProductLotService) ProductLot {
+	return &productLot{
+		lotService: lotService,
+	}
+}
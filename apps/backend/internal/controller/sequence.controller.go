@@ -0,0 +1,200 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	Sequence interface {
+		FindAll(c *gin.Context)
+		Set(c *gin.Context)
+		Reset(c *gin.Context)
+		Reserve(c *gin.Context)
+		FindAudits(c *gin.Context)
+	}
+	sequence struct {
+		sequenceService service.SequenceService
+	}
+)
+
+// FindAll implements Sequence.
+//
+//	@Tags		sequences
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int	true	"Team ID"
+//	@Success	200		{object}	Response[[]service.SequenceResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/sequences [get]
+func (s *sequence) FindAll(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.sequenceService.FindAll(c, teamId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Set implements Sequence.
+//
+//	@Tags		sequences
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int							true	"Team ID"
+//	@Param		entity_type	path		string						true	"SO, CN, PO, TR, SC, ADJ, product, lot or partner"
+//	@Param		data		body		service.SequenceSetRequest	true	"Next number"
+//	@Success	201			{object}	Response[service.SequenceResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	409			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/sequences/{entity_type} [put]
+func (s *sequence) Set(c *gin.Context) {
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.SequenceSetRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.sequenceService.Set(c, userId, teamId, model.EntityType(c.Param("entity_type")), body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Reset implements Sequence.
+//
+//	@Tags		sequences
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int								true	"Team ID"
+//	@Param		entity_type	path		string							true	"SO, CN, PO, TR, SC, ADJ, product, lot or partner"
+//	@Param		data		body		service.SequenceResetRequest	true	"Reset"
+//	@Success	201			{object}	Response[service.SequenceResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	409			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/sequences/{entity_type}/reset [post]
+func (s *sequence) Reset(c *gin.Context) {
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.SequenceResetRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.sequenceService.Reset(c, userId, teamId, model.EntityType(c.Param("entity_type")), body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Reserve implements Sequence.
+//
+//	@Tags		sequences
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int								true	"Team ID"
+//	@Param		entity_type	path		string							true	"SO, CN, PO, TR, SC, ADJ, product, lot or partner"
+//	@Param		data		body		service.SequenceReserveRequest	true	"Block of numbers"
+//	@Success	201			{object}	Response[service.SequenceReserveResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/sequences/{entity_type}/reserve [post]
+func (s *sequence) Reserve(c *gin.Context) {
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.SequenceReserveRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.sequenceService.Reserve(c, userId, teamId, model.EntityType(c.Param("entity_type")), body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// FindAudits implements Sequence.
+//
+//	@Tags		sequences
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int		true	"Team ID"
+//	@Param		page		query		int		false	"Page"
+//	@Param		limit		query		int		false	"Limit"
+//	@Param		entity_type	query		string	false	"Entity type"
+//	@Param		action		query		string	false	"set, reset or reserve"
+//	@Success	200			{object}	ResponsePagination[[]service.SequenceAuditResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/sequences/audits [get]
+func (s *sequence) FindAudits(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	pg, err := helpers.NewPaginate(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.SequenceAuditFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.sequenceService.FindAudits(c, teamId, pg, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handlePaginationJsonResponse(c, res, pg)
+}
+
+func NewSequence(sequenceService service.SequenceService) Sequence {
+	return &sequence{
+		sequenceService: sequenceService,
+	}
+}
@@ -0,0 +1,157 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	Stock interface {
+		Balance(c *gin.Context)
+		FindMovements(c *gin.Context)
+		ExportMovements(c *gin.Context)
+		Reconcile(c *gin.Context)
+	}
+	stock struct {
+		ledgerService service.StockLedgerService
+	}
+)
+
+// Balance implements Stock.
+//
+//	@Tags		stock
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id			path		int		true	"Team ID"
+//	@Param		at				query		string	false	"RFC 3339 time or YYYY-MM-DD meaning the end of that day, default now"
+//	@Param		product_id		query		int		false	"Product ID"
+//	@Param		product_lot_id	query		int		false	"Product Lot ID"
+//	@Param		warehouse_id	query		int		false	"Warehouse ID"
+//	@Param		location_id		query		int		false	"Location ID"
+//	@Param		group_by		query		string	false	"lot (default), product, location or warehouse"
+//	@Success	200				{object}	Response[[]service.StockBalanceResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	500				{object}	Response[any]
+//	@Router		/teams/{team_id}/stock/balance [get]
+func (s *stock) Balance(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.StockBalanceFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.ledgerService.Balance(c, teamId, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// FindMovements implements Stock.
+//
+//	@Tags		stock
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id			path		int		true	"Team ID"
+//	@Param		page			query		int		false	"Page"
+//	@Param		limit			query		int		false	"Limit"
+//	@Param		product_id		query		int		false	"Product ID"
+//	@Param		product_lot_id	query		int		false	"Product Lot ID"
+//	@Param		location_id		query		int		false	"Location ID"
+//	@Param		reason			query		string	false	"Reason"
+//	@Success	200				{object}	ResponsePagination[[]service.StockMovementResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	500				{object}	Response[any]
+//	@Router		/teams/{team_id}/stock/movements [get]
+func (s *stock) FindMovements(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	pg, err := helpers.NewPaginate(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.StockMovementFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.ledgerService.FindMovements(c, teamId, pg, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handlePaginationJsonResponse(c, res, pg)
+}
+
+// ExportMovements implements Stock.
+//
+//	@Tags		stock
+//	@Produce	application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Param		team_id			path	int		true	"Team ID"
+//	@Param		product_id		query	int		false	"Product ID"
+//	@Param		product_lot_id	query	int		false	"Product Lot ID"
+//	@Param		location_id		query	int		false	"Location ID"
+//	@Param		reason			query	string	false	"Reason"
+//	@Success	200				{file}	file
+//	@Failure	400				{object}	Response[any]
+//	@Failure	500				{object}	Response[any]
+//	@Router		/teams/{team_id}/stock/movements/export [get]
+func (s *stock) ExportMovements(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.StockMovementFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.ledgerService.ExportMovements(c, teamId, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleExcelStream(c, res.Filename, res.Write)
+}
+
+// Reconcile implements Stock.
+//
+//	@Tags		stock
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int	true	"Team ID"
+//	@Success	200		{object}	Response[[]service.StockDiscrepancyResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/stock/reconcile [get]
+func (s *stock) Reconcile(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.ledgerService.Reconcile(c, teamId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+func NewStock(ledgerService service.StockLedgerService) Stock {
+	return &stock{
+		ledgerService: ledgerService,
+	}
+}
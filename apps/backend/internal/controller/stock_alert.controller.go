@@ -0,0 +1,316 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	StockAlert interface {
+		FindAll(c *gin.Context)
+		FindById(c *gin.Context)
+		Acknowledge(c *gin.Context)
+		Snooze(c *gin.Context)
+		Evaluate(c *gin.Context)
+		FindRules(c *gin.Context)
+		SaveRule(c *gin.Context)
+		Subscription(c *gin.Context)
+		Subscribe(c *gin.Context)
+		Unsubscribe(c *gin.Context)
+	}
+	stockAlert struct {
+		alertService service.StockAlertService
+	}
+)
+
+// FindAll implements StockAlert.
+//
+//	@Tags		alerts
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int		true	"Team ID"
+//	@Param		page		query		int		false	"Page"
+//	@Param		limit		query		int		false	"Limit"
+//	@Param		type		query		string	false	"low_stock, expiring or expired"
+//	@Param		status		query		string	false	"open, acknowledged or resolved"
+//	@Param		product_id	query		int		false	"Product ID"
+//	@Param		snoozed		query		bool	false	"Snoozed"
+//	@Success	200			{object}	ResponsePagination[[]service.AlertResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/alerts [get]
+func (s *stockAlert) FindAll(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	pg, err := helpers.NewPaginate(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.AlertFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.alertService.FindAll(c, teamId, pg, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handlePaginationJsonResponse(c, res, pg)
+}
+
+// FindById implements StockAlert.
+//
+//	@Tags		alerts
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int	true	"Team ID"
+//	@Param		alert_id	path		int	true	"Alert ID"
+//	@Success	200			{object}	Response[service.AlertResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/alerts/{alert_id} [get]
+func (s *stockAlert) FindById(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	alertId, err := getAlertId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.alertService.FindById(c, teamId, alertId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Acknowledge implements StockAlert.
+//
+//	@Tags		alerts
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int	true	"Team ID"
+//	@Param		alert_id	path		int	true	"Alert ID"
+//	@Success	200			{object}	Response[service.AlertResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/alerts/{alert_id}/acknowledge [post]
+func (s *stockAlert) Acknowledge(c *gin.Context) {
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	alertId, err := getAlertId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.alertService.Acknowledge(c, userId, teamId, alertId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Snooze implements StockAlert.
+//
+//	@Tags		alerts
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int							true	"Team ID"
+//	@Param		alert_id	path		int							true	"Alert ID"
+//	@Param		data		body		service.SnoozeAlertRequest	true	"Days to snooze"
+//	@Success	200			{object}	Response[service.AlertResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/alerts/{alert_id}/snooze [post]
+func (s *stockAlert) Snooze(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	alertId, err := getAlertId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.SnoozeAlertRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.alertService.Snooze(c, teamId, alertId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Evaluate implements StockAlert.
+//
+//	@Tags		alerts
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int	true	"Team ID"
+//	@Success	200		{object}	Response[service.AlertEvaluationResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/alerts/evaluate [post]
+func (s *stockAlert) Evaluate(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.alertService.Evaluate(c, teamId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// FindRules implements StockAlert.
+//
+//	@Tags		alerts
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int	true	"Team ID"
+//	@Success	200		{object}	Response[[]service.AlertRuleResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/alert-rules [get]
+func (s *stockAlert) FindRules(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.alertService.FindRules(c, teamId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// SaveRule implements StockAlert.
+//
+//	@Tags		alerts
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int							true	"Team ID"
+//	@Param		alert_type	path		string						true	"low_stock, expiring or expired"
+//	@Param		data		body		service.AlertRuleRequest	true	"Rule"
+//	@Success	200			{object}	Response[service.AlertRuleResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/alert-rules/{alert_type} [put]
+func (s *stockAlert) SaveRule(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.AlertRuleRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.alertService.SaveRule(c, teamId, c.Param("alert_type"), body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Subscription implements StockAlert.
+//
+//	@Tags		alerts
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int	true	"Team ID"
+//	@Success	200		{object}	Response[service.AlertSubscriptionResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/alerts/subscription [get]
+func (s *stockAlert) Subscription(c *gin.Context) {
+	s.subscription(c, s.alertService.Subscription)
+}
+
+// Subscribe implements StockAlert.
+//
+//	@Tags		alerts
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int	true	"Team ID"
+//	@Success	200		{object}	Response[service.AlertSubscriptionResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/alerts/subscription [post]
+func (s *stockAlert) Subscribe(c *gin.Context) {
+	s.subscription(c, s.alertService.Subscribe)
+}
+
+// Unsubscribe implements StockAlert.
+//
+//	@Tags		alerts
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int	true	"Team ID"
+//	@Success	200		{object}	Response[service.AlertSubscriptionResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/alerts/subscription [delete]
+func (s *stockAlert) Unsubscribe(c *gin.Context) {
+	s.subscription(c, s.alertService.Unsubscribe)
+}
+
+// subscription runs a subscription action of the signed in user.
+func (s *stockAlert) subscription(c *gin.Context, run func(ctx context.Context, userId, teamId uint) (*service.AlertSubscriptionResponse, error)) {
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := run(c, userId, teamId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+func NewStockAlert(alertService service.StockAlertService) StockAlert {
+	return &stockAlert{
+		alertService: alertService,
+	}
+}
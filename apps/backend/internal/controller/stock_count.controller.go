@@ -0,0 +1,394 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	StockCount interface {
+		FindAll(c *gin.Context)
+		FindById(c *gin.Context)
+		Create(c *gin.Context)
+		Count(c *gin.Context)
+		Import(c *gin.Context)
+		Sheet(c *gin.Context)
+		Submit(c *gin.Context)
+		Reopen(c *gin.Context)
+		Approve(c *gin.Context)
+		Cancel(c *gin.Context)
+		FindAdjustments(c *gin.Context)
+		FindAdjustmentById(c *gin.Context)
+	}
+	stockCount struct {
+		countService service.StockCountService
+	}
+)
+
+// FindAll implements StockCount.
+//
+//	@Tags		stock_counts
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int		true	"Team ID"
+//	@Param		page		query		int		false	"Page"
+//	@Param		limit		query		int		false	"Limit"
+//	@Param		code		query		string	false	"Code"
+//	@Param		status		query		string	false	"Status"
+//	@Param		location_id	query		int		false	"Location ID"
+//	@Success	200			{object}	ResponsePagination[[]service.StockCountResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-counts [get]
+func (s *stockCount) FindAll(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	pg, err := helpers.NewPaginate(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.StockCountFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.countService.FindAll(c, teamId, pg, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handlePaginationJsonResponse(c, res, pg)
+}
+
+// FindById implements StockCount.
+//
+//	@Tags		stock_counts
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id			path		int	true	"Team ID"
+//	@Param		stock_count_id	path		int	true	"Stock Count ID"
+//	@Success	200				{object}	Response[service.StockCountResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	500				{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-counts/{stock_count_id} [get]
+func (s *stockCount) FindById(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	countId, err := getStockCountId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.countService.FindById(c, teamId, countId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Create implements StockCount.
+//
+//	@Tags		stock_counts
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int								true	"Team ID"
+//	@Param		data	body		service.CreateStockCountRequest	true	"Location to count"
+//	@Success	201		{object}	Response[service.StockCountResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-counts [post]
+func (s *stockCount) Create(c *gin.Context) {
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.CreateStockCountRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.countService.Create(c, userId, teamId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Count implements StockCount.
+//
+//	@Tags		stock_counts
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id			path		int							true	"Team ID"
+//	@Param		stock_count_id	path		int							true	"Stock Count ID"
+//	@Param		data			body		service.CountStockRequest	true	"Counted quantities"
+//	@Success	201				{object}	Response[service.StockCountResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	500				{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-counts/{stock_count_id}/items [put]
+func (s *stockCount) Count(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	countId, err := getStockCountId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.CountStockRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.countService.Count(c, teamId, countId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Import implements StockCount.
+//
+//	@Tags		stock_counts
+//	@Accept		multipart/form-data
+//	@Produce	json
+//	@Param		team_id			path		int		true	"Team ID"
+//	@Param		stock_count_id	path		int		true	"Stock Count ID"
+//	@Param		file			formData	file	true	"Filled in count sheet (.xlsx)"
+//	@Success	201				{object}	Response[service.StockCountResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	413				{object}	Response[any]
+//	@Failure	500				{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-counts/{stock_count_id}/import [post]
+func (s *stockCount) Import(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	countId, err := getStockCountId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	fh, err := getFormFile(c, "file", excelMaxBytes)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	file, err := fh.Open()
+	if err != nil {
+		handlerError(c, errs.ErrFileUploadNotFound)
+		return
+	}
+	defer file.Close() //nolint:errcheck
+	res, err := s.countService.Import(c, teamId, countId, file)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Sheet implements StockCount.
+//
+//	@Tags		stock_counts
+//	@Produce	application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Param		team_id			path	int	true	"Team ID"
+//	@Param		stock_count_id	path	int	true	"Stock Count ID"
+//	@Success	200				{file}	file
+//	@Failure	400				{object}	Response[any]
+//	@Failure	500				{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-counts/{stock_count_id}/sheet [get]
+func (s *stockCount) Sheet(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	countId, err := getStockCountId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.countService.Sheet(c, teamId, countId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleExcelResponse(c, res.Filename, res.Content)
+}
+
+// Submit implements StockCount.
+//
+//	@Tags		stock_counts
+//	@Produce	json
+//	@Param		team_id			path		int	true	"Team ID"
+//	@Param		stock_count_id	path		int	true	"Stock Count ID"
+//	@Success	201				{object}	Response[service.StockCountResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-counts/{stock_count_id}/submit [post]
+func (s *stockCount) Submit(c *gin.Context) {
+	s.transition(c, s.countService.Submit)
+}
+
+// Reopen implements StockCount.
+//
+//	@Tags		stock_counts
+//	@Produce	json
+//	@Param		team_id			path		int	true	"Team ID"
+//	@Param		stock_count_id	path		int	true	"Stock Count ID"
+//	@Success	201				{object}	Response[service.StockCountResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-counts/{stock_count_id}/reopen [post]
+func (s *stockCount) Reopen(c *gin.Context) {
+	s.transition(c, s.countService.Reopen)
+}
+
+// Approve implements StockCount.
+//
+//	@Tags		stock_counts
+//	@Produce	json
+//	@Param		team_id			path		int	true	"Team ID"
+//	@Param		stock_count_id	path		int	true	"Stock Count ID"
+//	@Success	201				{object}	Response[service.StockCountResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-counts/{stock_count_id}/approve [post]
+func (s *stockCount) Approve(c *gin.Context) {
+	s.transition(c, s.countService.Approve)
+}
+
+// Cancel implements StockCount.
+//
+//	@Tags		stock_counts
+//	@Produce	json
+//	@Param		team_id			path		int	true	"Team ID"
+//	@Param		stock_count_id	path		int	true	"Stock Count ID"
+//	@Success	201				{object}	Response[service.StockCountResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-counts/{stock_count_id}/cancel [post]
+func (s *stockCount) Cancel(c *gin.Context) {
+	s.transition(c, s.countService.Cancel)
+}
+
+// FindAdjustments implements StockCount.
+//
+//	@Tags		stock_adjustments
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id			path		int		true	"Team ID"
+//	@Param		page			query		int		false	"Page"
+//	@Param		limit			query		int		false	"Limit"
+//	@Param		code			query		string	false	"Code"
+//	@Param		location_id		query		int		false	"Location ID"
+//	@Param		stock_count_id	query		int		false	"Stock Count ID"
+//	@Success	200				{object}	ResponsePagination[[]service.StockAdjustmentResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	500				{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-adjustments [get]
+func (s *stockCount) FindAdjustments(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	pg, err := helpers.NewPaginate(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.StockAdjustmentFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.countService.FindAdjustments(c, teamId, pg, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handlePaginationJsonResponse(c, res, pg)
+}
+
+// FindAdjustmentById implements StockCount.
+//
+//	@Tags		stock_adjustments
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id				path		int	true	"Team ID"
+//	@Param		stock_adjustment_id	path		int	true	"Stock Adjustment ID"
+//	@Success	200					{object}	Response[service.StockAdjustmentResponse]
+//	@Failure	400					{object}	Response[any]
+//	@Failure	500					{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-adjustments/{stock_adjustment_id} [get]
+func (s *stockCount) FindAdjustmentById(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	adjustmentId, err := getStockAdjustmentId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.countService.FindAdjustmentById(c, teamId, adjustmentId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+func (s *stockCount) transition(c *gin.Context, run func(ctx context.Context, userId, teamId, countId uint) (*service.StockCountResponse, error)) {
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	countId, err := getStockCountId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := run(c, userId, teamId, countId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+func NewStockCount(countService service.StockCountService) StockCount {
+	return &stockCount{
+		countService: countService,
+	}
+}
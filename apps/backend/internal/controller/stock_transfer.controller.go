@@ -0,0 +1,230 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	StockTransfer interface {
+		FindAll(c *gin.Context)
+		FindById(c *gin.Context)
+		Create(c *gin.Context)
+		Update(c *gin.Context)
+		Ship(c *gin.Context)
+		Receive(c *gin.Context)
+		Cancel(c *gin.Context)
+	}
+	stockTransfer struct {
+		transferService service.StockTransferService
+	}
+)
+
+// FindAll implements StockTransfer.
+//
+//	@Tags		stock_transfers
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id				path		int		true	"Team ID"
+//	@Param		page				query		int		false	"Page"
+//	@Param		limit				query		int		false	"Limit"
+//	@Param		code				query		string	false	"Code"
+//	@Param		status				query		string	false	"Status"
+//	@Param		from_location_id	query		int		false	"Source Location ID"
+//	@Param		to_location_id		query		int		false	"Destination Location ID"
+//	@Success	200					{object}	ResponsePagination[[]service.StockTransferResponse]
+//	@Failure	400					{object}	Response[any]
+//	@Failure	500					{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-transfers [get]
+func (s *stockTransfer) FindAll(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	pg, err := helpers.NewPaginate(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.StockTransferFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.transferService.FindAll(c, teamId, pg, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handlePaginationJsonResponse(c, res, pg)
+}
+
+// FindById implements StockTransfer.
+//
+//	@Tags		stock_transfers
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id				path		int	true	"Team ID"
+//	@Param		stock_transfer_id	path		int	true	"Stock Transfer ID"
+//	@Success	200					{object}	Response[service.StockTransferResponse]
+//	@Failure	400					{object}	Response[any]
+//	@Failure	500					{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-transfers/{stock_transfer_id} [get]
+func (s *stockTransfer) FindById(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	transferId, err := getStockTransferId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.transferService.FindById(c, teamId, transferId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Create implements StockTransfer.
+//
+//	@Tags		stock_transfers
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int								true	"Team ID"
+//	@Param		data	body		service.StockTransferRequest	true	"Draft transfer"
+//	@Success	201		{object}	Response[service.StockTransferResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-transfers [post]
+func (s *stockTransfer) Create(c *gin.Context) {
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.StockTransferRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.transferService.Create(c, userId, teamId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Update implements StockTransfer.
+//
+//	@Tags		stock_transfers
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id				path		int								true	"Team ID"
+//	@Param		stock_transfer_id	path		int								true	"Stock Transfer ID"
+//	@Param		data				body		service.StockTransferRequest	true	"Locations, note and items"
+//	@Success	201					{object}	Response[service.StockTransferResponse]
+//	@Failure	400					{object}	Response[any]
+//	@Failure	500					{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-transfers/{stock_transfer_id} [put]
+func (s *stockTransfer) Update(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	transferId, err := getStockTransferId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.StockTransferRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := s.transferService.Update(c, teamId, transferId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Ship implements StockTransfer.
+//
+//	@Tags		stock_transfers
+//	@Produce	json
+//	@Param		team_id				path		int	true	"Team ID"
+//	@Param		stock_transfer_id	path		int	true	"Stock Transfer ID"
+//	@Success	201					{object}	Response[service.StockTransferResponse]
+//	@Failure	400					{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-transfers/{stock_transfer_id}/ship [post]
+func (s *stockTransfer) Ship(c *gin.Context) {
+	s.transition(c, s.transferService.Ship)
+}
+
+// Receive implements StockTransfer.
+//
+//	@Tags		stock_transfers
+//	@Produce	json
+//	@Param		team_id				path		int	true	"Team ID"
+//	@Param		stock_transfer_id	path		int	true	"Stock Transfer ID"
+//	@Success	201					{object}	Response[service.StockTransferResponse]
+//	@Failure	400					{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-transfers/{stock_transfer_id}/receive [post]
+func (s *stockTransfer) Receive(c *gin.Context) {
+	s.transition(c, s.transferService.Receive)
+}
+
+// Cancel implements StockTransfer.
+//
+//	@Tags		stock_transfers
+//	@Produce	json
+//	@Param		team_id				path		int	true	"Team ID"
+//	@Param		stock_transfer_id	path		int	true	"Stock Transfer ID"
+//	@Success	201					{object}	Response[service.StockTransferResponse]
+//	@Failure	400					{object}	Response[any]
+//	@Router		/teams/{team_id}/stock-transfers/{stock_transfer_id}/cancel [post]
+func (s *stockTransfer) Cancel(c *gin.Context) {
+	s.transition(c, s.transferService.Cancel)
+}
+
+func (s *stockTransfer) transition(c *gin.Context, run func(ctx context.Context, teamId, transferId uint) (*service.StockTransferResponse, error)) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	transferId, err := getStockTransferId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := run(c, teamId, transferId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+func NewStockTransfer(transferService service.StockTransferService) StockTransfer {
+	return &stockTransfer{
+		transferService: transferService,
+	}
+}
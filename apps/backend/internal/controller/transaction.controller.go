@@ -0,0 +1,315 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	Transaction interface {
+		FindAll(c *gin.Context)
+		FindById(c *gin.Context)
+		Create(c *gin.Context)
+		Update(c *gin.Context)
+		Submit(c *gin.Context)
+		Approve(c *gin.Context)
+		Reject(c *gin.Context)
+		Cancel(c *gin.Context)
+		FindApprovals(c *gin.Context)
+	}
+	transaction struct {
+		transactionService service.TransactionService
+	}
+)
+
+// FindAll implements Transaction.
+//
+//	@Tags		transactions
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int		true	"Team ID"
+//	@Param		page		query		int		false	"Page"
+//	@Param		limit		query		int		false	"Limit"
+//	@Param		code		query		string	false	"Code"
+//	@Param		type		query		string	false	"Type, SO PO or CN"
+//	@Param		status		query		string	false	"Status"
+//	@Param		partner_id	query		int		false	"Partner ID"
+//	@Success	200			{object}	ResponsePagination[[]service.TransactionResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/transactions [get]
+func (t *transaction) FindAll(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	pg, err := helpers.NewPaginate(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.TransactionFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := t.transactionService.FindAll(c, teamId, pg, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handlePaginationJsonResponse(c, res, pg)
+}
+
+// FindById implements Transaction.
+//
+//	@Tags		transactions
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id			path		int	true	"Team ID"
+//	@Param		transaction_id	path		int	true	"Transaction ID"
+//	@Success	200				{object}	Response[service.TransactionResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	404				{object}	Response[any]
+//	@Failure	500				{object}	Response[any]
+//	@Router		/teams/{team_id}/transactions/{transaction_id} [get]
+func (t *transaction) FindById(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	transactionId, err := getTransactionId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := t.transactionService.FindById(c, teamId, transactionId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Create implements Transaction.
+//
+//	@Tags		transactions
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int									true	"Team ID"
+//	@Param		data	body		service.CreateTransactionRequest	true	"Draft transaction"
+//	@Success	201		{object}	Response[service.TransactionResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	404		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/transactions [post]
+func (t *transaction) Create(c *gin.Context) {
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.CreateTransactionRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := t.transactionService.Create(c, userId, teamId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Update implements Transaction.
+//
+//	@Tags		transactions
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id			path		int									true	"Team ID"
+//	@Param		transaction_id	path		int									true	"Transaction ID"
+//	@Param		data			body		service.UpdateTransactionRequest	true	"Note and items"
+//	@Success	201				{object}	Response[service.TransactionResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	404				{object}	Response[any]
+//	@Failure	500				{object}	Response[any]
+//	@Router		/teams/{team_id}/transactions/{transaction_id} [put]
+func (t *transaction) Update(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	transactionId, err := getTransactionId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.UpdateTransactionRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := t.transactionService.Update(c, teamId, transactionId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Submit implements Transaction.
+//
+//	@Tags		transactions
+//	@Produce	json
+//	@Param		team_id			path		int	true	"Team ID"
+//	@Param		transaction_id	path		int	true	"Transaction ID"
+//	@Success	201				{object}	Response[service.TransactionResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	404				{object}	Response[any]
+//	@Router		/teams/{team_id}/transactions/{transaction_id}/submit [post]
+func (t *transaction) Submit(c *gin.Context) {
+	t.transition(c, t.transactionService.Submit)
+}
+
+// Approve implements Transaction.
+//
+//	@Tags		transactions
+//	@Produce	json
+//	@Param		team_id			path		int	true	"Team ID"
+//	@Param		transaction_id	path		int									true	"Transaction ID"
+//	@Param		data			body		service.DecideTransactionRequest	false	"Remark"
+//	@Success	201				{object}	Response[service.TransactionResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	403				{object}	Response[any]
+//	@Failure	404				{object}	Response[any]
+//	@Router		/teams/{team_id}/transactions/{transaction_id}/approve [post]
+func (t *transaction) Approve(c *gin.Context) {
+	body, err := getDecideTransactionRequest(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	t.transition(c, func(ctx context.Context, userId, teamId, transactionId uint) (*service.TransactionResponse, error) {
+		return t.transactionService.Approve(ctx, userId, teamId, transactionId, body)
+	})
+}
+
+// Reject implements Transaction.
+//
+//	@Tags		transactions
+//	@Produce	json
+//	@Param		team_id			path		int	true	"Team ID"
+//	@Param		transaction_id	path		int									true	"Transaction ID"
+//	@Param		data			body		service.DecideTransactionRequest	false	"Remark"
+//	@Success	201				{object}	Response[service.TransactionResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	403				{object}	Response[any]
+//	@Failure	404				{object}	Response[any]
+//	@Router		/teams/{team_id}/transactions/{transaction_id}/reject [post]
+func (t *transaction) Reject(c *gin.Context) {
+	body, err := getDecideTransactionRequest(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	t.transition(c, func(ctx context.Context, userId, teamId, transactionId uint) (*service.TransactionResponse, error) {
+		return t.transactionService.Reject(ctx, userId, teamId, transactionId, body)
+	})
+}
+
+// Cancel implements Transaction.
+//
+//	@Tags		transactions
+//	@Produce	json
+//	@Param		team_id			path		int	true	"Team ID"
+//	@Param		transaction_id	path		int	true	"Transaction ID"
+//	@Success	201				{object}	Response[service.TransactionResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	404				{object}	Response[any]
+//	@Router		/teams/{team_id}/transactions/{transaction_id}/cancel [post]
+func (t *transaction) Cancel(c *gin.Context) {
+	t.transition(c, t.transactionService.Cancel)
+}
+
+// FindApprovals implements Transaction.
+//
+//	@Tags		transactions
+//	@Produce	json
+//	@Param		team_id			path		int	true	"Team ID"
+//	@Param		transaction_id	path		int	true	"Transaction ID"
+//	@Success	200				{object}	Response[[]service.TransactionApprovalResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	404				{object}	Response[any]
+//	@Router		/teams/{team_id}/transactions/{transaction_id}/approvals [get]
+func (t *transaction) FindApprovals(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	transactionId, err := getTransactionId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := t.transactionService.FindApprovals(c, teamId, transactionId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// getDecideTransactionRequest binds the remark of a decision, the body is optional.
+func getDecideTransactionRequest(c *gin.Context) (service.DecideTransactionRequest, error) {
+	var body service.DecideTransactionRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		return body, err
+	}
+	return body, nil
+}
+
+func (t *transaction) transition(c *gin.Context, run func(ctx context.Context, userId, teamId, transactionId uint) (*service.TransactionResponse, error)) {
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	transactionId, err := getTransactionId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := run(c, userId, teamId, transactionId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+func NewTransaction(transactionService service.TransactionService) Transaction {
+	return &transaction{
+		transactionService: transactionService,
+	}
+}
@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	Uom interface {
+		FindAll(c *gin.Context)
+		FindById(c *gin.Context)
+		Create(c *gin.Context)
+		Update(c *gin.Context)
+		Delete(c *gin.Context)
+	}
+	uom struct {
+		uomService service.UomService
+	}
+)
+
+// FindAll implements Uom.
+//
+//	@Tags		uoms
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int		true	"Team ID"
+//	@Param		page	query		int		false	"Page"
+//	@Param		limit	query		int		false	"Limit"
+//	@Param		name	query		string	false	"Name"
+//	@Success	200		{object}	ResponsePagination[[]service.UomResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	404		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/uoms [get]
+func (u *uom) FindAll(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	pg, err := helpers.NewPaginate(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.UomFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := u.uomService.FindAll(c, teamId, pg, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handlePaginationJsonResponse(c, res, pg)
+}
+
+// FindById implements Uom.
+//
+//	@Tags		uoms
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int	true	"Team ID"
+//	@Param		uom_id	path		int	true	"Unit of Measure ID"
+//	@Success	200		{object}	Response[service.UomResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	404		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/uoms/{uom_id} [get]
+func (u *uom) FindById(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	uomId, err := getUomId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := u.uomService.FindById(c, teamId, uomId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Create implements Uom.
+//
+//	@Tags		uoms
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int				true	"Team ID"
+//	@Param		data	body		service.UomRequest	true	"Unit of measure"
+//	@Success	201		{object}	Response[service.UomResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	404		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/uoms [post]
+func (u *uom) Create(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.UomRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := u.uomService.Create(c, teamId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Update implements Uom.
+//
+//	@Tags		uoms
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int				true	"Team ID"
+//	@Param		uom_id	path		int				true	"Unit of Measure ID"
+//	@Param		data	body		service.UomRequest	true	"Unit of measure"
+//	@Success	201		{object}	Response[service.UomResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	404		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/uoms/{uom_id} [put]
+func (u *uom) Update(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	uomId, err := getUomId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.UomRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := u.uomService.Update(c, teamId, uomId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Delete implements Uom.
+//
+//	@Tags		uoms
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int	true	"Team ID"
+//	@Param		uom_id	path		int	true	"Unit of Measure ID"
+//	@Success	201		{object}	Response[any]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	404		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/uoms/{uom_id} [delete]
+func (u *uom) Delete(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	uomId, err := getUomId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	if err := u.uomService.Delete(c, teamId, uomId); err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, nil)
+}
+
+func NewUom(uomService service.UomService) Uom {
+	return &uom{
+		uomService: uomService,
+	}
+}
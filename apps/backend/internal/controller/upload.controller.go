@@ -0,0 +1,233 @@
+package controller
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	// Upload speaks the tus 1.0 protocol, chunks are sent with PATCH and the
+	// offset is recovered with HEAD after a dropped connection.
+	Upload interface {
+		Options(c *gin.Context)
+		Create(c *gin.Context)
+		Head(c *gin.Context)
+		Patch(c *gin.Context)
+		Terminate(c *gin.Context)
+		GetUpload(c *gin.Context)
+	}
+	upload struct {
+		conf          *config.Config
+		uploadService service.UploadService
+		uploadPolicy  service.UploadPolicy
+	}
+)
+
+// Options implements Upload.
+//
+//	@Tags		uploads
+//	@Success	204
+//	@Router		/uploads [options]
+func (u *upload) Options(c *gin.Context) {
+	u.setTusHeaders(c)
+	c.Header("Tus-Version", service.TusVersion)
+	c.Header("Tus-Extension", service.TusExtensions)
+	if max := u.uploadPolicy.MaxBytes(); max > 0 {
+		c.Header("Tus-Max-Size", strconv.FormatInt(max, 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Create implements Upload.
+//
+//	@Tags		uploads
+//	@Param		Tus-Resumable	header	string	true	"1.0.0"
+//	@Param		Upload-Length	header	int		true	"Size of the whole file in bytes"
+//	@Param		Upload-Metadata	header	string	false	"filename and target, base64 encoded"
+//	@Success	201
+//	@Failure	400	{object}	Response[any]
+//	@Failure	412	{object}	Response[any]
+//	@Failure	413	{object}	Response[any]
+//	@Router		/uploads [post]
+func (u *upload) Create(c *gin.Context) {
+	if !u.checkTusResumable(c) {
+		return
+	}
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil {
+		handlerError(c, errs.ErrUploadInvalidLength)
+		return
+	}
+	res, err := u.uploadService.Create(c, userId, service.CreateUploadDto{
+		Length:   length,
+		Metadata: c.GetHeader("Upload-Metadata"),
+	})
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	c.Header("Location", u.conf.SERVER.HOST_NAME+"/api/v1/uploads/"+res.UUID)
+	u.setUploadHeaders(c, res)
+	c.Status(http.StatusCreated)
+}
+
+// Head implements Upload.
+//
+//	@Tags		uploads
+//	@Param		upload_id		path	string	true	"Upload ID"
+//	@Param		Tus-Resumable	header	string	true	"1.0.0"
+//	@Success	200
+//	@Failure	404	{object}	Response[any]
+//	@Failure	410	{object}	Response[any]
+//	@Router		/uploads/{upload_id} [head]
+func (u *upload) Head(c *gin.Context) {
+	if !u.checkTusResumable(c) {
+		return
+	}
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := u.uploadService.GetUpload(c, userId, c.Param("upload_id"))
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Length", strconv.FormatInt(res.Length, 10))
+	if res.Metadata != "" {
+		c.Header("Upload-Metadata", res.Metadata)
+	}
+	u.setUploadHeaders(c, res)
+	c.Status(http.StatusOK)
+}
+
+// Patch implements Upload.
+//
+//	@Tags		uploads
+//	@Accept		application/offset+octet-stream
+//	@Param		upload_id		path	string	true	"Upload ID"
+//	@Param		Tus-Resumable	header	string	true	"1.0.0"
+//	@Param		Upload-Offset	header	int		true	"Offset the chunk starts at"
+//	@Success	204
+//	@Failure	404	{object}	Response[any]
+//	@Failure	409	{object}	Response[any]
+//	@Failure	410	{object}	Response[any]
+//	@Failure	415	{object}	Response[any]
+//	@Router		/uploads/{upload_id} [patch]
+func (u *upload) Patch(c *gin.Context) {
+	if !u.checkTusResumable(c) {
+		return
+	}
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	if contentType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type")); contentType != service.TusChunkContentType {
+		handlerError(c, errs.ErrUploadInvalidContentType)
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		handlerError(c, errs.ErrUploadOffsetMismatch)
+		return
+	}
+	res, err := u.uploadService.WriteChunk(c, userId, c.Param("upload_id"), offset, c.Request.Body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	u.setUploadHeaders(c, res)
+	c.Status(http.StatusNoContent)
+}
+
+// Terminate implements Upload.
+//
+//	@Tags		uploads
+//	@Param		upload_id		path	string	true	"Upload ID"
+//	@Param		Tus-Resumable	header	string	true	"1.0.0"
+//	@Success	204
+//	@Failure	404	{object}	Response[any]
+//	@Router		/uploads/{upload_id} [delete]
+func (u *upload) Terminate(c *gin.Context) {
+	if !u.checkTusResumable(c) {
+		return
+	}
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	if err := u.uploadService.Terminate(c, userId, c.Param("upload_id")); err != nil {
+		handlerError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetUpload implements Upload.
+//
+// Not part of tus, tells the client which record a finished upload became.
+//
+//	@Tags		uploads
+//	@Produce	json
+//	@Param		upload_id	path		string	true	"Upload ID"
+//	@Success	200			{object}	Response[service.UploadResponse]
+//	@Failure	404			{object}	Response[any]
+//	@Failure	410			{object}	Response[any]
+//	@Router		/uploads/{upload_id} [get]
+func (u *upload) GetUpload(c *gin.Context) {
+	userId, err := getProtectUserId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := u.uploadService.GetUpload(c, userId, c.Param("upload_id"))
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+func (u *upload) checkTusResumable(c *gin.Context) bool {
+	u.setTusHeaders(c)
+	if c.GetHeader("Tus-Resumable") != service.TusVersion {
+		c.Header("Tus-Version", service.TusVersion)
+		handlerError(c, errs.ErrTusVersionNotSupported)
+		return false
+	}
+	return true
+}
+
+func (u *upload) setTusHeaders(c *gin.Context) {
+	c.Header("Tus-Resumable", service.TusVersion)
+}
+
+func (u *upload) setUploadHeaders(c *gin.Context, res *service.UploadResponse) {
+	c.Header("Upload-Offset", strconv.FormatInt(res.Offset, 10))
+	if !res.Completed {
+		c.Header("Upload-Expires", res.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+func NewUpload(conf *config.Config, uploadService service.UploadService, uploadPolicy service.UploadPolicy) Upload {
+	return &upload{
+		conf:          conf,
+		uploadService: uploadService,
+		uploadPolicy:  uploadPolicy,
+	}
+}
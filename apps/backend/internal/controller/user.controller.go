@@ -14,7 +14,8 @@ type (
 		CreateProfileImage(c *gin.Context)
 	}
 	user struct {
-		userService service.UserService
+		userService  service.UserService
+		uploadPolicy service.UploadPolicy
 	}
 )
 
@@ -26,7 +27,10 @@ type (
 //	@Param		file	formData	file	true	"Profile image file"
 //	@Success	201		{object}	Response[service.UserResponse]
 //	@Failure	400		{object}	Response[any]
+//	@Failure	403		{object}	Response[any]
 //	@Failure	404		{object}	Response[any]
+//	@Failure	413		{object}	Response[any]
+//	@Failure	415		{object}	Response[any]
 //	@Failure	500		{object}	Response[any]
 //	@Router		/users/profile-image [post]
 func (a *user) CreateProfileImage(c *gin.Context) {
@@ -35,7 +39,7 @@ func (a *user) CreateProfileImage(c *gin.Context) {
 		handlerError(c, err)
 		return
 	}
-	file, err := c.FormFile("file")
+	file, err := getFormFile(c, "file", a.uploadPolicy.MaxBytes())
 	if err != nil {
 		handlerError(c, err)
 		return
@@ -144,6 +148,9 @@ func (u *user) GetUserMe(c *gin.Context) {
 	handleJsonResponse(c, res)
 }
 
-func NewUser(userService service.UserService) User {
-	return &user{userService: userService}
+func NewUser(userService service.UserService, uploadPolicy service.UploadPolicy) User {
+	return &user{
+		userService:  userService,
+		uploadPolicy: uploadPolicy,
+	}
 }
@@ -1,15 +1,19 @@
 package controller
 
 import (
+	"bytes"
 	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
 )
 
-/*
 func handlePaginationJsonResponse(c *gin.Context, json any, pg *helpers.Pagination, msg ...string) {
 	// Default message if none is provided
 	message := "Success"
@@ -38,7 +42,7 @@ func handlePaginationJsonResponse(c *gin.Context, json any, pg *helpers.Paginati
 
 	// Send response with the determined status
 	c.JSON(status, response)
-}*/
+}
 
 func handleJsonResponse(c *gin.Context, json any, msg ...string) {
 	// Default message if none is provided
@@ -69,6 +73,45 @@ func handleJsonResponse(c *gin.Context, json any, msg ...string) {
 	c.JSON(status, response)
 }
 
+// excelMaxBytes limits uploaded Excel sheets.
+const excelMaxBytes = 10 << 20
+
+const excelContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// handleExcelResponse sends content as an Excel file download.
+func handleExcelResponse(c *gin.Context, filename string, content *bytes.Buffer) {
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, excelContentType, content.Bytes())
+}
+
+// handleExcelStream writes the workbook of write straight to the response. An
+// error before its first byte is sent as usual, a later one can only cut the
+// response short.
+func handleExcelStream(c *gin.Context, filename string, write func(w io.Writer) error) {
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Header("Content-Type", excelContentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+	if err := write(c.Writer); err != nil {
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Disposition")
+			c.Writer.Header().Del("Content-Type")
+			handlerError(c, err)
+			return
+		}
+		c.Abort()
+	}
+}
+
+// handleInlineResponse sends content as a file the browser may show, e.g. an
+// image or a PDF to print.
+func handleInlineResponse(c *gin.Context, filename, contentType string, content *bytes.Buffer) {
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": filename}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, contentType, content.Bytes())
+}
+
 func handlerError(c *gin.Context, err error) {
 	message := "Something went wrong"
 	status := http.StatusBadRequest
@@ -87,7 +130,6 @@ func handlerError(c *gin.Context, err error) {
 	c.AbortWithStatusJSON(status, response)
 }
 
-/*
 func getTeamId(c *gin.Context) (teamId uint, err error) {
 	teamIdStr := c.Param("team_id")
 	if teamIdStr == "" {
@@ -100,9 +142,17 @@ func getTeamId(c *gin.Context) (teamId uint, err error) {
 	return uint(teamIdInt), nil
 }
 
-func getFiles(c *gin.Context) ([]*multipart.FileHeader, error) {
+// getFiles reads the "files" field, maxBytes limits the whole body as getFormFile does.
+func getFiles(c *gin.Context, maxBytes int64) ([]*multipart.FileHeader, error) {
+	if maxBytes > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+multipartOverhead)
+	}
 	form, err := c.MultipartForm()
 	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, errs.ErrUploadTooLarge
+		}
 		return nil, errs.ErrFileUploadNotFound
 	}
 	files := form.File["files"]
@@ -120,7 +170,23 @@ func getProductImageId(c *gin.Context) (uint, error) {
 		return 0, errs.ErrBadRequest
 	}
 	return uint(productImageIdInt), nil
-}*/
+}
+
+func getProductId(c *gin.Context) (uint, error) {
+	productId, err := strconv.Atoi(c.Param("products_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(productId), nil
+}
+
+func getProductCategoryId(c *gin.Context) (uint, error) {
+	categoryId, err := strconv.Atoi(c.Param("product_category_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(categoryId), nil
+}
 
 func getProtectUserId(c *gin.Context) (uId uint, err error) {
 	uIdStr, ok := c.Get("user_id")
@@ -134,6 +200,148 @@ func getProtectUserId(c *gin.Context) (uId uint, err error) {
 	return uId, nil
 }
 
+// multipartOverhead is allowed on top of the file for boundaries and other fields.
+const multipartOverhead = 1 << 20
+
+// getFormFile stops reading the body once it exceeds maxBytes, before the
+// file is spooled to a temp file. maxBytes <= 0 means no limit.
+func getFormFile(c *gin.Context, name string, maxBytes int64) (*multipart.FileHeader, error) {
+	if maxBytes > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+multipartOverhead)
+	}
+	fh, err := c.FormFile(name)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, errs.ErrUploadTooLarge
+		}
+		return nil, errs.ErrFileUploadNotFound
+	}
+	return fh, nil
+}
+
+func getImageId(c *gin.Context) (uint, error) {
+	imageIdStr := c.Param("image_id")
+
+	imageIdInt, err := strconv.Atoi(imageIdStr)
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(imageIdInt), nil
+}
+
+func getEntityId(c *gin.Context) (uint, error) {
+	entityId, err := strconv.Atoi(c.Param("entity_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(entityId), nil
+}
+
+func getAttachmentId(c *gin.Context) (uint, error) {
+	attachmentId, err := strconv.Atoi(c.Param("attachment_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(attachmentId), nil
+}
+
+func getProductLotId(c *gin.Context) (uint, error) {
+	lotId, err := strconv.Atoi(c.Param("lot_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(lotId), nil
+}
+
+func getTransactionId(c *gin.Context) (uint, error) {
+	transactionId, err := strconv.Atoi(c.Param("transaction_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(transactionId), nil
+}
+
+func getApprovalStepId(c *gin.Context) (uint, error) {
+	stepId, err := strconv.Atoi(c.Param("approval_step_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(stepId), nil
+}
+
+func getApprovalId(c *gin.Context) (uint, error) {
+	approvalId, err := strconv.Atoi(c.Param("approval_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(approvalId), nil
+}
+
+func getWarehouseId(c *gin.Context) (uint, error) {
+	warehouseId, err := strconv.Atoi(c.Param("warehouse_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(warehouseId), nil
+}
+
+func getLocationId(c *gin.Context) (uint, error) {
+	locationId, err := strconv.Atoi(c.Param("location_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(locationId), nil
+}
+
+func getStockTransferId(c *gin.Context) (uint, error) {
+	transferId, err := strconv.Atoi(c.Param("stock_transfer_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(transferId), nil
+}
+
+func getStockCountId(c *gin.Context) (uint, error) {
+	countId, err := strconv.Atoi(c.Param("stock_count_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(countId), nil
+}
+
+func getStockAdjustmentId(c *gin.Context) (uint, error) {
+	adjustmentId, err := strconv.Atoi(c.Param("stock_adjustment_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(adjustmentId), nil
+}
+
+func getUomId(c *gin.Context) (uint, error) {
+	uomId, err := strconv.Atoi(c.Param("uom_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(uomId), nil
+}
+
+func getAlertId(c *gin.Context) (uint, error) {
+	alertId, err := strconv.Atoi(c.Param("alert_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(alertId), nil
+}
+
+func getPartnerId(c *gin.Context) (uint, error) {
+	partnerId, err := strconv.Atoi(c.Param("partner_id"))
+	if err != nil {
+		return 0, errs.ErrBadRequest
+	}
+	return uint(partnerId), nil
+}
+
 func getUserIdFromParam(c *gin.Context) (uId uint, err error) {
 	uIdStr := c.Param("id")
 	u, err := strconv.Atoi(uIdStr)
@@ -0,0 +1,173 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	Valuation interface {
+		Balance(c *gin.Context)
+		Export(c *gin.Context)
+		Cogs(c *gin.Context)
+		FindSetting(c *gin.Context)
+		SaveSetting(c *gin.Context)
+	}
+	valuation struct {
+		valuationService service.ValuationService
+	}
+)
+
+// Balance implements Valuation.
+//
+//	@Tags		valuation
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int		true	"Team ID"
+//	@Param		at			query		string	false	"RFC 3339 time or 2006-01-02 date, now when empty"
+//	@Param		product_id	query		int		false	"Product ID"
+//	@Success	200			{object}	Response[[]service.ValuationResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/valuation [get]
+func (v *valuation) Balance(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.ValuationFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := v.valuationService.Balance(c, teamId, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Export implements Valuation.
+//
+//	@Tags		valuation
+//	@Produce	application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+//	@Param		team_id		path	int		true	"Team ID"
+//	@Param		at			query	string	false	"RFC 3339 time or 2006-01-02 date, now when empty"
+//	@Param		product_id	query	int		false	"Product ID"
+//	@Success	200			{file}	file
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/valuation/export [get]
+func (v *valuation) Export(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.ValuationFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := v.valuationService.Export(c, teamId, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleExcelResponse(c, res.Filename, res.Content)
+}
+
+// Cogs implements Valuation.
+//
+//	@Tags		valuation
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int		true	"Team ID"
+//	@Param		from		query		string	false	"2006-01-02, start of the current month when empty"
+//	@Param		to			query		string	false	"2006-01-02, end of the current month when empty"
+//	@Param		product_id	query		int		false	"Product ID"
+//	@Success	200			{object}	Response[[]service.CogsResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/valuation/cogs [get]
+func (v *valuation) Cogs(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.CogsFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := v.valuationService.Cogs(c, teamId, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// FindSetting implements Valuation.
+//
+//	@Tags		valuation
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int	true	"Team ID"
+//	@Success	200		{object}	Response[service.InventorySettingResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/valuation/setting [get]
+func (v *valuation) FindSetting(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := v.valuationService.FindSetting(c, teamId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// SaveSetting implements Valuation.
+//
+//	@Tags		valuation
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int								true	"Team ID"
+//	@Param		data	body		service.InventorySettingRequest	true	"Inventory setting"
+//	@Success	201		{object}	Response[service.InventorySettingResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/valuation/setting [put]
+func (v *valuation) SaveSetting(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.InventorySettingRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := v.valuationService.SaveSetting(c, teamId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+func NewValuation(valuationService service.ValuationService) Valuation {
+	return &valuation{
+		valuationService: valuationService,
+	}
+}
@@ -0,0 +1,327 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/service"
+)
+
+type (
+	Warehouse interface {
+		FindAll(c *gin.Context)
+		FindById(c *gin.Context)
+		Create(c *gin.Context)
+		Update(c *gin.Context)
+		Delete(c *gin.Context)
+		CreateLocation(c *gin.Context)
+		FindLocationById(c *gin.Context)
+		UpdateLocation(c *gin.Context)
+		DeleteLocation(c *gin.Context)
+	}
+	warehouse struct {
+		warehouseService service.WarehouseService
+	}
+)
+
+// FindAll implements Warehouse.
+//
+//	@Tags		warehouses
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int		true	"Team ID"
+//	@Param		page	query		int		false	"Page"
+//	@Param		limit	query		int		false	"Limit"
+//	@Param		code	query		string	false	"Code"
+//	@Param		name	query		string	false	"Name"
+//	@Success	200		{object}	ResponsePagination[[]service.WarehouseResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/warehouses [get]
+func (w *warehouse) FindAll(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	pg, err := helpers.NewPaginate(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	f, err := filter.New[filter.WarehouseFilter](c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := w.warehouseService.FindWarehouses(c, teamId, pg, f)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handlePaginationJsonResponse(c, res, pg)
+}
+
+// FindById implements Warehouse.
+//
+//	@Tags		warehouses
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id			path		int	true	"Team ID"
+//	@Param		warehouse_id	path		int	true	"Warehouse ID"
+//	@Success	200				{object}	Response[service.WarehouseResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	500				{object}	Response[any]
+//	@Router		/teams/{team_id}/warehouses/{warehouse_id} [get]
+func (w *warehouse) FindById(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	warehouseId, err := getWarehouseId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := w.warehouseService.FindWarehouseById(c, teamId, warehouseId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Create implements Warehouse.
+//
+//	@Tags		warehouses
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id	path		int								true	"Team ID"
+//	@Param		data	body		service.CreateWarehouseRequest	true	"Warehouse"
+//	@Success	201		{object}	Response[service.WarehouseResponse]
+//	@Failure	400		{object}	Response[any]
+//	@Failure	500		{object}	Response[any]
+//	@Router		/teams/{team_id}/warehouses [post]
+func (w *warehouse) Create(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.CreateWarehouseRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := w.warehouseService.CreateWarehouse(c, teamId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Update implements Warehouse.
+//
+//	@Tags		warehouses
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id			path		int								true	"Team ID"
+//	@Param		warehouse_id	path		int								true	"Warehouse ID"
+//	@Param		data			body		service.UpdateWarehouseRequest	true	"Name and address"
+//	@Success	201				{object}	Response[service.WarehouseResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	500				{object}	Response[any]
+//	@Router		/teams/{team_id}/warehouses/{warehouse_id} [put]
+func (w *warehouse) Update(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	warehouseId, err := getWarehouseId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.UpdateWarehouseRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := w.warehouseService.UpdateWarehouse(c, teamId, warehouseId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// Delete implements Warehouse.
+//
+//	@Tags		warehouses
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id			path		int	true	"Team ID"
+//	@Param		warehouse_id	path		int	true	"Warehouse ID"
+//	@Success	201				{object}	Response[any]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	409				{object}	Response[any]
+//	@Failure	500				{object}	Response[any]
+//	@Router		/teams/{team_id}/warehouses/{warehouse_id} [delete]
+func (w *warehouse) Delete(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	warehouseId, err := getWarehouseId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	if err := w.warehouseService.DeleteWarehouse(c, teamId, warehouseId); err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, nil)
+}
+
+// CreateLocation implements Warehouse.
+//
+//	@Tags		warehouses
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id			path		int						true	"Team ID"
+//	@Param		warehouse_id	path		int						true	"Warehouse ID"
+//	@Param		data			body		service.LocationRequest	true	"Storage location"
+//	@Success	201				{object}	Response[service.LocationResponse]
+//	@Failure	400				{object}	Response[any]
+//	@Failure	500				{object}	Response[any]
+//	@Router		/teams/{team_id}/warehouses/{warehouse_id}/locations [post]
+func (w *warehouse) CreateLocation(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	warehouseId, err := getWarehouseId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.LocationRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := w.warehouseService.CreateLocation(c, teamId, warehouseId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// FindLocationById implements Warehouse.
+//
+//	@Tags		warehouses
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int	true	"Team ID"
+//	@Param		location_id	path		int	true	"Location ID"
+//	@Success	200			{object}	Response[service.LocationResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/locations/{location_id} [get]
+func (w *warehouse) FindLocationById(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	locationId, err := getLocationId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := w.warehouseService.FindLocationById(c, teamId, locationId)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// UpdateLocation implements Warehouse.
+//
+//	@Tags		warehouses
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int						true	"Team ID"
+//	@Param		location_id	path		int						true	"Location ID"
+//	@Param		data		body		service.LocationRequest	true	"Code and name"
+//	@Success	201			{object}	Response[service.LocationResponse]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/locations/{location_id} [put]
+func (w *warehouse) UpdateLocation(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	locationId, err := getLocationId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	var body service.LocationRequest
+	if err := c.ShouldBindBodyWithJSON(&body); err != nil {
+		handlerError(c, err)
+		return
+	}
+	res, err := w.warehouseService.UpdateLocation(c, teamId, locationId, body)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, res)
+}
+
+// DeleteLocation implements Warehouse.
+//
+//	@Tags		warehouses
+//	@Accept		json
+//	@Produce	json
+//	@Param		team_id		path		int	true	"Team ID"
+//	@Param		location_id	path		int	true	"Location ID"
+//	@Success	201			{object}	Response[any]
+//	@Failure	400			{object}	Response[any]
+//	@Failure	409			{object}	Response[any]
+//	@Failure	500			{object}	Response[any]
+//	@Router		/teams/{team_id}/locations/{location_id} [delete]
+func (w *warehouse) DeleteLocation(c *gin.Context) {
+	teamId, err := getTeamId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	locationId, err := getLocationId(c)
+	if err != nil {
+		handlerError(c, err)
+		return
+	}
+	if err := w.warehouseService.DeleteLocation(c, teamId, locationId); err != nil {
+		handlerError(c, err)
+		return
+	}
+	handleJsonResponse(c, nil)
+}
+
+func NewWarehouse(warehouseService service.WarehouseService) Warehouse {
+	return &warehouse{
+		warehouseService: warehouseService,
+	}
+}
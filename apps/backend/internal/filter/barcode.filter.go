@@ -0,0 +1,18 @@
+package filter
+
+type (
+	BarcodeFilter struct {
+		// Symbology is code128 or qr, code128 when empty.
+		Symbology string `form:"symbology" binding:"omitempty,oneof=code128 qr"`
+		// Format is png or svg, png when empty.
+		Format string `form:"format" binding:"omitempty,oneof=png svg"`
+		// Width and Height are the largest size in pixels, QR codes are square.
+		Width  int `form:"width" binding:"omitempty,min=1,max=4000"`
+		Height int `form:"height" binding:"omitempty,min=1,max=4000"`
+	}
+
+	ScanFilter struct {
+		// Code is the scanned product or lot code.
+		Code string `form:"code" binding:"required,max=100"`
+	}
+)
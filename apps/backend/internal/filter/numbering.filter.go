@@ -0,0 +1,13 @@
+package filter
+
+type NumberingPreviewFilter struct {
+	// Count is the number of codes, 5 when empty.
+	Count int `form:"count" binding:"omitempty,min=1,max=50"`
+	// EntityID is the product of lot codes.
+	EntityID uint `form:"entity_id"`
+	// Template, ResetPeriod and FiscalYearStart preview a draft scheme
+	// instead of the current one when Template is set.
+	Template        string `form:"template" binding:"omitempty,max=64"`
+	ResetPeriod     string `form:"reset_period" binding:"omitempty,oneof=never daily monthly yearly fiscal_year"`
+	FiscalYearStart int    `form:"fiscal_year_start" binding:"omitempty,min=1,max=12"`
+}
@@ -0,0 +1,8 @@
+package filter
+
+type PartnerFilter struct {
+	// Search matches the code, name or tax id.
+	Search string `form:"search"`
+	// Type keeps partners of the type, partners of type both match supplier and customer.
+	Type string `form:"type"`
+}
@@ -0,0 +1,25 @@
+package filter
+
+type (
+	ProductCategoryFilter struct {
+		Name string `form:"name"`
+	}
+
+	ProductFilter struct {
+		Code string `form:"code"`
+		Name string `form:"name"`
+		UOM  string `form:"uom"`
+	}
+
+	UomFilter struct {
+		Name string `form:"name"`
+	}
+)
+
+type ProductLotFilter struct {
+	Code string `form:"code"`
+	// InStock keeps only lots with remaining quantity.
+	InStock bool `form:"in_stock"`
+	// Expired keeps only expired lots when true, only usable ones when false.
+	Expired *bool `form:"expired"`
+}
@@ -0,0 +1,6 @@
+package filter
+
+type SequenceAuditFilter struct {
+	EntityType string `form:"entity_type"`
+	Action     string `form:"action" binding:"omitempty,oneof=set reset reserve"`
+}
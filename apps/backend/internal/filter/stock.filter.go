@@ -0,0 +1,21 @@
+package filter
+
+type (
+	StockBalanceFilter struct {
+		// At is an RFC 3339 time or a 2006-01-02 date meaning the end of that day, now when empty.
+		At           string `form:"at"`
+		ProductID    uint   `form:"product_id"`
+		ProductLotID uint   `form:"product_lot_id"`
+		WarehouseID  uint   `form:"warehouse_id"`
+		LocationID   uint   `form:"location_id"`
+		// GroupBy is lot (default), product, location or warehouse.
+		GroupBy string `form:"group_by" binding:"omitempty,oneof=lot product location warehouse"`
+	}
+
+	StockMovementFilter struct {
+		ProductID    uint   `form:"product_id"`
+		ProductLotID uint   `form:"product_lot_id"`
+		LocationID   uint   `form:"location_id"`
+		Reason       string `form:"reason"`
+	}
+)
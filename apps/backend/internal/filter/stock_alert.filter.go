@@ -0,0 +1,9 @@
+package filter
+
+type AlertFilter struct {
+	Type      string `form:"type"`
+	Status    string `form:"status"`
+	ProductID uint   `form:"product_id"`
+	// Snoozed keeps only snoozed alerts when true, only alerts not snoozed when false.
+	Snoozed *bool `form:"snoozed"`
+}
@@ -0,0 +1,15 @@
+package filter
+
+type (
+	StockCountFilter struct {
+		Code       string `form:"code"`
+		Status     string `form:"status"`
+		LocationID uint   `form:"location_id"`
+	}
+
+	StockAdjustmentFilter struct {
+		Code         string `form:"code"`
+		LocationID   uint   `form:"location_id"`
+		StockCountID uint   `form:"stock_count_id"`
+	}
+)
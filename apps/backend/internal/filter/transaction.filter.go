@@ -0,0 +1,8 @@
+package filter
+
+type TransactionFilter struct {
+	Code      string `form:"code"`
+	Type      string `form:"type"`
+	Status    string `form:"status"`
+	PartnerID uint   `form:"partner_id"`
+}
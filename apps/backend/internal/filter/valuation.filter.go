@@ -0,0 +1,16 @@
+package filter
+
+type (
+	ValuationFilter struct {
+		// At is an RFC 3339 time or a 2006-01-02 date meaning the end of that day, now when empty.
+		At        string `form:"at"`
+		ProductID uint   `form:"product_id"`
+	}
+
+	CogsFilter struct {
+		// From and To are 2006-01-02 dates, both included, the current month when empty.
+		From      string `form:"from" binding:"omitempty,datetime=2006-01-02"`
+		To        string `form:"to" binding:"omitempty,datetime=2006-01-02"`
+		ProductID uint   `form:"product_id"`
+	}
+)
@@ -0,0 +1,15 @@
+package filter
+
+type (
+	WarehouseFilter struct {
+		Code string `form:"code"`
+		Name string `form:"name"`
+	}
+
+	StockTransferFilter struct {
+		Code           string `form:"code"`
+		Status         string `form:"status"`
+		FromLocationID uint   `form:"from_location_id"`
+		ToLocationID   uint   `form:"to_location_id"`
+	}
+)
@@ -17,15 +17,45 @@ type (
 		Protect(c *gin.Context)
 		ProtectRefreshToken(c *gin.Context)
 		Permission(c *gin.Context)
+		// AttachmentPermission guards routes with :entity_type and :entity_id,
+		// see service.AttachmentService.CheckPermission.
+		AttachmentPermission(c *gin.Context)
 	}
 	authGuardMiddleware struct {
 		jwt      service.JWTService
 		enforcer *casbin.Enforcer
 		logger   logger.AppLogger
 		user     service.UserService
+		attach   service.AttachmentService
 	}
 )
 
+// AttachmentPermission implements AuthGuardMiddleware.
+func (a *authGuardMiddleware) AttachmentPermission(c *gin.Context) {
+	userIdStr, ok := c.Get("user_id")
+	if !ok {
+		a.logger.Error("can not get userId")
+		handlerError(c, errs.ErrUnauthorized)
+		return
+	}
+	uId, ok := userIdStr.(uint)
+	if !ok {
+		a.logger.Error("user id not uint")
+		handlerError(c, errs.ErrUnauthorized)
+		return
+	}
+	entityId, err := strconv.Atoi(c.Param("entity_id"))
+	if err != nil {
+		handlerError(c, errs.ErrBadRequest)
+		return
+	}
+	if err := a.attach.CheckPermission(c, uId, c.Param("entity_type"), uint(entityId), c.Request.URL.Path, c.Request.Method); err != nil {
+		handlerError(c, err)
+		return
+	}
+	c.Next()
+}
+
 // Permission implements AuthGuardMiddleware.
 func (a *authGuardMiddleware) Permission(c *gin.Context) {
 	userIdStr, ok := c.Get("user_id")
@@ -135,11 +165,13 @@ func NewAuthGuardMiddleware(
 	enforcer *casbin.Enforcer,
 	logger logger.AppLogger,
 	user service.UserService,
+	attach service.AttachmentService,
 ) AuthGuardMiddleware {
 	return &authGuardMiddleware{
 		jwt:      jwt,
 		enforcer: enforcer,
 		logger:   logger,
 		user:     user,
+		attach:   attach,
 	}
 }
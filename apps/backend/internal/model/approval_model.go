@@ -0,0 +1,41 @@
+package model
+
+import "database/sql"
+
+// status of a TransactionApproval
+const (
+	ApprovalStatusPending   = "pending"
+	ApprovalStatusApproved  = "approved"
+	ApprovalStatusRejected  = "rejected"
+	ApprovalStatusCancelled = "cancelled" // an earlier step rejected or the transaction was cancelled
+)
+
+type (
+	// ApprovalStep is one level of the approval chain of a team. A step applies to
+	// transactions of TransactionType, every type when empty, whose total amount
+	// is at least MinAmount, so thresholds can require additional approvers.
+	ApprovalStep struct {
+		CommonModel
+		TeamID          uint       `db:"team_id" json:"team_id" gorm:"index"`
+		TransactionType EntityType `db:"transaction_type" json:"transaction_type"`
+		Level           int        `db:"level" json:"level"`
+		MinAmount       float64    `db:"min_amount" json:"min_amount"`
+		ApproverID      uint       `db:"approver_id" json:"approver_id"`
+		Approver        *User      `db:"-" json:"approver,omitempty"`
+	}
+
+	// TransactionApproval is a decision on a transaction. The applying steps are
+	// copied on submit and decided in Level order, together they are the approval
+	// history of the transaction.
+	TransactionApproval struct {
+		CommonModel
+		TeamID        uint         `db:"team_id" json:"team_id"`
+		TransactionID uint         `db:"transaction_id" json:"transaction_id" gorm:"index"`
+		Level         int          `db:"level" json:"level"`
+		ApproverID    uint         `db:"approver_id" json:"approver_id" gorm:"index"`
+		Status        string       `db:"status" json:"status"`
+		Remark        string       `db:"remark" json:"remark"`
+		DecidedAt     sql.NullTime `db:"decided_at" json:"decided_at"`
+		Approver      *User        `db:"-" json:"approver,omitempty"`
+	}
+)
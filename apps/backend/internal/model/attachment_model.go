@@ -0,0 +1,29 @@
+package model
+
+import "database/sql"
+
+// entities attachments can be linked to, as they appear in the attachment routes
+const (
+	AttachmentEntityUser    = "users"
+	AttachmentEntityTeam    = "teams"
+	AttachmentEntityProduct = "products"
+)
+
+type (
+	// Attachment is a file of any type linked to an entity by EntityType and EntityID.
+	Attachment struct {
+		CommonModel
+		EntityType    string       `db:"entity_type" json:"entity_type" gorm:"index:idx_attachment_entity"`
+		EntityID      uint         `db:"entity_id" json:"entity_id" gorm:"index:idx_attachment_entity"`
+		Key           string       `db:"key" json:"key"` // storage key, shared with the Blob of the same content
+		Hash          string       `db:"hash" json:"hash" gorm:"index"`
+		Filename      string       `db:"filename" json:"filename"` // original file name
+		Size          int64        `db:"size" json:"size"`
+		MimeType      string       `db:"mime_type" json:"mime_type"`
+		ScanStatus    string       `db:"scan_status" json:"scan_status" gorm:"index"`
+		ScanSignature string       `db:"scan_signature" json:"scan_signature"`
+		ScannedAt     sql.NullTime `db:"scanned_at" json:"scanned_at"`
+		UploaderID    uint         `db:"uploader_id" json:"uploader_id" gorm:"index"`
+		Uploader      *User        `db:"-" json:"uploader,omitempty"`
+	}
+)
@@ -3,10 +3,11 @@ package model
 type (
 	AutoIncrementSequence struct {
 		CommonModel
-		EntityType EntityType `db:"entity_type" json:"entity_type"` // "transaction_sale", "transaction_purchase", "product", "lot"
-		TeamID     uint       `db:"team_id" json:"team_id"`         // for team-specific sequences
-		EntityID   uint       `db:"entity_id" json:"entity_id"`     // additional identifier (e.g., product_id for lots)
-		Sequence   uint       `db:"sequence" json:"sequence"`       // current sequence number
+		EntityType EntityType `db:"entity_type" json:"entity_type" gorm:"uniqueIndex:idx_sequence_key"` // "transaction_sale", "transaction_purchase", "product", "lot", "partner"
+		TeamID     uint       `db:"team_id" json:"team_id" gorm:"uniqueIndex:idx_sequence_key"`         // for team-specific sequences
+		EntityID   uint       `db:"entity_id" json:"entity_id" gorm:"uniqueIndex:idx_sequence_key"`     // additional identifier (e.g., product_id for lots)
+		Sequence   uint       `db:"sequence" json:"sequence"`                                           // current sequence number
+		Period     string     `db:"period" json:"period"`                                               // period key of Sequence, it restarts in another period
 	}
 	EntityType string
 )
@@ -17,4 +18,8 @@ const (
 	EntityTypeTransactionPurchase EntityType = "PO"
 	EntityTypeProduct             EntityType = "product"
 	EntityTypeLot                 EntityType = "lot"
+	EntityTypeStockTransfer       EntityType = "TR"
+	EntityTypeStockCount          EntityType = "SC"
+	EntityTypeStockAdjustment     EntityType = "ADJ"
+	EntityTypePartner             EntityType = "partner"
 )
@@ -0,0 +1,15 @@
+package model
+
+type (
+	// Blob is a stored file identified by the SHA-256 of its content. Records
+	// pointing at the same content (e.g. Image) share one blob, RefCount is the
+	// number of such records.
+	Blob struct {
+		CommonModel
+		Hash        string `db:"hash" json:"hash" gorm:"uniqueIndex"`
+		Key         string `db:"key" json:"key" gorm:"index"`
+		Size        int64  `db:"size" json:"size"`
+		ContentType string `db:"content_type" json:"content_type"`
+		RefCount    int    `db:"ref_count" json:"ref_count"`
+	}
+)
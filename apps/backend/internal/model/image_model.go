@@ -1,15 +1,28 @@
 package model
 
+import "database/sql"
+
+// scan status of an uploaded file, only clean files are served
+const (
+	ScanStatusPending  = "pending"  // not scanned yet or the scanner failed
+	ScanStatusClean    = "clean"    //
+	ScanStatusInfected = "infected" // moved to quarantine
+)
+
 type (
 	Image struct {
 		CommonModel
-		Path   string  `db:"path" json:"path"`
-		Url    string  `db:"url" json:"url"`
-		Size   float64 `db:"size" json:"size"`
-		Width  uint    `db:"width" json:"width"`
-		Height uint    `db:"height" json:"height"`
-		Type   string  `db:"type" json:"type"`
-		UserID uint    `db:"user_id" json:"user_id"`
-		User   *User   `db:"-" json:"user,omitempty"`
+		Path          string       `db:"path" json:"path"`
+		Hash          string       `db:"hash" json:"hash" gorm:"index"` // sha256 of the content, see Blob
+		Url           string       `db:"url" json:"url"`
+		Size          float64      `db:"size" json:"size"`
+		Width         uint         `db:"width" json:"width"`
+		Height        uint         `db:"height" json:"height"`
+		Type          string       `db:"type" json:"type"`
+		ScanStatus    string       `db:"scan_status" json:"scan_status" gorm:"index;default:clean"` // images stored before scanning are clean
+		ScanSignature string       `db:"scan_signature" json:"scan_signature"`
+		ScannedAt     sql.NullTime `db:"scanned_at" json:"scanned_at"`
+		UserID        uint         `db:"user_id" json:"user_id"`
+		User          *User        `db:"-" json:"user,omitempty"`
 	}
 )
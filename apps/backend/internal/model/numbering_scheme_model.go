@@ -0,0 +1,27 @@
+package model
+
+// reset period of a NumberingScheme
+const (
+	ResetNever      = "never"
+	ResetDaily      = "daily"
+	ResetMonthly    = "monthly"
+	ResetYearly     = "yearly"
+	ResetFiscalYear = "fiscal_year"
+)
+
+type (
+	// NumberingScheme is how a team numbers the documents of an entity type,
+	// types without one use the built-in scheme of service.CodeService. The
+	// sequence restarts at 1 every ResetPeriod, a fiscal year starts on the
+	// first day of month FiscalYearStart. Gapless numbers are counted in the
+	// transaction of the document, a rolled back document gives its number back.
+	NumberingScheme struct {
+		CommonModel
+		TeamID          uint       `db:"team_id" json:"team_id" gorm:"uniqueIndex:idx_numbering_team_type"`
+		EntityType      EntityType `db:"entity_type" json:"entity_type" gorm:"uniqueIndex:idx_numbering_team_type"`
+		Template        string     `db:"template" json:"template"`
+		ResetPeriod     string     `db:"reset_period" json:"reset_period"`
+		FiscalYearStart int        `db:"fiscal_year_start" json:"fiscal_year_start" gorm:"default:1"`
+		Gapless         bool       `db:"gapless" json:"gapless"`
+	}
+)
@@ -0,0 +1,51 @@
+package model
+
+// type of a Partner
+const (
+	PartnerTypeSupplier = "supplier" // counterparty of purchases
+	PartnerTypeCustomer = "customer" // counterparty of sales and returns
+	PartnerTypeBoth     = "both"
+)
+
+type (
+	// Partner is a supplier or customer of a team, Code is generated by
+	// CodeService unless given.
+	Partner struct {
+		CommonModel
+		TeamID    uint             `db:"team_id" json:"team_id" gorm:"uniqueIndex:idx_partner_team_code"`
+		Code      string           `db:"code" json:"code" gorm:"uniqueIndex:idx_partner_team_code"`
+		Type      string           `db:"type" json:"type" gorm:"index"`
+		Name      string           `db:"name" json:"name"`
+		TaxID     string           `db:"tax_id" json:"tax_id"`
+		Email     string           `db:"email" json:"email"`
+		Phone     string           `db:"phone" json:"phone"`
+		Note      string           `db:"note" json:"note"`
+		Addresses []PartnerAddress `db:"-" json:"addresses,omitempty"`
+		Contacts  []PartnerContact `db:"-" json:"contacts,omitempty"`
+	}
+
+	// PartnerAddress is an address of a partner, e.g. billing or shipping, the
+	// default one is exported and printed on documents.
+	PartnerAddress struct {
+		CommonModel
+		PartnerID  uint   `db:"partner_id" json:"partner_id" gorm:"index"`
+		Label      string `db:"label" json:"label"`
+		Address    string `db:"address" json:"address"`
+		District   string `db:"district" json:"district"`
+		Province   string `db:"province" json:"province"`
+		PostalCode string `db:"postal_code" json:"postal_code"`
+		Country    string `db:"country" json:"country"`
+		IsDefault  bool   `db:"is_default" json:"is_default"`
+	}
+
+	// PartnerContact is a person to contact at a partner.
+	PartnerContact struct {
+		CommonModel
+		PartnerID uint   `db:"partner_id" json:"partner_id" gorm:"index"`
+		Name      string `db:"name" json:"name"`
+		Position  string `db:"position" json:"position"`
+		Email     string `db:"email" json:"email"`
+		Phone     string `db:"phone" json:"phone"`
+		IsPrimary bool   `db:"is_primary" json:"is_primary"`
+	}
+)
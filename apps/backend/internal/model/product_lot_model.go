@@ -0,0 +1,21 @@
+package model
+
+import "database/sql"
+
+type (
+	// ProductLot is a received batch of a product, stock is issued from lots
+	// first-expired-first-out and never from expired ones. UnitCost is the
+	// purchase cost of a base unit, see service.ValuationService.
+	ProductLot struct {
+		CommonModel
+		TeamID          uint         `db:"team_id" json:"team_id" gorm:"index"`
+		ProductID       uint         `db:"product_id" json:"product_id" gorm:"uniqueIndex:idx_product_lot_code"`
+		Code            string       `db:"code" json:"code" gorm:"uniqueIndex:idx_product_lot_code"`
+		ManufactureDate sql.NullTime `db:"manufacture_date" json:"manufacture_date" gorm:"type:date"`
+		ExpiryDate      sql.NullTime `db:"expiry_date" json:"expiry_date" gorm:"type:date;index"` // null never expires
+		ReceivedQty     float64      `db:"received_qty" json:"received_qty"`
+		RemainingQty    float64      `db:"remaining_qty" json:"remaining_qty"`
+		UnitCost        float64      `db:"unit_cost" json:"unit_cost"`
+		Product         *Product     `db:"-" json:"product,omitempty"`
+	}
+)
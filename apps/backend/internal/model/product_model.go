@@ -0,0 +1,45 @@
+package model
+
+type (
+	ProductCategory struct {
+		CommonModel
+		TeamID uint   `db:"team_id" json:"team_id" gorm:"index"`
+		Name   string `db:"name" json:"name"`
+	}
+
+	// Product is a catalog item of a team, Code is generated on create and
+	// unique within the team. Stock is kept in the base unit BaseUomID, UOM is
+	// its name, Uoms are the alternate units the product is traded in. A low
+	// stock alert is raised once stock falls to ReorderPoint, never when zero.
+	Product struct {
+		CommonModel
+		TeamID                 uint                     `db:"team_id" json:"team_id" gorm:"uniqueIndex:idx_product_team_code"`
+		Code                   string                   `db:"code" json:"code" gorm:"uniqueIndex:idx_product_team_code"`
+		Name                   string                   `db:"name" json:"name"`
+		Description            string                   `db:"description" json:"description"`
+		Price                  float64                  `db:"price" json:"price"`
+		UOM                    string                   `db:"uom" json:"uom"`
+		BaseUomID              *uint                    `db:"base_uom_id" json:"base_uom_id" gorm:"index"`
+		BaseUom                *Uom                     `db:"-" json:"base_uom,omitempty"`
+		Uoms                   []ProductUom             `db:"-" json:"uoms,omitempty"`
+		ReorderPoint           float64                  `db:"reorder_point" json:"reorder_point"`
+		ProductImage           []ProductImage           `db:"-" json:"product_image,omitempty"`
+		ProductProductCategory []ProductProductCategory `db:"-" json:"product_product_category,omitempty"`
+	}
+
+	ProductProductCategory struct {
+		CommonModel
+		ProductID  uint             `db:"product_id" json:"product_id" gorm:"index"`
+		CategoryID uint             `db:"category_id" json:"category_id" gorm:"index"`
+		Category   *ProductCategory `db:"-" json:"category,omitempty"`
+	}
+
+	// ProductImage links an Image to a product, images are listed by SortOrder.
+	ProductImage struct {
+		CommonModel
+		ProductID uint   `db:"product_id" json:"product_id" gorm:"index"`
+		ImageID   uint   `db:"image_id" json:"image_id"`
+		SortOrder int    `db:"sort_order" json:"sort_order"`
+		Image     *Image `db:"-" json:"image,omitempty"`
+	}
+)
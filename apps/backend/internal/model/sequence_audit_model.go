@@ -0,0 +1,28 @@
+package model
+
+// action of a SequenceAudit
+const (
+	SequenceActionSet     = "set"
+	SequenceActionReset   = "reset"
+	SequenceActionReserve = "reserve"
+)
+
+type (
+	// SequenceAudit is a manual change of a sequence, from the last number
+	// PreviousSequence counted in PreviousPeriod to Sequence in Period. A
+	// reserve takes the numbers after PreviousSequence up to Sequence.
+	SequenceAudit struct {
+		CommonModel
+		TeamID           uint       `db:"team_id" json:"team_id" gorm:"index"`
+		EntityType       EntityType `db:"entity_type" json:"entity_type"`
+		EntityID         uint       `db:"entity_id" json:"entity_id"`
+		Action           string     `db:"action" json:"action"`
+		PreviousSequence uint       `db:"previous_sequence" json:"previous_sequence"`
+		PreviousPeriod   string     `db:"previous_period" json:"previous_period"`
+		Sequence         uint       `db:"sequence" json:"sequence"`
+		Period           string     `db:"period" json:"period"`
+		Note             string     `db:"note" json:"note"`
+		CreatedByID      uint       `db:"created_by_id" json:"created_by_id"`
+		CreatedBy        *User      `db:"-" json:"created_by,omitempty"`
+	}
+)
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+// type of an AlertRule and the Alerts it raises
+const (
+	AlertTypeLowStock = "low_stock" // stock of a product at or below its reorder point
+	AlertTypeExpiring = "expiring"  // lot in stock expiring within the days of the rule
+	AlertTypeExpired  = "expired"   // lot in stock past its expiry date
+)
+
+// status of an Alert
+const (
+	AlertStatusOpen         = "open"
+	AlertStatusAcknowledged = "acknowledged"
+	AlertStatusResolved     = "resolved" // the condition no longer holds
+)
+
+type (
+	// AlertRule turns on an alert type for a team, Days is the window of
+	// AlertTypeExpiring.
+	AlertRule struct {
+		CommonModel
+		TeamID  uint   `db:"team_id" json:"team_id" gorm:"uniqueIndex:idx_alert_rule_team_type"`
+		Type    string `db:"type" json:"type" gorm:"uniqueIndex:idx_alert_rule_team_type"`
+		Days    int    `db:"days" json:"days"`
+		Enabled bool   `db:"enabled" json:"enabled"`
+	}
+
+	// Alert is raised by the evaluation of an AlertRule. Key identifies what it
+	// is about, e.g. the product or lot, so there is one unresolved alert per
+	// key. It is resolved by the first evaluation the condition no longer holds.
+	Alert struct {
+		CommonModel
+		TeamID           uint         `db:"team_id" json:"team_id" gorm:"uniqueIndex:idx_alert_team_key,where:resolved_at IS NULL;index"`
+		Key              string       `db:"key" json:"key" gorm:"uniqueIndex:idx_alert_team_key,where:resolved_at IS NULL"`
+		RuleID           uint         `db:"rule_id" json:"rule_id"`
+		Type             string       `db:"type" json:"type"`
+		Status           string       `db:"status" json:"status" gorm:"index"`
+		ProductID        uint         `db:"product_id" json:"product_id"`
+		ProductLotID     *uint        `db:"product_lot_id" json:"product_lot_id"`
+		Quantity         float64      `db:"quantity" json:"quantity"`
+		Threshold        float64      `db:"threshold" json:"threshold"` // reorder point of low stock alerts
+		ExpiryDate       sql.NullTime `db:"expiry_date" json:"expiry_date" gorm:"type:date"`
+		LastSeenAt       time.Time    `db:"last_seen_at" json:"last_seen_at"`
+		SnoozedUntil     sql.NullTime `db:"snoozed_until" json:"snoozed_until"`
+		AcknowledgedByID *uint        `db:"acknowledged_by_id" json:"acknowledged_by_id"`
+		AcknowledgedAt   sql.NullTime `db:"acknowledged_at" json:"acknowledged_at"`
+		ResolvedAt       sql.NullTime `db:"resolved_at" json:"resolved_at"`
+		Product          *Product     `db:"-" json:"product,omitempty"`
+		ProductLot       *ProductLot  `db:"-" json:"product_lot,omitempty"`
+	}
+
+	// AlertSubscriber receives the daily alert digest of a team.
+	AlertSubscriber struct {
+		CommonModel
+		TeamID       uint         `db:"team_id" json:"team_id" gorm:"uniqueIndex:idx_alert_subscriber"`
+		UserID       uint         `db:"user_id" json:"user_id" gorm:"uniqueIndex:idx_alert_subscriber"`
+		LastDigestAt sql.NullTime `db:"last_digest_at" json:"last_digest_at"`
+		User         *User        `db:"-" json:"user,omitempty"`
+	}
+)
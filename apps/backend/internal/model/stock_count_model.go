@@ -0,0 +1,83 @@
+package model
+
+import "database/sql"
+
+// status of a StockCount
+const (
+	StockCountStatusCounting  = "counting"
+	StockCountStatusSubmitted = "submitted" // waiting for approval, counts can no longer change
+	StockCountStatusPosted    = "posted"
+	StockCountStatusCancelled = "cancelled"
+)
+
+// reason code of a StockAdjustmentItem
+const (
+	AdjustReasonDamaged  = "damaged"
+	AdjustReasonLost     = "lost"
+	AdjustReasonFound    = "found"
+	AdjustReasonExpired  = "expired"
+	AdjustReasonMiscount = "miscount" // an earlier posting recorded the wrong quantity
+	AdjustReasonOther    = "other"
+)
+
+type (
+	// StockCount is a physical count of a location. Creating it snapshots the
+	// stock of every lot held there as ExpectedQty, approving it posts the
+	// variances as a StockAdjustment.
+	StockCount struct {
+		CommonModel
+		TeamID       uint             `db:"team_id" json:"team_id" gorm:"uniqueIndex:idx_stock_count_team_code"`
+		Code         string           `db:"code" json:"code" gorm:"uniqueIndex:idx_stock_count_team_code"`
+		Status       string           `db:"status" json:"status" gorm:"index"`
+		LocationID   uint             `db:"location_id" json:"location_id"`
+		Note         string           `db:"note" json:"note"`
+		CreatedByID  uint             `db:"created_by_id" json:"created_by_id"`
+		SnapshotAt   sql.NullTime     `db:"snapshot_at" json:"snapshot_at"`
+		ApprovedByID *uint            `db:"approved_by_id" json:"approved_by_id"`
+		ApprovedAt   sql.NullTime     `db:"approved_at" json:"approved_at"`
+		Items        []StockCountItem `db:"-" json:"items,omitempty"`
+		Location     *Location        `db:"-" json:"location,omitempty"`
+	}
+
+	// StockCountItem is a lot of a count. CountedQty is nil until counted, lots
+	// found that were not in the snapshot have an ExpectedQty of zero.
+	StockCountItem struct {
+		CommonModel
+		StockCountID uint        `db:"stock_count_id" json:"stock_count_id" gorm:"uniqueIndex:idx_stock_count_item_lot"`
+		ProductID    uint        `db:"product_id" json:"product_id" gorm:"index"`
+		ProductLotID uint        `db:"product_lot_id" json:"product_lot_id" gorm:"uniqueIndex:idx_stock_count_item_lot"`
+		ExpectedQty  float64     `db:"expected_qty" json:"expected_qty"`
+		CountedQty   *float64    `db:"counted_qty" json:"counted_qty"`
+		ReasonCode   string      `db:"reason_code" json:"reason_code"`
+		Note         string      `db:"note" json:"note"`
+		Product      *Product    `db:"-" json:"product,omitempty"`
+		ProductLot   *ProductLot `db:"-" json:"product_lot,omitempty"`
+	}
+
+	// StockAdjustment corrects the stock of a location, every item is posted
+	// to the stock ledger.
+	StockAdjustment struct {
+		CommonModel
+		TeamID       uint                  `db:"team_id" json:"team_id" gorm:"uniqueIndex:idx_stock_adjustment_team_code"`
+		Code         string                `db:"code" json:"code" gorm:"uniqueIndex:idx_stock_adjustment_team_code"`
+		StockCountID *uint                 `db:"stock_count_id" json:"stock_count_id" gorm:"index"`
+		LocationID   uint                  `db:"location_id" json:"location_id"`
+		Note         string                `db:"note" json:"note"`
+		CreatedByID  uint                  `db:"created_by_id" json:"created_by_id"`
+		Items        []StockAdjustmentItem `db:"-" json:"items,omitempty"`
+		Location     *Location             `db:"-" json:"location,omitempty"`
+	}
+
+	// StockAdjustmentItem is the quantity added to a lot, negative when taken out.
+	StockAdjustmentItem struct {
+		CommonModel
+		StockAdjustmentID uint        `db:"stock_adjustment_id" json:"stock_adjustment_id" gorm:"index"`
+		ProductID         uint        `db:"product_id" json:"product_id"`
+		ProductLotID      uint        `db:"product_lot_id" json:"product_lot_id"`
+		Quantity          float64     `db:"quantity" json:"quantity"`
+		ReasonCode        string      `db:"reason_code" json:"reason_code"`
+		Note              string      `db:"note" json:"note"`
+		Product           *Product    `db:"-" json:"product,omitempty"`
+		ProductLot        *ProductLot `db:"-" json:"product_lot,omitempty"`
+	}
+)
@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// reason of a StockMovement
+const (
+	StockReasonOpening  = "opening" // remaining quantity of a lot received before the ledger existed
+	StockReasonReceive  = "receive"
+	StockReasonIssue    = "issue"
+	StockReasonSale     = "sale"
+	StockReasonPurchase = "purchase"
+	StockReasonReturn   = "return"
+	StockReasonTransfer = "transfer"
+	StockReasonRelocate = "relocate" // stock recorded before locations existed, moved to the default location
+	StockReasonAdjust   = "adjust"   // a posted StockAdjustment, its items tell why
+)
+
+type (
+	// StockMovement is an entry of the append-only stock ledger, every posting
+	// that changes ProductLot.RemainingQty writes one in the same database
+	// transaction. Quantity is negative when stock leaves the lot or location,
+	// Balance is the running balance of the lot over all locations after the
+	// movement. LocationID is nil for stock recorded before locations existed.
+	// Rows are never updated or deleted, MigrateDb installs a trigger refusing it.
+	StockMovement struct {
+		CommonModel
+		TeamID       uint        `db:"team_id" json:"team_id" gorm:"index"`
+		ProductID    uint        `db:"product_id" json:"product_id" gorm:"index"`
+		ProductLotID uint        `db:"product_lot_id" json:"product_lot_id" gorm:"index:idx_stock_movement_lot"`
+		LocationID   *uint       `db:"location_id" json:"location_id" gorm:"index"`
+		Quantity     float64     `db:"quantity" json:"quantity"`
+		Balance      float64     `db:"balance" json:"balance"`
+		Reason       string      `db:"reason" json:"reason"`
+		RefType      EntityType  `db:"ref_type" json:"ref_type"` // the posting document, e.g. a transaction type
+		RefID        *uint       `db:"ref_id" json:"ref_id"`
+		MovedAt      time.Time   `db:"moved_at" json:"moved_at" gorm:"index:idx_stock_movement_lot"`
+		Product      *Product    `db:"-" json:"product,omitempty"`
+		ProductLot   *ProductLot `db:"-" json:"product_lot,omitempty"`
+		Location     *Location   `db:"-" json:"location,omitempty"`
+	}
+)
@@ -0,0 +1,44 @@
+package model
+
+import "database/sql"
+
+// status of a StockTransfer
+const (
+	StockTransferStatusDraft     = "draft"
+	StockTransferStatusInTransit = "in_transit" // shipped, held in the transit location
+	StockTransferStatusReceived  = "received"
+	StockTransferStatusCancelled = "cancelled"
+)
+
+type (
+	// StockTransfer moves stock between two locations of a team. Shipping takes
+	// the items out of FromLocationID into the transit location, receiving puts
+	// them into ToLocationID.
+	StockTransfer struct {
+		CommonModel
+		TeamID         uint                `db:"team_id" json:"team_id" gorm:"uniqueIndex:idx_stock_transfer_team_code"`
+		Code           string              `db:"code" json:"code" gorm:"uniqueIndex:idx_stock_transfer_team_code"`
+		Status         string              `db:"status" json:"status" gorm:"index"`
+		FromLocationID uint                `db:"from_location_id" json:"from_location_id"`
+		ToLocationID   uint                `db:"to_location_id" json:"to_location_id"`
+		Note           string              `db:"note" json:"note"`
+		CreatedByID    uint                `db:"created_by_id" json:"created_by_id"`
+		ShippedAt      sql.NullTime        `db:"shipped_at" json:"shipped_at"`
+		ReceivedAt     sql.NullTime        `db:"received_at" json:"received_at"`
+		Items          []StockTransferItem `db:"-" json:"items,omitempty"`
+		FromLocation   *Location           `db:"-" json:"from_location,omitempty"`
+		ToLocation     *Location           `db:"-" json:"to_location,omitempty"`
+	}
+
+	// StockTransferItem is a line of a transfer. Without ProductLotID the lots are
+	// picked first-expired-first-out on shipping and the item is split per lot.
+	StockTransferItem struct {
+		CommonModel
+		StockTransferID uint        `db:"stock_transfer_id" json:"stock_transfer_id" gorm:"index"`
+		ProductID       uint        `db:"product_id" json:"product_id" gorm:"index"`
+		ProductLotID    *uint       `db:"product_lot_id" json:"product_lot_id"`
+		Quantity        float64     `db:"quantity" json:"quantity"`
+		Product         *Product    `db:"-" json:"product,omitempty"`
+		ProductLot      *ProductLot `db:"-" json:"product_lot,omitempty"`
+	}
+)
@@ -0,0 +1,64 @@
+package model
+
+import "database/sql"
+
+// status of a Transaction, see service.TransactionService for the transitions
+const (
+	TransactionStatusDraft     = "draft"
+	TransactionStatusPending   = "pending"
+	TransactionStatusApproved  = "approved" // stock is posted
+	TransactionStatusRejected  = "rejected"
+	TransactionStatusCancelled = "cancelled"
+)
+
+type (
+	// Transaction moves stock of a team, Type is EntityTypeTransactionSale (issue),
+	// EntityTypeTransactionPurchase (receive) or EntityTypeTransactionReturn, a
+	// return references the approved sale in ParentID. LocationID is where a
+	// purchase or return puts stock and the only location a sale takes from, the
+	// default location of the team and any storage location when nil. PartnerID
+	// is the supplier of a purchase or the customer of a sale or return.
+	Transaction struct {
+		CommonModel
+		TeamID       uint              `db:"team_id" json:"team_id" gorm:"uniqueIndex:idx_transaction_team_code"`
+		Code         string            `db:"code" json:"code" gorm:"uniqueIndex:idx_transaction_team_code"`
+		Type         EntityType        `db:"type" json:"type" gorm:"index"`
+		Status       string            `db:"status" json:"status" gorm:"index"`
+		ParentID     *uint             `db:"parent_id" json:"parent_id" gorm:"index"`
+		LocationID   *uint             `db:"location_id" json:"location_id"`
+		PartnerID    *uint             `db:"partner_id" json:"partner_id" gorm:"index"`
+		Note         string            `db:"note" json:"note"`
+		CreatedByID  uint              `db:"created_by_id" json:"created_by_id"`
+		ApprovedByID *uint             `db:"approved_by_id" json:"approved_by_id"` // who approved or rejected
+		ApprovedAt   sql.NullTime      `db:"approved_at" json:"approved_at"`
+		Items        []TransactionItem `db:"-" json:"items,omitempty"`
+		Parent       *Transaction      `db:"-" json:"parent,omitempty"`
+		Partner      *Partner          `db:"-" json:"partner,omitempty"`
+	}
+
+	// TransactionItem is a line of a transaction. Once approved every item names
+	// the lot and location it moved: sale items are split per lot and location
+	// picked first-expired-first-out, purchase items create their lot from
+	// LotCode and the dates. Quantity and Price are in the base unit of the
+	// product, UomQuantity is the quantity as entered in UomID, one of which is
+	// UomFactor base units. Cost is the cost of goods of an approved sale item.
+	TransactionItem struct {
+		CommonModel
+		TransactionID   uint         `db:"transaction_id" json:"transaction_id" gorm:"index"`
+		ProductID       uint         `db:"product_id" json:"product_id" gorm:"index"`
+		ProductLotID    *uint        `db:"product_lot_id" json:"product_lot_id" gorm:"index"`
+		LocationID      *uint        `db:"location_id" json:"location_id"`
+		Quantity        float64      `db:"quantity" json:"quantity"`
+		Price           float64      `db:"price" json:"price"`
+		UomID           *uint        `db:"uom_id" json:"uom_id"`
+		UomQuantity     float64      `db:"uom_quantity" json:"uom_quantity"`
+		UomFactor       float64      `db:"uom_factor" json:"uom_factor" gorm:"default:1"`
+		Cost            float64      `db:"cost" json:"cost"`
+		LotCode         string       `db:"lot_code" json:"lot_code"`
+		ManufactureDate sql.NullTime `db:"manufacture_date" json:"manufacture_date" gorm:"type:date"`
+		ExpiryDate      sql.NullTime `db:"expiry_date" json:"expiry_date" gorm:"type:date"`
+		Product         *Product     `db:"-" json:"product,omitempty"`
+		ProductLot      *ProductLot  `db:"-" json:"product_lot,omitempty"`
+		Uom             *Uom         `db:"-" json:"uom,omitempty"`
+	}
+)
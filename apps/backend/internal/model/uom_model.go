@@ -0,0 +1,21 @@
+package model
+
+type (
+	// Uom is a unit of measure in the catalog of a team, Name is unique within the team.
+	Uom struct {
+		CommonModel
+		TeamID      uint   `db:"team_id" json:"team_id" gorm:"uniqueIndex:idx_uom_team_name"`
+		Name        string `db:"name" json:"name" gorm:"uniqueIndex:idx_uom_team_name"`
+		Description string `db:"description" json:"description"`
+	}
+
+	// ProductUom is an alternate unit of a product, one of it is Factor base
+	// units of the product (1 box = 12 pieces has a Factor of 12).
+	ProductUom struct {
+		CommonModel
+		ProductID uint    `db:"product_id" json:"product_id" gorm:"uniqueIndex:idx_product_uom"`
+		UomID     uint    `db:"uom_id" json:"uom_id" gorm:"uniqueIndex:idx_product_uom;index"`
+		Factor    float64 `db:"factor" json:"factor"`
+		Uom       *Uom    `db:"-" json:"uom,omitempty"`
+	}
+)
@@ -0,0 +1,33 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+)
+
+type (
+	// Upload is a resumable (tus) upload, chunks are kept as UploadPart
+	// objects in storage until the upload is complete.
+	Upload struct {
+		CommonModel
+		UUID        string       `db:"uuid" json:"uuid" gorm:"uniqueIndex"`
+		UserID      uint         `db:"user_id" json:"user_id"`
+		Length      int64        `db:"length" json:"length"`
+		Offset      int64        `db:"offset" json:"offset"`
+		Metadata    string       `db:"metadata" json:"metadata"` // raw Upload-Metadata header
+		Filename    string       `db:"filename" json:"filename"`
+		Target      string       `db:"target" json:"target"` // hook run when the upload is complete
+		ExpiresAt   time.Time    `db:"expires_at" json:"expires_at"`
+		CompletedAt sql.NullTime `db:"completed_at" json:"completed_at"`
+		ResultID    uint         `db:"result_id" json:"result_id"` // id of the record created by the hook
+		Parts       []UploadPart `db:"-" json:"parts,omitempty"`
+	}
+
+	UploadPart struct {
+		CommonModel
+		UploadID uint   `db:"upload_id" json:"upload_id" gorm:"index"`
+		Offset   int64  `db:"offset" json:"offset"`
+		Size     int64  `db:"size" json:"size"`
+		Key      string `db:"key" json:"key"`
+	}
+)
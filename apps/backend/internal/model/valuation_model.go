@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+// costing method of an InventorySetting
+const (
+	CostingMethodFifo    = "fifo"    // issues are costed from the oldest cost layers
+	CostingMethodAverage = "average" // issues are costed at the weighted moving average
+)
+
+type (
+	// InventorySetting holds the inventory settings of a team, teams without
+	// one use CostingMethodFifo.
+	InventorySetting struct {
+		CommonModel
+		TeamID        uint   `db:"team_id" json:"team_id" gorm:"uniqueIndex"`
+		CostingMethod string `db:"costing_method" json:"costing_method"`
+	}
+
+	// CostLayer is the cost of a receipt of a lot, FIFO issues consume the
+	// remaining quantity of the layers of a product in ReceivedAt order.
+	// UnitCost is per base unit of the product.
+	CostLayer struct {
+		CommonModel
+		TeamID       uint      `db:"team_id" json:"team_id" gorm:"index"`
+		ProductID    uint      `db:"product_id" json:"product_id" gorm:"index:idx_cost_layer_product"`
+		ProductLotID uint      `db:"product_lot_id" json:"product_lot_id" gorm:"index"`
+		Quantity     float64   `db:"quantity" json:"quantity"`
+		RemainingQty float64   `db:"remaining_qty" json:"remaining_qty"`
+		UnitCost     float64   `db:"unit_cost" json:"unit_cost"`
+		ReceivedAt   time.Time `db:"received_at" json:"received_at" gorm:"index:idx_cost_layer_product"`
+	}
+
+	// ProductCost is the weighted moving average cost of a product, Value over
+	// Quantity. Its row is locked while stock of the product is valued.
+	ProductCost struct {
+		CommonModel
+		TeamID    uint    `db:"team_id" json:"team_id" gorm:"index"`
+		ProductID uint    `db:"product_id" json:"product_id" gorm:"uniqueIndex"`
+		Quantity  float64 `db:"quantity" json:"quantity"`
+		Value     float64 `db:"value" json:"value"`
+	}
+
+	// CostEntry is an entry of the append-only cost journal, one per receipt or
+	// issue of a lot. Quantity and Amount are negative for issues, the Amount
+	// of sale issues is the cost of goods sold.
+	CostEntry struct {
+		CommonModel
+		TeamID       uint       `db:"team_id" json:"team_id" gorm:"index:idx_cost_entry_team_posted"`
+		ProductID    uint       `db:"product_id" json:"product_id" gorm:"index"`
+		ProductLotID uint       `db:"product_lot_id" json:"product_lot_id"`
+		Method       string     `db:"method" json:"method"`
+		Quantity     float64    `db:"quantity" json:"quantity"`
+		Amount       float64    `db:"amount" json:"amount"`
+		Reason       string     `db:"reason" json:"reason"`
+		RefType      EntityType `db:"ref_type" json:"ref_type"`
+		RefID        *uint      `db:"ref_id" json:"ref_id"`
+		PostedAt     time.Time  `db:"posted_at" json:"posted_at" gorm:"index:idx_cost_entry_team_posted"`
+		Product      *Product   `db:"-" json:"product,omitempty"`
+	}
+)
@@ -0,0 +1,46 @@
+package model
+
+// type of a Location
+const (
+	LocationTypeStorage = "storage"
+	LocationTypeTransit = "transit" // stock of shipped transfers not yet received
+)
+
+type (
+	// Warehouse groups the locations of a team.
+	Warehouse struct {
+		CommonModel
+		TeamID    uint       `db:"team_id" json:"team_id" gorm:"uniqueIndex:idx_warehouse_team_code"`
+		Code      string     `db:"code" json:"code" gorm:"uniqueIndex:idx_warehouse_team_code"`
+		Name      string     `db:"name" json:"name"`
+		Address   string     `db:"address" json:"address"`
+		Locations []Location `db:"-" json:"locations,omitempty"`
+	}
+
+	// Location is a bin of a warehouse stock is held in. Every team has one
+	// default storage location, receipts without a location go there, and one
+	// transit location holding shipped transfers.
+	Location struct {
+		CommonModel
+		TeamID      uint       `db:"team_id" json:"team_id" gorm:"index"`
+		WarehouseID uint       `db:"warehouse_id" json:"warehouse_id" gorm:"uniqueIndex:idx_location_warehouse_code"`
+		Code        string     `db:"code" json:"code" gorm:"uniqueIndex:idx_location_warehouse_code"`
+		Name        string     `db:"name" json:"name"`
+		Type        string     `db:"type" json:"type"`
+		IsDefault   bool       `db:"is_default" json:"is_default"`
+		Warehouse   *Warehouse `db:"-" json:"warehouse,omitempty"`
+	}
+
+	// LotStock is the quantity of a lot held in a location, a cache of the stock
+	// ledger like ProductLot.RemainingQty, which is the sum over all locations.
+	LotStock struct {
+		CommonModel
+		TeamID       uint        `db:"team_id" json:"team_id" gorm:"index"`
+		ProductID    uint        `db:"product_id" json:"product_id" gorm:"index"`
+		ProductLotID uint        `db:"product_lot_id" json:"product_lot_id" gorm:"uniqueIndex:idx_lot_stock_location"`
+		LocationID   uint        `db:"location_id" json:"location_id" gorm:"uniqueIndex:idx_lot_stock_location;index"`
+		Quantity     float64     `db:"quantity" json:"quantity"`
+		ProductLot   *ProductLot `db:"-" json:"product_lot,omitempty"`
+		Location     *Location   `db:"-" json:"location,omitempty"`
+	}
+)
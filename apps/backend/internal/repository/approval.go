@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+)
+
+type (
+	ApprovalStep interface {
+		Create(ctx context.Context, tx *gorm.DB, step *model.ApprovalStep) error
+		Update(ctx context.Context, tx *gorm.DB, step *model.ApprovalStep) error
+		Delete(ctx context.Context, tx *gorm.DB, teamId, stepId uint) error
+		FindById(ctx context.Context, tx *gorm.DB, teamId, stepId uint) (*model.ApprovalStep, error)
+		FindByTeam(ctx context.Context, tx *gorm.DB, teamId uint) ([]model.ApprovalStep, error)
+		// FindChain returns the steps applying to a transaction of transactionType
+		// with the total amount, in Level order.
+		FindChain(ctx context.Context, tx *gorm.DB, teamId uint, transactionType model.EntityType, amount float64) ([]model.ApprovalStep, error)
+	}
+	approvalStep struct {
+		db *gorm.DB
+	}
+
+	TransactionApproval interface {
+		Create(ctx context.Context, tx *gorm.DB, approvals []model.TransactionApproval) error
+		FindById(ctx context.Context, tx *gorm.DB, approvalId uint) (*model.TransactionApproval, error)
+		// FindByTransaction returns the history of the transaction with the approvers.
+		FindByTransaction(ctx context.Context, tx *gorm.DB, transactionId uint) ([]model.TransactionApproval, error)
+		// FindPending returns the undecided steps of the transaction in Level order.
+		FindPending(ctx context.Context, tx *gorm.DB, transactionId uint) ([]model.TransactionApproval, error)
+		Decide(ctx context.Context, tx *gorm.DB, approvalId uint, status, remark string, decidedAt sql.NullTime) error
+		// CancelPending cancels the undecided steps of the transaction.
+		CancelPending(ctx context.Context, tx *gorm.DB, transactionId uint) error
+	}
+	transactionApproval struct {
+		db *gorm.DB
+	}
+)
+
+// Create implements ApprovalStep.
+func (a *approvalStep) Create(ctx context.Context, tx *gorm.DB, step *model.ApprovalStep) error {
+	if tx == nil {
+		tx = a.db
+	}
+	return gorm.G[model.ApprovalStep](tx).Create(ctx, step)
+}
+
+// Update implements ApprovalStep.
+func (a *approvalStep) Update(ctx context.Context, tx *gorm.DB, step *model.ApprovalStep) error {
+	if tx == nil {
+		tx = a.db
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.ApprovalStep{}).
+		Where("team_id = ? AND id = ?", step.TeamID, step.ID).
+		Updates(map[string]any{
+			"transaction_type": step.TransactionType,
+			"level":            step.Level,
+			"min_amount":       step.MinAmount,
+			"approver_id":      step.ApproverID,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete implements ApprovalStep.
+func (a *approvalStep) Delete(ctx context.Context, tx *gorm.DB, teamId, stepId uint) error {
+	if tx == nil {
+		tx = a.db
+	}
+	rows, err := gorm.G[model.ApprovalStep](tx).Where("team_id = ? AND id = ?", teamId, stepId).Delete(ctx)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindById implements ApprovalStep.
+func (a *approvalStep) FindById(ctx context.Context, tx *gorm.DB, teamId, stepId uint) (*model.ApprovalStep, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	var m model.ApprovalStep
+	if err := tx.WithContext(ctx).Preload("Approver").Where("team_id = ? AND id = ?", teamId, stepId).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindByTeam implements ApprovalStep.
+func (a *approvalStep) FindByTeam(ctx context.Context, tx *gorm.DB, teamId uint) ([]model.ApprovalStep, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	var steps []model.ApprovalStep
+	err := tx.WithContext(ctx).
+		Preload("Approver").
+		Where("team_id = ?", teamId).
+		Order("transaction_type ASC, level ASC, id ASC").
+		Find(&steps).Error
+	return steps, err
+}
+
+// FindChain implements ApprovalStep.
+func (a *approvalStep) FindChain(ctx context.Context, tx *gorm.DB, teamId uint, transactionType model.EntityType, amount float64) ([]model.ApprovalStep, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	return gorm.G[model.ApprovalStep](tx).
+		Where("team_id = ? AND (transaction_type = ? OR transaction_type = '') AND min_amount <= ?", teamId, transactionType, amount).
+		Order("level ASC, id ASC").
+		Find(ctx)
+}
+
+// Create implements TransactionApproval.
+func (t *transactionApproval) Create(ctx context.Context, tx *gorm.DB, approvals []model.TransactionApproval) error {
+	if tx == nil {
+		tx = t.db
+	}
+	if len(approvals) == 0 {
+		return nil
+	}
+	return gorm.G[model.TransactionApproval](tx).CreateInBatches(ctx, &approvals, len(approvals))
+}
+
+// FindById implements TransactionApproval.
+func (t *transactionApproval) FindById(ctx context.Context, tx *gorm.DB, approvalId uint) (*model.TransactionApproval, error) {
+	if tx == nil {
+		tx = t.db
+	}
+	m, err := gorm.G[model.TransactionApproval](tx).Where("id = ?", approvalId).First(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindByTransaction implements TransactionApproval.
+func (t *transactionApproval) FindByTransaction(ctx context.Context, tx *gorm.DB, transactionId uint) ([]model.TransactionApproval, error) {
+	if tx == nil {
+		tx = t.db
+	}
+	var approvals []model.TransactionApproval
+	err := tx.WithContext(ctx).
+		Preload("Approver").
+		Where("transaction_id = ?", transactionId).
+		Order("id ASC").
+		Find(&approvals).Error
+	return approvals, err
+}
+
+// FindPending implements TransactionApproval.
+func (t *transactionApproval) FindPending(ctx context.Context, tx *gorm.DB, transactionId uint) ([]model.TransactionApproval, error) {
+	if tx == nil {
+		tx = t.db
+	}
+	return gorm.G[model.TransactionApproval](tx).
+		Where("transaction_id = ? AND status = ?", transactionId, model.ApprovalStatusPending).
+		Order("level ASC, id ASC").
+		Find(ctx)
+}
+
+// Decide implements TransactionApproval.
+func (t *transactionApproval) Decide(ctx context.Context, tx *gorm.DB, approvalId uint, status, remark string, decidedAt sql.NullTime) error {
+	if tx == nil {
+		tx = t.db
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.TransactionApproval{}).
+		Where("id = ? AND status = ?", approvalId, model.ApprovalStatusPending).
+		Updates(map[string]any{
+			"status":     status,
+			"remark":     remark,
+			"decided_at": decidedAt,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// CancelPending implements TransactionApproval.
+func (t *transactionApproval) CancelPending(ctx context.Context, tx *gorm.DB, transactionId uint) error {
+	if tx == nil {
+		tx = t.db
+	}
+	_, err := gorm.G[model.TransactionApproval](tx).
+		Where("transaction_id = ? AND status = ?", transactionId, model.ApprovalStatusPending).
+		Update(ctx, "status", model.ApprovalStatusCancelled)
+	return err
+}
+
+func NewApprovalStep(db *gorm.DB) ApprovalStep {
+	return &approvalStep{
+		db: db,
+	}
+}
+
+func NewTransactionApproval(db *gorm.DB) TransactionApproval {
+	return &transactionApproval{
+		db: db,
+	}
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+)
+
+type (
+	Attachment interface {
+		Create(ctx context.Context, tx *gorm.DB, attachment *model.Attachment) error
+		FindById(ctx context.Context, tx *gorm.DB, attachmentId uint) (*model.Attachment, error)
+		FindByEntity(ctx context.Context, tx *gorm.DB, entityType string, entityId uint) ([]model.Attachment, error)
+		Delete(ctx context.Context, tx *gorm.DB, attachmentId uint) error
+		// SumSizeByUploaderId returns the bytes uploaded as attachments by userId.
+		SumSizeByUploaderId(ctx context.Context, tx *gorm.DB, userId uint) (int64, error)
+		FindByScanStatus(ctx context.Context, tx *gorm.DB, status string, limit int) ([]model.Attachment, error)
+		// UpdateScan saves the scan fields and the key, which changes on quarantine.
+		UpdateScan(ctx context.Context, tx *gorm.DB, attachment *model.Attachment) error
+		// QuarantineByHash marks the other attachments stored at from with the content
+		// of the infected attachment like it, key included, and returns how many it marked.
+		QuarantineByHash(ctx context.Context, tx *gorm.DB, from string, attachment *model.Attachment) (int64, error)
+	}
+	attachment struct {
+		db *gorm.DB
+	}
+)
+
+// Create implements Attachment.
+func (a *attachment) Create(ctx context.Context, tx *gorm.DB, attachment *model.Attachment) error {
+	if tx == nil {
+		tx = a.db
+	}
+	return gorm.G[model.Attachment](tx).Create(ctx, attachment)
+}
+
+// FindById implements Attachment.
+func (a *attachment) FindById(ctx context.Context, tx *gorm.DB, attachmentId uint) (*model.Attachment, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	m, err := gorm.G[model.Attachment](tx).Where("id = ?", attachmentId).First(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindByEntity implements Attachment.
+func (a *attachment) FindByEntity(ctx context.Context, tx *gorm.DB, entityType string, entityId uint) ([]model.Attachment, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	return gorm.G[model.Attachment](tx).
+		Where("entity_type = ? AND entity_id = ?", entityType, entityId).
+		Order("id ASC").
+		Find(ctx)
+}
+
+// Delete implements Attachment.
+func (a *attachment) Delete(ctx context.Context, tx *gorm.DB, attachmentId uint) error {
+	if tx == nil {
+		tx = a.db
+	}
+	_, err := gorm.G[model.Attachment](tx).Where("id = ?", attachmentId).Delete(ctx)
+	return err
+}
+
+// SumSizeByUploaderId implements Attachment.
+func (a *attachment) SumSizeByUploaderId(ctx context.Context, tx *gorm.DB, userId uint) (int64, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	var size int64
+	err := tx.WithContext(ctx).
+		Model(&model.Attachment{}).
+		Where("uploader_id = ?", userId).
+		Select("COALESCE(SUM(size), 0)").
+		Scan(&size).Error
+	return size, err
+}
+
+// FindByScanStatus implements Attachment.
+func (a *attachment) FindByScanStatus(ctx context.Context, tx *gorm.DB, status string, limit int) ([]model.Attachment, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	return gorm.G[model.Attachment](tx).Where("scan_status = ?", status).Order("id ASC").Limit(limit).Find(ctx)
+}
+
+// UpdateScan implements Attachment.
+func (a *attachment) UpdateScan(ctx context.Context, tx *gorm.DB, attachment *model.Attachment) error {
+	if tx == nil {
+		tx = a.db
+	}
+	return tx.WithContext(ctx).
+		Model(&model.Attachment{}).
+		Where("id = ?", attachment.ID).
+		Updates(map[string]any{
+			"key":            attachment.Key,
+			"scan_status":    attachment.ScanStatus,
+			"scan_signature": attachment.ScanSignature,
+			"scanned_at":     attachment.ScannedAt,
+		}).Error
+}
+
+// QuarantineByHash implements Attachment.
+func (a *attachment) QuarantineByHash(ctx context.Context, tx *gorm.DB, from string, attachment *model.Attachment) (int64, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	if attachment.Hash == "" {
+		return 0, nil
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.Attachment{}).
+		Where("hash = ? AND key = ? AND id <> ? AND scan_status <> ?", attachment.Hash, from, attachment.ID, model.ScanStatusInfected).
+		Updates(map[string]any{
+			"key":            attachment.Key,
+			"scan_status":    attachment.ScanStatus,
+			"scan_signature": attachment.ScanSignature,
+			"scanned_at":     attachment.ScannedAt,
+		})
+	return res.RowsAffected, res.Error
+}
+
+func NewAttachment(db *gorm.DB) Attachment {
+	return &attachment{db: db}
+}
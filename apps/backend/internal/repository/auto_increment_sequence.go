@@ -3,15 +3,30 @@ package repository
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"github.com/suttapak/starter/internal/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type (
 	AutoIncrementSequence interface {
-		GetNextSequence(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId uint, entityId uint) (uint, error)
+		// GetNextSequence returns the next number in period, restarting at 1 when
+		// the sequence was last counted in another period.
+		GetNextSequence(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId uint, entityId uint, period string) (uint, error)
+		// FindSequence returns the sequence without counting, gorm.ErrRecordNotFound
+		// before its first number.
+		FindSequence(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId uint, entityId uint) (*model.AutoIncrementSequence, error)
+		// FindSequenceForUpdate is FindSequence locking the sequence until tx ends.
+		FindSequenceForUpdate(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId uint, entityId uint) (*model.AutoIncrementSequence, error)
+		// FindAllByTeam returns the sequences of the team, with the lot sequences
+		// of its products.
+		FindAllByTeam(ctx context.Context, tx *gorm.DB, teamId uint) ([]model.AutoIncrementSequence, error)
+		// ReserveSequence takes count numbers at once like GetNextSequence and
+		// returns the last of them.
+		ReserveSequence(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId uint, entityId uint, count uint, period string) (uint, error)
+		// SetSequence makes sequence the last number taken in period.
+		SetSequence(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId uint, entityId uint, sequence uint, period string) error
 		ResetSequence(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId uint, entityId uint) error
 	}
 
@@ -20,52 +35,101 @@ type (
 	}
 )
 
-// GetNextSequence atomically increments and returns the next sequence number
-func (a *autoIncrementSequence) GetNextSequence(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId uint, entityId uint) (uint, error) {
+// GetNextSequence counts the next number with one upsert, the conflict on
+// idx_sequence_key row locks the sequence until tx ends. Without tx the number
+// is committed at once and a failed caller leaves a gap, within the caller's
+// transaction a rollback gives the number back and concurrent callers wait.
+func (a *autoIncrementSequence) GetNextSequence(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId uint, entityId uint, period string) (uint, error) {
+	return a.ReserveSequence(ctx, tx, entityType, teamId, entityId, 1, period)
+}
+
+// ReserveSequence implements AutoIncrementSequence.
+func (a *autoIncrementSequence) ReserveSequence(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId uint, entityId uint, count uint, period string) (uint, error) {
 	if tx == nil {
 		tx = a.db
 	}
+	var sequence uint
+	err := tx.WithContext(ctx).Raw(`
+INSERT INTO auto_increment_sequences (entity_type, team_id, entity_id, sequence, period, created_at, updated_at)
+VALUES (@entity_type, @team_id, @entity_id, @count, @period, NOW(), NOW())
+ON CONFLICT (entity_type, team_id, entity_id) DO UPDATE SET
+	sequence = CASE WHEN auto_increment_sequences.period = EXCLUDED.period
+		THEN auto_increment_sequences.sequence + @count ELSE @count END,
+	period = EXCLUDED.period,
+	updated_at = EXCLUDED.updated_at
+RETURNING sequence
+`, map[string]any{
+		"entity_type": entityType,
+		"team_id":     teamId,
+		"entity_id":   entityId,
+		"count":       count,
+		"period":      period,
+	}).Scan(&sequence).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to get next sequence: %w", err)
+	}
+	return sequence, nil
+}
 
-	var sequence model.AutoIncrementSequence
+// SetSequence implements AutoIncrementSequence.
+func (a *autoIncrementSequence) SetSequence(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId uint, entityId uint, sequence uint, period string) error {
+	if tx == nil {
+		tx = a.db
+	}
+	m := model.AutoIncrementSequence{
+		EntityType: entityType,
+		TeamID:     teamId,
+		EntityID:   entityId,
+		Sequence:   sequence,
+		Period:     period,
+	}
+	return tx.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "entity_type"}, {Name: "team_id"}, {Name: "entity_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"sequence", "period", "updated_at"}),
+		}).
+		Create(&m).Error
+}
 
-	// Use SELECT FOR UPDATE to prevent race conditions
-	err := tx.WithContext(ctx).
-		Set("gorm:query_option", "FOR UPDATE").
+// FindSequence implements AutoIncrementSequence.
+func (a *autoIncrementSequence) FindSequence(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId uint, entityId uint) (*model.AutoIncrementSequence, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	m, err := gorm.G[model.AutoIncrementSequence](tx).
 		Where("entity_type = ? AND team_id = ? AND entity_id = ?", entityType, teamId, entityId).
-		First(&sequence).Error
-
+		First(ctx)
 	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			// Create new sequence starting at 1
-			sequence = model.AutoIncrementSequence{
-				EntityType: entityType,
-				TeamID:     teamId,
-				EntityID:   entityId,
-				Sequence:   1,
-			}
-			if err := tx.Create(&sequence).Error; err != nil {
-				return 0, fmt.Errorf("failed to create sequence: %w", err)
-			}
-			return 1, nil
-		}
-		return 0, fmt.Errorf("failed to get sequence: %w", err)
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindSequenceForUpdate implements AutoIncrementSequence.
+func (a *autoIncrementSequence) FindSequenceForUpdate(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId uint, entityId uint) (*model.AutoIncrementSequence, error) {
+	if tx == nil {
+		tx = a.db
 	}
-	if time.Now().Truncate(24 * time.Hour).Equal(sequence.UpdatedAt.Truncate(24 * time.Hour)) {
-		// Increment sequence
-		sequence.Sequence++
-	} else {
-		if entityType == model.EntityTypeProduct || entityType == model.EntityTypeLot {
-			sequence.Sequence++
-		} else {
-			// reset to 1 in this date
-			sequence.Sequence = 1
-		}
+	m, err := gorm.G[model.AutoIncrementSequence](tx, clause.Locking{Strength: clause.LockingStrengthUpdate}).
+		Where("entity_type = ? AND team_id = ? AND entity_id = ?", entityType, teamId, entityId).
+		First(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return &m, nil
+}
 
-	if err := tx.Save(&sequence).Error; err != nil {
-		return 0, fmt.Errorf("failed to update sequence: %w", err)
+// FindAllByTeam implements AutoIncrementSequence.
+func (a *autoIncrementSequence) FindAllByTeam(ctx context.Context, tx *gorm.DB, teamId uint) ([]model.AutoIncrementSequence, error) {
+	if tx == nil {
+		tx = a.db
 	}
-	return sequence.Sequence, nil
+	return gorm.G[model.AutoIncrementSequence](tx).
+		Where("team_id = ? AND entity_type <> ?", teamId, model.EntityTypeLot).
+		Or("entity_type = ? AND entity_id IN (?)", model.EntityTypeLot,
+			tx.Model(&model.Product{}).Select("id").Where("team_id = ?", teamId)).
+		Order("entity_type, entity_id").
+		Find(ctx)
 }
 
 // ResetSequence resets the sequence counter to 0
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	Blob interface {
+		// FindByHash locks and returns the blob of hash, gorm.ErrRecordNotFound if there is none.
+		FindByHash(ctx context.Context, tx *gorm.DB, hash string) (*model.Blob, error)
+		FindByKey(ctx context.Context, tx *gorm.DB, key string) (*model.Blob, error)
+		// Acquire inserts blob with one reference, or adds a reference to the blob
+		// with the same hash. blob is filled with the stored row.
+		Acquire(ctx context.Context, tx *gorm.DB, blob *model.Blob) error
+		// AddRef adds delta to the reference count and returns the updated blob.
+		AddRef(ctx context.Context, tx *gorm.DB, blobId uint, delta int) (*model.Blob, error)
+		Delete(ctx context.Context, tx *gorm.DB, blobId uint) (deleted bool, err error)
+		FindUnreferenced(ctx context.Context, tx *gorm.DB) ([]model.Blob, error)
+		// IsKeyReferenced reports whether a blob, an image or an attachment points at key.
+		IsKeyReferenced(ctx context.Context, tx *gorm.DB, key string) (bool, error)
+	}
+	blob struct {
+		db *gorm.DB
+	}
+)
+
+// FindByHash implements Blob.
+func (b *blob) FindByHash(ctx context.Context, tx *gorm.DB, hash string) (*model.Blob, error) {
+	if tx == nil {
+		tx = b.db
+	}
+	var m model.Blob
+	err := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+		Where("hash = ?", hash).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindByKey implements Blob.
+func (b *blob) FindByKey(ctx context.Context, tx *gorm.DB, key string) (*model.Blob, error) {
+	if tx == nil {
+		tx = b.db
+	}
+	var m model.Blob
+	err := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+		Where("key = ?", key).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Acquire implements Blob.
+func (b *blob) Acquire(ctx context.Context, tx *gorm.DB, blob *model.Blob) error {
+	if tx == nil {
+		tx = b.db
+	}
+	blob.RefCount = 1
+	return tx.WithContext(ctx).
+		Clauses(
+			clause.OnConflict{
+				Columns: []clause.Column{{Name: "hash"}},
+				DoUpdates: clause.Assignments(map[string]any{
+					"ref_count":  gorm.Expr("blobs.ref_count + 1"),
+					"updated_at": time.Now(),
+				}),
+			},
+			clause.Returning{},
+		).
+		Create(blob).Error
+}
+
+// AddRef implements Blob.
+func (b *blob) AddRef(ctx context.Context, tx *gorm.DB, blobId uint, delta int) (*model.Blob, error) {
+	if tx == nil {
+		tx = b.db
+	}
+	var m model.Blob
+	err := tx.WithContext(ctx).
+		Model(&m).
+		Clauses(clause.Returning{}).
+		Where("id = ?", blobId).
+		Update("ref_count", gorm.Expr("ref_count + ?", delta)).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Delete implements Blob. Only unreferenced blobs are deleted, deleted reports whether the row was removed.
+func (b *blob) Delete(ctx context.Context, tx *gorm.DB, blobId uint) (deleted bool, err error) {
+	if tx == nil {
+		tx = b.db
+	}
+	rows, err := gorm.G[model.Blob](tx).Where("id = ? AND ref_count <= 0", blobId).Delete(ctx)
+	return rows > 0, err
+}
+
+// FindUnreferenced implements Blob.
+func (b *blob) FindUnreferenced(ctx context.Context, tx *gorm.DB) ([]model.Blob, error) {
+	if tx == nil {
+		tx = b.db
+	}
+	return gorm.G[model.Blob](tx).Where("ref_count <= 0").Find(ctx)
+}
+
+// IsKeyReferenced implements Blob.
+func (b *blob) IsKeyReferenced(ctx context.Context, tx *gorm.DB, key string) (bool, error) {
+	if tx == nil {
+		tx = b.db
+	}
+	var count int64
+	err := tx.WithContext(ctx).
+		Raw(`SELECT (SELECT COUNT(*) FROM blobs WHERE key = ?)
+			+ (SELECT COUNT(*) FROM images WHERE path = ?)
+			+ (SELECT COUNT(*) FROM attachments WHERE key = ?)`, key, key, key).
+		Scan(&count).Error
+	return count > 0, err
+}
+
+func NewBlob(db *gorm.DB) Blob {
+	return &blob{db: db}
+}
@@ -11,12 +11,81 @@ type (
 	Image interface {
 		Save(ctx context.Context, tx *gorm.DB, userId uint, image *model.Image) (*model.Image, error)
 		Delete(ctx context.Context, tx *gorm.DB, imageId uint) error
+		FindById(ctx context.Context, tx *gorm.DB, imageId uint) (*model.Image, error)
+		UpdateUrl(ctx context.Context, tx *gorm.DB, imageId uint, url string) error
+		// SumSizeByUserId returns the bytes used by the images of userId.
+		SumSizeByUserId(ctx context.Context, tx *gorm.DB, userId uint) (int64, error)
+		FindByScanStatus(ctx context.Context, tx *gorm.DB, status string, limit int) ([]model.Image, error)
+		// UpdateScan saves the scan fields and the path, which changes on quarantine.
+		UpdateScan(ctx context.Context, tx *gorm.DB, image *model.Image) error
+		// QuarantineByHash marks the other images stored at from with the content
+		// of the infected image like it, path included, and returns how many it marked.
+		QuarantineByHash(ctx context.Context, tx *gorm.DB, from string, image *model.Image) (int64, error)
 	}
 	image struct {
 		db *gorm.DB
 	}
 )
 
+// FindById implements Image.
+func (i *image) FindById(ctx context.Context, tx *gorm.DB, imageId uint) (*model.Image, error) {
+	if tx == nil {
+		tx = i.db
+	}
+	image, err := gorm.G[model.Image](tx).Where("id = ?", imageId).First(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+// UpdateUrl implements Image.
+func (i *image) UpdateUrl(ctx context.Context, tx *gorm.DB, imageId uint, url string) error {
+	if tx == nil {
+		tx = i.db
+	}
+	_, err := gorm.G[model.Image](tx).Where("id = ?", imageId).Update(ctx, "url", url)
+	return err
+}
+
+// SumSizeByUserId implements Image.
+func (i *image) SumSizeByUserId(ctx context.Context, tx *gorm.DB, userId uint) (int64, error) {
+	if tx == nil {
+		tx = i.db
+	}
+	var size float64
+	err := tx.WithContext(ctx).
+		Model(&model.Image{}).
+		Where("user_id = ?", userId).
+		Select("COALESCE(SUM(size), 0)").
+		Scan(&size).Error
+	return int64(size), err
+}
+
+// FindByScanStatus implements Image.
+func (i *image) FindByScanStatus(ctx context.Context, tx *gorm.DB, status string, limit int) ([]model.Image, error) {
+	if tx == nil {
+		tx = i.db
+	}
+	return gorm.G[model.Image](tx).Where("scan_status = ?", status).Order("id ASC").Limit(limit).Find(ctx)
+}
+
+// UpdateScan implements Image.
+func (i *image) UpdateScan(ctx context.Context, tx *gorm.DB, image *model.Image) error {
+	if tx == nil {
+		tx = i.db
+	}
+	return tx.WithContext(ctx).
+		Model(&model.Image{}).
+		Where("id = ?", image.ID).
+		Updates(map[string]any{
+			"path":           image.Path,
+			"scan_status":    image.ScanStatus,
+			"scan_signature": image.ScanSignature,
+			"scanned_at":     image.ScannedAt,
+		}).Error
+}
+
 // Delete implements Image.
 func (i *image) Delete(ctx context.Context, tx *gorm.DB, imageId uint) error {
 	if tx == nil {
@@ -35,6 +104,26 @@ func (i *image) Save(ctx context.Context, tx *gorm.DB, userId uint, image *model
 	return image, err
 }
 
+// QuarantineByHash implements Image.
+func (i *image) QuarantineByHash(ctx context.Context, tx *gorm.DB, from string, image *model.Image) (int64, error) {
+	if tx == nil {
+		tx = i.db
+	}
+	if image.Hash == "" {
+		return 0, nil
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.Image{}).
+		Where("hash = ? AND path = ? AND id <> ? AND scan_status <> ?", image.Hash, from, image.ID, model.ScanStatusInfected).
+		Updates(map[string]any{
+			"path":           image.Path,
+			"scan_status":    image.ScanStatus,
+			"scan_signature": image.ScanSignature,
+			"scanned_at":     image.ScannedAt,
+		})
+	return res.RowsAffected, res.Error
+}
+
 func NewImage(db *gorm.DB) Image {
 	return &image{
 		db: db,
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	LotStock interface {
+		// Add adds delta to the quantity of the lot in m.LocationID, creating the
+		// row when stock first arrives. It fails with gorm.ErrRecordNotFound
+		// instead of going below zero.
+		Add(ctx context.Context, tx *gorm.DB, m *model.LotStock, delta float64) error
+		// FindPickable locks the stock of the product in storage locations, lots
+		// first-expired-first-out, lots without expiry last. Lots expired on today
+		// are left out unless today is nil, locationId and lotId narrow the search
+		// when not zero.
+		FindPickable(ctx context.Context, tx *gorm.DB, productId uint, today *time.Time, locationId, lotId uint) ([]model.LotStock, error)
+		// FindByLocation returns the stock held in the location, of productIds only
+		// when not empty.
+		FindByLocation(ctx context.Context, tx *gorm.DB, locationId uint, productIds []uint) ([]model.LotStock, error)
+		// SumByLocation is the quantity of every lot held in the location.
+		SumByLocation(ctx context.Context, tx *gorm.DB, locationIds ...uint) (float64, error)
+		// Rebuild sets the stock of every lot and location from the stock ledger,
+		// teamId 0 means every team. It returns the number of rows written.
+		Rebuild(ctx context.Context, tx *gorm.DB, teamId uint) (int64, error)
+	}
+	lotStock struct {
+		db *gorm.DB
+	}
+)
+
+// Add implements LotStock.
+func (l *lotStock) Add(ctx context.Context, tx *gorm.DB, m *model.LotStock, delta float64) error {
+	if tx == nil {
+		tx = l.db
+	}
+	if delta < 0 {
+		res := tx.WithContext(ctx).
+			Model(&model.LotStock{}).
+			Where("product_lot_id = ? AND location_id = ? AND quantity + ? >= ?", m.ProductLotID, m.LocationID, delta, -StockTolerance).
+			Update("quantity", gorm.Expr("quantity + ?", delta))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	}
+	m.Quantity = delta
+	return tx.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "product_lot_id"}, {Name: "location_id"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"quantity":   gorm.Expr("lot_stocks.quantity + EXCLUDED.quantity"),
+				"updated_at": gorm.Expr("EXCLUDED.updated_at"),
+			}),
+		}).
+		Create(m).Error
+}
+
+// FindPickable implements LotStock.
+func (l *lotStock) FindPickable(ctx context.Context, tx *gorm.DB, productId uint, today *time.Time, locationId, lotId uint) ([]model.LotStock, error) {
+	if tx == nil {
+		tx = l.db
+	}
+	q := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate, Table: clause.Table{Name: "lot_stocks"}}).
+		Joins("JOIN product_lots ON product_lots.id = lot_stocks.product_lot_id").
+		Joins("JOIN locations ON locations.id = lot_stocks.location_id").
+		Where("lot_stocks.product_id = ? AND lot_stocks.quantity > 0 AND locations.type = ?", productId, model.LocationTypeStorage)
+	if today != nil {
+		q = q.Where("product_lots.expiry_date IS NULL OR product_lots.expiry_date >= ?", *today)
+	}
+	if locationId != 0 {
+		q = q.Where("lot_stocks.location_id = ?", locationId)
+	}
+	if lotId != 0 {
+		q = q.Where("lot_stocks.product_lot_id = ?", lotId)
+	}
+	var stocks []model.LotStock
+	err := q.
+		Preload("ProductLot").
+		Order("product_lots.expiry_date ASC NULLS LAST, product_lots.id ASC, lot_stocks.location_id ASC").
+		Find(&stocks).Error
+	return stocks, err
+}
+
+// FindByLocation implements LotStock.
+func (l *lotStock) FindByLocation(ctx context.Context, tx *gorm.DB, locationId uint, productIds []uint) ([]model.LotStock, error) {
+	if tx == nil {
+		tx = l.db
+	}
+	q := gorm.G[model.LotStock](tx).Where("location_id = ? AND quantity > ?", locationId, StockTolerance)
+	if len(productIds) > 0 {
+		q = q.Where("product_id IN ?", productIds)
+	}
+	return q.Order("product_id ASC, product_lot_id ASC").Find(ctx)
+}
+
+// SumByLocation implements LotStock.
+func (l *lotStock) SumByLocation(ctx context.Context, tx *gorm.DB, locationIds ...uint) (float64, error) {
+	if tx == nil {
+		tx = l.db
+	}
+	if len(locationIds) == 0 {
+		return 0, nil
+	}
+	var sum float64
+	err := tx.WithContext(ctx).
+		Model(&model.LotStock{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("location_id IN ?", locationIds).
+		Scan(&sum).Error
+	return sum, err
+}
+
+// Rebuild implements LotStock.
+func (l *lotStock) Rebuild(ctx context.Context, tx *gorm.DB, teamId uint) (int64, error) {
+	if tx == nil {
+		tx = l.db
+	}
+	if err := tx.WithContext(ctx).Exec("DELETE FROM lot_stocks WHERE ? = 0 OR team_id = ?", teamId, teamId).Error; err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	res := tx.WithContext(ctx).Exec(`
+INSERT INTO lot_stocks (created_at, updated_at, team_id, product_id, product_lot_id, location_id, quantity)
+SELECT ?, ?, team_id, product_id, product_lot_id, location_id, SUM(quantity)
+FROM stock_movements
+WHERE location_id IS NOT NULL AND (? = 0 OR team_id = ?)
+GROUP BY team_id, product_id, product_lot_id, location_id
+HAVING ABS(SUM(quantity)) > ?`,
+		now, now, teamId, teamId, StockTolerance)
+	return res.RowsAffected, res.Error
+}
+
+func NewLotStock(db *gorm.DB) LotStock {
+	return &lotStock{
+		db: db,
+	}
+}
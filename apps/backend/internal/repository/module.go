@@ -13,5 +13,31 @@ var (
 		fx.Provide(NewAutoIncrementSequence),
 		fx.Provide(NewODT),
 		fx.Provide(NewMailRepository),
+		fx.Provide(NewStorage),
+		fx.Provide(NewBlob),
+		fx.Provide(NewUpload),
+		fx.Provide(NewScanner),
+		fx.Provide(NewAttachment),
+		fx.Provide(NewProductCategory),
+		fx.Provide(NewProduct),
+		fx.Provide(NewUom),
+		fx.Provide(NewProductLot),
+		fx.Provide(NewTransaction),
+		fx.Provide(NewApprovalStep),
+		fx.Provide(NewTransactionApproval),
+		fx.Provide(NewStockMovement),
+		fx.Provide(NewWarehouse),
+		fx.Provide(NewLocation),
+		fx.Provide(NewLotStock),
+		fx.Provide(NewStockTransfer),
+		fx.Provide(NewStockCount),
+		fx.Provide(NewStockAdjustment),
+		fx.Provide(NewAlertRule),
+		fx.Provide(NewAlert),
+		fx.Provide(NewAlertSubscriber),
+		fx.Provide(NewPartner),
+		fx.Provide(NewValuation),
+		fx.Provide(NewNumberingScheme),
+		fx.Provide(NewSequenceAudit),
 	)
 )
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	NumberingScheme interface {
+		// FindByTeam returns the schemes the team set, by entity type.
+		FindByTeam(ctx context.Context, tx *gorm.DB, teamId uint) ([]model.NumberingScheme, error)
+		// FindByType returns gorm.ErrRecordNotFound when the team has no scheme
+		// for the entity type.
+		FindByType(ctx context.Context, tx *gorm.DB, teamId uint, entityType model.EntityType) (*model.NumberingScheme, error)
+		// Save creates the scheme of its team and entity type or updates the existing one.
+		Save(ctx context.Context, tx *gorm.DB, scheme *model.NumberingScheme) error
+		Delete(ctx context.Context, tx *gorm.DB, teamId uint, entityType model.EntityType) error
+	}
+	numberingScheme struct {
+		db *gorm.DB
+	}
+)
+
+// FindByTeam implements NumberingScheme.
+func (n *numberingScheme) FindByTeam(ctx context.Context, tx *gorm.DB, teamId uint) ([]model.NumberingScheme, error) {
+	if tx == nil {
+		tx = n.db
+	}
+	return gorm.G[model.NumberingScheme](tx).Where("team_id = ?", teamId).Order("entity_type ASC").Find(ctx)
+}
+
+// FindByType implements NumberingScheme.
+func (n *numberingScheme) FindByType(ctx context.Context, tx *gorm.DB, teamId uint, entityType model.EntityType) (*model.NumberingScheme, error) {
+	if tx == nil {
+		tx = n.db
+	}
+	m, err := gorm.G[model.NumberingScheme](tx).Where("team_id = ? AND entity_type = ?", teamId, entityType).First(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Save implements NumberingScheme.
+func (n *numberingScheme) Save(ctx context.Context, tx *gorm.DB, m *model.NumberingScheme) error {
+	if tx == nil {
+		tx = n.db
+	}
+	return tx.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "team_id"}, {Name: "entity_type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"template", "reset_period", "fiscal_year_start", "gapless", "updated_at"}),
+		}).
+		Create(m).Error
+}
+
+// Delete implements NumberingScheme.
+func (n *numberingScheme) Delete(ctx context.Context, tx *gorm.DB, teamId uint, entityType model.EntityType) error {
+	if tx == nil {
+		tx = n.db
+	}
+	_, err := gorm.G[model.NumberingScheme](tx).Where("team_id = ? AND entity_type = ?", teamId, entityType).Delete(ctx)
+	return err
+}
+
+func NewNumberingScheme(db *gorm.DB) NumberingScheme {
+	return &numberingScheme{
+		db: db,
+	}
+}
@@ -2,17 +2,25 @@ package repository
 
 import (
 	"bytes"
-	"path/filepath"
+	"context"
+	"path"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	"github.com/suttapak/starter/domain/config"
 	"github.com/suttapak/starter/errs"
 )
 
+const (
+	pdfPath       = "pdf"
+	pdfExpireTime = time.Hour * 24
+)
+
 type (
 	odt struct {
-		conf   *config.Config
-		client *resty.Client
+		conf    *config.Config
+		client  *resty.Client
+		storage Storage
 	}
 
 	Odt interface {
@@ -37,10 +45,7 @@ type (
 
 // RenderPDF implements LabODTRepository.
 func (l odt) RenderPDF(id string, data map[string]interface{}, filename ...string) (result *RenderPdfData, err error) {
-	path, err := filepath.Abs("./public/static/pdf")
-	if err != nil {
-		return nil, errs.New(500, "Failed to get absolute path: "+err.Error())
-	}
+	ctx := context.Background()
 	fileName := ""
 	if len(filename) > 0 && filename[0] != "" {
 		fileName = filename[0]
@@ -61,10 +66,9 @@ func (l odt) RenderPDF(id string, data map[string]interface{}, filename ...strin
 		return nil, errs.New(res.StatusCode(), string(res.Body()))
 	}
 	if fileName == "" {
-		fileName = filepath.Base(result.Data)
+		fileName = path.Base(result.Data)
 	}
-	res, err = l.client.SetOutputDirectory(path).R().
-		SetOutput(filepath.Join(path, fileName)).
+	res, err = l.client.R().
 		SetAuthScheme("Bearer").
 		SetAuthToken(l.conf.LabODT.Token).
 		Get(l.conf.LabODT.Url + result.Data)
@@ -75,8 +79,17 @@ func (l odt) RenderPDF(id string, data map[string]interface{}, filename ...strin
 	if !res.IsSuccess() {
 		return nil, errs.New(res.StatusCode(), string(res.Body()))
 	}
+	key := path.Join(pdfPath, path.Base(fileName))
+	body := res.Body()
+	if _, err := l.storage.Put(ctx, key, bytes.NewReader(body), int64(len(body)), "application/pdf"); err != nil {
+		return nil, err
+	}
+	dataUrl, err := l.storage.SignedURL(ctx, key, pdfExpireTime)
+	if err != nil {
+		return nil, err
+	}
 	return &RenderPdfData{
-		Data:    "/static/pdf/" + fileName,
+		Data:    dataUrl,
 		Message: result.Message,
 	}, nil
 }
@@ -119,9 +132,10 @@ func (l odt) UploadTemplate(file *bytes.Reader) (id string, err error) {
 	return result.Data.InsertedID, nil
 }
 
-func NewODT(conf *config.Config) Odt {
+func NewODT(conf *config.Config, storage Storage) Odt {
 	return odt{
-		conf:   conf,
-		client: resty.New(),
+		conf:    conf,
+		client:  resty.New(),
+		storage: storage,
 	}
 }
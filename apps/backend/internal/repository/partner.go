@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+)
+
+type (
+	Partner interface {
+		// Create saves the partner with its addresses and contacts.
+		Create(ctx context.Context, tx *gorm.DB, partner *model.Partner) error
+		// Update saves the columns of partner and replaces its addresses and contacts.
+		Update(ctx context.Context, tx *gorm.DB, partner *model.Partner) error
+		// Delete removes the partner with its addresses and contacts.
+		Delete(ctx context.Context, tx *gorm.DB, teamId, partnerId uint) error
+		// FindById preloads the addresses and contacts.
+		FindById(ctx context.Context, tx *gorm.DB, teamId, partnerId uint) (*model.Partner, error)
+		// FindByCodes returns the partners of the team with one of codes.
+		FindByCodes(ctx context.Context, tx *gorm.DB, teamId uint, codes []string) ([]model.Partner, error)
+		// FindAll fills pg.Count with the number of matching partners.
+		FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.PartnerFilter) ([]model.Partner, error)
+		// FindEvery is FindAll without paging, for exports.
+		FindEvery(ctx context.Context, tx *gorm.DB, teamId uint, f *filter.PartnerFilter) ([]model.Partner, error)
+		// InUse reports whether a transaction references the partner.
+		InUse(ctx context.Context, tx *gorm.DB, partnerId uint) (bool, error)
+	}
+	partner struct {
+		db *gorm.DB
+	}
+)
+
+// Create implements Partner.
+func (p *partner) Create(ctx context.Context, tx *gorm.DB, m *model.Partner) error {
+	if tx == nil {
+		tx = p.db
+	}
+	return tx.WithContext(ctx).Create(m).Error
+}
+
+// Update implements Partner.
+func (p *partner) Update(ctx context.Context, tx *gorm.DB, m *model.Partner) error {
+	if tx == nil {
+		tx = p.db
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.Partner{}).
+		Where("team_id = ? AND id = ?", m.TeamID, m.ID).
+		Updates(map[string]any{
+			"code":   m.Code,
+			"type":   m.Type,
+			"name":   m.Name,
+			"tax_id": m.TaxID,
+			"email":  m.Email,
+			"phone":  m.Phone,
+			"note":   m.Note,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if err := p.deleteDetails(ctx, tx, m.ID); err != nil {
+		return err
+	}
+	for i := range m.Addresses {
+		m.Addresses[i].ID = 0
+		m.Addresses[i].PartnerID = m.ID
+	}
+	for i := range m.Contacts {
+		m.Contacts[i].ID = 0
+		m.Contacts[i].PartnerID = m.ID
+	}
+	if len(m.Addresses) > 0 {
+		if err := tx.WithContext(ctx).Create(&m.Addresses).Error; err != nil {
+			return err
+		}
+	}
+	if len(m.Contacts) > 0 {
+		if err := tx.WithContext(ctx).Create(&m.Contacts).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete implements Partner.
+func (p *partner) Delete(ctx context.Context, tx *gorm.DB, teamId, partnerId uint) error {
+	if tx == nil {
+		tx = p.db
+	}
+	rows, err := gorm.G[model.Partner](tx).Where("team_id = ? AND id = ?", teamId, partnerId).Delete(ctx)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return p.deleteDetails(ctx, tx, partnerId)
+}
+
+// FindById implements Partner.
+func (p *partner) FindById(ctx context.Context, tx *gorm.DB, teamId, partnerId uint) (*model.Partner, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	var m model.Partner
+	err := p.preload(tx.WithContext(ctx)).
+		Where("team_id = ? AND id = ?", teamId, partnerId).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindByCodes implements Partner.
+func (p *partner) FindByCodes(ctx context.Context, tx *gorm.DB, teamId uint, codes []string) ([]model.Partner, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	if len(codes) == 0 {
+		return nil, nil
+	}
+	return gorm.G[model.Partner](tx).Where("team_id = ? AND code IN ?", teamId, codes).Find(ctx)
+}
+
+// FindAll implements Partner.
+func (p *partner) FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.PartnerFilter) ([]model.Partner, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	q := p.filter(tx.WithContext(ctx), teamId, f)
+	if err := q.Count(&pg.Count).Error; err != nil {
+		return nil, err
+	}
+	var partners []model.Partner
+	err := p.preload(q).
+		Order("code ASC").
+		Limit(pg.Limit).
+		Offset(pg.Offset).
+		Find(&partners).Error
+	return partners, err
+}
+
+// FindEvery implements Partner.
+func (p *partner) FindEvery(ctx context.Context, tx *gorm.DB, teamId uint, f *filter.PartnerFilter) ([]model.Partner, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	var partners []model.Partner
+	err := p.preload(p.filter(tx.WithContext(ctx), teamId, f)).
+		Order("code ASC").
+		Find(&partners).Error
+	return partners, err
+}
+
+// InUse implements Partner.
+func (p *partner) InUse(ctx context.Context, tx *gorm.DB, partnerId uint) (bool, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	count, err := gorm.G[model.Transaction](tx).Where("partner_id = ?", partnerId).Count(ctx, "id")
+	return count > 0, err
+}
+
+func (p *partner) filter(tx *gorm.DB, teamId uint, f *filter.PartnerFilter) *gorm.DB {
+	q := tx.Model(&model.Partner{}).Where("team_id = ?", teamId)
+	if f == nil {
+		return q
+	}
+	if f.Search != "" {
+		search := "%" + f.Search + "%"
+		q = q.Where("code ILIKE ? OR name ILIKE ? OR tax_id ILIKE ?", search, search, search)
+	}
+	if f.Type != "" {
+		q = q.Where("type IN ?", []string{f.Type, model.PartnerTypeBoth})
+	}
+	return q
+}
+
+func (p *partner) preload(tx *gorm.DB) *gorm.DB {
+	return tx.
+		Preload("Addresses", func(db *gorm.DB) *gorm.DB {
+			return db.Order("is_default DESC, id ASC")
+		}).
+		Preload("Contacts", func(db *gorm.DB) *gorm.DB {
+			return db.Order("is_primary DESC, id ASC")
+		})
+}
+
+func (p *partner) deleteDetails(ctx context.Context, tx *gorm.DB, partnerId uint) error {
+	if _, err := gorm.G[model.PartnerAddress](tx).Where("partner_id = ?", partnerId).Delete(ctx); err != nil {
+		return err
+	}
+	_, err := gorm.G[model.PartnerContact](tx).Where("partner_id = ?", partnerId).Delete(ctx)
+	return err
+}
+
+func NewPartner(db *gorm.DB) Partner {
+	return &partner{
+		db: db,
+	}
+}
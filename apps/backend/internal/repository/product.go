@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+)
+
+type (
+	Product interface {
+		Create(ctx context.Context, tx *gorm.DB, product *model.Product) error
+		// Update saves the editable fields, code and team never change.
+		Update(ctx context.Context, tx *gorm.DB, product *model.Product) error
+		// Delete removes the product with its category links and alternate units,
+		// images are left to the caller.
+		Delete(ctx context.Context, tx *gorm.DB, teamId, productId uint) error
+		// FindById preloads the units, the categories and the images in their sort order.
+		FindById(ctx context.Context, tx *gorm.DB, teamId, productId uint) (*model.Product, error)
+		// FindByCode returns the product of the team with code, without preloads.
+		FindByCode(ctx context.Context, tx *gorm.DB, teamId uint, code string) (*model.Product, error)
+		// FindAll fills pg.Count with the number of matching products.
+		FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.ProductFilter) ([]model.Product, error)
+		// CountByIds counts the products of teamId among productIds.
+		CountByIds(ctx context.Context, tx *gorm.DB, teamId uint, productIds []uint) (int64, error)
+		// ReplaceCategories links exactly categoryIds to the product.
+		ReplaceCategories(ctx context.Context, tx *gorm.DB, productId uint, categoryIds []uint) error
+
+		CreateImage(ctx context.Context, tx *gorm.DB, image *model.ProductImage) error
+		// FindImages returns the images of the product with their Image, in sort order.
+		FindImages(ctx context.Context, tx *gorm.DB, productId uint) ([]model.ProductImage, error)
+		FindImageById(ctx context.Context, tx *gorm.DB, productId, productImageId uint) (*model.ProductImage, error)
+		DeleteImage(ctx context.Context, tx *gorm.DB, productImageId uint) error
+		UpdateImageOrder(ctx context.Context, tx *gorm.DB, productImageId uint, sortOrder int) error
+		// NextImageOrder returns the sort order that places a new image last.
+		NextImageOrder(ctx context.Context, tx *gorm.DB, productId uint) (int, error)
+	}
+	product struct {
+		db *gorm.DB
+	}
+)
+
+// Create implements Product.
+func (p *product) Create(ctx context.Context, tx *gorm.DB, product *model.Product) error {
+	if tx == nil {
+		tx = p.db
+	}
+	return gorm.G[model.Product](tx).Create(ctx, product)
+}
+
+// Update implements Product.
+func (p *product) Update(ctx context.Context, tx *gorm.DB, product *model.Product) error {
+	if tx == nil {
+		tx = p.db
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.Product{}).
+		Where("team_id = ? AND id = ?", product.TeamID, product.ID).
+		Updates(map[string]any{
+			"name":          product.Name,
+			"description":   product.Description,
+			"price":         product.Price,
+			"uom":           product.UOM,
+			"base_uom_id":   product.BaseUomID,
+			"reorder_point": product.ReorderPoint,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete implements Product.
+func (p *product) Delete(ctx context.Context, tx *gorm.DB, teamId, productId uint) error {
+	if tx == nil {
+		tx = p.db
+	}
+	if _, err := gorm.G[model.ProductProductCategory](tx).Where("product_id = ?", productId).Delete(ctx); err != nil {
+		return err
+	}
+	if _, err := gorm.G[model.ProductUom](tx).Where("product_id = ?", productId).Delete(ctx); err != nil {
+		return err
+	}
+	rows, err := gorm.G[model.Product](tx).Where("team_id = ? AND id = ?", teamId, productId).Delete(ctx)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindById implements Product.
+func (p *product) FindById(ctx context.Context, tx *gorm.DB, teamId, productId uint) (*model.Product, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	var m model.Product
+	err := p.preload(tx.WithContext(ctx)).
+		Where("team_id = ? AND id = ?", teamId, productId).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindByCode implements Product.
+func (p *product) FindByCode(ctx context.Context, tx *gorm.DB, teamId uint, code string) (*model.Product, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	m, err := gorm.G[model.Product](tx).Where("team_id = ? AND code = ?", teamId, code).First(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindAll implements Product.
+func (p *product) FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.ProductFilter) ([]model.Product, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	q := tx.WithContext(ctx).Model(&model.Product{}).Where("team_id = ?", teamId)
+	if f.Code != "" {
+		q = q.Where("code ILIKE ?", "%"+f.Code+"%")
+	}
+	if f.Name != "" {
+		q = q.Where("name ILIKE ?", "%"+f.Name+"%")
+	}
+	if f.UOM != "" {
+		q = q.Where("uom = ?", f.UOM)
+	}
+	if err := q.Count(&pg.Count).Error; err != nil {
+		return nil, err
+	}
+	var products []model.Product
+	err := p.preload(q).Order("code ASC").Limit(pg.Limit).Offset(pg.Offset).Find(&products).Error
+	return products, err
+}
+
+// CountByIds implements Product.
+func (p *product) CountByIds(ctx context.Context, tx *gorm.DB, teamId uint, productIds []uint) (int64, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	return gorm.G[model.Product](tx).Where("team_id = ? AND id IN ?", teamId, productIds).Count(ctx, "id")
+}
+
+// ReplaceCategories implements Product.
+func (p *product) ReplaceCategories(ctx context.Context, tx *gorm.DB, productId uint, categoryIds []uint) error {
+	if tx == nil {
+		tx = p.db
+	}
+	if _, err := gorm.G[model.ProductProductCategory](tx).Where("product_id = ?", productId).Delete(ctx); err != nil {
+		return err
+	}
+	if len(categoryIds) == 0 {
+		return nil
+	}
+	links := make([]model.ProductProductCategory, 0, len(categoryIds))
+	for _, id := range categoryIds {
+		links = append(links, model.ProductProductCategory{ProductID: productId, CategoryID: id})
+	}
+	return gorm.G[model.ProductProductCategory](tx).CreateInBatches(ctx, &links, len(links))
+}
+
+// CreateImage implements Product.
+func (p *product) CreateImage(ctx context.Context, tx *gorm.DB, image *model.ProductImage) error {
+	if tx == nil {
+		tx = p.db
+	}
+	return gorm.G[model.ProductImage](tx).Create(ctx, image)
+}
+
+// FindImages implements Product.
+func (p *product) FindImages(ctx context.Context, tx *gorm.DB, productId uint) ([]model.ProductImage, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	var images []model.ProductImage
+	err := tx.WithContext(ctx).
+		Preload("Image").
+		Where("product_id = ?", productId).
+		Order("sort_order ASC, id ASC").
+		Find(&images).Error
+	return images, err
+}
+
+// FindImageById implements Product.
+func (p *product) FindImageById(ctx context.Context, tx *gorm.DB, productId, productImageId uint) (*model.ProductImage, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	var m model.ProductImage
+	err := tx.WithContext(ctx).
+		Preload("Image").
+		Where("product_id = ? AND id = ?", productId, productImageId).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// DeleteImage implements Product.
+func (p *product) DeleteImage(ctx context.Context, tx *gorm.DB, productImageId uint) error {
+	if tx == nil {
+		tx = p.db
+	}
+	_, err := gorm.G[model.ProductImage](tx).Where("id = ?", productImageId).Delete(ctx)
+	return err
+}
+
+// UpdateImageOrder implements Product.
+func (p *product) UpdateImageOrder(ctx context.Context, tx *gorm.DB, productImageId uint, sortOrder int) error {
+	if tx == nil {
+		tx = p.db
+	}
+	_, err := gorm.G[model.ProductImage](tx).Where("id = ?", productImageId).Update(ctx, "sort_order", sortOrder)
+	return err
+}
+
+// NextImageOrder implements Product.
+func (p *product) NextImageOrder(ctx context.Context, tx *gorm.DB, productId uint) (int, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	var next int
+	err := tx.WithContext(ctx).
+		Model(&model.ProductImage{}).
+		Where("product_id = ?", productId).
+		Select("COALESCE(MAX(sort_order) + 1, 0)").
+		Scan(&next).Error
+	return next, err
+}
+
+func (p *product) preload(tx *gorm.DB) *gorm.DB {
+	return tx.
+		Preload("BaseUom").
+		Preload("Uoms", func(db *gorm.DB) *gorm.DB {
+			return db.Order("factor ASC")
+		}).
+		Preload("Uoms.Uom").
+		Preload("ProductProductCategory.Category").
+		Preload("ProductImage", func(db *gorm.DB) *gorm.DB {
+			return db.Order("sort_order ASC, id ASC")
+		}).
+		Preload("ProductImage.Image")
+}
+
+func NewProduct(db *gorm.DB) Product {
+	return &product{
+		db: db,
+	}
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+)
+
+type (
+	ProductCategory interface {
+		Create(ctx context.Context, tx *gorm.DB, category *model.ProductCategory) error
+		Update(ctx context.Context, tx *gorm.DB, teamId, categoryId uint, name string) error
+		Delete(ctx context.Context, tx *gorm.DB, teamId, categoryId uint) error
+		FindById(ctx context.Context, tx *gorm.DB, teamId, categoryId uint) (*model.ProductCategory, error)
+		// FindAll fills pg.Count with the number of matching categories.
+		FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.ProductCategoryFilter) ([]model.ProductCategory, error)
+		// CountByIds counts the categories of teamId among categoryIds.
+		CountByIds(ctx context.Context, tx *gorm.DB, teamId uint, categoryIds []uint) (int64, error)
+	}
+	productCategory struct {
+		db *gorm.DB
+	}
+)
+
+// Create implements ProductCategory.
+func (p *productCategory) Create(ctx context.Context, tx *gorm.DB, category *model.ProductCategory) error {
+	if tx == nil {
+		tx = p.db
+	}
+	return gorm.G[model.ProductCategory](tx).Create(ctx, category)
+}
+
+// Update implements ProductCategory.
+func (p *productCategory) Update(ctx context.Context, tx *gorm.DB, teamId, categoryId uint, name string) error {
+	if tx == nil {
+		tx = p.db
+	}
+	rows, err := gorm.G[model.ProductCategory](tx).
+		Where("team_id = ? AND id = ?", teamId, categoryId).
+		Update(ctx, "name", name)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete implements ProductCategory.
+func (p *productCategory) Delete(ctx context.Context, tx *gorm.DB, teamId, categoryId uint) error {
+	if tx == nil {
+		tx = p.db
+	}
+	if _, err := gorm.G[model.ProductProductCategory](tx).Where("category_id = ?", categoryId).Delete(ctx); err != nil {
+		return err
+	}
+	rows, err := gorm.G[model.ProductCategory](tx).Where("team_id = ? AND id = ?", teamId, categoryId).Delete(ctx)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindById implements ProductCategory.
+func (p *productCategory) FindById(ctx context.Context, tx *gorm.DB, teamId, categoryId uint) (*model.ProductCategory, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	m, err := gorm.G[model.ProductCategory](tx).Where("team_id = ? AND id = ?", teamId, categoryId).First(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindAll implements ProductCategory.
+func (p *productCategory) FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.ProductCategoryFilter) ([]model.ProductCategory, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	q := tx.WithContext(ctx).Model(&model.ProductCategory{}).Where("team_id = ?", teamId)
+	if f.Name != "" {
+		q = q.Where("name ILIKE ?", "%"+f.Name+"%")
+	}
+	if err := q.Count(&pg.Count).Error; err != nil {
+		return nil, err
+	}
+	var categories []model.ProductCategory
+	err := q.Order("name ASC").Limit(pg.Limit).Offset(pg.Offset).Find(&categories).Error
+	return categories, err
+}
+
+// CountByIds implements ProductCategory.
+func (p *productCategory) CountByIds(ctx context.Context, tx *gorm.DB, teamId uint, categoryIds []uint) (int64, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	return gorm.G[model.ProductCategory](tx).Where("team_id = ? AND id IN ?", teamId, categoryIds).Count(ctx, "id")
+}
+
+func NewProductCategory(db *gorm.DB) ProductCategory {
+	return &productCategory{
+		db: db,
+	}
+}
@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	ProductLot interface {
+		Create(ctx context.Context, tx *gorm.DB, lot *model.ProductLot) error
+		FindById(ctx context.Context, tx *gorm.DB, productId, lotId uint) (*model.ProductLot, error)
+		// FindInTeam returns the lot when it belongs to a product of the team.
+		FindInTeam(ctx context.Context, tx *gorm.DB, teamId, lotId uint) (*model.ProductLot, error)
+		// FindByCode returns the lot of the team by product and lot code.
+		FindByCode(ctx context.Context, tx *gorm.DB, teamId uint, productCode, lotCode string) (*model.ProductLot, error)
+		// FindAllByCode returns the lots of the team with the lot code and their
+		// Product, lot codes are only unique per product.
+		FindAllByCode(ctx context.Context, tx *gorm.DB, teamId uint, lotCode string) ([]model.ProductLot, error)
+		// FindAll fills pg.Count with the number of matching lots, today decides
+		// which lots f.Expired selects.
+		FindAll(ctx context.Context, tx *gorm.DB, productId uint, today time.Time, pg *helpers.Pagination, f *filter.ProductLotFilter) ([]model.ProductLot, error)
+		// ExistsByProduct reports whether a lot of the product was ever received.
+		ExistsByProduct(ctx context.Context, tx *gorm.DB, productId uint) (bool, error)
+		// Lock locks the lots, as stock movements of a lot must be recorded one
+		// at a time.
+		Lock(ctx context.Context, tx *gorm.DB, lotIds ...uint) error
+		// AddRemaining adds delta to the remaining quantity, it fails with
+		// gorm.ErrRecordNotFound instead of going below zero or above received by
+		// more than StockTolerance.
+		AddRemaining(ctx context.Context, tx *gorm.DB, lotId uint, delta float64) error
+		// Adjust adds delta to the remaining quantity like AddRemaining, raising
+		// the received quantity when stock is found beyond it.
+		Adjust(ctx context.Context, tx *gorm.DB, lotId uint, delta float64) error
+	}
+	productLot struct {
+		db *gorm.DB
+	}
+)
+
+// Create implements ProductLot.
+func (p *productLot) Create(ctx context.Context, tx *gorm.DB, lot *model.ProductLot) error {
+	if tx == nil {
+		tx = p.db
+	}
+	return gorm.G[model.ProductLot](tx).Create(ctx, lot)
+}
+
+// FindById implements ProductLot.
+func (p *productLot) FindById(ctx context.Context, tx *gorm.DB, productId, lotId uint) (*model.ProductLot, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	m, err := gorm.G[model.ProductLot](tx).Where("product_id = ? AND id = ?", productId, lotId).First(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindAll implements ProductLot.
+func (p *productLot) FindAll(ctx context.Context, tx *gorm.DB, productId uint, today time.Time, pg *helpers.Pagination, f *filter.ProductLotFilter) ([]model.ProductLot, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	q := tx.WithContext(ctx).Model(&model.ProductLot{}).Where("product_id = ?", productId)
+	if f.Code != "" {
+		q = q.Where("code ILIKE ?", "%"+f.Code+"%")
+	}
+	if f.InStock {
+		q = q.Where("remaining_qty > 0")
+	}
+	if f.Expired != nil {
+		if *f.Expired {
+			q = q.Where("expiry_date < ?", today)
+		} else {
+			q = q.Where("expiry_date IS NULL OR expiry_date >= ?", today)
+		}
+	}
+	if err := q.Count(&pg.Count).Error; err != nil {
+		return nil, err
+	}
+	var lots []model.ProductLot
+	err := q.Order("expiry_date ASC NULLS LAST, id ASC").Limit(pg.Limit).Offset(pg.Offset).Find(&lots).Error
+	return lots, err
+}
+
+// Lock implements ProductLot.
+func (p *productLot) Lock(ctx context.Context, tx *gorm.DB, lotIds ...uint) error {
+	if tx == nil {
+		tx = p.db
+	}
+	if len(lotIds) == 0 {
+		return nil
+	}
+	var ids []uint
+	return tx.WithContext(ctx).
+		Model(&model.ProductLot{}).
+		Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+		Where("id IN ?", lotIds).
+		Order("id ASC").
+		Pluck("id", &ids).Error
+}
+
+// FindInTeam implements ProductLot.
+func (p *productLot) FindInTeam(ctx context.Context, tx *gorm.DB, teamId, lotId uint) (*model.ProductLot, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	m, err := gorm.G[model.ProductLot](tx).Where("team_id = ? AND id = ?", teamId, lotId).First(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindByCode implements ProductLot.
+func (p *productLot) FindByCode(ctx context.Context, tx *gorm.DB, teamId uint, productCode, lotCode string) (*model.ProductLot, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	var m model.ProductLot
+	err := tx.WithContext(ctx).
+		Joins("JOIN products ON products.id = product_lots.product_id").
+		Where("product_lots.team_id = ? AND products.code = ? AND product_lots.code = ?", teamId, productCode, lotCode).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindAllByCode implements ProductLot.
+func (p *productLot) FindAllByCode(ctx context.Context, tx *gorm.DB, teamId uint, lotCode string) ([]model.ProductLot, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	var lots []model.ProductLot
+	err := tx.WithContext(ctx).
+		Preload("Product").
+		Where("team_id = ? AND code = ?", teamId, lotCode).
+		Order("id ASC").
+		Find(&lots).Error
+	return lots, err
+}
+
+// AddRemaining implements ProductLot.
+func (p *productLot) AddRemaining(ctx context.Context, tx *gorm.DB, lotId uint, delta float64) error {
+	if tx == nil {
+		tx = p.db
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.ProductLot{}).
+		Where("id = ? AND remaining_qty + ? >= ? AND remaining_qty + ? <= received_qty + ?", lotId, delta, -StockTolerance, delta, StockTolerance).
+		Update("remaining_qty", gorm.Expr("remaining_qty + ?", delta))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Adjust implements ProductLot.
+func (p *productLot) Adjust(ctx context.Context, tx *gorm.DB, lotId uint, delta float64) error {
+	if tx == nil {
+		tx = p.db
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.ProductLot{}).
+		Where("id = ? AND remaining_qty + ? >= ?", lotId, delta, -StockTolerance).
+		Updates(map[string]any{
+			"remaining_qty": gorm.Expr("remaining_qty + ?", delta),
+			"received_qty":  gorm.Expr("GREATEST(received_qty, remaining_qty + ?)", delta),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ExistsByProduct implements ProductLot.
+func (p *productLot) ExistsByProduct(ctx context.Context, tx *gorm.DB, productId uint) (bool, error) {
+	if tx == nil {
+		tx = p.db
+	}
+	var exists bool
+	err := tx.WithContext(ctx).
+		Raw("SELECT EXISTS (SELECT 1 FROM product_lots WHERE product_id = ?)", productId).
+		Scan(&exists).Error
+	return exists, err
+}
+
+func NewProductLot(db *gorm.DB) ProductLot {
+	return &productLot{
+		db: db,
+	}
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/suttapak/starter/domain/config"
+)
+
+// clamdChunkSize must stay below StreamMaxLength of clamd.conf.
+const clamdChunkSize = 64 << 10
+
+type (
+	// clamdScanner streams files to a ClamAV daemon with the INSTREAM command,
+	// see https://linux.die.net/man/8/clamd.
+	clamdScanner struct {
+		address string
+		timeout time.Duration
+	}
+)
+
+// Scan implements Scanner.
+func (s *clamdScanner) Scan(ctx context.Context, r io.Reader) (*ScanResult, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close() //nolint:errcheck
+	if s.timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+			return nil, err
+		}
+	}
+
+	// z prefixed commands are terminated by a NUL byte, so is the reply
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return nil, err
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	// a zero length chunk ends the stream
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return parseClamdReply(string(bytes.TrimRight(reply, "\x00")))
+}
+
+// parseClamdReply reads "stream: OK", "stream: <signature> FOUND" or "<message> ERROR".
+func parseClamdReply(reply string) (*ScanResult, error) {
+	reply = strings.TrimSpace(reply)
+	switch {
+	case strings.HasSuffix(reply, " FOUND"):
+		signature := strings.TrimSuffix(reply, " FOUND")
+		if i := strings.Index(signature, ": "); i >= 0 {
+			signature = signature[i+2:]
+		}
+		return &ScanResult{Infected: true, Signature: signature}, nil
+	case strings.HasSuffix(reply, " OK"):
+		return &ScanResult{}, nil
+	default:
+		return nil, fmt.Errorf("clamd: %s", reply)
+	}
+}
+
+func newClamdScanner(conf *config.Config) Scanner {
+	return &clamdScanner{
+		address: conf.SCANNER.ADDRESS,
+		timeout: conf.SCANNER.TIMEOUT,
+	}
+}
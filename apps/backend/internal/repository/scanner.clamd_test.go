@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClamdReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		want    *ScanResult
+		wantErr bool
+	}{
+		{name: "ok", reply: "stream: OK", want: &ScanResult{}},
+		{name: "found", reply: "stream: Eicar-Test-Signature FOUND", want: &ScanResult{Infected: true, Signature: "Eicar-Test-Signature"}},
+		{name: "found with spaces", reply: " stream: Win.Test.EICAR_HDB-1 FOUND\n", want: &ScanResult{Infected: true, Signature: "Win.Test.EICAR_HDB-1"}},
+		{name: "error", reply: "INSTREAM size limit exceeded. ERROR", wantErr: true},
+		{name: "malformed", reply: "PONG", wantErr: true},
+		{name: "empty", reply: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseClamdReply(tt.reply)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// fakeClamd is a clamd that takes one INSTREAM session, answering reply
+// unless the stream grows past limit.
+type fakeClamd struct {
+	command string
+	chunks  []int
+	content []byte
+}
+
+func startFakeClamd(t *testing.T, limit int, reply string) (string, <-chan *fakeClamd) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() }) //nolint:errcheck
+
+	done := make(chan *fakeClamd, 1)
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close() //nolint:errcheck
+		r := bufio.NewReader(conn)
+		f := &fakeClamd{}
+		if f.command, err = r.ReadString(0); err != nil {
+			return
+		}
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return
+			}
+			if size == 0 {
+				break
+			}
+			chunk := make([]byte, size)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return
+			}
+			f.chunks = append(f.chunks, int(size))
+			f.content = append(f.content, chunk...)
+			if len(f.content) > limit {
+				conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00")) //nolint:errcheck
+				done <- f
+				return
+			}
+		}
+		conn.Write([]byte(reply + "\x00")) //nolint:errcheck
+		done <- f
+	}()
+	return ln.Addr().String(), done
+}
+
+func TestClamdScannerChunks(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789"), clamdChunkSize/4)
+	address, done := startFakeClamd(t, len(content), "stream: Eicar-Test-Signature FOUND")
+	s := &clamdScanner{address: address, timeout: 5 * time.Second}
+
+	res, err := s.Scan(context.Background(), bytes.NewReader(content))
+	require.NoError(t, err)
+	assert.Equal(t, &ScanResult{Infected: true, Signature: "Eicar-Test-Signature"}, res)
+
+	f := <-done
+	require.NotNil(t, f)
+	assert.Equal(t, "zINSTREAM\x00", f.command)
+	assert.Equal(t, []int{clamdChunkSize, clamdChunkSize, len(content) - 2*clamdChunkSize}, f.chunks)
+	assert.Equal(t, content, f.content)
+}
+
+func TestClamdScannerEmpty(t *testing.T) {
+	address, done := startFakeClamd(t, 0, "stream: OK")
+	s := &clamdScanner{address: address, timeout: 5 * time.Second}
+
+	res, err := s.Scan(context.Background(), bytes.NewReader(nil))
+	require.NoError(t, err)
+	assert.Equal(t, &ScanResult{}, res)
+	assert.Empty(t, (<-done).chunks)
+}
+
+func TestClamdScannerSizeLimit(t *testing.T) {
+	address, done := startFakeClamd(t, clamdChunkSize, "stream: OK")
+	s := &clamdScanner{address: address, timeout: 5 * time.Second}
+
+	_, err := s.Scan(context.Background(), bytes.NewReader(make([]byte, 3*clamdChunkSize)))
+	assert.Error(t, err)
+	<-done
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/suttapak/starter/domain/config"
+)
+
+const (
+	ScannerDriverNoop  = "noop"
+	ScannerDriverClamd = "clamd"
+)
+
+type (
+	// Scanner checks file content for malware.
+	Scanner interface {
+		Scan(ctx context.Context, r io.Reader) (*ScanResult, error)
+	}
+
+	ScanResult struct {
+		Infected  bool
+		Signature string // name of the malware found, empty when clean
+	}
+
+	// noopScanner reports every file clean, for development and deployments
+	// without a scanner.
+	noopScanner struct{}
+)
+
+// Scan implements Scanner.
+func (noopScanner) Scan(ctx context.Context, r io.Reader) (*ScanResult, error) {
+	return &ScanResult{}, nil
+}
+
+func NewScanner(conf *config.Config) (Scanner, error) {
+	switch conf.SCANNER.DRIVER {
+	case ScannerDriverNoop, "":
+		return noopScanner{}, nil
+	case ScannerDriverClamd:
+		return newClamdScanner(conf), nil
+	default:
+		return nil, fmt.Errorf("scanner: unknown driver %q", conf.SCANNER.DRIVER)
+	}
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+)
+
+type (
+	// SequenceAudit is append-only, it is the history of manual sequence changes.
+	SequenceAudit interface {
+		Create(ctx context.Context, tx *gorm.DB, m *model.SequenceAudit) error
+		// FindAll fills pg.Count with the number of matching audits, newest first.
+		FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.SequenceAuditFilter) ([]model.SequenceAudit, error)
+	}
+	sequenceAudit struct {
+		db *gorm.DB
+	}
+)
+
+// Create implements SequenceAudit.
+func (s *sequenceAudit) Create(ctx context.Context, tx *gorm.DB, m *model.SequenceAudit) error {
+	if tx == nil {
+		tx = s.db
+	}
+	return gorm.G[model.SequenceAudit](tx).Create(ctx, m)
+}
+
+// FindAll implements SequenceAudit.
+func (s *sequenceAudit) FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.SequenceAuditFilter) ([]model.SequenceAudit, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	q := tx.WithContext(ctx).Model(&model.SequenceAudit{}).Where("team_id = ?", teamId)
+	if f.EntityType != "" {
+		q = q.Where("entity_type = ?", f.EntityType)
+	}
+	if f.Action != "" {
+		q = q.Where("action = ?", f.Action)
+	}
+	if err := q.Count(&pg.Count).Error; err != nil {
+		return nil, err
+	}
+	var audits []model.SequenceAudit
+	err := q.
+		Preload("CreatedBy").
+		Order("id DESC").
+		Limit(pg.Limit).
+		Offset(pg.Offset).
+		Find(&audits).Error
+	return audits, err
+}
+
+func NewSequenceAudit(db *gorm.DB) SequenceAudit {
+	return &sequenceAudit{
+		db: db,
+	}
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+)
+
+type (
+	StockAdjustment interface {
+		// Create saves the adjustment with its items.
+		Create(ctx context.Context, tx *gorm.DB, adjustment *model.StockAdjustment) error
+		FindById(ctx context.Context, tx *gorm.DB, teamId, adjustmentId uint) (*model.StockAdjustment, error)
+		// FindAll fills pg.Count with the number of matching adjustments, items are not loaded.
+		FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.StockAdjustmentFilter) ([]model.StockAdjustment, error)
+	}
+	stockAdjustment struct {
+		db *gorm.DB
+	}
+)
+
+// Create implements StockAdjustment.
+func (s *stockAdjustment) Create(ctx context.Context, tx *gorm.DB, adjustment *model.StockAdjustment) error {
+	if tx == nil {
+		tx = s.db
+	}
+	return tx.WithContext(ctx).Create(adjustment).Error
+}
+
+// FindById implements StockAdjustment.
+func (s *stockAdjustment) FindById(ctx context.Context, tx *gorm.DB, teamId, adjustmentId uint) (*model.StockAdjustment, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	var m model.StockAdjustment
+	err := tx.WithContext(ctx).
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).
+		Preload("Items.Product").
+		Preload("Items.ProductLot").
+		Preload("Location").
+		Where("team_id = ? AND id = ?", teamId, adjustmentId).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindAll implements StockAdjustment.
+func (s *stockAdjustment) FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.StockAdjustmentFilter) ([]model.StockAdjustment, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	q := tx.WithContext(ctx).Model(&model.StockAdjustment{}).Where("team_id = ?", teamId)
+	if f.Code != "" {
+		q = q.Where("code ILIKE ?", "%"+f.Code+"%")
+	}
+	if f.LocationID != 0 {
+		q = q.Where("location_id = ?", f.LocationID)
+	}
+	if f.StockCountID != 0 {
+		q = q.Where("stock_count_id = ?", f.StockCountID)
+	}
+	if err := q.Count(&pg.Count).Error; err != nil {
+		return nil, err
+	}
+	var adjustments []model.StockAdjustment
+	err := q.
+		Preload("Location").
+		Order("id DESC").
+		Limit(pg.Limit).
+		Offset(pg.Offset).
+		Find(&adjustments).Error
+	return adjustments, err
+}
+
+func NewStockAdjustment(db *gorm.DB) StockAdjustment {
+	return &stockAdjustment{
+		db: db,
+	}
+}
@@ -0,0 +1,343 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	AlertRule interface {
+		// Save creates the rule of its team and type or updates the existing one.
+		Save(ctx context.Context, tx *gorm.DB, rule *model.AlertRule) error
+		FindByTeam(ctx context.Context, tx *gorm.DB, teamId uint) ([]model.AlertRule, error)
+		// FindEnabled returns the enabled rules of every team, ordered by team.
+		FindEnabled(ctx context.Context, tx *gorm.DB) ([]model.AlertRule, error)
+		// FindTeams returns the teams with a rule, enabled or not.
+		FindTeams(ctx context.Context, tx *gorm.DB) ([]uint, error)
+	}
+	alertRule struct {
+		db *gorm.DB
+	}
+
+	Alert interface {
+		// Raise creates the alerts, or refreshes the unresolved alert of the same key.
+		Raise(ctx context.Context, tx *gorm.DB, alerts []model.Alert) error
+		// ResolveUnseen resolves the unresolved alerts of the team not raised since seenAt.
+		ResolveUnseen(ctx context.Context, tx *gorm.DB, teamId uint, seenAt time.Time) (int64, error)
+		FindById(ctx context.Context, tx *gorm.DB, teamId, alertId uint) (*model.Alert, error)
+		// FindAll fills pg.Count with the number of matching alerts, now decides
+		// which alerts f.Snoozed selects.
+		FindAll(ctx context.Context, tx *gorm.DB, teamId uint, now time.Time, pg *helpers.Pagination, f *filter.AlertFilter) ([]model.Alert, error)
+		// FindDigest returns the open alerts of the team that are not snoozed at now.
+		FindDigest(ctx context.Context, tx *gorm.DB, teamId uint, now time.Time) ([]model.Alert, error)
+		// Update changes an unresolved alert, it fails with
+		// gorm.ErrRecordNotFound when the alert is resolved.
+		Update(ctx context.Context, tx *gorm.DB, teamId, alertId uint, updates map[string]any) error
+
+		// FindLowStock returns the products of the team with a reorder point
+		// whose stock is at or below it.
+		FindLowStock(ctx context.Context, tx *gorm.DB, teamId uint) ([]AlertCandidate, error)
+		// FindExpiring returns the lots of the team in stock expiring on or after
+		// from, when not zero, and before to.
+		FindExpiring(ctx context.Context, tx *gorm.DB, teamId uint, from, to time.Time) ([]AlertCandidate, error)
+	}
+	alert struct {
+		db *gorm.DB
+	}
+
+	AlertSubscriber interface {
+		// Subscribe adds the user to the digest of the team, subscribing twice is a no-op.
+		Subscribe(ctx context.Context, tx *gorm.DB, teamId, userId uint) error
+		Unsubscribe(ctx context.Context, tx *gorm.DB, teamId, userId uint) error
+		Exists(ctx context.Context, tx *gorm.DB, teamId, userId uint) (bool, error)
+		// FindDue returns the subscribers with their User whose last digest was
+		// before since, or who never got one.
+		FindDue(ctx context.Context, tx *gorm.DB, since time.Time) ([]model.AlertSubscriber, error)
+		MarkSent(ctx context.Context, tx *gorm.DB, subscriberId uint, at time.Time) error
+	}
+	alertSubscriber struct {
+		db *gorm.DB
+	}
+
+	// AlertCandidate is what an alert would be raised about.
+	AlertCandidate struct {
+		ProductID    uint
+		ProductLotID *uint
+		Quantity     float64
+		Threshold    float64
+		ExpiryDate   sql.NullTime
+	}
+)
+
+// Save implements AlertRule.
+func (a *alertRule) Save(ctx context.Context, tx *gorm.DB, m *model.AlertRule) error {
+	if tx == nil {
+		tx = a.db
+	}
+	return tx.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "team_id"}, {Name: "type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"days", "enabled", "updated_at"}),
+		}).
+		Create(m).Error
+}
+
+// FindByTeam implements AlertRule.
+func (a *alertRule) FindByTeam(ctx context.Context, tx *gorm.DB, teamId uint) ([]model.AlertRule, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	return gorm.G[model.AlertRule](tx).Where("team_id = ?", teamId).Order("type ASC").Find(ctx)
+}
+
+// FindEnabled implements AlertRule.
+func (a *alertRule) FindEnabled(ctx context.Context, tx *gorm.DB) ([]model.AlertRule, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	return gorm.G[model.AlertRule](tx).Where("enabled").Order("team_id ASC, type ASC").Find(ctx)
+}
+
+// FindTeams implements AlertRule.
+func (a *alertRule) FindTeams(ctx context.Context, tx *gorm.DB) ([]uint, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	var teamIds []uint
+	err := tx.WithContext(ctx).
+		Model(&model.AlertRule{}).
+		Distinct("team_id").
+		Order("team_id ASC").
+		Pluck("team_id", &teamIds).Error
+	return teamIds, err
+}
+
+// Raise implements Alert.
+func (a *alert) Raise(ctx context.Context, tx *gorm.DB, alerts []model.Alert) error {
+	if tx == nil {
+		tx = a.db
+	}
+	if len(alerts) == 0 {
+		return nil
+	}
+	return tx.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:     []clause.Column{{Name: "team_id"}, {Name: "key"}},
+			TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "resolved_at IS NULL"}}},
+			DoUpdates:   clause.AssignmentColumns([]string{"rule_id", "quantity", "threshold", "expiry_date", "last_seen_at", "updated_at"}),
+		}).
+		CreateInBatches(&alerts, 500).Error
+}
+
+// ResolveUnseen implements Alert.
+func (a *alert) ResolveUnseen(ctx context.Context, tx *gorm.DB, teamId uint, seenAt time.Time) (int64, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.Alert{}).
+		Where("team_id = ? AND resolved_at IS NULL AND last_seen_at < ?", teamId, seenAt).
+		Updates(map[string]any{
+			"status":      model.AlertStatusResolved,
+			"resolved_at": sql.NullTime{Time: seenAt, Valid: true},
+		})
+	return res.RowsAffected, res.Error
+}
+
+// FindById implements Alert.
+func (a *alert) FindById(ctx context.Context, tx *gorm.DB, teamId, alertId uint) (*model.Alert, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	var m model.Alert
+	err := tx.WithContext(ctx).
+		Preload("Product").
+		Preload("ProductLot").
+		Where("team_id = ? AND id = ?", teamId, alertId).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindAll implements Alert.
+func (a *alert) FindAll(ctx context.Context, tx *gorm.DB, teamId uint, now time.Time, pg *helpers.Pagination, f *filter.AlertFilter) ([]model.Alert, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	q := tx.WithContext(ctx).Model(&model.Alert{}).Where("team_id = ?", teamId)
+	if f.Type != "" {
+		q = q.Where("type = ?", f.Type)
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.ProductID != 0 {
+		q = q.Where("product_id = ?", f.ProductID)
+	}
+	if f.Snoozed != nil {
+		if *f.Snoozed {
+			q = q.Where("snoozed_until > ?", now)
+		} else {
+			q = q.Where("snoozed_until IS NULL OR snoozed_until <= ?", now)
+		}
+	}
+	if err := q.Count(&pg.Count).Error; err != nil {
+		return nil, err
+	}
+	var alerts []model.Alert
+	err := q.
+		Preload("Product").
+		Preload("ProductLot").
+		Order("id DESC").
+		Limit(pg.Limit).
+		Offset(pg.Offset).
+		Find(&alerts).Error
+	return alerts, err
+}
+
+// FindDigest implements Alert.
+func (a *alert) FindDigest(ctx context.Context, tx *gorm.DB, teamId uint, now time.Time) ([]model.Alert, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	var alerts []model.Alert
+	err := tx.WithContext(ctx).
+		Preload("Product").
+		Preload("ProductLot").
+		Where("team_id = ? AND status = ?", teamId, model.AlertStatusOpen).
+		Where("snoozed_until IS NULL OR snoozed_until <= ?", now).
+		Order("type ASC, expiry_date ASC NULLS LAST, id ASC").
+		Find(&alerts).Error
+	return alerts, err
+}
+
+// Update implements Alert.
+func (a *alert) Update(ctx context.Context, tx *gorm.DB, teamId, alertId uint, updates map[string]any) error {
+	if tx == nil {
+		tx = a.db
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.Alert{}).
+		Where("team_id = ? AND id = ? AND resolved_at IS NULL", teamId, alertId).
+		Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindLowStock implements Alert.
+func (a *alert) FindLowStock(ctx context.Context, tx *gorm.DB, teamId uint) ([]AlertCandidate, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	var candidates []AlertCandidate
+	err := tx.WithContext(ctx).
+		Table("products AS p").
+		Joins("LEFT JOIN product_lots AS l ON l.product_id = p.id").
+		Select("p.id AS product_id, COALESCE(SUM(l.remaining_qty), 0) AS quantity, p.reorder_point AS threshold").
+		Where("p.team_id = ? AND p.reorder_point > 0", teamId).
+		Group("p.id").
+		Having("COALESCE(SUM(l.remaining_qty), 0) <= p.reorder_point").
+		Order("p.id ASC").
+		Scan(&candidates).Error
+	return candidates, err
+}
+
+// FindExpiring implements Alert.
+func (a *alert) FindExpiring(ctx context.Context, tx *gorm.DB, teamId uint, from, to time.Time) ([]AlertCandidate, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	q := tx.WithContext(ctx).
+		Model(&model.ProductLot{}).
+		Select("product_id, id AS product_lot_id, remaining_qty AS quantity, expiry_date").
+		Where("team_id = ? AND remaining_qty > 0 AND expiry_date < ?", teamId, to)
+	if !from.IsZero() {
+		q = q.Where("expiry_date >= ?", from)
+	}
+	var candidates []AlertCandidate
+	err := q.Order("expiry_date ASC, id ASC").Scan(&candidates).Error
+	return candidates, err
+}
+
+// Subscribe implements AlertSubscriber.
+func (a *alertSubscriber) Subscribe(ctx context.Context, tx *gorm.DB, teamId, userId uint) error {
+	if tx == nil {
+		tx = a.db
+	}
+	return tx.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.AlertSubscriber{TeamID: teamId, UserID: userId}).Error
+}
+
+// Unsubscribe implements AlertSubscriber.
+func (a *alertSubscriber) Unsubscribe(ctx context.Context, tx *gorm.DB, teamId, userId uint) error {
+	if tx == nil {
+		tx = a.db
+	}
+	_, err := gorm.G[model.AlertSubscriber](tx).Where("team_id = ? AND user_id = ?", teamId, userId).Delete(ctx)
+	return err
+}
+
+// Exists implements AlertSubscriber.
+func (a *alertSubscriber) Exists(ctx context.Context, tx *gorm.DB, teamId, userId uint) (bool, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	count, err := gorm.G[model.AlertSubscriber](tx).Where("team_id = ? AND user_id = ?", teamId, userId).Count(ctx, "id")
+	return count > 0, err
+}
+
+// FindDue implements AlertSubscriber.
+func (a *alertSubscriber) FindDue(ctx context.Context, tx *gorm.DB, since time.Time) ([]model.AlertSubscriber, error) {
+	if tx == nil {
+		tx = a.db
+	}
+	var subscribers []model.AlertSubscriber
+	err := tx.WithContext(ctx).
+		Preload("User").
+		Where("last_digest_at IS NULL OR last_digest_at < ?", since).
+		Order("team_id ASC, id ASC").
+		Find(&subscribers).Error
+	return subscribers, err
+}
+
+// MarkSent implements AlertSubscriber.
+func (a *alertSubscriber) MarkSent(ctx context.Context, tx *gorm.DB, subscriberId uint, at time.Time) error {
+	if tx == nil {
+		tx = a.db
+	}
+	_, err := gorm.G[model.AlertSubscriber](tx).Where("id = ?", subscriberId).Update(ctx, "last_digest_at", at)
+	return err
+}
+
+func NewAlertRule(db *gorm.DB) AlertRule {
+	return &alertRule{
+		db: db,
+	}
+}
+
+func NewAlert(db *gorm.DB) Alert {
+	return &alert{
+		db: db,
+	}
+}
+
+func NewAlertSubscriber(db *gorm.DB) AlertSubscriber {
+	return &alertSubscriber{
+		db: db,
+	}
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	StockCount interface {
+		// Create saves the count with its items.
+		Create(ctx context.Context, tx *gorm.DB, count *model.StockCount) error
+		FindById(ctx context.Context, tx *gorm.DB, teamId, countId uint) (*model.StockCount, error)
+		// FindByIdForUpdate locks the count row until tx ends.
+		FindByIdForUpdate(ctx context.Context, tx *gorm.DB, teamId, countId uint) (*model.StockCount, error)
+		// FindAll fills pg.Count with the number of matching counts, items are not loaded.
+		FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.StockCountFilter) ([]model.StockCount, error)
+		// UpdateStatus moves the count to status when it is in one of from, it fails
+		// with gorm.ErrRecordNotFound otherwise.
+		UpdateStatus(ctx context.Context, tx *gorm.DB, countId uint, from []string, status string, updates map[string]any) error
+		// SaveCounts sets the counted quantity, reason and note of the items by lot,
+		// adding the lots not in the snapshot with an expected quantity of zero.
+		SaveCounts(ctx context.Context, tx *gorm.DB, countId uint, items []model.StockCountItem) error
+	}
+	stockCount struct {
+		db *gorm.DB
+	}
+)
+
+// Create implements StockCount.
+func (s *stockCount) Create(ctx context.Context, tx *gorm.DB, count *model.StockCount) error {
+	if tx == nil {
+		tx = s.db
+	}
+	return tx.WithContext(ctx).Create(count).Error
+}
+
+// FindById implements StockCount.
+func (s *stockCount) FindById(ctx context.Context, tx *gorm.DB, teamId, countId uint) (*model.StockCount, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	return s.find(tx.WithContext(ctx), teamId, countId)
+}
+
+// FindByIdForUpdate implements StockCount.
+func (s *stockCount) FindByIdForUpdate(ctx context.Context, tx *gorm.DB, teamId, countId uint) (*model.StockCount, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	// lock the header only, preloads run as separate queries
+	if err := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+		Where("team_id = ? AND id = ?", teamId, countId).
+		First(&model.StockCount{}).Error; err != nil {
+		return nil, err
+	}
+	return s.find(tx.WithContext(ctx), teamId, countId)
+}
+
+// FindAll implements StockCount.
+func (s *stockCount) FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.StockCountFilter) ([]model.StockCount, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	q := tx.WithContext(ctx).Model(&model.StockCount{}).Where("team_id = ?", teamId)
+	if f.Code != "" {
+		q = q.Where("code ILIKE ?", "%"+f.Code+"%")
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.LocationID != 0 {
+		q = q.Where("location_id = ?", f.LocationID)
+	}
+	if err := q.Count(&pg.Count).Error; err != nil {
+		return nil, err
+	}
+	var counts []model.StockCount
+	err := q.
+		Preload("Location").
+		Order("id DESC").
+		Limit(pg.Limit).
+		Offset(pg.Offset).
+		Find(&counts).Error
+	return counts, err
+}
+
+// UpdateStatus implements StockCount.
+func (s *stockCount) UpdateStatus(ctx context.Context, tx *gorm.DB, countId uint, from []string, status string, updates map[string]any) error {
+	if tx == nil {
+		tx = s.db
+	}
+	values := map[string]any{"status": status}
+	for k, v := range updates {
+		values[k] = v
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.StockCount{}).
+		Where("id = ? AND status IN ?", countId, from).
+		Updates(values)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// SaveCounts implements StockCount.
+func (s *stockCount) SaveCounts(ctx context.Context, tx *gorm.DB, countId uint, items []model.StockCountItem) error {
+	if tx == nil {
+		tx = s.db
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	for i := range items {
+		items[i].ID = 0
+		items[i].StockCountID = countId
+		items[i].Product = nil
+		items[i].ProductLot = nil
+	}
+	return tx.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "stock_count_id"}, {Name: "product_lot_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"counted_qty", "reason_code", "note", "updated_at"}),
+		}).
+		CreateInBatches(&items, len(items)).Error
+}
+
+func (s *stockCount) find(tx *gorm.DB, teamId, countId uint) (*model.StockCount, error) {
+	var m model.StockCount
+	err := tx.
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("product_id ASC, product_lot_id ASC")
+		}).
+		Preload("Items.Product").
+		Preload("Items.ProductLot").
+		Preload("Location").
+		Where("team_id = ? AND id = ?", teamId, countId).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func NewStockCount(db *gorm.DB) StockCount {
+	return &stockCount{
+		db: db,
+	}
+}
@@ -0,0 +1,394 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+)
+
+type (
+	// StockMovement is the append-only stock ledger, it has no update or delete.
+	StockMovement interface {
+		// Create appends m, its Balance is computed from the previous movement of
+		// the lot. The lot row must be locked by tx, e.g. by updating it first.
+		Create(ctx context.Context, tx *gorm.DB, m *model.StockMovement) error
+		// FindAll fills pg.Count with the number of matching movements.
+		FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.StockMovementFilter) ([]model.StockMovement, error)
+		// Each calls fn with the movements matching f in ledger order, read one at
+		// a time from a cursor however many there are. It stops at the first
+		// error of fn.
+		Each(ctx context.Context, tx *gorm.DB, teamId uint, f *filter.StockMovementFilter, fn func(row StockMovementRow) error) error
+		// Balance sums the movements up to and including at, grouped by f.GroupBy.
+		Balance(ctx context.Context, tx *gorm.DB, teamId uint, at time.Time, f *filter.StockBalanceFilter) ([]StockBalance, error)
+		// Reconcile returns the lots whose cached remaining quantity, and the lots
+		// and locations whose cached stock, differ from their ledger.
+		Reconcile(ctx context.Context, tx *gorm.DB, teamId uint) ([]StockDiscrepancy, error)
+		// FindUnlocatedTeams returns the teams holding stock recorded before
+		// locations existed, teamId 0 means every team.
+		FindUnlocatedTeams(ctx context.Context, tx *gorm.DB, teamId uint) ([]uint, error)
+		// Relocate moves the stock of the team recorded before locations existed
+		// into locationId. It returns the number of lots moved.
+		Relocate(ctx context.Context, tx *gorm.DB, teamId, locationId uint, at time.Time) (int64, error)
+		// CreateOpening appends an opening movement for every lot without movements,
+		// teamId 0 means every team. It returns the number of lots opened.
+		CreateOpening(ctx context.Context, tx *gorm.DB, teamId uint, at time.Time) (int64, error)
+		// RebuildCache sets the remaining quantity of every lot to its ledger sum,
+		// teamId 0 means every team. It returns the number of lots changed.
+		RebuildCache(ctx context.Context, tx *gorm.DB, teamId uint) (int64, error)
+	}
+	stockMovement struct {
+		db *gorm.DB
+	}
+
+	StockBalance struct {
+		ProductID     uint
+		ProductCode   string
+		ProductName   string
+		UOM           string
+		ProductLotID  uint
+		LotCode       string
+		LocationID    *uint
+		LocationCode  string
+		WarehouseID   *uint
+		WarehouseCode string
+		Quantity      float64
+	}
+	StockMovementRow struct {
+		ID           uint
+		MovedAt      time.Time
+		ProductCode  string
+		ProductName  string
+		UOM          string
+		LotCode      string
+		LocationCode string
+		Quantity     float64
+		Balance      float64
+		Reason       string
+		RefType      string
+		RefID        *uint
+	}
+	StockDiscrepancy struct {
+		ProductID    uint
+		ProductLotID uint
+		LotCode      string
+		// LocationID is nil when the remaining quantity of the lot differs.
+		LocationID *uint
+		CachedQty  float64
+		LedgerQty  float64
+	}
+)
+
+// StockTolerance absorbs float rounding when comparing quantities, two
+// quantities closer than it are equal. Every stock check uses it.
+const StockTolerance = 0.000001
+
+// Create implements StockMovement.
+func (s *stockMovement) Create(ctx context.Context, tx *gorm.DB, m *model.StockMovement) error {
+	if tx == nil {
+		tx = s.db
+	}
+	var last model.StockMovement
+	err := tx.WithContext(ctx).
+		Select("balance").
+		Where("product_lot_id = ?", m.ProductLotID).
+		Order("id DESC").
+		Limit(1).
+		Find(&last).Error
+	if err != nil {
+		return err
+	}
+	m.Balance = last.Balance + m.Quantity
+	if m.MovedAt.IsZero() {
+		m.MovedAt = time.Now()
+	}
+	return gorm.G[model.StockMovement](tx).Create(ctx, m)
+}
+
+// FindAll implements StockMovement.
+func (s *stockMovement) FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.StockMovementFilter) ([]model.StockMovement, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	q := tx.WithContext(ctx).Model(&model.StockMovement{}).Where("team_id = ?", teamId)
+	if f != nil {
+		if f.ProductID != 0 {
+			q = q.Where("product_id = ?", f.ProductID)
+		}
+		if f.ProductLotID != 0 {
+			q = q.Where("product_lot_id = ?", f.ProductLotID)
+		}
+		if f.LocationID != 0 {
+			q = q.Where("location_id = ?", f.LocationID)
+		}
+		if f.Reason != "" {
+			q = q.Where("reason = ?", f.Reason)
+		}
+	}
+	if err := q.Count(&pg.Count).Error; err != nil {
+		return nil, err
+	}
+	var movements []model.StockMovement
+	err := q.
+		Preload("Product").
+		Preload("ProductLot").
+		Preload("Location").
+		Order("id DESC").
+		Limit(pg.Limit).
+		Offset(pg.Offset).
+		Find(&movements).Error
+	return movements, err
+}
+
+// Each implements StockMovement.
+func (s *stockMovement) Each(ctx context.Context, tx *gorm.DB, teamId uint, f *filter.StockMovementFilter, fn func(row StockMovementRow) error) error {
+	if tx == nil {
+		tx = s.db
+	}
+	q := tx.WithContext(ctx).
+		Table("stock_movements AS m").
+		Joins("JOIN products AS p ON p.id = m.product_id").
+		Joins("JOIN product_lots AS l ON l.id = m.product_lot_id").
+		Joins("LEFT JOIN locations AS loc ON loc.id = m.location_id").
+		Where("m.team_id = ?", teamId)
+	if f != nil {
+		if f.ProductID != 0 {
+			q = q.Where("m.product_id = ?", f.ProductID)
+		}
+		if f.ProductLotID != 0 {
+			q = q.Where("m.product_lot_id = ?", f.ProductLotID)
+		}
+		if f.LocationID != 0 {
+			q = q.Where("m.location_id = ?", f.LocationID)
+		}
+		if f.Reason != "" {
+			q = q.Where("m.reason = ?", f.Reason)
+		}
+	}
+	rows, err := q.
+		Select("m.id, m.moved_at, p.code AS product_code, p.name AS product_name, p.uom, l.code AS lot_code, " +
+			"COALESCE(loc.code, '') AS location_code, m.quantity, m.balance, m.reason, m.ref_type, m.ref_id").
+		Order("m.id").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close() //nolint:errcheck
+	for rows.Next() {
+		var row StockMovementRow
+		if err := q.ScanRows(rows, &row); err != nil {
+			return err
+		}
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Balance implements StockMovement.
+func (s *stockMovement) Balance(ctx context.Context, tx *gorm.DB, teamId uint, at time.Time, f *filter.StockBalanceFilter) ([]StockBalance, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	q := tx.WithContext(ctx).
+		Table("stock_movements AS m").
+		Joins("JOIN products AS p ON p.id = m.product_id").
+		Joins("JOIN product_lots AS l ON l.id = m.product_lot_id").
+		Joins("LEFT JOIN locations AS loc ON loc.id = m.location_id").
+		Joins("LEFT JOIN warehouses AS w ON w.id = loc.warehouse_id").
+		Where("m.team_id = ? AND m.moved_at <= ?", teamId, at)
+	if f.ProductID != 0 {
+		q = q.Where("m.product_id = ?", f.ProductID)
+	}
+	if f.ProductLotID != 0 {
+		q = q.Where("m.product_lot_id = ?", f.ProductLotID)
+	}
+	if f.LocationID != 0 {
+		q = q.Where("m.location_id = ?", f.LocationID)
+	}
+	if f.WarehouseID != 0 {
+		q = q.Where("loc.warehouse_id = ?", f.WarehouseID)
+	}
+	var columns string
+	switch f.GroupBy {
+	case "product":
+		columns = "m.product_id, p.code, p.name, p.uom"
+	case "location":
+		columns = "m.product_id, p.code, p.name, p.uom, m.product_lot_id, l.code, m.location_id, loc.code, loc.warehouse_id, w.code"
+	case "warehouse":
+		columns = "m.product_id, p.code, p.name, p.uom, loc.warehouse_id, w.code"
+	default:
+		columns = "m.product_id, p.code, p.name, p.uom, m.product_lot_id, l.code"
+	}
+	selects := map[string]string{
+		"m.product_id":     "m.product_id",
+		"p.code":           "p.code AS product_code",
+		"p.name":           "p.name AS product_name",
+		"p.uom":            "p.uom",
+		"m.product_lot_id": "m.product_lot_id",
+		"l.code":           "l.code AS lot_code",
+		"m.location_id":    "m.location_id",
+		"loc.code":         "loc.code AS location_code",
+		"loc.warehouse_id": "loc.warehouse_id AS warehouse_id",
+		"w.code":           "w.code AS warehouse_code",
+	}
+	var selected []string
+	for _, c := range strings.Split(columns, ", ") {
+		selected = append(selected, selects[c])
+	}
+	var balances []StockBalance
+	err := q.
+		Select(strings.Join(selected, ", ")+", SUM(m.quantity) AS quantity").
+		Group(columns).
+		Having("ABS(SUM(m.quantity)) > ?", StockTolerance).
+		Order(columns).
+		Scan(&balances).Error
+	return balances, err
+}
+
+// Reconcile implements StockMovement.
+func (s *stockMovement) Reconcile(ctx context.Context, tx *gorm.DB, teamId uint) ([]StockDiscrepancy, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	var lots []StockDiscrepancy
+	err := tx.WithContext(ctx).
+		Table("product_lots AS l").
+		Joins("LEFT JOIN stock_movements AS m ON m.product_lot_id = l.id").
+		Select("l.product_id, l.id AS product_lot_id, l.code AS lot_code, l.remaining_qty AS cached_qty, COALESCE(SUM(m.quantity), 0) AS ledger_qty").
+		Where("l.team_id = ?", teamId).
+		Group("l.id").
+		Having("ABS(l.remaining_qty - COALESCE(SUM(m.quantity), 0)) > ?", StockTolerance).
+		Order("l.id ASC").
+		Scan(&lots).Error
+	if err != nil {
+		return nil, err
+	}
+	var locations []StockDiscrepancy
+	err = tx.WithContext(ctx).Raw(`
+SELECT d.product_id, d.product_lot_id, l.code AS lot_code, d.location_id, d.cached_qty, d.ledger_qty
+FROM (
+	SELECT
+		COALESCE(s.product_id, g.product_id) AS product_id,
+		COALESCE(s.product_lot_id, g.product_lot_id) AS product_lot_id,
+		COALESCE(s.location_id, g.location_id) AS location_id,
+		COALESCE(s.quantity, 0) AS cached_qty,
+		COALESCE(g.quantity, 0) AS ledger_qty
+	FROM (SELECT * FROM lot_stocks WHERE team_id = ?) AS s
+	FULL OUTER JOIN (
+		SELECT product_id, product_lot_id, location_id, SUM(quantity) AS quantity
+		FROM stock_movements
+		WHERE team_id = ? AND location_id IS NOT NULL
+		GROUP BY product_id, product_lot_id, location_id
+	) AS g ON g.product_lot_id = s.product_lot_id AND g.location_id = s.location_id
+) AS d
+JOIN product_lots AS l ON l.id = d.product_lot_id
+WHERE ABS(d.cached_qty - d.ledger_qty) > ?
+ORDER BY d.product_lot_id ASC, d.location_id ASC`,
+		teamId, teamId, StockTolerance).
+		Scan(&locations).Error
+	return append(lots, locations...), err
+}
+
+// FindUnlocatedTeams implements StockMovement.
+func (s *stockMovement) FindUnlocatedTeams(ctx context.Context, tx *gorm.DB, teamId uint) ([]uint, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	var teamIds []uint
+	err := tx.WithContext(ctx).Raw(`
+SELECT DISTINCT team_id FROM (
+	SELECT team_id
+	FROM stock_movements
+	WHERE location_id IS NULL AND (? = 0 OR team_id = ?)
+	GROUP BY team_id, product_lot_id
+	HAVING ABS(SUM(quantity)) > ?
+) AS unlocated
+ORDER BY team_id`,
+		teamId, teamId, StockTolerance).
+		Scan(&teamIds).Error
+	return teamIds, err
+}
+
+// Relocate implements StockMovement.
+func (s *stockMovement) Relocate(ctx context.Context, tx *gorm.DB, teamId, locationId uint, at time.Time) (int64, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	// a movement out of no location then into locationId per lot, in that order
+	// so the running balance of the last movement stays the lot total
+	res := tx.WithContext(ctx).Exec(`
+WITH unlocated AS (
+	SELECT team_id, product_id, product_lot_id,
+		SUM(quantity) FILTER (WHERE location_id IS NULL) AS quantity,
+		SUM(quantity) AS total
+	FROM stock_movements
+	WHERE team_id = ?
+	GROUP BY team_id, product_id, product_lot_id
+	HAVING ABS(COALESCE(SUM(quantity) FILTER (WHERE location_id IS NULL), 0)) > ?
+)
+INSERT INTO stock_movements (created_at, updated_at, team_id, product_id, product_lot_id, location_id, quantity, balance, reason, ref_type, moved_at)
+SELECT created_at, updated_at, team_id, product_id, product_lot_id, location_id, quantity, balance, reason, ref_type, moved_at
+FROM (
+	SELECT ?::timestamptz AS created_at, ?::timestamptz AS updated_at, team_id, product_id, product_lot_id,
+		NULL::bigint AS location_id, -quantity AS quantity, total - quantity AS balance,
+		?::text AS reason, ''::text AS ref_type, ?::timestamptz AS moved_at, 0 AS step
+	FROM unlocated
+	UNION ALL
+	SELECT ?::timestamptz, ?::timestamptz, team_id, product_id, product_lot_id,
+		?::bigint, quantity, total,
+		?::text, ''::text, ?::timestamptz, 1
+	FROM unlocated
+) AS r
+ORDER BY product_lot_id, step`,
+		teamId, StockTolerance,
+		at, at, model.StockReasonRelocate, at,
+		at, at, locationId, model.StockReasonRelocate, at)
+	return res.RowsAffected / 2, res.Error
+}
+
+// CreateOpening implements StockMovement.
+func (s *stockMovement) CreateOpening(ctx context.Context, tx *gorm.DB, teamId uint, at time.Time) (int64, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	res := tx.WithContext(ctx).Exec(`
+INSERT INTO stock_movements (created_at, updated_at, team_id, product_id, product_lot_id, quantity, balance, reason, ref_type, moved_at)
+SELECT ?, ?, l.team_id, l.product_id, l.id, l.remaining_qty, l.remaining_qty, ?, '', ?
+FROM product_lots AS l
+WHERE (? = 0 OR l.team_id = ?)
+	AND NOT EXISTS (SELECT 1 FROM stock_movements AS m WHERE m.product_lot_id = l.id)`,
+		at, at, model.StockReasonOpening, at, teamId, teamId)
+	return res.RowsAffected, res.Error
+}
+
+// RebuildCache implements StockMovement.
+func (s *stockMovement) RebuildCache(ctx context.Context, tx *gorm.DB, teamId uint) (int64, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	res := tx.WithContext(ctx).Exec(`
+UPDATE product_lots AS l
+SET remaining_qty = ledger.quantity, updated_at = ?
+FROM (
+	SELECT product_lot_id, SUM(quantity) AS quantity
+	FROM stock_movements
+	GROUP BY product_lot_id
+) AS ledger
+WHERE ledger.product_lot_id = l.id
+	AND (? = 0 OR l.team_id = ?)
+	AND ABS(l.remaining_qty - ledger.quantity) > ?`,
+		time.Now(), teamId, teamId, StockTolerance)
+	return res.RowsAffected, res.Error
+}
+
+func NewStockMovement(db *gorm.DB) StockMovement {
+	return &stockMovement{
+		db: db,
+	}
+}
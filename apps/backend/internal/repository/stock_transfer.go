@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	StockTransfer interface {
+		// Create saves the transfer with its items.
+		Create(ctx context.Context, tx *gorm.DB, transfer *model.StockTransfer) error
+		// FindById preloads the locations and the items with their product and lot.
+		FindById(ctx context.Context, tx *gorm.DB, teamId, transferId uint) (*model.StockTransfer, error)
+		// FindByIdForUpdate is FindById locking the transfer row until tx ends.
+		FindByIdForUpdate(ctx context.Context, tx *gorm.DB, teamId, transferId uint) (*model.StockTransfer, error)
+		// FindAll fills pg.Count with the number of matching transfers.
+		FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.StockTransferFilter) ([]model.StockTransfer, error)
+		// Update changes the locations and note of the transfer.
+		Update(ctx context.Context, tx *gorm.DB, transferId, fromLocationId, toLocationId uint, note string) error
+		// UpdateStatus moves the transfer to status with the extra column updates,
+		// only if its status is one of from, otherwise gorm.ErrRecordNotFound.
+		UpdateStatus(ctx context.Context, tx *gorm.DB, transferId uint, from []string, status string, updates map[string]any) error
+		// ReplaceItems deletes the items of the transfer and creates items.
+		ReplaceItems(ctx context.Context, tx *gorm.DB, transferId uint, items []model.StockTransferItem) error
+	}
+	stockTransfer struct {
+		db *gorm.DB
+	}
+)
+
+// Create implements StockTransfer.
+func (s *stockTransfer) Create(ctx context.Context, tx *gorm.DB, transfer *model.StockTransfer) error {
+	if tx == nil {
+		tx = s.db
+	}
+	return tx.WithContext(ctx).Create(transfer).Error
+}
+
+// FindById implements StockTransfer.
+func (s *stockTransfer) FindById(ctx context.Context, tx *gorm.DB, teamId, transferId uint) (*model.StockTransfer, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	return s.find(tx.WithContext(ctx), teamId, transferId)
+}
+
+// FindByIdForUpdate implements StockTransfer.
+func (s *stockTransfer) FindByIdForUpdate(ctx context.Context, tx *gorm.DB, teamId, transferId uint) (*model.StockTransfer, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	// lock the header only, preloads run as separate queries
+	if err := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+		Where("team_id = ? AND id = ?", teamId, transferId).
+		First(&model.StockTransfer{}).Error; err != nil {
+		return nil, err
+	}
+	return s.find(tx.WithContext(ctx), teamId, transferId)
+}
+
+// FindAll implements StockTransfer.
+func (s *stockTransfer) FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.StockTransferFilter) ([]model.StockTransfer, error) {
+	if tx == nil {
+		tx = s.db
+	}
+	q := tx.WithContext(ctx).Model(&model.StockTransfer{}).Where("team_id = ?", teamId)
+	if f.Code != "" {
+		q = q.Where("code ILIKE ?", "%"+f.Code+"%")
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.FromLocationID != 0 {
+		q = q.Where("from_location_id = ?", f.FromLocationID)
+	}
+	if f.ToLocationID != 0 {
+		q = q.Where("to_location_id = ?", f.ToLocationID)
+	}
+	if err := q.Count(&pg.Count).Error; err != nil {
+		return nil, err
+	}
+	var transfers []model.StockTransfer
+	err := q.
+		Preload("FromLocation").
+		Preload("ToLocation").
+		Order("id DESC").
+		Limit(pg.Limit).
+		Offset(pg.Offset).
+		Find(&transfers).Error
+	return transfers, err
+}
+
+// Update implements StockTransfer.
+func (s *stockTransfer) Update(ctx context.Context, tx *gorm.DB, transferId, fromLocationId, toLocationId uint, note string) error {
+	if tx == nil {
+		tx = s.db
+	}
+	return tx.WithContext(ctx).
+		Model(&model.StockTransfer{}).
+		Where("id = ?", transferId).
+		Updates(map[string]any{
+			"from_location_id": fromLocationId,
+			"to_location_id":   toLocationId,
+			"note":             note,
+		}).Error
+}
+
+// UpdateStatus implements StockTransfer.
+func (s *stockTransfer) UpdateStatus(ctx context.Context, tx *gorm.DB, transferId uint, from []string, status string, updates map[string]any) error {
+	if tx == nil {
+		tx = s.db
+	}
+	values := map[string]any{"status": status}
+	for k, v := range updates {
+		values[k] = v
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.StockTransfer{}).
+		Where("id = ? AND status IN ?", transferId, from).
+		Updates(values)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ReplaceItems implements StockTransfer.
+func (s *stockTransfer) ReplaceItems(ctx context.Context, tx *gorm.DB, transferId uint, items []model.StockTransferItem) error {
+	if tx == nil {
+		tx = s.db
+	}
+	if _, err := gorm.G[model.StockTransferItem](tx).Where("stock_transfer_id = ?", transferId).Delete(ctx); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	for i := range items {
+		items[i].ID = 0
+		items[i].StockTransferID = transferId
+		items[i].Product = nil
+		items[i].ProductLot = nil
+	}
+	return gorm.G[model.StockTransferItem](tx).CreateInBatches(ctx, &items, len(items))
+}
+
+func (s *stockTransfer) find(tx *gorm.DB, teamId, transferId uint) (*model.StockTransfer, error) {
+	var m model.StockTransfer
+	err := tx.
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).
+		Preload("Items.Product").
+		Preload("Items.ProductLot").
+		Preload("FromLocation").
+		Preload("ToLocation").
+		Where("team_id = ? AND id = ?", teamId, transferId).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func NewStockTransfer(db *gorm.DB) StockTransfer {
+	return &stockTransfer{
+		db: db,
+	}
+}
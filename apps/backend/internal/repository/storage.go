@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/helpers"
+)
+
+const (
+	StorageDriverLocal = "local"
+	StorageDriverS3    = "s3"
+)
+
+var (
+	ErrStorageObjectNotFound = errors.New("storage: object not found")
+	ErrStorageInvalidKey     = errors.New("storage: invalid key")
+)
+
+type (
+	// Storage is a blob store addressed by slash separated keys such as
+	// "profile/images/<uuid>.png". Every replica of the backend must see
+	// the same objects, so nothing outside a Storage driver should touch
+	// the filesystem for uploaded or generated files.
+	Storage interface {
+		Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (*StorageObject, error)
+		Get(ctx context.Context, key string) (io.ReadSeekCloser, *StorageObject, error)
+		Delete(ctx context.Context, key string) error
+		Stat(ctx context.Context, key string) (*StorageObject, error)
+		// List returns every object whose key starts with prefix.
+		List(ctx context.Context, prefix string) ([]StorageObject, error)
+		// SignedURL returns a URL the object can be downloaded from until expire has passed.
+		SignedURL(ctx context.Context, key string, expire time.Duration) (string, error)
+	}
+
+	StorageObject struct {
+		Key          string
+		Size         int64
+		ContentType  string
+		ETag         string
+		LastModified time.Time
+	}
+)
+
+// CleanStorageKey normalizes key and rejects keys escaping the storage root.
+func CleanStorageKey(key string) (string, error) {
+	key = strings.ReplaceAll(key, "\\", "/")
+	key = strings.TrimPrefix(path.Clean("/"+key), "/")
+	if key == "" || key == "." {
+		return "", ErrStorageInvalidKey
+	}
+	return key, nil
+}
+
+func NewStorage(conf *config.Config, signer helpers.URLSigner) (Storage, error) {
+	switch conf.STORAGE.DRIVER {
+	case StorageDriverLocal, "":
+		return newLocalStorage(conf, signer)
+	case StorageDriverS3:
+		return newS3Storage(conf)
+	default:
+		return nil, fmt.Errorf("storage: unknown driver %q", conf.STORAGE.DRIVER)
+	}
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/helpers"
+)
+
+type (
+	localStorage struct {
+		root    string
+		baseUrl string
+		signer  helpers.URLSigner
+	}
+)
+
+// Delete implements Storage.
+func (l *localStorage) Delete(ctx context.Context, key string) error {
+	_, p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return ErrStorageObjectNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Get implements Storage.
+func (l *localStorage) Get(ctx context.Context, key string) (io.ReadSeekCloser, *StorageObject, error) {
+	key, p, err := l.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, ErrStorageObjectNotFound
+		}
+		return nil, nil, err
+	}
+	obj, err := l.stat(f, key)
+	if err != nil {
+		f.Close() //nolint:errcheck
+		return nil, nil, err
+	}
+	return f, obj, nil
+}
+
+// Put implements Storage.
+func (l *localStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (*StorageObject, error) {
+	key, p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return nil, err
+	}
+	// write to a temp file first so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close() //nolint:errcheck
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), p); err != nil {
+		return nil, err
+	}
+	obj, err := l.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		obj.ContentType = contentType
+	}
+	return obj, nil
+}
+
+// List implements Storage.
+func (l *localStorage) List(ctx context.Context, prefix string) ([]StorageObject, error) {
+	_, dir, err := l.path(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var objects []StorageObject
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// skip directories and in-flight writes of Put
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, StorageObject{
+			Key:          filepath.ToSlash(rel),
+			Size:         info.Size(),
+			ContentType:  mime.TypeByExtension(path.Ext(p)),
+			ETag:         fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size()),
+			LastModified: info.ModTime(),
+		})
+		return nil
+	})
+	return objects, err
+}
+
+// SignedURL implements Storage.
+//
+// Local files are served by the application itself, the URL points to the
+// signed download route and carries an HMAC of the key and its expiry.
+func (l *localStorage) SignedURL(ctx context.Context, key string, expire time.Duration) (string, error) {
+	key, err := CleanStorageKey(key)
+	if err != nil {
+		return "", err
+	}
+	q := l.signer.Sign("/"+key, nil, time.Now().Add(expire))
+	return l.baseUrl + (&url.URL{Path: "/" + key}).EscapedPath() + "?" + q.Encode(), nil
+}
+
+// Stat implements Storage.
+func (l *localStorage) Stat(ctx context.Context, key string) (*StorageObject, error) {
+	key, p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrStorageObjectNotFound
+		}
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+	return l.stat(f, key)
+}
+
+func (l *localStorage) stat(f *os.File, key string) (*StorageObject, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrStorageObjectNotFound
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		buf := make([]byte, 512)
+		n, err := f.Read(buf)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+		contentType = http.DetectContentType(buf[:n])
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	return &StorageObject{
+		Key:          key,
+		Size:         info.Size(),
+		ContentType:  contentType,
+		ETag:         fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size()),
+		LastModified: info.ModTime(),
+	}, nil
+}
+
+// path returns the cleaned key and its location on disk.
+func (l *localStorage) path(key string) (string, string, error) {
+	key, err := CleanStorageKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	return key, filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+func newLocalStorage(conf *config.Config, signer helpers.URLSigner) (Storage, error) {
+	root, err := filepath.Abs(conf.STORAGE.LOCAL.ROOT)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, err
+	}
+	return &localStorage{
+		root:    root,
+		baseUrl: strings.TrimSuffix(conf.STORAGE.LOCAL.BASE_URL, "/"),
+		signer:  signer,
+	}, nil
+}
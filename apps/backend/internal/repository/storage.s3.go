@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/suttapak/starter/domain/config"
+)
+
+type (
+	// s3Storage works with any S3 compatible service (AWS S3, MinIO, ...).
+	s3Storage struct {
+		client *minio.Client
+		bucket string
+	}
+)
+
+// Delete implements Storage.
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	key, err := CleanStorageKey(key)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Stat(ctx, key); err != nil {
+		return err
+	}
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+// Get implements Storage.
+func (s *s3Storage) Get(ctx context.Context, key string) (io.ReadSeekCloser, *StorageObject, error) {
+	key, err := CleanStorageKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, s.handleErr(err)
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close() //nolint:errcheck
+		return nil, nil, s.handleErr(err)
+	}
+	return obj, s.toStorageObject(info), nil
+}
+
+// Put implements Storage.
+func (s *s3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (*StorageObject, error) {
+	key, err := CleanStorageKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		size = -1
+	}
+	info, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &StorageObject{
+		Key:          key,
+		Size:         info.Size,
+		ContentType:  contentType,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+	}, nil
+}
+
+// List implements Storage.
+func (s *s3Storage) List(ctx context.Context, prefix string) ([]StorageObject, error) {
+	prefix, err := CleanStorageKey(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var objects []StorageObject
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{
+		Prefix:    prefix + "/",
+		Recursive: true,
+	}) {
+		if info.Err != nil {
+			return nil, info.Err
+		}
+		objects = append(objects, *s.toStorageObject(info))
+	}
+	return objects, nil
+}
+
+// SignedURL implements Storage.
+func (s *s3Storage) SignedURL(ctx context.Context, key string, expire time.Duration) (string, error) {
+	key, err := CleanStorageKey(key)
+	if err != nil {
+		return "", err
+	}
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, expire, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// Stat implements Storage.
+func (s *s3Storage) Stat(ctx context.Context, key string) (*StorageObject, error) {
+	key, err := CleanStorageKey(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, s.handleErr(err)
+	}
+	return s.toStorageObject(info), nil
+}
+
+func (s *s3Storage) toStorageObject(info minio.ObjectInfo) *StorageObject {
+	return &StorageObject{
+		Key:          info.Key,
+		Size:         info.Size,
+		ContentType:  info.ContentType,
+		ETag:         info.ETag,
+		LastModified: info.LastModified,
+	}
+}
+
+func (s *s3Storage) handleErr(err error) error {
+	if resp := minio.ToErrorResponse(err); resp.StatusCode == http.StatusNotFound || resp.Code == "NoSuchKey" {
+		return ErrStorageObjectNotFound
+	}
+	return err
+}
+
+func newS3Storage(conf *config.Config) (Storage, error) {
+	c := conf.STORAGE.S3
+	client, err := minio.New(c.ENDPOINT, &minio.Options{
+		Creds:  credentials.NewStaticV4(c.ACCESS_KEY, c.SECRET_KEY, ""),
+		Secure: c.USE_SSL,
+		Region: c.REGION,
+	})
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, c.BUCKET)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, c.BUCKET, minio.MakeBucketOptions{Region: c.REGION}); err != nil {
+			return nil, err
+		}
+	}
+	return &s3Storage{
+		client: client,
+		bucket: c.BUCKET,
+	}, nil
+}
@@ -0,0 +1,225 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	Transaction interface {
+		// Create saves the transaction with its items.
+		Create(ctx context.Context, tx *gorm.DB, transaction *model.Transaction) error
+		// FindById preloads the items with their product and lot, and the partner.
+		FindById(ctx context.Context, tx *gorm.DB, teamId, transactionId uint) (*model.Transaction, error)
+		// FindByIdForUpdate is FindById locking the transaction row until tx ends.
+		FindByIdForUpdate(ctx context.Context, tx *gorm.DB, teamId, transactionId uint) (*model.Transaction, error)
+		// FindAll fills pg.Count with the number of matching transactions.
+		FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.TransactionFilter) ([]model.Transaction, error)
+		UpdateNote(ctx context.Context, tx *gorm.DB, transactionId uint, note string) error
+		UpdateLocation(ctx context.Context, tx *gorm.DB, transactionId uint, locationId *uint) error
+		UpdatePartner(ctx context.Context, tx *gorm.DB, transactionId uint, partnerId *uint) error
+		// UpdateStatus moves the transaction to status with the extra column updates,
+		// only if its status is one of from, otherwise gorm.ErrRecordNotFound.
+		UpdateStatus(ctx context.Context, tx *gorm.DB, transactionId uint, from []string, status string, updates map[string]any) error
+		// ReplaceItems deletes the items of the transaction and creates items.
+		ReplaceItems(ctx context.Context, tx *gorm.DB, transactionId uint, items []model.TransactionItem) error
+		// CountChildren counts the transactions referencing parentId in one of statuses.
+		CountChildren(ctx context.Context, tx *gorm.DB, parentId uint, statuses []string) (int64, error)
+		// SumReturnedByLot returns the quantity per lot of the approved returns of parentId.
+		SumReturnedByLot(ctx context.Context, tx *gorm.DB, parentId uint) (map[uint]float64, error)
+	}
+	transaction struct {
+		db *gorm.DB
+	}
+)
+
+// Create implements Transaction.
+func (t *transaction) Create(ctx context.Context, tx *gorm.DB, transaction *model.Transaction) error {
+	if tx == nil {
+		tx = t.db
+	}
+	return tx.WithContext(ctx).Create(transaction).Error
+}
+
+// FindById implements Transaction.
+func (t *transaction) FindById(ctx context.Context, tx *gorm.DB, teamId, transactionId uint) (*model.Transaction, error) {
+	if tx == nil {
+		tx = t.db
+	}
+	return t.find(tx.WithContext(ctx), teamId, transactionId)
+}
+
+// FindByIdForUpdate implements Transaction.
+func (t *transaction) FindByIdForUpdate(ctx context.Context, tx *gorm.DB, teamId, transactionId uint) (*model.Transaction, error) {
+	if tx == nil {
+		tx = t.db
+	}
+	// lock the header only, preloads run as separate queries
+	if err := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+		Where("team_id = ? AND id = ?", teamId, transactionId).
+		First(&model.Transaction{}).Error; err != nil {
+		return nil, err
+	}
+	return t.find(tx.WithContext(ctx), teamId, transactionId)
+}
+
+// FindAll implements Transaction.
+func (t *transaction) FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.TransactionFilter) ([]model.Transaction, error) {
+	if tx == nil {
+		tx = t.db
+	}
+	q := tx.WithContext(ctx).Model(&model.Transaction{}).Where("team_id = ?", teamId)
+	if f.Code != "" {
+		q = q.Where("code ILIKE ?", "%"+f.Code+"%")
+	}
+	if f.Type != "" {
+		q = q.Where("type = ?", f.Type)
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.PartnerID != 0 {
+		q = q.Where("partner_id = ?", f.PartnerID)
+	}
+	if err := q.Count(&pg.Count).Error; err != nil {
+		return nil, err
+	}
+	var transactions []model.Transaction
+	err := q.Preload("Partner").Order("id DESC").Limit(pg.Limit).Offset(pg.Offset).Find(&transactions).Error
+	return transactions, err
+}
+
+// UpdateNote implements Transaction.
+func (t *transaction) UpdateNote(ctx context.Context, tx *gorm.DB, transactionId uint, note string) error {
+	if tx == nil {
+		tx = t.db
+	}
+	_, err := gorm.G[model.Transaction](tx).Where("id = ?", transactionId).Update(ctx, "note", note)
+	return err
+}
+
+// UpdateLocation implements Transaction.
+func (t *transaction) UpdateLocation(ctx context.Context, tx *gorm.DB, transactionId uint, locationId *uint) error {
+	if tx == nil {
+		tx = t.db
+	}
+	return tx.WithContext(ctx).
+		Model(&model.Transaction{}).
+		Where("id = ?", transactionId).
+		Update("location_id", locationId).Error
+}
+
+// UpdatePartner implements Transaction.
+func (t *transaction) UpdatePartner(ctx context.Context, tx *gorm.DB, transactionId uint, partnerId *uint) error {
+	if tx == nil {
+		tx = t.db
+	}
+	return tx.WithContext(ctx).
+		Model(&model.Transaction{}).
+		Where("id = ?", transactionId).
+		Update("partner_id", partnerId).Error
+}
+
+// UpdateStatus implements Transaction.
+func (t *transaction) UpdateStatus(ctx context.Context, tx *gorm.DB, transactionId uint, from []string, status string, updates map[string]any) error {
+	if tx == nil {
+		tx = t.db
+	}
+	values := map[string]any{"status": status}
+	for k, v := range updates {
+		values[k] = v
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.Transaction{}).
+		Where("id = ? AND status IN ?", transactionId, from).
+		Updates(values)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ReplaceItems implements Transaction.
+func (t *transaction) ReplaceItems(ctx context.Context, tx *gorm.DB, transactionId uint, items []model.TransactionItem) error {
+	if tx == nil {
+		tx = t.db
+	}
+	if _, err := gorm.G[model.TransactionItem](tx).Where("transaction_id = ?", transactionId).Delete(ctx); err != nil {
+		return err
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	for i := range items {
+		items[i].ID = 0
+		items[i].TransactionID = transactionId
+	}
+	return gorm.G[model.TransactionItem](tx).CreateInBatches(ctx, &items, len(items))
+}
+
+// CountChildren implements Transaction.
+func (t *transaction) CountChildren(ctx context.Context, tx *gorm.DB, parentId uint, statuses []string) (int64, error) {
+	if tx == nil {
+		tx = t.db
+	}
+	return gorm.G[model.Transaction](tx).Where("parent_id = ? AND status IN ?", parentId, statuses).Count(ctx, "id")
+}
+
+// SumReturnedByLot implements Transaction.
+func (t *transaction) SumReturnedByLot(ctx context.Context, tx *gorm.DB, parentId uint) (map[uint]float64, error) {
+	if tx == nil {
+		tx = t.db
+	}
+	var rows []struct {
+		ProductLotID uint
+		Quantity     float64
+	}
+	err := tx.WithContext(ctx).
+		Model(&model.TransactionItem{}).
+		Select("transaction_items.product_lot_id, SUM(transaction_items.quantity) AS quantity").
+		Joins("JOIN transactions ON transactions.id = transaction_items.transaction_id").
+		Where("transactions.parent_id = ? AND transactions.status = ?", parentId, model.TransactionStatusApproved).
+		Group("transaction_items.product_lot_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	returned := make(map[uint]float64, len(rows))
+	for _, r := range rows {
+		returned[r.ProductLotID] = r.Quantity
+	}
+	return returned, nil
+}
+
+func (t *transaction) find(tx *gorm.DB, teamId, transactionId uint) (*model.Transaction, error) {
+	var m model.Transaction
+	err := tx.
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id ASC")
+		}).
+		Preload("Items.Product").
+		Preload("Items.ProductLot").
+		Preload("Items.Uom").
+		Preload("Partner").
+		Where("team_id = ? AND id = ?", teamId, transactionId).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func NewTransaction(db *gorm.DB) Transaction {
+	return &transaction{
+		db: db,
+	}
+}
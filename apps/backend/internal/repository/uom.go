@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	Uom interface {
+		Create(ctx context.Context, tx *gorm.DB, uom *model.Uom) error
+		// CreateIfNotExists creates the unit unless its name is taken, uom is then
+		// loaded from the existing row. It does not abort tx on conflict.
+		CreateIfNotExists(ctx context.Context, tx *gorm.DB, uom *model.Uom) error
+		// Update renames the unit, the unit name of the products based on it follows.
+		Update(ctx context.Context, tx *gorm.DB, teamId, uomId uint, name, description string) error
+		Delete(ctx context.Context, tx *gorm.DB, teamId, uomId uint) error
+		FindById(ctx context.Context, tx *gorm.DB, teamId, uomId uint) (*model.Uom, error)
+		// FindAll fills pg.Count with the number of matching units.
+		FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.UomFilter) ([]model.Uom, error)
+		// CountByIds counts the units of teamId among uomIds.
+		CountByIds(ctx context.Context, tx *gorm.DB, teamId uint, uomIds []uint) (int64, error)
+		// InUse reports whether a product or a transaction item uses the unit.
+		InUse(ctx context.Context, tx *gorm.DB, uomId uint) (bool, error)
+
+		// FindConversions returns every unit of the products, the base unit with
+		// a factor of one first.
+		FindConversions(ctx context.Context, tx *gorm.DB, productIds []uint) ([]UomConversion, error)
+		// ReplaceProductUoms sets exactly uoms as the alternate units of the product.
+		ReplaceProductUoms(ctx context.Context, tx *gorm.DB, productId uint, uoms []model.ProductUom) error
+	}
+	uom struct {
+		db *gorm.DB
+	}
+
+	UomConversion struct {
+		ProductID uint
+		UomID     uint
+		UomName   string
+		Factor    float64
+		IsBase    bool
+	}
+)
+
+// Create implements Uom.
+func (u *uom) Create(ctx context.Context, tx *gorm.DB, m *model.Uom) error {
+	if tx == nil {
+		tx = u.db
+	}
+	return gorm.G[model.Uom](tx).Create(ctx, m)
+}
+
+// CreateIfNotExists implements Uom.
+func (u *uom) CreateIfNotExists(ctx context.Context, tx *gorm.DB, m *model.Uom) error {
+	if tx == nil {
+		tx = u.db
+	}
+	if err := tx.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(m).Error; err != nil {
+		return err
+	}
+	if m.ID != 0 {
+		return nil
+	}
+	return tx.WithContext(ctx).Where("team_id = ? AND name = ?", m.TeamID, m.Name).First(m).Error
+}
+
+// Update implements Uom.
+func (u *uom) Update(ctx context.Context, tx *gorm.DB, teamId, uomId uint, name, description string) error {
+	if tx == nil {
+		tx = u.db
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.Uom{}).
+		Where("team_id = ? AND id = ?", teamId, uomId).
+		Updates(map[string]any{"name": name, "description": description})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return tx.WithContext(ctx).
+		Model(&model.Product{}).
+		Where("team_id = ? AND base_uom_id = ?", teamId, uomId).
+		Update("uom", name).Error
+}
+
+// Delete implements Uom.
+func (u *uom) Delete(ctx context.Context, tx *gorm.DB, teamId, uomId uint) error {
+	if tx == nil {
+		tx = u.db
+	}
+	rows, err := gorm.G[model.Uom](tx).Where("team_id = ? AND id = ?", teamId, uomId).Delete(ctx)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindById implements Uom.
+func (u *uom) FindById(ctx context.Context, tx *gorm.DB, teamId, uomId uint) (*model.Uom, error) {
+	if tx == nil {
+		tx = u.db
+	}
+	m, err := gorm.G[model.Uom](tx).Where("team_id = ? AND id = ?", teamId, uomId).First(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindAll implements Uom.
+func (u *uom) FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.UomFilter) ([]model.Uom, error) {
+	if tx == nil {
+		tx = u.db
+	}
+	q := tx.WithContext(ctx).Model(&model.Uom{}).Where("team_id = ?", teamId)
+	if f.Name != "" {
+		q = q.Where("name ILIKE ?", "%"+f.Name+"%")
+	}
+	if err := q.Count(&pg.Count).Error; err != nil {
+		return nil, err
+	}
+	var uoms []model.Uom
+	err := q.Order("name ASC").Limit(pg.Limit).Offset(pg.Offset).Find(&uoms).Error
+	return uoms, err
+}
+
+// CountByIds implements Uom.
+func (u *uom) CountByIds(ctx context.Context, tx *gorm.DB, teamId uint, uomIds []uint) (int64, error) {
+	if tx == nil {
+		tx = u.db
+	}
+	return gorm.G[model.Uom](tx).Where("team_id = ? AND id IN ?", teamId, uomIds).Count(ctx, "id")
+}
+
+// InUse implements Uom.
+func (u *uom) InUse(ctx context.Context, tx *gorm.DB, uomId uint) (bool, error) {
+	if tx == nil {
+		tx = u.db
+	}
+	var used bool
+	err := tx.WithContext(ctx).Raw(`
+SELECT EXISTS (SELECT 1 FROM products WHERE base_uom_id = @id)
+	OR EXISTS (SELECT 1 FROM product_uoms WHERE uom_id = @id)
+	OR EXISTS (SELECT 1 FROM transaction_items WHERE uom_id = @id)
+`, map[string]any{"id": uomId}).Scan(&used).Error
+	return used, err
+}
+
+// FindConversions implements Uom.
+func (u *uom) FindConversions(ctx context.Context, tx *gorm.DB, productIds []uint) ([]UomConversion, error) {
+	if tx == nil {
+		tx = u.db
+	}
+	var conversions []UomConversion
+	if len(productIds) == 0 {
+		return conversions, nil
+	}
+	err := tx.WithContext(ctx).Raw(`
+SELECT c.product_id, c.uom_id, u.name AS uom_name, c.factor, c.is_base
+FROM (
+	SELECT id AS product_id, base_uom_id AS uom_id, 1 AS factor, TRUE AS is_base
+	FROM products WHERE id IN @ids AND base_uom_id IS NOT NULL
+	UNION ALL
+	SELECT product_id, uom_id, factor, FALSE AS is_base
+	FROM product_uoms WHERE product_id IN @ids
+) AS c
+JOIN uoms AS u ON u.id = c.uom_id
+ORDER BY c.product_id ASC, c.is_base DESC, c.factor ASC
+`, map[string]any{"ids": productIds}).Scan(&conversions).Error
+	return conversions, err
+}
+
+// ReplaceProductUoms implements Uom.
+func (u *uom) ReplaceProductUoms(ctx context.Context, tx *gorm.DB, productId uint, uoms []model.ProductUom) error {
+	if tx == nil {
+		tx = u.db
+	}
+	if _, err := gorm.G[model.ProductUom](tx).Where("product_id = ?", productId).Delete(ctx); err != nil {
+		return err
+	}
+	if len(uoms) == 0 {
+		return nil
+	}
+	for i := range uoms {
+		uoms[i].ProductID = productId
+	}
+	return gorm.G[model.ProductUom](tx).CreateInBatches(ctx, &uoms, len(uoms))
+}
+
+func NewUom(db *gorm.DB) Uom {
+	return &uom{
+		db: db,
+	}
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	Upload interface {
+		Create(ctx context.Context, tx *gorm.DB, upload *model.Upload) error
+		FindByUUID(ctx context.Context, tx *gorm.DB, uuid string) (*model.Upload, error)
+		// AppendPart stores part and moves the offset of the upload forward, it
+		// fails with gorm.ErrRecordNotFound when the offset was moved by someone else.
+		AppendPart(ctx context.Context, tx *gorm.DB, upload *model.Upload, part *model.UploadPart) error
+		// Lock locks the upload row until tx ends and returns it, without its parts.
+		Lock(ctx context.Context, tx *gorm.DB, uploadId uint) (*model.Upload, error)
+		// Complete records the result of an upload once, it fails with
+		// gorm.ErrRecordNotFound when the upload was already completed.
+		Complete(ctx context.Context, tx *gorm.DB, uploadId uint, resultId uint) error
+		Delete(ctx context.Context, tx *gorm.DB, uploadId uint) error
+		FindExpired(ctx context.Context, tx *gorm.DB, now time.Time) ([]model.Upload, error)
+	}
+	upload struct {
+		db *gorm.DB
+	}
+)
+
+// Create implements Upload.
+func (u *upload) Create(ctx context.Context, tx *gorm.DB, upload *model.Upload) error {
+	if tx == nil {
+		tx = u.db
+	}
+	return gorm.G[model.Upload](tx).Create(ctx, upload)
+}
+
+// FindByUUID implements Upload.
+func (u *upload) FindByUUID(ctx context.Context, tx *gorm.DB, uuid string) (*model.Upload, error) {
+	if tx == nil {
+		tx = u.db
+	}
+	var m model.Upload
+	err := tx.WithContext(ctx).
+		Preload("Parts", func(db *gorm.DB) *gorm.DB {
+			return db.Order("\"offset\" ASC")
+		}).
+		Where("uuid = ?", uuid).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// AppendPart implements Upload.
+func (u *upload) AppendPart(ctx context.Context, tx *gorm.DB, upload *model.Upload, part *model.UploadPart) error {
+	if tx == nil {
+		tx = u.db
+	}
+	return tx.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&model.Upload{}).
+			Where("id = ? AND \"offset\" = ?", upload.ID, part.Offset).
+			Updates(map[string]any{
+				"offset":     gorm.Expr("\"offset\" + ?", part.Size),
+				"expires_at": upload.ExpiresAt,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		part.UploadID = upload.ID
+		return tx.Create(part).Error
+	})
+}
+
+// Lock implements Upload.
+func (u *upload) Lock(ctx context.Context, tx *gorm.DB, uploadId uint) (*model.Upload, error) {
+	if tx == nil {
+		tx = u.db
+	}
+	var m model.Upload
+	err := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+		Where("id = ?", uploadId).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Complete implements Upload.
+func (u *upload) Complete(ctx context.Context, tx *gorm.DB, uploadId uint, resultId uint) error {
+	if tx == nil {
+		tx = u.db
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.Upload{}).
+		Where("id = ? AND completed_at IS NULL", uploadId).
+		Updates(map[string]any{
+			"completed_at": time.Now(),
+			"result_id":    resultId,
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete implements Upload.
+func (u *upload) Delete(ctx context.Context, tx *gorm.DB, uploadId uint) error {
+	if tx == nil {
+		tx = u.db
+	}
+	return tx.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("upload_id = ?", uploadId).Delete(&model.UploadPart{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", uploadId).Delete(&model.Upload{}).Error
+	})
+}
+
+// FindExpired implements Upload.
+func (u *upload) FindExpired(ctx context.Context, tx *gorm.DB, now time.Time) ([]model.Upload, error) {
+	if tx == nil {
+		tx = u.db
+	}
+	return gorm.G[model.Upload](tx).Where("completed_at IS NULL AND expires_at < ?", now).Find(ctx)
+}
+
+func NewUpload(db *gorm.DB) Upload {
+	return &upload{db: db}
+}
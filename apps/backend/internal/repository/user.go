@@ -17,6 +17,10 @@ type (
 		GetUserByEmailOrUsername(ctx context.Context, tx *gorm.DB, emailOrUsername string) (user *model.User, err error)
 		FindById(ctx context.Context, tx *gorm.DB, uId uint) (user *model.User, err error)
 		VerifyEmail(ctx context.Context, tx *gorm.DB, userId uint) (user *model.User, err error)
+		// ChangePassword replaces the password hash from with to and verifies the
+		// email, as the link to change it was mailed. It fails with
+		// gorm.ErrRecordNotFound when the hash is no longer from.
+		ChangePassword(ctx context.Context, tx *gorm.DB, userId uint, from, to string) error
 		FindByUsername(ctx context.Context, tx *gorm.DB, username string) (user []model.User, err error)
 		IsVerifyEmailByUserId(ctx context.Context, tx *gorm.DB, userId uint) (bool, error)
 		// Create Image Profile From Image ID
@@ -113,6 +117,24 @@ func (u *user) VerifyEmail(ctx context.Context, tx *gorm.DB, userId uint) (user
 	return
 }
 
+// ChangePassword implements User.
+func (u *user) ChangePassword(ctx context.Context, tx *gorm.DB, userId uint, from, to string) error {
+	if tx == nil {
+		tx = u.db
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.User{}).
+		Where("id = ? AND password = ?", userId, from).
+		Updates(map[string]any{"password": to, "email_verifyed": true})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
 func (u *user) GetUserByEmailOrUsername(ctx context.Context, tx *gorm.DB, emailOrUsername string) (user *model.User, err error) {
 	if tx == nil {
 		tx = u.db
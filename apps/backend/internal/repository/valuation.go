@@ -0,0 +1,221 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	Valuation interface {
+		// FindSetting returns the inventory setting of the team, gorm.ErrRecordNotFound
+		// when it has none.
+		FindSetting(ctx context.Context, tx *gorm.DB, teamId uint) (*model.InventorySetting, error)
+		// SaveSetting creates the setting of its team or updates the existing one.
+		SaveSetting(ctx context.Context, tx *gorm.DB, setting *model.InventorySetting) error
+
+		// LockProductCost returns the moving average of the product, created
+		// empty on first use, locking its row until tx ends.
+		LockProductCost(ctx context.Context, tx *gorm.DB, teamId, productId uint) (*model.ProductCost, error)
+		UpdateProductCost(ctx context.Context, tx *gorm.DB, productCostId uint, qty, value float64) error
+
+		CreateLayer(ctx context.Context, tx *gorm.DB, layer *model.CostLayer) error
+		// FindOpenLayers returns the layers of the product with a remaining
+		// quantity, oldest first.
+		FindOpenLayers(ctx context.Context, tx *gorm.DB, productId uint) ([]model.CostLayer, error)
+		// ConsumeLayer takes qty out of the remaining quantity of the layer.
+		ConsumeLayer(ctx context.Context, tx *gorm.DB, layerId uint, qty float64) error
+		// LotCost returns the unit cost of the lot.
+		LotCost(ctx context.Context, tx *gorm.DB, lotId uint) (float64, error)
+
+		CreateEntry(ctx context.Context, tx *gorm.DB, entry *model.CostEntry) error
+		// Balance sums the cost journal per product up to at.
+		Balance(ctx context.Context, tx *gorm.DB, teamId uint, at time.Time, f *filter.ValuationFilter) ([]ValuationBalance, error)
+		// Cogs sums the sale and return entries per product posted in [from, to).
+		Cogs(ctx context.Context, tx *gorm.DB, teamId uint, from, to time.Time, f *filter.CogsFilter) ([]CogsBalance, error)
+	}
+	valuation struct {
+		db *gorm.DB
+	}
+
+	ValuationBalance struct {
+		ProductID   uint
+		ProductCode string
+		ProductName string
+		UOM         string
+		Quantity    float64
+		Value       float64
+	}
+	CogsBalance struct {
+		ProductID   uint
+		ProductCode string
+		ProductName string
+		UOM         string
+		// Quantity and Amount are the issued quantity and its cost, net of returns.
+		Quantity float64
+		Amount   float64
+	}
+)
+
+// FindSetting implements Valuation.
+func (v *valuation) FindSetting(ctx context.Context, tx *gorm.DB, teamId uint) (*model.InventorySetting, error) {
+	if tx == nil {
+		tx = v.db
+	}
+	m, err := gorm.G[model.InventorySetting](tx).Where("team_id = ?", teamId).First(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// SaveSetting implements Valuation.
+func (v *valuation) SaveSetting(ctx context.Context, tx *gorm.DB, m *model.InventorySetting) error {
+	if tx == nil {
+		tx = v.db
+	}
+	return tx.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "team_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"costing_method", "updated_at"}),
+		}).
+		Create(m).Error
+}
+
+// LockProductCost implements Valuation.
+func (v *valuation) LockProductCost(ctx context.Context, tx *gorm.DB, teamId, productId uint) (*model.ProductCost, error) {
+	if tx == nil {
+		tx = v.db
+	}
+	if err := tx.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.ProductCost{TeamID: teamId, ProductID: productId}).Error; err != nil {
+		return nil, err
+	}
+	var m model.ProductCost
+	err := tx.WithContext(ctx).
+		Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).
+		Where("product_id = ?", productId).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// UpdateProductCost implements Valuation.
+func (v *valuation) UpdateProductCost(ctx context.Context, tx *gorm.DB, productCostId uint, qty, value float64) error {
+	if tx == nil {
+		tx = v.db
+	}
+	return tx.WithContext(ctx).
+		Model(&model.ProductCost{}).
+		Where("id = ?", productCostId).
+		Updates(map[string]any{"quantity": qty, "value": value}).Error
+}
+
+// CreateLayer implements Valuation.
+func (v *valuation) CreateLayer(ctx context.Context, tx *gorm.DB, m *model.CostLayer) error {
+	if tx == nil {
+		tx = v.db
+	}
+	return gorm.G[model.CostLayer](tx).Create(ctx, m)
+}
+
+// FindOpenLayers implements Valuation.
+func (v *valuation) FindOpenLayers(ctx context.Context, tx *gorm.DB, productId uint) ([]model.CostLayer, error) {
+	if tx == nil {
+		tx = v.db
+	}
+	return gorm.G[model.CostLayer](tx).
+		Where("product_id = ? AND remaining_qty > 0", productId).
+		Order("received_at ASC, id ASC").
+		Find(ctx)
+}
+
+// ConsumeLayer implements Valuation.
+func (v *valuation) ConsumeLayer(ctx context.Context, tx *gorm.DB, layerId uint, qty float64) error {
+	if tx == nil {
+		tx = v.db
+	}
+	return tx.WithContext(ctx).
+		Model(&model.CostLayer{}).
+		Where("id = ?", layerId).
+		Update("remaining_qty", gorm.Expr("remaining_qty - ?", qty)).Error
+}
+
+// LotCost implements Valuation.
+func (v *valuation) LotCost(ctx context.Context, tx *gorm.DB, lotId uint) (float64, error) {
+	if tx == nil {
+		tx = v.db
+	}
+	var cost float64
+	err := tx.WithContext(ctx).
+		Model(&model.ProductLot{}).
+		Where("id = ?", lotId).
+		Select("unit_cost").
+		Scan(&cost).Error
+	return cost, err
+}
+
+// CreateEntry implements Valuation.
+func (v *valuation) CreateEntry(ctx context.Context, tx *gorm.DB, m *model.CostEntry) error {
+	if tx == nil {
+		tx = v.db
+	}
+	return gorm.G[model.CostEntry](tx).Create(ctx, m)
+}
+
+// Balance implements Valuation.
+func (v *valuation) Balance(ctx context.Context, tx *gorm.DB, teamId uint, at time.Time, f *filter.ValuationFilter) ([]ValuationBalance, error) {
+	if tx == nil {
+		tx = v.db
+	}
+	q := tx.WithContext(ctx).
+		Table("cost_entries AS e").
+		Joins("JOIN products AS p ON p.id = e.product_id").
+		Select("e.product_id, p.code AS product_code, p.name AS product_name, p.uom, SUM(e.quantity) AS quantity, SUM(e.amount) AS value").
+		Where("e.team_id = ? AND e.posted_at <= ?", teamId, at)
+	if f.ProductID != 0 {
+		q = q.Where("e.product_id = ?", f.ProductID)
+	}
+	var balances []ValuationBalance
+	err := q.
+		Group("e.product_id, p.code, p.name, p.uom").
+		Having("SUM(e.quantity) <> 0 OR SUM(e.amount) <> 0").
+		Order("p.code ASC").
+		Scan(&balances).Error
+	return balances, err
+}
+
+// Cogs implements Valuation.
+func (v *valuation) Cogs(ctx context.Context, tx *gorm.DB, teamId uint, from, to time.Time, f *filter.CogsFilter) ([]CogsBalance, error) {
+	if tx == nil {
+		tx = v.db
+	}
+	q := tx.WithContext(ctx).
+		Table("cost_entries AS e").
+		Joins("JOIN products AS p ON p.id = e.product_id").
+		Select("e.product_id, p.code AS product_code, p.name AS product_name, p.uom, -SUM(e.quantity) AS quantity, -SUM(e.amount) AS amount").
+		Where("e.team_id = ? AND e.posted_at >= ? AND e.posted_at < ?", teamId, from, to).
+		Where("e.reason IN ?", []string{model.StockReasonSale, model.StockReasonReturn})
+	if f.ProductID != 0 {
+		q = q.Where("e.product_id = ?", f.ProductID)
+	}
+	var balances []CogsBalance
+	err := q.
+		Group("e.product_id, p.code, p.name, p.uom").
+		Order("p.code ASC").
+		Scan(&balances).Error
+	return balances, err
+}
+
+func NewValuation(db *gorm.DB) Valuation {
+	return &valuation{
+		db: db,
+	}
+}
@@ -0,0 +1,271 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	Warehouse interface {
+		Create(ctx context.Context, tx *gorm.DB, warehouse *model.Warehouse) error
+		// CreateIfNotExists creates the warehouse unless its code is taken, warehouse
+		// is then loaded from the existing row. It does not abort tx on conflict.
+		CreateIfNotExists(ctx context.Context, tx *gorm.DB, warehouse *model.Warehouse) error
+		Update(ctx context.Context, tx *gorm.DB, teamId, warehouseId uint, name, address string) error
+		// Delete removes the warehouse with its locations.
+		Delete(ctx context.Context, tx *gorm.DB, teamId, warehouseId uint) error
+		FindById(ctx context.Context, tx *gorm.DB, teamId, warehouseId uint) (*model.Warehouse, error)
+		// FindAll fills pg.Count with the number of matching warehouses.
+		FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.WarehouseFilter) ([]model.Warehouse, error)
+	}
+	warehouse struct {
+		db *gorm.DB
+	}
+
+	Location interface {
+		Create(ctx context.Context, tx *gorm.DB, location *model.Location) error
+		// CreateIfNotExists is Warehouse.CreateIfNotExists for locations.
+		CreateIfNotExists(ctx context.Context, tx *gorm.DB, location *model.Location) error
+		Update(ctx context.Context, tx *gorm.DB, teamId, locationId uint, code, name string) error
+		Delete(ctx context.Context, tx *gorm.DB, teamId, locationId uint) error
+		FindById(ctx context.Context, tx *gorm.DB, teamId, locationId uint) (*model.Location, error)
+		FindByWarehouse(ctx context.Context, tx *gorm.DB, teamId, warehouseId uint) ([]model.Location, error)
+		// FindDefault returns the default storage location of the team.
+		FindDefault(ctx context.Context, tx *gorm.DB, teamId uint) (*model.Location, error)
+		// FindTransit returns the transit location of the team.
+		FindTransit(ctx context.Context, tx *gorm.DB, teamId uint) (*model.Location, error)
+	}
+	location struct {
+		db *gorm.DB
+	}
+)
+
+// Create implements Warehouse.
+func (w *warehouse) Create(ctx context.Context, tx *gorm.DB, m *model.Warehouse) error {
+	if tx == nil {
+		tx = w.db
+	}
+	return gorm.G[model.Warehouse](tx).Create(ctx, m)
+}
+
+// CreateIfNotExists implements Warehouse.
+func (w *warehouse) CreateIfNotExists(ctx context.Context, tx *gorm.DB, m *model.Warehouse) error {
+	if tx == nil {
+		tx = w.db
+	}
+	if err := tx.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(m).Error; err != nil {
+		return err
+	}
+	if m.ID != 0 {
+		return nil
+	}
+	return tx.WithContext(ctx).Where("team_id = ? AND code = ?", m.TeamID, m.Code).First(m).Error
+}
+
+// Update implements Warehouse.
+func (w *warehouse) Update(ctx context.Context, tx *gorm.DB, teamId, warehouseId uint, name, address string) error {
+	if tx == nil {
+		tx = w.db
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.Warehouse{}).
+		Where("team_id = ? AND id = ?", teamId, warehouseId).
+		Updates(map[string]any{"name": name, "address": address})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete implements Warehouse.
+func (w *warehouse) Delete(ctx context.Context, tx *gorm.DB, teamId, warehouseId uint) error {
+	if tx == nil {
+		tx = w.db
+	}
+	if _, err := gorm.G[model.Location](tx).Where("team_id = ? AND warehouse_id = ?", teamId, warehouseId).Delete(ctx); err != nil {
+		return err
+	}
+	rows, err := gorm.G[model.Warehouse](tx).Where("team_id = ? AND id = ?", teamId, warehouseId).Delete(ctx)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindById implements Warehouse.
+func (w *warehouse) FindById(ctx context.Context, tx *gorm.DB, teamId, warehouseId uint) (*model.Warehouse, error) {
+	if tx == nil {
+		tx = w.db
+	}
+	var m model.Warehouse
+	err := tx.WithContext(ctx).
+		Preload("Locations", func(db *gorm.DB) *gorm.DB {
+			return db.Order("code ASC")
+		}).
+		Where("team_id = ? AND id = ?", teamId, warehouseId).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindAll implements Warehouse.
+func (w *warehouse) FindAll(ctx context.Context, tx *gorm.DB, teamId uint, pg *helpers.Pagination, f *filter.WarehouseFilter) ([]model.Warehouse, error) {
+	if tx == nil {
+		tx = w.db
+	}
+	q := tx.WithContext(ctx).Model(&model.Warehouse{}).Where("team_id = ?", teamId)
+	if f != nil {
+		if f.Code != "" {
+			q = q.Where("code ILIKE ?", "%"+f.Code+"%")
+		}
+		if f.Name != "" {
+			q = q.Where("name ILIKE ?", "%"+f.Name+"%")
+		}
+	}
+	if err := q.Count(&pg.Count).Error; err != nil {
+		return nil, err
+	}
+	var warehouses []model.Warehouse
+	err := q.
+		Preload("Locations", func(db *gorm.DB) *gorm.DB {
+			return db.Order("code ASC")
+		}).
+		Order("code ASC").
+		Limit(pg.Limit).
+		Offset(pg.Offset).
+		Find(&warehouses).Error
+	return warehouses, err
+}
+
+// Create implements Location.
+func (l *location) Create(ctx context.Context, tx *gorm.DB, m *model.Location) error {
+	if tx == nil {
+		tx = l.db
+	}
+	return gorm.G[model.Location](tx).Create(ctx, m)
+}
+
+// CreateIfNotExists implements Location.
+func (l *location) CreateIfNotExists(ctx context.Context, tx *gorm.DB, m *model.Location) error {
+	if tx == nil {
+		tx = l.db
+	}
+	if err := tx.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(m).Error; err != nil {
+		return err
+	}
+	if m.ID != 0 {
+		return nil
+	}
+	return tx.WithContext(ctx).Where("warehouse_id = ? AND code = ?", m.WarehouseID, m.Code).First(m).Error
+}
+
+// Update implements Location.
+func (l *location) Update(ctx context.Context, tx *gorm.DB, teamId, locationId uint, code, name string) error {
+	if tx == nil {
+		tx = l.db
+	}
+	res := tx.WithContext(ctx).
+		Model(&model.Location{}).
+		Where("team_id = ? AND id = ?", teamId, locationId).
+		Updates(map[string]any{"code": code, "name": name})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete implements Location.
+func (l *location) Delete(ctx context.Context, tx *gorm.DB, teamId, locationId uint) error {
+	if tx == nil {
+		tx = l.db
+	}
+	rows, err := gorm.G[model.Location](tx).Where("team_id = ? AND id = ?", teamId, locationId).Delete(ctx)
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindById implements Location.
+func (l *location) FindById(ctx context.Context, tx *gorm.DB, teamId, locationId uint) (*model.Location, error) {
+	if tx == nil {
+		tx = l.db
+	}
+	var m model.Location
+	if err := tx.WithContext(ctx).Preload("Warehouse").Where("team_id = ? AND id = ?", teamId, locationId).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindByWarehouse implements Location.
+func (l *location) FindByWarehouse(ctx context.Context, tx *gorm.DB, teamId, warehouseId uint) ([]model.Location, error) {
+	if tx == nil {
+		tx = l.db
+	}
+	return gorm.G[model.Location](tx).
+		Where("team_id = ? AND warehouse_id = ?", teamId, warehouseId).
+		Order("code ASC").
+		Find(ctx)
+}
+
+// FindDefault implements Location.
+func (l *location) FindDefault(ctx context.Context, tx *gorm.DB, teamId uint) (*model.Location, error) {
+	if tx == nil {
+		tx = l.db
+	}
+	m, err := gorm.G[model.Location](tx).
+		Where("team_id = ? AND type = ? AND is_default", teamId, model.LocationTypeStorage).
+		Order("id ASC").
+		First(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindTransit implements Location.
+func (l *location) FindTransit(ctx context.Context, tx *gorm.DB, teamId uint) (*model.Location, error) {
+	if tx == nil {
+		tx = l.db
+	}
+	m, err := gorm.G[model.Location](tx).
+		Where("team_id = ? AND type = ?", teamId, model.LocationTypeTransit).
+		Order("id ASC").
+		First(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func NewWarehouse(db *gorm.DB) Warehouse {
+	return &warehouse{
+		db: db,
+	}
+}
+
+func NewLocation(db *gorm.DB) Location {
+	return &location{
+		db: db,
+	}
+}
@@ -0,0 +1,30 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UseApproval(
+	r *gin.Engine,
+	stepController controller.ApprovalStep,
+	approvalController controller.Approval,
+	guard middleware.AuthGuardMiddleware,
+) {
+	steps := r.Group("teams/:team_id/approval-steps", guard.Protect, guard.Permission)
+	{
+		steps.GET("", stepController.FindAll)
+		steps.POST("", stepController.Create)
+		steps.GET("/:approval_step_id", stepController.FindById)
+		steps.PUT("/:approval_step_id", stepController.Update)
+		steps.DELETE("/:approval_step_id", stepController.Delete)
+	}
+
+	// emailed links, the signature stands in for the session
+	approvals := r.Group("approvals")
+	{
+		approvals.GET("/:approval_id/:action", approvalController.Confirm)
+		approvals.POST("/:approval_id/:action", approvalController.Decide)
+	}
+}
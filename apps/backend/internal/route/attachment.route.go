@@ -0,0 +1,21 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UseAttachment(
+	r *gin.Engine,
+	attachmentController controller.Attachment,
+	guard middleware.AuthGuardMiddleware,
+) {
+	group := r.Group("attachments")
+	{
+		group.GET("/:entity_type/:entity_id", guard.Protect, guard.AttachmentPermission, attachmentController.FindAll)
+		group.POST("/:entity_type/:entity_id", guard.Protect, guard.AttachmentPermission, attachmentController.Create)
+		group.GET("/:entity_type/:entity_id/:attachment_id", guard.Protect, guard.AttachmentPermission, attachmentController.GetFile)
+		group.DELETE("/:entity_type/:entity_id/:attachment_id", guard.Protect, guard.AttachmentPermission, attachmentController.Delete)
+	}
+}
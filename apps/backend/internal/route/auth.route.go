@@ -16,5 +16,7 @@ func UseAuth(engine *gin.Engine, c controller.Auth, guard middleware.AuthGuardMi
 		r.POST("/logout", c.Logout)
 		r.GET("/email/verify", c.VerifyEmail)
 		r.POST("/email/send-verify", guard.Protect, c.SendVerifyEmail)
+		r.POST("/password/forgot", c.ForgotPassword)
+		r.POST("/password/set", c.SetPassword)
 	}
 }
@@ -0,0 +1,24 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UseFile(
+	r *gin.Engine,
+	fileController controller.File,
+	guard middleware.AuthGuardMiddleware,
+) {
+	group := r.Group("files")
+	{
+		// anyone holding a valid signed URL, no session required
+		group.GET("/signed/*filepath", fileController.ServeSigned)
+
+		group.GET("/images/:image_id", guard.Protect, fileController.GetImage)
+		group.POST("/images/:image_id/signed-url", guard.Protect, fileController.CreateImageSignedUrl)
+
+		group.POST("/gc", guard.Protect, guard.Permission, fileController.CollectGarbage)
+	}
+}
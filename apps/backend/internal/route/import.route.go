@@ -0,0 +1,26 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UseImport(
+	r *gin.Engine,
+	importController controller.Import,
+	guard middleware.AuthGuardMiddleware,
+) {
+	users := r.Group("imports/users", guard.Protect, guard.Permission)
+	{
+		users.POST("", importController.Users)
+		users.POST("/report", importController.UserReport)
+		users.GET("/template", importController.UserTemplate)
+	}
+	products := r.Group("teams/:team_id/imports/products", guard.Protect, guard.Permission)
+	{
+		products.POST("", importController.Products)
+		products.POST("/report", importController.ProductReport)
+		products.GET("/template", importController.ProductTemplate)
+	}
+}
@@ -0,0 +1,27 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UseLabel(
+	r *gin.Engine,
+	labelController controller.Label,
+	guard middleware.AuthGuardMiddleware,
+) {
+	products := r.Group("teams/:team_id/products", guard.Protect, guard.Permission)
+	{
+		products.GET("/:products_id/barcode", labelController.ProductBarcode)
+		products.GET("/:products_id/lots/:lot_id/barcode", labelController.LotBarcode)
+	}
+	labels := r.Group("teams/:team_id/labels", guard.Protect, guard.Permission)
+	{
+		labels.POST("", labelController.Sheet)
+	}
+	scan := r.Group("teams/:team_id/scan", guard.Protect, guard.Permission)
+	{
+		scan.GET("", labelController.Scan)
+	}
+}
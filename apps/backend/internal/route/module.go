@@ -5,5 +5,21 @@ import "go.uber.org/fx"
 var Module = fx.Options(
 	fx.Invoke(UseAuth),
 	fx.Invoke(UseUser),
+	fx.Invoke(UseFile),
+	fx.Invoke(UseUpload),
+	fx.Invoke(UseAttachment),
+	fx.Invoke(UseProduct),
+	fx.Invoke(UseLabel),
+	fx.Invoke(UsePartner),
+	fx.Invoke(UseTransaction),
+	fx.Invoke(UseApproval),
+	fx.Invoke(UseStock),
+	fx.Invoke(UseWarehouse),
+	fx.Invoke(UseStockCount),
+	fx.Invoke(UseStockAlert),
+	fx.Invoke(UseValuation),
+	fx.Invoke(UseNumbering),
+	fx.Invoke(UseSequence),
+	fx.Invoke(UseImport),
 	fx.Invoke(UseHealthCheck),
 )
@@ -0,0 +1,21 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UseNumbering(
+	r *gin.Engine,
+	numberingController controller.Numbering,
+	guard middleware.AuthGuardMiddleware,
+) {
+	numbering := r.Group("teams/:team_id/numbering", guard.Protect, guard.Permission)
+	{
+		numbering.GET("", numberingController.FindAll)
+		numbering.PUT("/:entity_type", numberingController.Save)
+		numbering.DELETE("/:entity_type", numberingController.Delete)
+		numbering.GET("/:entity_type/preview", numberingController.Preview)
+	}
+}
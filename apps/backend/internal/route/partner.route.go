@@ -0,0 +1,24 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UsePartner(
+	r *gin.Engine,
+	partnerController controller.Partner,
+	guard middleware.AuthGuardMiddleware,
+) {
+	partners := r.Group("teams/:team_id/partners", guard.Protect, guard.Permission)
+	{
+		partners.GET("", partnerController.FindAll)
+		partners.POST("", partnerController.Create)
+		partners.GET("/export", partnerController.Export)
+		partners.POST("/import", partnerController.Import)
+		partners.GET("/:partner_id", partnerController.FindById)
+		partners.PUT("/:partner_id", partnerController.Update)
+		partners.DELETE("/:partner_id", partnerController.Delete)
+	}
+}
@@ -0,0 +1,50 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UseProduct(
+	r *gin.Engine,
+	categoryController controller.ProductCategory,
+	productController controller.Product,
+	lotController controller.ProductLot,
+	uomController controller.Uom,
+	guard middleware.AuthGuardMiddleware,
+) {
+	category := r.Group("teams/:team_id/product_category", guard.Protect, guard.Permission)
+	{
+		category.GET("", categoryController.FindAll)
+		category.POST("", categoryController.Create)
+		category.GET("/:product_category_id", categoryController.FindById)
+		category.PUT("/:product_category_id", categoryController.Update)
+		category.DELETE("/:product_category_id", categoryController.Delete)
+	}
+	uoms := r.Group("teams/:team_id/uoms", guard.Protect, guard.Permission)
+	{
+		uoms.GET("", uomController.FindAll)
+		uoms.POST("", uomController.Create)
+		uoms.GET("/:uom_id", uomController.FindById)
+		uoms.PUT("/:uom_id", uomController.Update)
+		uoms.DELETE("/:uom_id", uomController.Delete)
+	}
+	products := r.Group("teams/:team_id/products", guard.Protect, guard.Permission)
+	{
+		products.GET("", productController.FindAll)
+		products.POST("", productController.Create)
+		products.GET("/:products_id", productController.FindById)
+		products.PUT("/:products_id", productController.Update)
+		products.DELETE("/:products_id", productController.Delete)
+		products.POST("/:products_id/upload_image", productController.UploadImage)
+		products.PUT("/:products_id/images/order", productController.ReorderImages)
+		products.GET("/:products_id/images/:product_image_id", productController.GetImage)
+		products.DELETE("/:products_id/images/:product_image_id", productController.DeleteImage)
+
+		products.GET("/:products_id/lots", lotController.FindAll)
+		products.POST("/:products_id/lots", lotController.Receive)
+		products.POST("/:products_id/lots/issue", lotController.Issue)
+		products.GET("/:products_id/lots/:lot_id", lotController.FindById)
+	}
+}
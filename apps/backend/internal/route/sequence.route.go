@@ -0,0 +1,22 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UseSequence(
+	r *gin.Engine,
+	sequenceController controller.Sequence,
+	guard middleware.AuthGuardMiddleware,
+) {
+	sequences := r.Group("teams/:team_id/sequences", guard.Protect, guard.Permission)
+	{
+		sequences.GET("", sequenceController.FindAll)
+		sequences.GET("/audits", sequenceController.FindAudits)
+		sequences.PUT("/:entity_type", sequenceController.Set)
+		sequences.POST("/:entity_type/reset", sequenceController.Reset)
+		sequences.POST("/:entity_type/reserve", sequenceController.Reserve)
+	}
+}
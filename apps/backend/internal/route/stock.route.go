@@ -0,0 +1,21 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UseStock(
+	r *gin.Engine,
+	stockController controller.Stock,
+	guard middleware.AuthGuardMiddleware,
+) {
+	group := r.Group("teams/:team_id/stock", guard.Protect, guard.Permission)
+	{
+		group.GET("/balance", stockController.Balance)
+		group.GET("/movements", stockController.FindMovements)
+		group.GET("/movements/export", stockController.ExportMovements)
+		group.GET("/reconcile", stockController.Reconcile)
+	}
+}
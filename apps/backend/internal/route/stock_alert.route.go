@@ -0,0 +1,30 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UseStockAlert(
+	r *gin.Engine,
+	alertController controller.StockAlert,
+	guard middleware.AuthGuardMiddleware,
+) {
+	alerts := r.Group("teams/:team_id/alerts", guard.Protect, guard.Permission)
+	{
+		alerts.GET("", alertController.FindAll)
+		alerts.POST("/evaluate", alertController.Evaluate)
+		alerts.GET("/subscription", alertController.Subscription)
+		alerts.POST("/subscription", alertController.Subscribe)
+		alerts.DELETE("/subscription", alertController.Unsubscribe)
+		alerts.GET("/:alert_id", alertController.FindById)
+		alerts.POST("/:alert_id/acknowledge", alertController.Acknowledge)
+		alerts.POST("/:alert_id/snooze", alertController.Snooze)
+	}
+	rules := r.Group("teams/:team_id/alert-rules", guard.Protect, guard.Permission)
+	{
+		rules.GET("", alertController.FindRules)
+		rules.PUT("/:alert_type", alertController.SaveRule)
+	}
+}
@@ -0,0 +1,32 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UseStockCount(
+	r *gin.Engine,
+	countController controller.StockCount,
+	guard middleware.AuthGuardMiddleware,
+) {
+	counts := r.Group("teams/:team_id/stock-counts", guard.Protect, guard.Permission)
+	{
+		counts.GET("", countController.FindAll)
+		counts.POST("", countController.Create)
+		counts.GET("/:stock_count_id", countController.FindById)
+		counts.PUT("/:stock_count_id/items", countController.Count)
+		counts.GET("/:stock_count_id/sheet", countController.Sheet)
+		counts.POST("/:stock_count_id/import", countController.Import)
+		counts.POST("/:stock_count_id/submit", countController.Submit)
+		counts.POST("/:stock_count_id/reopen", countController.Reopen)
+		counts.POST("/:stock_count_id/approve", countController.Approve)
+		counts.POST("/:stock_count_id/cancel", countController.Cancel)
+	}
+	adjustments := r.Group("teams/:team_id/stock-adjustments", guard.Protect, guard.Permission)
+	{
+		adjustments.GET("", countController.FindAdjustments)
+		adjustments.GET("/:stock_adjustment_id", countController.FindAdjustmentById)
+	}
+}
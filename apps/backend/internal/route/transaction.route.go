@@ -0,0 +1,26 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UseTransaction(
+	r *gin.Engine,
+	transactionController controller.Transaction,
+	guard middleware.AuthGuardMiddleware,
+) {
+	group := r.Group("teams/:team_id/transactions", guard.Protect, guard.Permission)
+	{
+		group.GET("", transactionController.FindAll)
+		group.POST("", transactionController.Create)
+		group.GET("/:transaction_id", transactionController.FindById)
+		group.PUT("/:transaction_id", transactionController.Update)
+		group.POST("/:transaction_id/submit", transactionController.Submit)
+		group.POST("/:transaction_id/approve", transactionController.Approve)
+		group.POST("/:transaction_id/reject", transactionController.Reject)
+		group.POST("/:transaction_id/cancel", transactionController.Cancel)
+		group.GET("/:transaction_id/approvals", transactionController.FindApprovals)
+	}
+}
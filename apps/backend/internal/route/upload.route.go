@@ -0,0 +1,25 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UseUpload(
+	r *gin.Engine,
+	uploadController controller.Upload,
+	guard middleware.AuthGuardMiddleware,
+) {
+	group := r.Group("uploads")
+	{
+		// tus discovery, must answer without a session
+		group.OPTIONS("", uploadController.Options)
+
+		group.POST("", guard.Protect, uploadController.Create)
+		group.HEAD("/:upload_id", guard.Protect, uploadController.Head)
+		group.PATCH("/:upload_id", guard.Protect, uploadController.Patch)
+		group.DELETE("/:upload_id", guard.Protect, uploadController.Terminate)
+		group.GET("/:upload_id", guard.Protect, uploadController.GetUpload)
+	}
+}
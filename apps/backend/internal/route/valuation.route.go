@@ -0,0 +1,22 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UseValuation(
+	r *gin.Engine,
+	valuationController controller.Valuation,
+	guard middleware.AuthGuardMiddleware,
+) {
+	valuation := r.Group("teams/:team_id/valuation", guard.Protect, guard.Permission)
+	{
+		valuation.GET("", valuationController.Balance)
+		valuation.GET("/export", valuationController.Export)
+		valuation.GET("/cogs", valuationController.Cogs)
+		valuation.GET("/setting", valuationController.FindSetting)
+		valuation.PUT("/setting", valuationController.SaveSetting)
+	}
+}
@@ -0,0 +1,40 @@
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/suttapak/starter/internal/controller"
+	"github.com/suttapak/starter/internal/middleware"
+)
+
+func UseWarehouse(
+	r *gin.Engine,
+	warehouseController controller.Warehouse,
+	transferController controller.StockTransfer,
+	guard middleware.AuthGuardMiddleware,
+) {
+	warehouses := r.Group("teams/:team_id/warehouses", guard.Protect, guard.Permission)
+	{
+		warehouses.GET("", warehouseController.FindAll)
+		warehouses.POST("", warehouseController.Create)
+		warehouses.GET("/:warehouse_id", warehouseController.FindById)
+		warehouses.PUT("/:warehouse_id", warehouseController.Update)
+		warehouses.DELETE("/:warehouse_id", warehouseController.Delete)
+		warehouses.POST("/:warehouse_id/locations", warehouseController.CreateLocation)
+	}
+	locations := r.Group("teams/:team_id/locations", guard.Protect, guard.Permission)
+	{
+		locations.GET("/:location_id", warehouseController.FindLocationById)
+		locations.PUT("/:location_id", warehouseController.UpdateLocation)
+		locations.DELETE("/:location_id", warehouseController.DeleteLocation)
+	}
+	transfers := r.Group("teams/:team_id/stock-transfers", guard.Protect, guard.Permission)
+	{
+		transfers.GET("", transferController.FindAll)
+		transfers.POST("", transferController.Create)
+		transfers.GET("/:stock_transfer_id", transferController.FindById)
+		transfers.PUT("/:stock_transfer_id", transferController.Update)
+		transfers.POST("/:stock_transfer_id/ship", transferController.Ship)
+		transfers.POST("/:stock_transfer_id/receive", transferController.Receive)
+		transfers.POST("/:stock_transfer_id/cancel", transferController.Cancel)
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+type (
+	// ApprovalStepService manages the approval chain of a team, see TransactionService.
+	ApprovalStepService interface {
+		Create(ctx context.Context, teamId uint, body ApprovalStepRequest) (*ApprovalStepResponse, error)
+		Update(ctx context.Context, teamId, stepId uint, body ApprovalStepRequest) (*ApprovalStepResponse, error)
+		Delete(ctx context.Context, teamId, stepId uint) error
+		FindById(ctx context.Context, teamId, stepId uint) (*ApprovalStepResponse, error)
+		FindAll(ctx context.Context, teamId uint) ([]ApprovalStepResponse, error)
+	}
+	approvalStepService struct {
+		step   repository.ApprovalStep
+		user   repository.User
+		logger logger.AppLogger
+	}
+
+	ApprovalStepRequest struct {
+		// TransactionType limits the step to SO, PO or CN, empty applies to every type.
+		TransactionType string `json:"transaction_type" binding:"omitempty,oneof=SO PO CN"`
+		Level           int    `json:"level" binding:"min=1"`
+		// MinAmount is the total amount from which the step applies.
+		MinAmount  float64 `json:"min_amount" binding:"min=0"`
+		ApproverID uint    `json:"approver_id" binding:"required"`
+	}
+
+	ApprovalStepResponse struct {
+		CommonModel
+		TeamID          uint    `json:"team_id"`
+		TransactionType string  `json:"transaction_type"`
+		Level           int     `json:"level"`
+		MinAmount       float64 `json:"min_amount"`
+		ApproverID      uint    `json:"approver_id"`
+		ApproverName    string  `json:"approver_name"`
+	}
+)
+
+// Create implements ApprovalStepService.
+func (a *approvalStepService) Create(ctx context.Context, teamId uint, body ApprovalStepRequest) (*ApprovalStepResponse, error) {
+	m, err := a.toModel(ctx, teamId, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.step.Create(ctx, nil, m); err != nil {
+		a.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return a.FindById(ctx, teamId, m.ID)
+}
+
+// Update implements ApprovalStepService.
+func (a *approvalStepService) Update(ctx context.Context, teamId, stepId uint, body ApprovalStepRequest) (*ApprovalStepResponse, error) {
+	m, err := a.toModel(ctx, teamId, body)
+	if err != nil {
+		return nil, err
+	}
+	m.ID = stepId
+	if err := a.step.Update(ctx, nil, m); err != nil {
+		a.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrApprovalStepNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return a.FindById(ctx, teamId, stepId)
+}
+
+// Delete implements ApprovalStepService. Transactions already submitted keep their approvals.
+func (a *approvalStepService) Delete(ctx context.Context, teamId, stepId uint) error {
+	if err := a.step.Delete(ctx, nil, teamId, stepId); err != nil {
+		a.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errs.ErrApprovalStepNotFound
+		}
+		return errs.HandleSqlErr(err)
+	}
+	return nil
+}
+
+// FindById implements ApprovalStepService.
+func (a *approvalStepService) FindById(ctx context.Context, teamId, stepId uint) (*ApprovalStepResponse, error) {
+	m, err := a.step.FindById(ctx, nil, teamId, stepId)
+	if err != nil {
+		a.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrApprovalStepNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return toApprovalStepResponse(m), nil
+}
+
+// FindAll implements ApprovalStepService.
+func (a *approvalStepService) FindAll(ctx context.Context, teamId uint) ([]ApprovalStepResponse, error) {
+	steps, err := a.step.FindByTeam(ctx, nil, teamId)
+	if err != nil {
+		a.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	res := make([]ApprovalStepResponse, 0, len(steps))
+	for i := range steps {
+		res = append(res, *toApprovalStepResponse(&steps[i]))
+	}
+	return res, nil
+}
+
+func (a *approvalStepService) toModel(ctx context.Context, teamId uint, body ApprovalStepRequest) (*model.ApprovalStep, error) {
+	if _, err := a.user.FindById(ctx, nil, body.ApproverID); err != nil {
+		a.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return &model.ApprovalStep{
+		TeamID:          teamId,
+		TransactionType: model.EntityType(body.TransactionType),
+		Level:           body.Level,
+		MinAmount:       body.MinAmount,
+		ApproverID:      body.ApproverID,
+	}, nil
+}
+
+func toApprovalStepResponse(m *model.ApprovalStep) *ApprovalStepResponse {
+	res := &ApprovalStepResponse{
+		CommonModel: CommonModel{
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		},
+		TeamID:          m.TeamID,
+		TransactionType: string(m.TransactionType),
+		Level:           m.Level,
+		MinAmount:       m.MinAmount,
+		ApproverID:      m.ApproverID,
+	}
+	if m.Approver != nil {
+		res.ApproverName = userDisplayName(m.Approver)
+	}
+	return res
+}
+
+func NewApprovalStepService(
+	step repository.ApprovalStep,
+	user repository.User,
+	logger logger.AppLogger,
+) ApprovalStepService {
+	return &approvalStepService{
+		step:   step,
+		user:   user,
+		logger: logger,
+	}
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	attachmentPath = "attachments"
+
+	UploadTargetAttachment = "attachment"
+)
+
+// attachmentEntityTypes are the entities files can be attached to, add new
+// domain entities here.
+var attachmentEntityTypes = map[string]bool{
+	model.AttachmentEntityUser:    true,
+	model.AttachmentEntityTeam:    true,
+	model.AttachmentEntityProduct: true,
+}
+
+type (
+	AttachmentService interface {
+		// CheckPermission allows users on their own user record, everything else
+		// needs a casbin policy for the attachment route path and method.
+		CheckPermission(ctx context.Context, userId uint, entityType string, entityId uint, path, method string) error
+		Create(ctx context.Context, userId uint, entityType string, entityId uint, fh *multipart.FileHeader) (*AttachmentResponse, error)
+		FindAll(ctx context.Context, entityType string, entityId uint) ([]AttachmentResponse, error)
+		Open(ctx context.Context, entityType string, entityId, attachmentId uint, download bool) (io.ReadSeekCloser, *FileInfo, error)
+		Delete(ctx context.Context, entityType string, entityId, attachmentId uint) error
+		// CreateFromUpload is the UploadHook of UploadTargetAttachment, the
+		// entity_type and entity_id metadata select the entity.
+		CreateFromUpload(ctx context.Context, tx *gorm.DB, file *UploadedFile) (uint, error)
+		// CheckUpload is the UploadCheck of UploadTargetAttachment.
+		CheckUpload(ctx context.Context, userId uint, metadata map[string]string) error
+	}
+	attachmentService struct {
+		attachment   repository.Attachment
+		storage      repository.Storage
+		dbTx         repository.DatabaseTransaction
+		imageService ImageFileService
+		policy       UploadPolicy
+		scan         FileScanService
+		enforcer     *casbin.Enforcer
+		logger       logger.AppLogger
+	}
+
+	AttachmentResponse struct {
+		CommonModel
+		EntityType string `json:"entity_type"`
+		EntityID   uint   `json:"entity_id"`
+		Filename   string `json:"filename"`
+		Size       int64  `json:"size"`
+		MimeType   string `json:"mime_type"`
+		ScanStatus string `json:"scan_status"`
+		UploaderID uint   `json:"uploader_id"`
+		Url        string `json:"url"`
+	}
+)
+
+// CheckPermission implements AttachmentService.
+func (a *attachmentService) CheckPermission(ctx context.Context, userId uint, entityType string, entityId uint, path, method string) error {
+	if !attachmentEntityTypes[entityType] {
+		return errs.ErrAttachmentEntityNotSupported
+	}
+	if entityType == model.AttachmentEntityUser && entityId == userId {
+		return nil
+	}
+	if err := a.enforcer.LoadPolicy(); err != nil {
+		a.logger.Error(err)
+		return errs.ErrForbidden
+	}
+	allowed, err := a.enforcer.Enforce(strconv.Itoa(int(userId)), path, method)
+	if err != nil {
+		a.logger.Error(err)
+		return errs.ErrForbidden
+	}
+	if !allowed {
+		return errs.ErrForbidden
+	}
+	return nil
+}
+
+// Create implements AttachmentService.
+func (a *attachmentService) Create(ctx context.Context, userId uint, entityType string, entityId uint, fh *multipart.FileHeader) (*AttachmentResponse, error) {
+	if err := a.policy.CheckFileHeader(ctx, userId, UploadKindFile, fh); err != nil {
+		return nil, err
+	}
+	tx := a.dbTx.BeginTx()
+	defer a.dbTx.RollbackTx(tx) //nolint:errcheck
+	blob, err := a.imageService.SaveBlobFromFileHeader(ctx, tx, fh, attachmentPath)
+	if err != nil {
+		a.logger.Error(err)
+		return nil, errs.ErrFileImageCanNotSaveToDisk
+	}
+	m, err := a.save(ctx, tx, userId, entityType, entityId, path.Base(fh.Filename), blob)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.dbTx.CommitTx(tx); err != nil {
+		a.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if m.ScanStatus == model.ScanStatusInfected {
+		return nil, errs.ErrFileInfected
+	}
+	return toAttachmentResponse(m), nil
+}
+
+// CreateFromUpload implements AttachmentService.
+func (a *attachmentService) CreateFromUpload(ctx context.Context, tx *gorm.DB, file *UploadedFile) (uint, error) {
+	entityType, entityId, err := attachmentEntityFromMetadata(file.Metadata)
+	if err != nil {
+		return 0, err
+	}
+	blob, err := a.imageService.SaveBlobFromStorage(ctx, tx, file, attachmentPath)
+	if err != nil {
+		a.logger.Error(err)
+		return 0, errs.ErrFileImageCanNotSaveToDisk
+	}
+	m, err := a.save(ctx, tx, file.UserID, entityType, entityId, file.Filename, blob)
+	if err != nil {
+		return 0, err
+	}
+	return m.ID, nil
+}
+
+// CheckUpload implements AttachmentService.
+func (a *attachmentService) CheckUpload(ctx context.Context, userId uint, metadata map[string]string) error {
+	entityType, entityId, err := attachmentEntityFromMetadata(metadata)
+	if err != nil {
+		return err
+	}
+	return a.CheckPermission(ctx, userId, entityType, entityId, attachmentUrl(entityType, entityId, 0), http.MethodPost)
+}
+
+// FindAll implements AttachmentService.
+func (a *attachmentService) FindAll(ctx context.Context, entityType string, entityId uint) ([]AttachmentResponse, error) {
+	models, err := a.attachment.FindByEntity(ctx, nil, entityType, entityId)
+	if err != nil {
+		a.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	res := make([]AttachmentResponse, 0, len(models))
+	for i := range models {
+		res = append(res, *toAttachmentResponse(&models[i]))
+	}
+	return res, nil
+}
+
+// Open implements AttachmentService.
+func (a *attachmentService) Open(ctx context.Context, entityType string, entityId, attachmentId uint, download bool) (io.ReadSeekCloser, *FileInfo, error) {
+	m, err := a.find(ctx, nil, entityType, entityId, attachmentId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkScanStatus(m.ScanStatus); err != nil {
+		return nil, nil, err
+	}
+	disposition := DispositionInline
+	if download {
+		disposition = DispositionAttachment
+	}
+	r, info, err := openFile(ctx, a.storage, a.logger, m.Key, m.Filename, disposition, protectedFileMaxAge)
+	if err != nil {
+		return nil, nil, err
+	}
+	// sniffed on upload, the extension of the shared blob may differ
+	info.ContentType = m.MimeType
+	return r, info, nil
+}
+
+// Delete implements AttachmentService.
+func (a *attachmentService) Delete(ctx context.Context, entityType string, entityId, attachmentId uint) error {
+	tx := a.dbTx.BeginTx()
+	defer a.dbTx.RollbackTx(tx) //nolint:errcheck
+	m, err := a.find(ctx, tx, entityType, entityId, attachmentId)
+	if err != nil {
+		return err
+	}
+	if err := a.attachment.Delete(ctx, tx, m.ID); err != nil {
+		a.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	// quarantined files hold no blob reference
+	orphaned := false
+	if m.ScanStatus != model.ScanStatusInfected {
+		if orphaned, err = a.imageService.ReleaseBlob(ctx, tx, m.Key); err != nil {
+			a.logger.Error(err)
+			return errs.HandleSqlErr(err)
+		}
+	}
+	if err := a.dbTx.CommitTx(tx); err != nil {
+		a.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	if orphaned {
+		if err := a.imageService.DeleteFile(ctx, m.Key); err != nil && !errors.Is(err, repository.ErrStorageObjectNotFound) {
+			// left for the file collector
+			a.logger.Error(err, zap.String("key", m.Key))
+		}
+	}
+	return nil
+}
+
+func (a *attachmentService) save(ctx context.Context, tx *gorm.DB, userId uint, entityType string, entityId uint, filename string, blob *model.Blob) (*model.Attachment, error) {
+	m := &model.Attachment{
+		EntityType: entityType,
+		EntityID:   entityId,
+		Key:        blob.Key,
+		Hash:       blob.Hash,
+		Filename:   filename,
+		Size:       blob.Size,
+		MimeType:   blob.ContentType,
+		UploaderID: userId,
+	}
+	if err := a.scan.ScanAttachment(ctx, tx, m); err != nil {
+		return nil, err
+	}
+	if err := a.attachment.Create(ctx, tx, m); err != nil {
+		a.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return m, nil
+}
+
+// find returns the attachment only if it belongs to the entity the guard checked.
+func (a *attachmentService) find(ctx context.Context, tx *gorm.DB, entityType string, entityId, attachmentId uint) (*model.Attachment, error) {
+	m, err := a.attachment.FindById(ctx, tx, attachmentId)
+	if err != nil {
+		a.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if m.EntityType != entityType || m.EntityID != entityId {
+		return nil, errs.ErrNotFound
+	}
+	return m, nil
+}
+
+func attachmentEntityFromMetadata(metadata map[string]string) (string, uint, error) {
+	entityType := metadata["entity_type"]
+	if !attachmentEntityTypes[entityType] {
+		return "", 0, errs.ErrAttachmentEntityNotSupported
+	}
+	entityId, err := strconv.ParseUint(metadata["entity_id"], 10, 64)
+	if err != nil {
+		return "", 0, errs.ErrUploadInvalidMetadata
+	}
+	return entityType, uint(entityId), nil
+}
+
+// attachmentUrl returns the path of the attachment routes, see route.UseAttachment.
+// Without attachmentId it is the collection of the entity.
+func attachmentUrl(entityType string, entityId, attachmentId uint) string {
+	if attachmentId == 0 {
+		return fmt.Sprintf("/attachments/%s/%d", entityType, entityId)
+	}
+	return fmt.Sprintf("/attachments/%s/%d/%d", entityType, entityId, attachmentId)
+}
+
+func toAttachmentResponse(m *model.Attachment) *AttachmentResponse {
+	return &AttachmentResponse{
+		CommonModel: CommonModel{
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		},
+		EntityType: m.EntityType,
+		EntityID:   m.EntityID,
+		Filename:   m.Filename,
+		Size:       m.Size,
+		MimeType:   m.MimeType,
+		ScanStatus: m.ScanStatus,
+		UploaderID: m.UploaderID,
+		Url:        attachmentUrl(m.EntityType, m.EntityID, m.ID),
+	}
+}
+
+func NewAttachmentService(
+	attachment repository.Attachment,
+	storage repository.Storage,
+	dbTx repository.DatabaseTransaction,
+	imageService ImageFileService,
+	policy UploadPolicy,
+	scan FileScanService,
+	enforcer *casbin.Enforcer,
+	logger logger.AppLogger,
+) AttachmentService {
+	return &attachmentService{
+		attachment:   attachment,
+		storage:      storage,
+		dbTx:         dbTx,
+		imageService: imageService,
+		policy:       policy,
+		scan:         scan,
+		enforcer:     enforcer,
+		logger:       logger,
+	}
+}
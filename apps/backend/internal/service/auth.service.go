@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/suttapak/starter/domain/config"
@@ -11,6 +12,7 @@ import (
 	"github.com/suttapak/starter/internal/model"
 	"github.com/suttapak/starter/internal/repository"
 	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
 )
 
 type (
@@ -20,6 +22,15 @@ type (
 		RefreshToken(ctx context.Context, uId uint) (res *AuthResponse, err error)
 		VerifyEmail(ctx context.Context, body VerifyEmailDto) (res *UserResponse, err error)
 		SendVerifyEmail(ctx context.Context, userId SendVerifyEmailDto) (err error)
+		// SendPasswordEmail mails the user a link to set their password, good
+		// once for PasswordExpireTime.
+		SendPasswordEmail(ctx context.Context, userId uint) error
+		// ForgotPassword sends the link of SendPasswordEmail to the user of the
+		// email, it succeeds without one so as not to tell who has an account.
+		ForgotPassword(ctx context.Context, body ForgotPasswordDto) error
+		// SetPassword sets the password of the user of a SendPasswordEmail link
+		// and verifies their email.
+		SetPassword(ctx context.Context, body SetPasswordDto) error
 	}
 	auth struct {
 		// utils
@@ -54,6 +65,13 @@ type (
 	SendVerifyEmailDto struct {
 		UserID uint `json:"user_id"`
 	}
+	ForgotPasswordDto struct {
+		Email string `json:"email" binding:"required"`
+	}
+	SetPasswordDto struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=8"`
+	}
 )
 
 // RefreshToken implements Auth.
@@ -102,6 +120,77 @@ func (a *auth) SendVerifyEmail(ctx context.Context, input SendVerifyEmailDto) (e
 	return
 }
 
+// SendPasswordEmail implements Auth.
+func (a *auth) SendPasswordEmail(ctx context.Context, userId uint) error {
+	userModel, err := a.userRepo.FindById(ctx, nil, userId)
+	if err != nil {
+		a.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return a.sendPasswordEmail(ctx, userModel)
+}
+
+// ForgotPassword implements Auth.
+func (a *auth) ForgotPassword(ctx context.Context, body ForgotPasswordDto) error {
+	userModel, err := a.userRepo.GetUserByEmailOrUsername(ctx, nil, body.Email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		a.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return a.sendPasswordEmail(ctx, userModel)
+}
+
+func (a *auth) sendPasswordEmail(ctx context.Context, userModel *model.User) error {
+	token, err := a.jwtService.GeneratePasswordToken(ctx, userModel.ID, userModel.Password)
+	if err != nil {
+		a.logger.Error(err)
+		return errs.ErrGenerateJWTFail
+	}
+	if err := a.mailService.NewRequest([]string{userModel.Email}, "Set Password").
+		ParseSetPasswordTemplate(ctx, &SetPasswordTemplateDataDto{
+			Email:           userModel.Email,
+			Username:        userModel.Username,
+			SetPasswordLink: fmt.Sprintf("%s/set-password?token=%s", a.config.SERVER.HOST_NAME, token),
+		}).SendMail(ctx); err != nil {
+		a.logger.Error(err)
+		return errs.ErrSendEmail
+	}
+	return nil
+}
+
+// SetPassword implements Auth.
+func (a *auth) SetPassword(ctx context.Context, body SetPasswordDto) error {
+	userId, fingerprint, err := a.jwtService.GetUserIdFromPasswordToken(ctx, body.Token)
+	if err != nil {
+		return err
+	}
+	userModel, err := a.userRepo.FindById(ctx, nil, userId)
+	if err != nil {
+		a.logger.Error(err)
+		return errs.ErrPasswordLink
+	}
+	// the link is used up once the password changes
+	if passwordFingerprint(userModel.Password) != fingerprint {
+		return errs.ErrPasswordLink
+	}
+	password, err := a.passwordHelper.HashPassword(body.Password)
+	if err != nil {
+		a.logger.Error(err)
+		return errs.ErrHashPassword
+	}
+	if err := a.userRepo.ChangePassword(ctx, nil, userId, userModel.Password, password); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errs.ErrPasswordLink
+		}
+		a.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return nil
+}
+
 func (a auth) VerifyEmail(ctx context.Context, body VerifyEmailDto) (res *UserResponse, err error) {
 	email, err := a.jwtService.GetUserIdFromExternalToken(ctx, body.Token)
 	if err != nil {
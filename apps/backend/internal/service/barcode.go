@@ -0,0 +1,232 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+	"github.com/go-pdf/fpdf"
+	"github.com/suttapak/starter/errs"
+)
+
+type (
+	BarcodeSymbology string
+	BarcodeFormat    string
+)
+
+const (
+	Code128 BarcodeSymbology = "code128"
+	QRCode  BarcodeSymbology = "qr"
+
+	PNG BarcodeFormat = "png"
+	SVG BarcodeFormat = "svg"
+)
+
+// A4 label sheet in millimetres.
+const (
+	labelPageWidth  = 210.0
+	labelPageHeight = 297.0
+	labelMargin     = 10.0
+	labelPadding    = 2.0
+	labelLineHeight = 3.5
+)
+
+type (
+	// Label is one label of a sheet, Content is encoded and Lines are printed
+	// under it. The core PDF fonts have no Thai glyphs, lines should be codes
+	// and dates.
+	Label struct {
+		Content string
+		Lines   []string
+	}
+
+	// LabelLayout is the grid of labels on an A4 page, Skip labels are left
+	// empty at the start of the first page to reuse a partly printed sheet.
+	LabelLayout struct {
+		Symbology     BarcodeSymbology
+		Columns, Rows int
+		Skip          int
+	}
+
+	Barcode interface {
+		// Encode renders content in whole pixels per module so the bars stay
+		// sharp, at most width pixels wide with its quiet zone. Code128 bars are
+		// height pixels high, QR codes are square. It fails with
+		// ErrBarcodeTooSmall when a module does not fit in a pixel.
+		Encode(content string, symbology BarcodeSymbology, format BarcodeFormat, width, height int) (*bytes.Buffer, error)
+		// LabelSheet lays labels out on A4 pages as a PDF.
+		LabelSheet(labels []Label, layout LabelLayout) (*bytes.Buffer, error)
+	}
+	barcodeEncoder struct{}
+
+	// barcodeModules is a symbol with its quiet zone, row by row, true is dark.
+	// Code128 symbols are a single row.
+	barcodeModules [][]bool
+)
+
+// Encode implements Barcode.
+func (b barcodeEncoder) Encode(content string, symbology BarcodeSymbology, format BarcodeFormat, width, height int) (*bytes.Buffer, error) {
+	modules, err := encodeModules(content, symbology)
+	if err != nil {
+		return nil, err
+	}
+	scale := width / len(modules[0])
+	if scale < 1 {
+		return nil, errs.ErrBarcodeTooSmall
+	}
+	if symbology == QRCode {
+		height = scale * len(modules)
+	}
+	if format == SVG {
+		return modules.svg(scale*len(modules[0]), height), nil
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, modules.image(scale, height)); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// LabelSheet implements Barcode.
+func (b barcodeEncoder) LabelSheet(labels []Label, layout LabelLayout) (*bytes.Buffer, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(labelMargin, labelMargin, labelMargin)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.SetFont("Helvetica", "", 8)
+
+	perPage := layout.Columns * layout.Rows
+	cellWidth := (labelPageWidth - 2*labelMargin) / float64(layout.Columns)
+	cellHeight := (labelPageHeight - 2*labelMargin) / float64(layout.Rows)
+	registered := map[string]bool{}
+	for i, label := range labels {
+		pos := i + layout.Skip
+		if i == 0 || pos%perPage == 0 {
+			pdf.AddPage()
+		}
+		x := labelMargin + float64(pos%layout.Columns)*cellWidth
+		y := labelMargin + float64(pos%perPage/layout.Columns)*cellHeight
+
+		name := fmt.Sprintf("%s:%s", layout.Symbology, label.Content)
+		if !registered[name] {
+			modules, err := encodeModules(label.Content, layout.Symbology)
+			if err != nil {
+				return nil, err
+			}
+			var img bytes.Buffer
+			// the PDF scales the image, 4 pixels a module prints sharp
+			if err := png.Encode(&img, modules.image(4, 4*len(modules))); err != nil {
+				return nil, err
+			}
+			pdf.RegisterImageOptionsReader(name, fpdf.ImageOptions{ImageType: "PNG"}, &img)
+			registered[name] = true
+		}
+
+		w := cellWidth - 2*labelPadding
+		h := cellHeight - 2*labelPadding - float64(len(label.Lines))*labelLineHeight
+		imgX := x + labelPadding
+		if layout.Symbology == QRCode {
+			h = min(w, h)
+			imgX = x + (cellWidth-h)/2
+			w = h
+		}
+		pdf.ImageOptions(name, imgX, y+labelPadding, w, h, false, fpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+		for j, line := range label.Lines {
+			pdf.SetXY(x, y+labelPadding+h+float64(j)*labelLineHeight)
+			pdf.CellFormat(cellWidth, labelLineHeight, line, "", 0, "C", false, 0, "")
+		}
+	}
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+// encodeModules encodes content with a quiet zone of 10 modules around
+// Code128 bars and 4 around QR codes.
+func encodeModules(content string, symbology BarcodeSymbology) (barcodeModules, error) {
+	var (
+		bc    barcode.Barcode
+		err   error
+		quiet = 10
+		rows  = 1
+	)
+	switch symbology {
+	case QRCode:
+		bc, err = qr.Encode(content, qr.M, qr.Auto)
+		quiet = 4
+	default:
+		bc, err = code128.Encode(content)
+	}
+	if err != nil {
+		return nil, errs.ErrBarcodeContent
+	}
+	bounds := bc.Bounds()
+	if symbology == QRCode {
+		rows = bounds.Dy() + 2*quiet
+	}
+	modules := make(barcodeModules, rows)
+	for y := range modules {
+		modules[y] = make([]bool, bounds.Dx()+2*quiet)
+		by := y - quiet
+		if symbology != QRCode {
+			by = 0
+		} else if by < 0 || by >= bounds.Dy() {
+			continue
+		}
+		for x := 0; x < bounds.Dx(); x++ {
+			r, _, _, _ := bc.At(bounds.Min.X+x, bounds.Min.Y+by).RGBA()
+			modules[y][x+quiet] = r == 0
+		}
+	}
+	return modules, nil
+}
+
+// image draws every module scale pixels wide, the rows share height pixels.
+func (m barcodeModules) image(scale, height int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, scale*len(m[0]), height))
+	for py := 0; py < height; py++ {
+		row := m[py*len(m)/height]
+		for px := range scale * len(row) {
+			c := color.Gray{Y: 0xff}
+			if row[px/scale] {
+				c.Y = 0
+			}
+			img.SetGray(px, py, c)
+		}
+	}
+	return img
+}
+
+// svg draws the dark modules as runs of one path, stretched to width x height.
+func (m barcodeModules) svg(width, height int) *bytes.Buffer {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" preserveAspectRatio="none" shape-rendering="crispEdges">`,
+		width, height, len(m[0]), len(m))
+	buf.WriteString(`<rect width="100%" height="100%" fill="#fff"/><path fill="#000" d="`)
+	for y, row := range m {
+		for x := 0; x < len(row); {
+			if !row[x] {
+				x++
+				continue
+			}
+			run := 0
+			for x+run < len(row) && row[x+run] {
+				run++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", x, y, run, run)
+			x += run
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	return &buf
+}
+
+func NewBarcodeService() Barcode {
+	return barcodeEncoder{}
+}
@@ -2,83 +2,159 @@ package service
 
 import (
 	"context"
-	"fmt"
-	"strings"
+	"errors"
 	"time"
 
+	"github.com/suttapak/starter/domain/config"
 	"github.com/suttapak/starter/internal/model"
 	"github.com/suttapak/starter/internal/repository"
 	"github.com/suttapak/starter/logger"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 type (
+	// CodeService numbers documents with the numbering scheme of their team
+	// and entity type, see NumberingService. Callers pass the transaction that
+	// creates the document, gapless schemes count their number in it.
 	CodeService interface {
-		GenerateTransactionCode(ctx context.Context, transactionType model.EntityType, teamId uint) (string, error)
-		GenerateProductCode(ctx context.Context, teamId uint) (string, error)
-		GenerateLotCode(ctx context.Context, productId uint) (string, error)
+		GenerateTransactionCode(ctx context.Context, tx *gorm.DB, transactionType model.EntityType, teamId uint) (string, error)
+		GenerateProductCode(ctx context.Context, tx *gorm.DB, teamId uint) (string, error)
+		// ReserveProductCodes takes count product codes at once, committed right
+		// away so the sequence stays unlocked while products are created with
+		// them. With dryRun the next count codes are returned without taking them.
+		ReserveProductCodes(ctx context.Context, teamId, count uint, dryRun bool) ([]string, error)
+		GenerateLotCode(ctx context.Context, tx *gorm.DB, teamId, productId uint) (string, error)
+		GeneratePartnerCode(ctx context.Context, tx *gorm.DB, teamId uint) (string, error)
 	}
 
 	codeService struct {
 		logger             logger.AppLogger
 		sequenceRepository repository.AutoIncrementSequence
+		scheme             repository.NumberingScheme
+		conf               *config.Config
 	}
 )
 
 // GenerateTransactionCode generates a unique transaction code
-func (c *codeService) GenerateTransactionCode(ctx context.Context, transactionType model.EntityType, teamId uint) (string, error) {
+func (c *codeService) GenerateTransactionCode(ctx context.Context, tx *gorm.DB, transactionType model.EntityType, teamId uint) (string, error) {
+	return c.generate(ctx, tx, transactionType, teamId, 0)
+}
+
+// GenerateProductCode generates a unique product code
+func (c *codeService) GenerateProductCode(ctx context.Context, tx *gorm.DB, teamId uint) (string, error) {
+	return c.generate(ctx, tx, model.EntityTypeProduct, teamId, 0)
+}
 
-	sequence, err := c.sequenceRepository.GetNextSequence(ctx, nil, transactionType, teamId, 0)
+// ReserveProductCodes implements CodeService.
+func (c *codeService) ReserveProductCodes(ctx context.Context, teamId, count uint, dryRun bool) ([]string, error) {
+	if count == 0 {
+		return nil, nil
+	}
+	entityType := model.EntityTypeProduct
+	scheme, tpl, err := c.template(ctx, entityType, teamId)
 	if err != nil {
-		c.logger.Error("Failed to get next sequence for transaction code", zap.Error(err))
-		return "", err
+		return nil, err
 	}
 	now := time.Now()
-	dateStr := now.Format("060102")
-
-	typePrefix := strings.ToUpper(string(transactionType))
-
-	code := fmt.Sprintf("%s-%s%01d%03d", typePrefix, dateStr, teamId, sequence)
+	period := numberingPeriod(scheme, now, c.conf.SERVER.LOCATION)
+	seqTeam, seqEntity := sequenceOwner(entityType, teamId, 0)
+	last := count
+	if dryRun {
+		current, err := c.sequenceRepository.FindSequence(ctx, nil, entityType, seqTeam, seqEntity)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.logger.Error("Failed to get sequence", zap.String("entity_type", string(entityType)), zap.Error(err))
+			return nil, err
+		}
+		if err == nil && current.Period == period {
+			last += current.Sequence
+		}
+	} else if last, err = c.sequenceRepository.ReserveSequence(ctx, nil, entityType, seqTeam, seqEntity, count, period); err != nil {
+		c.logger.Error("Failed to reserve sequence", zap.String("entity_type", string(entityType)), zap.Error(err))
+		return nil, err
+	}
+	codes := make([]string, 0, count)
+	for sequence := last - count + 1; sequence <= last; sequence++ {
+		codes = append(codes, tpl.render(numberingValues{
+			At:              now,
+			Location:        c.conf.SERVER.LOCATION,
+			FiscalYearStart: scheme.FiscalYearStart,
+			EntityType:      entityType,
+			TeamID:          teamId,
+			Sequence:        sequence,
+		}))
+	}
+	c.logger.Info("Reserved codes", zap.String("entity_type", string(entityType)), zap.Uint("from", last-count+1), zap.Uint("to", last), zap.Bool("dry_run", dryRun))
+	return codes, nil
+}
 
-	c.logger.Info("Generated transaction code", zap.String("code", code), zap.Uint("sequence", sequence))
+// GenerateLotCode generates a unique lot code for a specific product
+func (c *codeService) GenerateLotCode(ctx context.Context, tx *gorm.DB, teamId, productId uint) (string, error) {
+	return c.generate(ctx, tx, model.EntityTypeLot, teamId, productId)
+}
 
-	return code, nil
+// GeneratePartnerCode generates a unique partner code
+func (c *codeService) GeneratePartnerCode(ctx context.Context, tx *gorm.DB, teamId uint) (string, error) {
+	return c.generate(ctx, tx, model.EntityTypePartner, teamId, 0)
 }
 
-// GenerateProductCode generates a unique product code
-func (c *codeService) GenerateProductCode(ctx context.Context, teamId uint) (string, error) {
-	sequence, err := c.sequenceRepository.GetNextSequence(ctx, nil, model.EntityTypeProduct, teamId, 0)
+// generate counts the code in tx when the scheme is gapless.
+func (c *codeService) generate(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId, entityId uint) (string, error) {
+	scheme, tpl, err := c.template(ctx, entityType, teamId)
 	if err != nil {
-		c.logger.Error("Failed to get next sequence for product code", zap.Error(err))
 		return "", err
 	}
-
-	code := fmt.Sprintf("PN-%02d%04d", teamId, sequence)
-	c.logger.Info("Generated product code", zap.String("code", code), zap.Uint("sequence", sequence))
+	if !scheme.Gapless {
+		// counted apart, the sequence is not locked until the caller commits
+		tx = nil
+	}
+	now := time.Now()
+	seqTeam, seqEntity := sequenceOwner(entityType, teamId, entityId)
+	sequence, err := c.sequenceRepository.GetNextSequence(ctx, tx, entityType, seqTeam, seqEntity, numberingPeriod(scheme, now, c.conf.SERVER.LOCATION))
+	if err != nil {
+		c.logger.Error("Failed to get next sequence", zap.String("entity_type", string(entityType)), zap.Error(err))
+		return "", err
+	}
+	code := tpl.render(numberingValues{
+		At:              now,
+		Location:        c.conf.SERVER.LOCATION,
+		FiscalYearStart: scheme.FiscalYearStart,
+		EntityType:      entityType,
+		TeamID:          teamId,
+		EntityID:        entityId,
+		Sequence:        sequence,
+	})
+	c.logger.Info("Generated code", zap.String("entity_type", string(entityType)), zap.String("code", code), zap.Uint("sequence", sequence))
 
 	return code, nil
 }
 
-// GenerateLotCode generates a unique lot code for a specific product
-func (c *codeService) GenerateLotCode(ctx context.Context, productId uint) (string, error) {
-	sequence, err := c.sequenceRepository.GetNextSequence(ctx, nil, model.EntityTypeLot, 0, productId)
+// template returns the numbering scheme of the team for entityType and its
+// parsed template.
+func (c *codeService) template(ctx context.Context, entityType model.EntityType, teamId uint) (model.NumberingScheme, numberingTemplate, error) {
+	scheme, err := findNumberingScheme(ctx, c.scheme, teamId, entityType)
 	if err != nil {
-		c.logger.Error("Failed to get next sequence for lot code", zap.Error(err))
-		return "", err
+		c.logger.Error("Failed to get numbering scheme", zap.Error(err))
+		return scheme, numberingTemplate{}, err
 	}
-
-	code := fmt.Sprintf("LN-%02d%04d", productId, sequence)
-	c.logger.Info("Generated lot code", zap.String("code", code), zap.Uint("sequence", sequence))
-
-	return code, nil
+	tpl, err := parseNumberingTemplate(scheme.Template)
+	if err != nil {
+		c.logger.Error("Invalid numbering template", zap.String("template", scheme.Template), zap.Error(err))
+		return scheme, numberingTemplate{}, err
+	}
+	return scheme, tpl, nil
 }
 
 func NewCodeService(
 	logger logger.AppLogger,
 	sequenceRepository repository.AutoIncrementSequence,
+	scheme repository.NumberingScheme,
+	conf *config.Config,
 ) CodeService {
 	return &codeService{
 		logger:             logger,
 		sequenceRepository: sequenceRepository,
+		scheme:             scheme,
+		conf:               conf,
 	}
 }
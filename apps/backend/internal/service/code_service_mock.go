@@ -5,6 +5,7 @@ import (
 
 	"github.com/stretchr/testify/mock"
 	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
 )
 
 type codeServiceMock struct {
@@ -12,19 +13,32 @@ type codeServiceMock struct {
 }
 
 // GenerateLotCode implements CodeService.
-func (c *codeServiceMock) GenerateLotCode(ctx context.Context, productId uint) (string, error) {
+func (c *codeServiceMock) GenerateLotCode(ctx context.Context, tx *gorm.DB, teamId, productId uint) (string, error) {
 	args := c.Called()
 	return args.String(0), args.Error(1)
 }
 
 // GenerateProductCode implements CodeService.
-func (c *codeServiceMock) GenerateProductCode(ctx context.Context, teamId uint) (string, error) {
+func (c *codeServiceMock) GenerateProductCode(ctx context.Context, tx *gorm.DB, teamId uint) (string, error) {
 	args := c.Called()
 	return args.String(0), args.Error(1)
 }
 
+// ReserveProductCodes implements CodeService.
+func (c *codeServiceMock) ReserveProductCodes(ctx context.Context, teamId, count uint, dryRun bool) ([]string, error) {
+	args := c.Called()
+	codes, _ := args.Get(0).([]string)
+	return codes, args.Error(1)
+}
+
 // GenerateTransactionCode implements CodeService.
-func (c *codeServiceMock) GenerateTransactionCode(ctx context.Context, transactionType model.EntityType, teamId uint) (string, error) {
+func (c *codeServiceMock) GenerateTransactionCode(ctx context.Context, tx *gorm.DB, transactionType model.EntityType, teamId uint) (string, error) {
+	args := c.Called()
+	return args.String(0), args.Error(1)
+}
+
+// GeneratePartnerCode implements CodeService.
+func (c *codeServiceMock) GeneratePartnerCode(ctx context.Context, tx *gorm.DB, teamId uint) (string, error) {
 	args := c.Called()
 	return args.String(0), args.Error(1)
 }
@@ -24,6 +24,7 @@ type (
 		TotalPrice  string `validate:"required"`
 		Remark      string
 		ApproveURL  string `validate:"required,url"`
+		RejectURL   string `validate:"required,url"`
 	}
 
 	RejectAndApproveTransactionDto struct {
@@ -43,6 +44,26 @@ type (
 		TeamName     string
 		JoinTeamLink string
 	}
+	SetPasswordTemplateDataDto struct {
+		Email           string
+		Username        string
+		SetPasswordLink string
+	}
+
+	StockAlertDigestDto struct {
+		Team   string                    `validate:"required"`
+		Date   string                    `validate:"required"`
+		Alerts []StockAlertDigestItemDto `validate:"required,min=1,dive"`
+		URL    string                    `validate:"required,url"`
+	}
+	StockAlertDigestItemDto struct {
+		Type       string `validate:"required"`
+		Product    string `validate:"required"`
+		Lot        string
+		Quantity   string `validate:"required"`
+		Threshold  string
+		ExpiryDate string
+	}
 )
 
 func listFiles(fsys fs.FS, dir string, indent string) {
@@ -72,6 +93,10 @@ func (r *RejectAndApproveTransactionDto) Validate() error {
 	validate := validator.New()
 	return validate.Struct(r)
 }
+func (r *StockAlertDigestDto) Validate() error {
+	validate := validator.New()
+	return validate.Struct(r)
+}
 
 type (
 	Email interface {
@@ -79,6 +104,17 @@ type (
 		SendMail(ctx context.Context) error
 		ParseVerifyEmailTemplate(ctx context.Context, body *VerifyEmailTemplateDataDto) Email
 		ParseInviteTeamMemberTemplate(ctx context.Context, body *InviteTeamMemberTemplateDataDto) Email
+		// ParseSetPasswordTemplate links a user to set their password, such as
+		// an imported user or one who forgot it.
+		ParseSetPasswordTemplate(ctx context.Context, body *SetPasswordTemplateDataDto) Email
+		// ParseRequestApproveTransactionTemplate asks an approver to decide on a transaction.
+		ParseRequestApproveTransactionTemplate(ctx context.Context, body *RequestApproveTransactionDto) Email
+		// ParseApproveTransactionTemplate and ParseRejectTransactionTemplate tell
+		// the requester about the decision, ApproveURL links to the transaction.
+		ParseApproveTransactionTemplate(ctx context.Context, body *RejectAndApproveTransactionDto) Email
+		ParseRejectTransactionTemplate(ctx context.Context, body *RejectAndApproveTransactionDto) Email
+		// ParseStockAlertDigestTemplate lists the open stock alerts of a team.
+		ParseStockAlertDigestTemplate(ctx context.Context, body *StockAlertDigestDto) Email
 	}
 	email struct {
 		to      []string
@@ -100,6 +136,66 @@ func (e *email) ParseInviteTeamMemberTemplate(ctx context.Context, body *InviteT
 	return e
 }
 
+// ParseRequestApproveTransactionTemplate implements Email.
+func (e *email) ParseRequestApproveTransactionTemplate(ctx context.Context, body *RequestApproveTransactionDto) Email {
+	const (
+		templateFile = "mail/request-approve-transaction.html"
+	)
+	if err := body.Validate(); err != nil {
+		e.err = err
+		return e
+	}
+	if err := e.parseTemplate(ctx, templateFile, body); err != nil {
+		e.err = err
+	}
+	return e
+}
+
+// ParseApproveTransactionTemplate implements Email.
+func (e *email) ParseApproveTransactionTemplate(ctx context.Context, body *RejectAndApproveTransactionDto) Email {
+	const (
+		templateFile = "mail/approve-transaction.html"
+	)
+	if err := body.Validate(); err != nil {
+		e.err = err
+		return e
+	}
+	if err := e.parseTemplate(ctx, templateFile, body); err != nil {
+		e.err = err
+	}
+	return e
+}
+
+// ParseRejectTransactionTemplate implements Email.
+func (e *email) ParseRejectTransactionTemplate(ctx context.Context, body *RejectAndApproveTransactionDto) Email {
+	const (
+		templateFile = "mail/reject-transaction.html"
+	)
+	if err := body.Validate(); err != nil {
+		e.err = err
+		return e
+	}
+	if err := e.parseTemplate(ctx, templateFile, body); err != nil {
+		e.err = err
+	}
+	return e
+}
+
+// ParseStockAlertDigestTemplate implements Email.
+func (e *email) ParseStockAlertDigestTemplate(ctx context.Context, body *StockAlertDigestDto) Email {
+	const (
+		templateFile = "mail/stock-alert-digest.html"
+	)
+	if err := body.Validate(); err != nil {
+		e.err = err
+		return e
+	}
+	if err := e.parseTemplate(ctx, templateFile, body); err != nil {
+		e.err = err
+	}
+	return e
+}
+
 // SendMail implements Email.
 func (e *email) SendMail(ctx context.Context) error {
 	if e.err != nil {
@@ -128,6 +224,17 @@ func (e *email) ParseVerifyEmailTemplate(ctx context.Context, body *VerifyEmailT
 	return e
 }
 
+// ParseSetPasswordTemplate implements Email.
+func (e *email) ParseSetPasswordTemplate(ctx context.Context, body *SetPasswordTemplateDataDto) Email {
+	const (
+		templateFile = "mail/set-password.html"
+	)
+	if err := e.parseTemplate(ctx, templateFile, body); err != nil {
+		e.err = err
+	}
+	return e
+}
+
 // ParseTemplate implements Email.
 func (e *email) parseTemplate(ctx context.Context, file string, data any) error {
 	listFiles(mtemplate.EmailTemplateFS, "mail", "")
@@ -3,6 +3,8 @@ package service
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"time"
 	"unicode/utf8"
 
 	"github.com/suttapak/starter/errs"
@@ -15,6 +17,45 @@ const (
 	CurrencyStyle ExcelStyle = iota + 1
 )
 
+// ExcelColumnType is how the values of an ExcelColumn are formatted.
+type ExcelColumnType string
+
+const (
+	ExcelGeneral  ExcelColumnType = ""         // as written
+	ExcelText     ExcelColumnType = "text"     // kept as text, such as codes with leading zeros
+	ExcelNumber   ExcelColumnType = "number"   // #,##0.00##
+	ExcelCurrency ExcelColumnType = "currency" // #,##0.00
+	ExcelPercent  ExcelColumnType = "percent"  // 0.00%, of fractions such as 0.25
+	ExcelDate     ExcelColumnType = "date"     // yyyy-mm-dd, of time.Time values
+)
+
+// ExcelTotal is the function of an ExcelColumn in the totals row.
+type ExcelTotal string
+
+const (
+	ExcelSum     ExcelTotal = "SUM"
+	ExcelAverage ExcelTotal = "AVERAGE"
+	ExcelCount   ExcelTotal = "COUNT"
+	ExcelMin     ExcelTotal = "MIN"
+	ExcelMax     ExcelTotal = "MAX"
+)
+
+// excelSubtotals are the SUBTOTAL function numbers of the totals, they leave
+// out the rows hidden by the auto filter.
+var excelSubtotals = map[ExcelTotal]int{
+	ExcelAverage: 101,
+	ExcelCount:   102,
+	ExcelMax:     104,
+	ExcelMin:     105,
+	ExcelSum:     109,
+}
+
+const (
+	excelRowHeight   = 18
+	excelMaxColWidth = 60
+	excelTotalLabel  = "รวม"
+)
+
 type (
 	ExcelDataFrame struct {
 		Header []string
@@ -26,96 +67,421 @@ type (
 		Style      ExcelStyle
 	}
 
+	// ExcelReport is a workbook of one or more sheets, in order.
+	ExcelReport struct {
+		Sheets []ExcelSheet
+	}
+	// ExcelSheet is a table with a header row, below Title when it is set.
+	ExcelSheet struct {
+		Name string
+		// Title is merged across the columns above the header, none when empty.
+		Title   string
+		Columns []ExcelColumn
+		Rows    [][]any
+		// FreezeHeader keeps the header, and FreezeColumns leading columns, in
+		// view while scrolling.
+		FreezeHeader  bool
+		FreezeColumns int
+		AutoFilter    bool
+		// Highlight fills the rows at these indexes of Rows in red, such as the
+		// rows of an import with errors. Stream leaves it out.
+		Highlight []int
+	}
+	// ExcelColumn is a column of an ExcelSheet. A totals row is added below the
+	// rows when any column has Total, labelled in the first column without one.
+	ExcelColumn struct {
+		Header string
+		Type   ExcelColumnType
+		// Format is a number format code replacing the one of Type, such as
+		// "dd/mm/yyyy hh:mm" or "#,##0".
+		Format string
+		// Width is fitted to the content when empty.
+		Width float64
+		Total ExcelTotal
+	}
+
 	Excel interface {
 		Build(df *ExcelDataFrame, st ...RowStyle) (*bytes.Buffer, error)
+		// Report writes the sheets of r to a workbook.
+		Report(r *ExcelReport) (*bytes.Buffer, error)
+		// Stream writes the sheet s, with the rows of rows instead of s.Rows, to
+		// w in bounded memory.
+		Stream(w io.Writer, s *ExcelSheet, rows ExcelRows) error
+		// Read returns the rows of the first sheet, the header first.
+		Read(r io.Reader) ([][]string, error)
 	}
 	excel struct{}
+
+	// excelStyles creates the styles of a workbook once each.
+	excelStyles struct {
+		f     *excelize.File
+		cache map[excelStyleKey]int
+	}
+	excelStyleKey struct {
+		kind   string
+		typ    ExcelColumnType
+		format string
+	}
 )
 
 // Build สร้างไฟล์ Excel จากข้อมูลที่ได้รับในรูปแบบ ExcelDataFrame โดยจะกำหนดชื่อคอลัมน์ในแถวแรก
 // และเติมข้อมูลแต่ละแถวลงในไฟล์ Excel จากนั้นปรับขนาดคอลัมน์ให้เหมาะสม และคืนค่าเป็น bytes.Buffer
 // หากเกิดข้อผิดพลาดระหว่างการสร้างหรือเขียนข้อมูล จะคืนค่า error กลับมา
 func (e excel) Build(df *ExcelDataFrame, st ...RowStyle) (*bytes.Buffer, error) {
-
 	if df == nil {
 		return nil, errs.ErrBadRequest
 	}
-	f := excelize.NewFile()
-
-	currencyStyle, err := f.NewStyle(&excelize.Style{
-		NumFmt: 4, // built-in Excel number format for currency with 2 decimals
-	})
-	if err != nil {
-		return nil, err
+	sheet := ExcelSheet{Name: "Sheet1", Rows: df.Rows}
+	for _, header := range df.Header {
+		sheet.Columns = append(sheet.Columns, ExcelColumn{Header: header})
 	}
-
-	const sheet = "Sheet1"
-
-	for i, header := range df.Header {
-		cell := fmt.Sprintf("%s1", string(rune(65+i))) // Column A, B, C, etc.
-		if err := f.SetCellValue(sheet, cell, header); err != nil {
+	for _, s := range st {
+		if s.Sheet != sheet.Name || s.Style != CurrencyStyle {
+			continue
+		}
+		col, err := excelize.ColumnNameToNumber(s.Col)
+		if err != nil {
 			return nil, err
 		}
+		if col <= len(sheet.Columns) {
+			sheet.Columns[col-1].Type = ExcelCurrency
+		}
 	}
+	return e.Report(&ExcelReport{Sheets: []ExcelSheet{sheet}})
+}
 
-	for i, row := range df.Rows {
-		for j, c := range row {
-			cell := string(rune(65 + j)) // Column A, B, C, etc.
-			if err := f.SetCellValue(sheet, fmt.Sprintf("%s%d", cell, i+2), c); err != nil {
+// Report เขียนแต่ละชีตของ ExcelReport ตามลำดับ พร้อมชื่อรายงาน หัวตาราง รูปแบบของแต่ละคอลัมน์
+// แถวรวมที่คำนวณด้วยสูตร การตรึงแถวหัวตาราง และตัวกรองอัตโนมัติ แล้วคืนค่าเป็น bytes.Buffer
+func (e excel) Report(r *ExcelReport) (*bytes.Buffer, error) {
+	if r == nil || len(r.Sheets) == 0 {
+		return nil, errs.ErrBadRequest
+	}
+	f := excelize.NewFile()
+	defer f.Close() //nolint:errcheck
+	styles := &excelStyles{f: f, cache: map[excelStyleKey]int{}}
+	for i := range r.Sheets {
+		sheet := &r.Sheets[i]
+		if len(sheet.Columns) == 0 {
+			return nil, errs.ErrBadRequest
+		}
+		if i == 0 {
+			if err := f.SetSheetName(f.GetSheetName(0), sheet.Name); err != nil {
 				return nil, err
 			}
+		} else if _, err := f.NewSheet(sheet.Name); err != nil {
+			return nil, err
+		}
+		if err := e.writeSheet(f, styles, sheet); err != nil {
+			return nil, err
 		}
 	}
-	if err := e.setColumnFit(sheet, f); err != nil {
+	fullCalc := true
+	if err := f.SetCalcProps(&excelize.CalcPropsOptions{FullCalcOnLoad: &fullCalc}); err != nil {
 		return nil, err
 	}
-
-	for _, s := range st {
-		if s.Style == CurrencyStyle {
-			if err := f.SetColStyle(s.Sheet, s.Col, currencyStyle); err != nil {
-				return nil, err
-			}
-		}
-	}
-
 	return f.WriteToBuffer()
-
 }
 
-func (e excel) setColumnFit(sheet string, x *excelize.File) error {
-	cols, err := x.GetCols(sheet)
+func (e excel) writeSheet(f *excelize.File, styles *excelStyles, s *ExcelSheet) error {
+	lastCol, err := excelize.ColumnNumberToName(len(s.Columns))
+	if err != nil {
+		return err
+	}
+	row := 1
+	if s.Title != "" {
+		if err := f.SetCellValue(s.Name, "A1", s.Title); err != nil {
+			return err
+		}
+		if err := f.MergeCell(s.Name, "A1", lastCol+"1"); err != nil {
+			return err
+		}
+		style, err := styles.get("title", ExcelGeneral, "")
+		if err != nil {
+			return err
+		}
+		if err := f.SetCellStyle(s.Name, "A1", lastCol+"1", style); err != nil {
+			return err
+		}
+		if err := f.SetRowHeight(s.Name, 1, excelRowHeight*1.5); err != nil {
+			return err
+		}
+		row++
+	}
+	headerRow := row
+	widths := make([]int, len(s.Columns))
+	header := make([]any, len(s.Columns))
+	for i, col := range s.Columns {
+		header[i] = col.Header
+		widths[i] = utf8.RuneCountInString(col.Header)
+	}
+	if err := f.SetSheetRow(s.Name, excelCell(1, headerRow), &header); err != nil {
+		return err
+	}
+	style, err := styles.get("header", ExcelGeneral, "")
 	if err != nil {
 		return err
 	}
-	for idx, col := range cols {
-		largestWidth := 0
-		for _, rowCell := range col {
-			cellWidth := utf8.RuneCountInString(rowCell) + 2 // + 2 for margin
-			if cellWidth > largestWidth {
-				largestWidth = cellWidth
+	if err := f.SetCellStyle(s.Name, excelCell(1, headerRow), excelCell(len(s.Columns), headerRow), style); err != nil {
+		return err
+	}
+
+	for _, values := range s.Rows {
+		row++
+		for i, v := range values {
+			if i >= len(s.Columns) {
+				break
+			}
+			if t, ok := v.(*time.Time); ok {
+				if t == nil {
+					continue
+				}
+				v = *t
+			}
+			if err := f.SetCellValue(s.Name, excelCell(i+1, row), v); err != nil {
+				return err
+			}
+			widths[i] = max(widths[i], excelWidth(s.Columns[i], v))
+		}
+	}
+	firstRow, lastRow := headerRow+1, row
+	if lastRow >= firstRow {
+		for i, col := range s.Columns {
+			style, err := styles.get("cell", col.Type, col.Format)
+			if err != nil {
+				return err
+			}
+			if err := f.SetCellStyle(s.Name, excelCell(i+1, firstRow), excelCell(i+1, lastRow), style); err != nil {
+				return err
+			}
+		}
+	}
+	for _, i := range s.Highlight {
+		if i < 0 || firstRow+i > lastRow {
+			continue
+		}
+		for c, col := range s.Columns {
+			style, err := styles.get("highlight", col.Type, col.Format)
+			if err != nil {
+				return err
+			}
+			if err := f.SetCellStyle(s.Name, excelCell(c+1, firstRow+i), excelCell(c+1, firstRow+i), style); err != nil {
+				return err
 			}
 		}
-		name, err := excelize.ColumnNumberToName(idx + 1)
+	}
+	if err := e.writeTotals(f, styles, s, firstRow, lastRow); err != nil {
+		return err
+	}
+
+	for i, col := range s.Columns {
+		name, err := excelize.ColumnNumberToName(i + 1)
 		if err != nil {
 			return err
 		}
-		if err := x.SetColWidth(sheet, name, name, float64(largestWidth)); err != nil {
+		width := col.Width
+		if width == 0 {
+			width = float64(min(widths[i]+2, excelMaxColWidth)) // + 2 for margin
+		}
+		if err := f.SetColWidth(s.Name, name, name, width); err != nil {
+			return err
+		}
+	}
+	for r := headerRow; r <= lastRow+1; r++ {
+		if err := f.SetRowHeight(s.Name, r, excelRowHeight); err != nil {
 			return err
 		}
 	}
+	if s.AutoFilter {
+		if err := f.AutoFilter(s.Name, excelCell(1, headerRow)+":"+excelCell(len(s.Columns), max(lastRow, firstRow)), nil); err != nil {
+			return err
+		}
+	}
+	if panes := s.panes(headerRow); panes != nil {
+		if err := f.SetPanes(s.Name, panes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	rows, err := x.GetRows(sheet)
+// panes freezes the rows through headerRow and the leading columns as asked,
+// nil when nothing is frozen.
+func (s *ExcelSheet) panes(headerRow int) *excelize.Panes {
+	if !s.FreezeHeader && s.FreezeColumns == 0 {
+		return nil
+	}
+	panes := &excelize.Panes{Freeze: true, XSplit: s.FreezeColumns, ActivePane: "topRight"}
+	if s.FreezeHeader {
+		panes.YSplit = headerRow
+		panes.ActivePane = "bottomLeft"
+		if s.FreezeColumns > 0 {
+			panes.ActivePane = "bottomRight"
+		}
+	}
+	panes.TopLeftCell = excelCell(panes.XSplit+1, panes.YSplit+1)
+	return panes
+}
+
+// writeTotals adds the totals row below lastRow.
+func (e excel) writeTotals(f *excelize.File, styles *excelStyles, s *ExcelSheet, firstRow, lastRow int) error {
+	cells, err := excelTotals(styles, s.Columns, firstRow, lastRow)
 	if err != nil {
 		return err
 	}
-	for idx := range rows {
-		if err := x.SetRowHeight(sheet, idx+1, 18); err != nil {
+	for i, c := range cells {
+		cell := excelCell(i+1, lastRow+1)
+		if c.Formula != "" {
+			if err := f.SetCellFormula(s.Name, cell, c.Formula); err != nil {
+				return err
+			}
+		} else if c.Value != nil {
+			if err := f.SetCellValue(s.Name, cell, c.Value); err != nil {
+				return err
+			}
+		}
+		if err := f.SetCellStyle(s.Name, cell, cell, c.StyleID); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// excelTotals returns the cells of the totals row of the rows from firstRow to
+// lastRow, none when no column has a total. The SUBTOTAL formulas total the
+// rows left by the auto filter.
+func excelTotals(styles *excelStyles, columns []ExcelColumn, firstRow, lastRow int) ([]excelize.Cell, error) {
+	label := -1
+	hasTotal := false
+	for i, col := range columns {
+		if col.Total == "" {
+			if label < 0 {
+				label = i
+			}
+			continue
+		}
+		if _, ok := excelSubtotals[col.Total]; !ok {
+			return nil, fmt.Errorf("unknown excel total %q", col.Total)
+		}
+		hasTotal = true
+	}
+	if !hasTotal {
+		return nil, nil
+	}
+	cells := make([]excelize.Cell, len(columns))
+	for i, col := range columns {
+		switch {
+		case col.Total != "" && lastRow >= firstRow:
+			cells[i].Formula = fmt.Sprintf("SUBTOTAL(%d,%s:%s)", excelSubtotals[col.Total], excelCell(i+1, firstRow), excelCell(i+1, lastRow))
+		case col.Total != "":
+			cells[i].Value = 0
+		case i == label:
+			cells[i].Value = excelTotalLabel
+		}
+		typ, format := col.Type, col.Format
+		if col.Total == ExcelCount {
+			typ, format = ExcelGeneral, "#,##0"
+		}
+		style, err := styles.get("total", typ, format)
+		if err != nil {
+			return nil, err
+		}
+		cells[i].StyleID = style
+	}
+	return cells, nil
+}
+
+// get returns the style of kind, title, header, cell, highlight or total, formatting
+// values as typ or format.
+func (s *excelStyles) get(kind string, typ ExcelColumnType, format string) (int, error) {
+	key := excelStyleKey{kind: kind, typ: typ, format: format}
+	if id, ok := s.cache[key]; ok {
+		return id, nil
+	}
+	style := &excelize.Style{
+		Alignment: &excelize.Alignment{Vertical: "center"},
+	}
+	switch {
+	case format != "":
+		style.CustomNumFmt = &format
+	case typ == ExcelText:
+		style.NumFmt = 49 // @
+	case typ == ExcelNumber:
+		custom := "#,##0.00##"
+		style.CustomNumFmt = &custom
+	case typ == ExcelCurrency:
+		style.NumFmt = 4 // #,##0.00
+	case typ == ExcelPercent:
+		style.NumFmt = 10 // 0.00%
+	case typ == ExcelDate:
+		custom := "yyyy-mm-dd"
+		style.CustomNumFmt = &custom
+	}
+	switch kind {
+	case "title":
+		style.Font = &excelize.Font{Bold: true, Size: 14}
+	case "header":
+		style.Font = &excelize.Font{Bold: true}
+		style.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"D9E1F2"}}
+		style.Border = []excelize.Border{{Type: "bottom", Color: "8EA9DB", Style: 1}}
+		style.Alignment.Horizontal = "center"
+		style.Alignment.WrapText = true
+	case "highlight":
+		style.Fill = excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFC7CE"}}
+		style.Font = &excelize.Font{Color: "9C0006"}
+	case "total":
+		style.Font = &excelize.Font{Bold: true}
+		style.Border = []excelize.Border{{Type: "top", Color: "000000", Style: 1}, {Type: "bottom", Color: "000000", Style: 6}}
+	}
+	id, err := s.f.NewStyle(style)
+	if err != nil {
+		return 0, err
+	}
+	s.cache[key] = id
+	return id, nil
+}
+
+// Read อ่านข้อมูลทุกแถวของชีตแรกในไฟล์ Excel โดยแถวแรกคือชื่อคอลัมน์
+// หากไฟล์ไม่ใช่ Excel ที่ถูกต้องจะคืนค่า ErrBadRequest
+func (e excel) Read(r io.Reader) ([][]string, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, errs.ErrBadRequest
+	}
+	defer f.Close() //nolint:errcheck
+	sheet := f.GetSheetName(0)
+	if sheet == "" {
+		return nil, errs.ErrBadRequest
+	}
+	return f.GetRows(sheet)
+}
+
+// excelCell names the cell of column col, from 1, in row.
+func excelCell(col, row int) string {
+	name, _ := excelize.CoordinatesToCellName(col, row) // fails only below 1
+	return name
+}
+
+// excelWidth is about the number of characters v takes once formatted.
+func excelWidth(col ExcelColumn, v any) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case time.Time:
+		if col.Format != "" {
+			return len(col.Format)
+		}
+		return len(time.DateOnly)
+	case float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s := fmt.Sprint(v)
+		n := len(s) + len(s)/3 // thousands separators
+		if col.Type == ExcelCurrency || col.Type == ExcelNumber || col.Type == ExcelPercent {
+			n += 3 // decimals and %
+		}
+		return n
+	}
+	return utf8.RuneCountInString(fmt.Sprint(v))
+}
+
 func NewExcelService() Excel {
 	return excel{}
 }
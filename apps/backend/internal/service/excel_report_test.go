@@ -0,0 +1,148 @@
+package service_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/suttapak/starter/internal/service"
+	"github.com/xuri/excelize/v2"
+)
+
+// reportSheet is a small stock sheet with one column of each type, totals
+// and a highlighted row.
+func reportSheet() service.ExcelSheet {
+	return service.ExcelSheet{
+		Name: "stock",
+		Columns: []service.ExcelColumn{
+			{Header: "code", Type: service.ExcelText},
+			{Header: "received", Type: service.ExcelDate},
+			{Header: "quantity", Type: service.ExcelNumber, Total: service.ExcelSum},
+			{Header: "value", Type: service.ExcelCurrency, Total: service.ExcelSum},
+			{Header: "share", Type: service.ExcelPercent, Total: service.ExcelAverage},
+			{Header: "lots", Format: "#,##0", Total: service.ExcelCount},
+		},
+		Rows: [][]any{
+			{"007", time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), 1.5, 1234.5, 0.25, 3},
+			{"010", (*time.Time)(nil), 2.125, 10, 0.75, 1},
+		},
+		FreezeHeader: true,
+		AutoFilter:   true,
+		Highlight:    []int{1},
+	}
+}
+
+// numFmt is the number format of the style of cell, the built-in id or the
+// custom code.
+func numFmt(t *testing.T, f *excelize.File, sheet, cell string) (int, string) {
+	t.Helper()
+	id, err := f.GetCellStyle(sheet, cell)
+	require.NoError(t, err)
+	style, err := f.GetStyle(id)
+	require.NoError(t, err)
+	if style.CustomNumFmt != nil {
+		return style.NumFmt, *style.CustomNumFmt
+	}
+	return style.NumFmt, ""
+}
+
+func fillColor(t *testing.T, f *excelize.File, sheet, cell string) []string {
+	t.Helper()
+	id, err := f.GetCellStyle(sheet, cell)
+	require.NoError(t, err)
+	style, err := f.GetStyle(id)
+	require.NoError(t, err)
+	return style.Fill.Color
+}
+
+func TestExcelReportRead(t *testing.T) {
+	excel := service.NewExcelService()
+	buf, err := excel.Report(&service.ExcelReport{Sheets: []service.ExcelSheet{reportSheet()}})
+	require.NoError(t, err)
+
+	rows, err := excel.Read(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	require.Len(t, rows, 4)
+	assert.Equal(t, []string{"code", "received", "quantity", "value", "share", "lots"}, rows[0])
+	assert.Equal(t, []string{"007", "2026-01-02", "1.50", "1,234.50", "25.00%", "3"}, rows[1])
+	assert.Equal(t, []string{"010", "", "2.125", "10.00", "75.00%", "1"}, rows[2])
+	// formulas are calculated on load, the file keeps no cached totals
+	assert.Equal(t, "รวม", rows[3][0])
+}
+
+func TestExcelReportCells(t *testing.T) {
+	excel := service.NewExcelService()
+	buf, err := excel.Report(&service.ExcelReport{Sheets: []service.ExcelSheet{reportSheet()}})
+	require.NoError(t, err)
+	f, err := excelize.OpenReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	defer f.Close() //nolint:errcheck
+
+	t.Run("totals", func(t *testing.T) {
+		want := map[string]string{
+			"C4": "SUBTOTAL(109,C2:C3)",
+			"D4": "SUBTOTAL(109,D2:D3)",
+			"E4": "SUBTOTAL(101,E2:E3)",
+			"F4": "SUBTOTAL(102,F2:F3)",
+		}
+		for cell, formula := range want {
+			got, err := f.GetCellFormula("stock", cell)
+			require.NoError(t, err)
+			assert.Equal(t, formula, got, cell)
+		}
+		for cell, want := range map[string]string{"C4": "3.625", "D4": "1244.5", "E4": "0.5", "F4": "2"} {
+			got, err := f.CalcCellValue("stock", cell, excelize.Options{RawCellValue: true})
+			require.NoError(t, err)
+			assert.Equal(t, want, got, cell)
+		}
+	})
+
+	t.Run("number formats", func(t *testing.T) {
+		tests := []struct {
+			cell   string
+			numFmt int
+			custom string
+		}{
+			{cell: "A2", numFmt: 49},
+			{cell: "B2", custom: "yyyy-mm-dd"},
+			{cell: "C2", custom: "#,##0.00##"},
+			{cell: "D2", numFmt: 4},
+			{cell: "E2", numFmt: 10},
+			{cell: "F2", custom: "#,##0"},
+			// the totals are formatted as their column, counts as whole numbers
+			{cell: "D4", numFmt: 4},
+			{cell: "F4", custom: "#,##0"},
+		}
+		for _, tt := range tests {
+			id, custom := numFmt(t, f, "stock", tt.cell)
+			if tt.custom != "" {
+				assert.Equal(t, tt.custom, custom, tt.cell)
+			} else {
+				assert.Equal(t, tt.numFmt, id, tt.cell)
+			}
+		}
+	})
+
+	t.Run("values", func(t *testing.T) {
+		for cell, want := range map[string]string{"A2": "007", "C3": "2.125", "D2": "1234.5", "E2": "0.25", "F3": "1"} {
+			got, err := f.GetCellValue("stock", cell, excelize.Options{RawCellValue: true})
+			require.NoError(t, err)
+			assert.Equal(t, want, got, cell)
+		}
+		typ, err := f.GetCellType("stock", "A2")
+		require.NoError(t, err)
+		assert.NotEqual(t, excelize.CellTypeNumber, typ, "codes stay text")
+	})
+
+	t.Run("highlight", func(t *testing.T) {
+		assert.Empty(t, fillColor(t, f, "stock", "A2"))
+		for _, cell := range []string{"A3", "D3", "F3"} {
+			assert.Equal(t, []string{"FFC7CE"}, fillColor(t, f, "stock", cell), cell)
+		}
+		// the highlight keeps the format of the column
+		id, _ := numFmt(t, f, "stock", "D3")
+		assert.Equal(t, 4, id)
+	})
+}
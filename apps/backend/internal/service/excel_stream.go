@@ -0,0 +1,170 @@
+package service
+
+import (
+	"io"
+	"time"
+	"unicode/utf8"
+
+	"github.com/suttapak/starter/errs"
+	"github.com/xuri/excelize/v2"
+)
+
+type (
+	// ExcelRows writes the rows of a streamed sheet with write, in order, e.g.
+	// while reading them from a database cursor. It returns the first error of
+	// write.
+	ExcelRows func(write func(row []any) error) error
+
+	// ExcelStream is a workbook written straight to a response by Write, it
+	// writes nothing when the rows fail.
+	ExcelStream struct {
+		Filename string
+		Write    func(w io.Writer) error
+	}
+)
+
+// Stream เขียนชีตเดียวด้วย StreamWriter ของ excelize โดยรับแถวทีละแถวจาก rows แทนการเก็บทั้งไฟล์ไว้ในหน่วยความจำ
+// แถวที่เขียนแล้วจะถูกพักไว้ในไฟล์ชั่วคราวจนครบ แล้วจึงเขียนไฟล์ Excel ทั้งหมดลง w
+// ความกว้างของคอลัมน์ประมาณจากชนิดของคอลัมน์ เพราะต้องกำหนดก่อนเขียนแถวแรก และตัวกรองอัตโนมัติใช้ตารางแทน
+func (e excel) Stream(w io.Writer, s *ExcelSheet, rows ExcelRows) error {
+	if s == nil || len(s.Columns) == 0 {
+		return errs.ErrBadRequest
+	}
+	f := excelize.NewFile()
+	defer f.Close() //nolint:errcheck
+	if err := f.SetSheetName(f.GetSheetName(0), s.Name); err != nil {
+		return err
+	}
+	sw, err := f.NewStreamWriter(s.Name)
+	if err != nil {
+		return err
+	}
+	styles := &excelStyles{f: f, cache: map[excelStyleKey]int{}}
+	lastCol, err := excelize.ColumnNumberToName(len(s.Columns))
+	if err != nil {
+		return err
+	}
+	// column widths and panes go before the first row
+	for i, col := range s.Columns {
+		if err := sw.SetColWidth(i+1, i+1, excelStreamWidth(col)); err != nil {
+			return err
+		}
+	}
+	row := 1
+	if s.Title != "" {
+		row++
+	}
+	headerRow := row
+	if panes := s.panes(headerRow); panes != nil {
+		if err := sw.SetPanes(panes); err != nil {
+			return err
+		}
+	}
+	if s.Title != "" {
+		style, err := styles.get("title", ExcelGeneral, "")
+		if err != nil {
+			return err
+		}
+		// the merged cells are styled like the title, as Report does
+		title := make([]any, len(s.Columns))
+		for i := range title {
+			title[i] = excelize.Cell{StyleID: style}
+		}
+		title[0] = excelize.Cell{StyleID: style, Value: s.Title}
+		if err := sw.SetRow("A1", title, excelize.RowOpts{Height: excelRowHeight * 1.5}); err != nil {
+			return err
+		}
+		if err := sw.MergeCell("A1", lastCol+"1"); err != nil {
+			return err
+		}
+	}
+	style, err := styles.get("header", ExcelGeneral, "")
+	if err != nil {
+		return err
+	}
+	cells := make([]any, len(s.Columns))
+	for i, col := range s.Columns {
+		cells[i] = excelize.Cell{StyleID: style, Value: col.Header}
+	}
+	if err := sw.SetRow(excelCell(1, headerRow), cells, excelize.RowOpts{Height: excelRowHeight}); err != nil {
+		return err
+	}
+
+	columnStyles := make([]int, len(s.Columns))
+	for i, col := range s.Columns {
+		if columnStyles[i], err = styles.get("cell", col.Type, col.Format); err != nil {
+			return err
+		}
+	}
+	err = rows(func(values []any) error {
+		row++
+		for i := range cells {
+			cell := excelize.Cell{StyleID: columnStyles[i]}
+			if i < len(values) {
+				cell.Value = values[i]
+				if t, ok := cell.Value.(*time.Time); ok {
+					cell.Value = nil
+					if t != nil {
+						cell.Value = *t
+					}
+				}
+			}
+			cells[i] = cell
+		}
+		return sw.SetRow(excelCell(1, row), cells, excelize.RowOpts{Height: excelRowHeight})
+	})
+	if err != nil {
+		return err
+	}
+
+	firstRow, lastRow := headerRow+1, row
+	if s.AutoFilter {
+		// a table without a style keeps the cell styles and filters while the
+		// worksheet is written, an auto filter would need it read back
+		if err := sw.AddTable(&excelize.Table{Range: excelCell(1, headerRow) + ":" + excelCell(len(s.Columns), max(lastRow, firstRow))}); err != nil {
+			return err
+		}
+	}
+	totals, err := excelTotals(styles, s.Columns, firstRow, lastRow)
+	if err != nil {
+		return err
+	}
+	if totals != nil {
+		cells := make([]any, len(totals))
+		for i, c := range totals {
+			cells[i] = c
+		}
+		if err := sw.SetRow(excelCell(1, lastRow+1), cells, excelize.RowOpts{Height: excelRowHeight}); err != nil {
+			return err
+		}
+	}
+	if err := sw.Flush(); err != nil {
+		return err
+	}
+	fullCalc := true
+	if err := f.SetCalcProps(&excelize.CalcPropsOptions{FullCalcOnLoad: &fullCalc}); err != nil {
+		return err
+	}
+	return f.Write(w)
+}
+
+// excelStreamWidth is the width of a streamed column, its values are not known
+// up front.
+func excelStreamWidth(col ExcelColumn) float64 {
+	if col.Width != 0 {
+		return col.Width
+	}
+	width := 20
+	switch {
+	case col.Type == ExcelDate && col.Format != "":
+		width = len(col.Format)
+	case col.Type == ExcelDate:
+		width = len(time.DateOnly)
+	case col.Type == ExcelPercent:
+		width = 8
+	case col.Type == ExcelNumber || col.Type == ExcelCurrency:
+		width = 14
+	}
+	width = max(width, utf8.RuneCountInString(col.Header))
+	return float64(min(width+2, excelMaxColWidth)) // + 2 for margin
+}
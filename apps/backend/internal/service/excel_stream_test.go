@@ -0,0 +1,253 @@
+package service_test
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/suttapak/starter/internal/service"
+	"github.com/xuri/excelize/v2"
+)
+
+// benchRows is the size of a large stock movement export.
+const benchRows = 100_000
+
+var benchColumns = []service.ExcelColumn{
+	{Header: "moved_at", Type: service.ExcelDate, Format: "yyyy-mm-dd hh:mm"},
+	{Header: "product_code", Type: service.ExcelText},
+	{Header: "product_name", Type: service.ExcelText},
+	{Header: "lot_code", Type: service.ExcelText},
+	{Header: "reason", Type: service.ExcelText},
+	{Header: "quantity", Type: service.ExcelNumber, Total: service.ExcelSum},
+	{Header: "balance", Type: service.ExcelNumber},
+}
+
+func benchRow(i int, at time.Time) []any {
+	return []any{at.Add(time.Duration(i) * time.Minute), "PN-010042", "สินค้าทดสอบ", "LN-420001", "sale", float64(i%7 - 3), float64(i)}
+}
+
+// peakHeap samples the heap in use until stop is called and reports the
+// largest sample, the memory the export holds at once.
+func peakHeap(b *testing.B) (stop func()) {
+	b.Helper()
+	runtime.GC()
+	var (
+		wg   sync.WaitGroup
+		peak uint64
+		done = make(chan struct{})
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		var m runtime.MemStats
+		for {
+			runtime.ReadMemStats(&m)
+			peak = max(peak, m.HeapInuse)
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+		b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MiB")
+	}
+}
+
+// BenchmarkExcelStream writes the rows one at a time as from a database
+// cursor, the heap stays flat whatever the number of rows.
+func BenchmarkExcelStream(b *testing.B) {
+	excel := service.NewExcelService()
+	at := time.Date(2026, 1, 1, 8, 0, 0, 0, time.Local)
+	b.ReportAllocs()
+	stop := peakHeap(b)
+	for range b.N {
+		sheet := &service.ExcelSheet{Name: "movements", Title: "movements", Columns: benchColumns, FreezeHeader: true, AutoFilter: true}
+		err := excel.Stream(io.Discard, sheet, func(write func(row []any) error) error {
+			for i := range benchRows {
+				if err := write(benchRow(i, at)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	stop()
+}
+
+// BenchmarkExcelReport builds the same sheet in memory for comparison.
+func BenchmarkExcelReport(b *testing.B) {
+	excel := service.NewExcelService()
+	at := time.Date(2026, 1, 1, 8, 0, 0, 0, time.Local)
+	b.ReportAllocs()
+	stop := peakHeap(b)
+	for range b.N {
+		sheet := service.ExcelSheet{Name: "movements", Title: "movements", Columns: benchColumns, FreezeHeader: true, AutoFilter: true}
+		sheet.Rows = make([][]any, 0, benchRows)
+		for i := range benchRows {
+			sheet.Rows = append(sheet.Rows, benchRow(i, at))
+		}
+		buf, err := excel.Report(&service.ExcelReport{Sheets: []service.ExcelSheet{sheet}})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := io.Copy(io.Discard, buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+	stop()
+}
+
+// TestExcelStreamMatchesReport streams small sheets and compares them cell by
+// cell with the workbook Report writes of the same rows. The column widths are
+// estimated up front and the auto filter is a table, they are compared apart.
+func TestExcelStreamMatchesReport(t *testing.T) {
+	at := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	rows := make([][]any, 0, 3)
+	for i := range 3 {
+		rows = append(rows, benchRow(i, at))
+	}
+	rows = append(rows, []any{(*time.Time)(nil), "PN-000001", "ไม่มีวันที่", nil, "count", 2.5, 0.125})
+
+	tests := []struct {
+		name  string
+		sheet service.ExcelSheet
+	}{
+		{name: "title, header frozen and filtered", sheet: service.ExcelSheet{Title: "movements", FreezeHeader: true, AutoFilter: true, Rows: rows}},
+		{name: "header and columns frozen", sheet: service.ExcelSheet{FreezeHeader: true, FreezeColumns: 2, Rows: rows}},
+		{name: "columns frozen", sheet: service.ExcelSheet{Title: "movements", FreezeColumns: 1, Rows: rows}},
+		{name: "no rows", sheet: service.ExcelSheet{Title: "movements", FreezeHeader: true, AutoFilter: true}},
+		{name: "no totals", sheet: service.ExcelSheet{AutoFilter: true, Rows: rows, Columns: []service.ExcelColumn{
+			{Header: "moved_at", Type: service.ExcelDate},
+			{Header: "product_code", Type: service.ExcelText},
+			{Header: "share", Type: service.ExcelPercent},
+		}}},
+	}
+	excel := service.NewExcelService()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sheet := tt.sheet
+			sheet.Name = "movements"
+			if sheet.Columns == nil {
+				sheet.Columns = benchColumns
+			}
+			report, err := excel.Report(&service.ExcelReport{Sheets: []service.ExcelSheet{sheet}})
+			require.NoError(t, err)
+			var stream bytes.Buffer
+			err = excel.Stream(&stream, &sheet, func(write func(row []any) error) error {
+				for _, row := range sheet.Rows {
+					if err := write(row); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			require.NoError(t, err)
+
+			want, err := excelize.OpenReader(report)
+			require.NoError(t, err)
+			defer want.Close() //nolint:errcheck
+			got, err := excelize.OpenReader(&stream)
+			require.NoError(t, err)
+			defer got.Close() //nolint:errcheck
+
+			headerRow := 1
+			if sheet.Title != "" {
+				headerRow = 2
+			}
+			// the totals row is always compared, empty when there are no totals
+			lastRow := headerRow + len(sheet.Rows) + 1
+			for row := 1; row <= lastRow; row++ {
+				for col := range sheet.Columns {
+					cell, err := excelize.CoordinatesToCellName(col+1, row)
+					require.NoError(t, err)
+					assertSameCell(t, want, got, sheet.Name, cell)
+				}
+			}
+
+			wantMerged, err := want.GetMergeCells(sheet.Name)
+			require.NoError(t, err)
+			gotMerged, err := got.GetMergeCells(sheet.Name)
+			require.NoError(t, err)
+			assert.Equal(t, len(wantMerged), len(gotMerged))
+			for i := range min(len(wantMerged), len(gotMerged)) {
+				assert.Equal(t, wantMerged[i].GetStartAxis(), gotMerged[i].GetStartAxis())
+				assert.Equal(t, wantMerged[i].GetEndAxis(), gotMerged[i].GetEndAxis())
+			}
+
+			wantPanes, err := want.GetPanes(sheet.Name)
+			require.NoError(t, err)
+			gotPanes, err := got.GetPanes(sheet.Name)
+			require.NoError(t, err)
+			assert.Equal(t, wantPanes.Freeze, gotPanes.Freeze)
+			assert.Equal(t, wantPanes.XSplit, gotPanes.XSplit)
+			assert.Equal(t, wantPanes.YSplit, gotPanes.YSplit)
+			assert.Equal(t, wantPanes.TopLeftCell, gotPanes.TopLeftCell)
+			assert.Equal(t, wantPanes.ActivePane, gotPanes.ActivePane)
+
+			tables, err := got.GetTables(sheet.Name)
+			require.NoError(t, err)
+			if !sheet.AutoFilter {
+				assert.Empty(t, tables)
+				assert.Empty(t, autoFilterRange(want, sheet.Name))
+				return
+			}
+			require.Len(t, tables, 1)
+			assert.Equal(t, autoFilterRange(want, sheet.Name), tables[0].Range)
+		})
+	}
+}
+
+// assertSameCell compares the value, formula and style of cell in want and got.
+func assertSameCell(t *testing.T, want, got *excelize.File, sheet, cell string) {
+	t.Helper()
+	raw := excelize.Options{RawCellValue: true}
+	wantValue, err := want.GetCellValue(sheet, cell, raw)
+	require.NoError(t, err)
+	gotValue, err := got.GetCellValue(sheet, cell, raw)
+	require.NoError(t, err)
+	assert.Equal(t, wantValue, gotValue, "value of %s", cell)
+
+	wantFormula, err := want.GetCellFormula(sheet, cell)
+	require.NoError(t, err)
+	gotFormula, err := got.GetCellFormula(sheet, cell)
+	require.NoError(t, err)
+	assert.Equal(t, wantFormula, gotFormula, "formula of %s", cell)
+
+	assert.Equal(t, cellStyle(t, want, sheet, cell), cellStyle(t, got, sheet, cell), "style of %s", cell)
+}
+
+func cellStyle(t *testing.T, f *excelize.File, sheet, cell string) *excelize.Style {
+	t.Helper()
+	id, err := f.GetCellStyle(sheet, cell)
+	require.NoError(t, err)
+	style, err := f.GetStyle(id)
+	require.NoError(t, err)
+	return style
+}
+
+// autoFilterRange is the range of the auto filter of sheet, such as A2:G6,
+// empty without one.
+func autoFilterRange(f *excelize.File, sheet string) string {
+	for _, name := range f.GetDefinedName() {
+		if name.Name == "_xlnm._FilterDatabase" && name.Scope == sheet {
+			_, ref, _ := strings.Cut(name.RefersTo, "!")
+			return strings.ReplaceAll(ref, "$", "")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/idx"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+)
+
+const (
+	// signedFilePath is the route serving signed download URLs, see route.UseFile.
+	signedFilePath = "/files/signed"
+
+	DispositionInline     = "inline"
+	DispositionAttachment = "attachment"
+
+	defaultSignedUrlExpire = time.Hour
+	// protected files may be cached by the browser, never by shared caches
+	protectedFileMaxAge = time.Hour
+)
+
+type (
+	FileService interface {
+		// OpenImage returns the image file if userId owns the image or is an admin.
+		OpenImage(ctx context.Context, userId, imageId uint, download bool) (io.ReadSeekCloser, *FileInfo, error)
+		// CreateImageSignedUrl returns an expiring URL anyone can download the image from.
+		CreateImageSignedUrl(ctx context.Context, userId, imageId uint, body CreateSignedUrlDto) (*SignedUrlResponse, error)
+		// OpenSigned returns the object of key if query carries a valid, unexpired signature.
+		OpenSigned(ctx context.Context, key string, query url.Values) (io.ReadSeekCloser, *FileInfo, error)
+	}
+	fileService struct {
+		conf    *config.Config
+		storage repository.Storage
+		image   repository.Image
+		user    repository.User
+		signer  helpers.URLSigner
+		logger  logger.AppLogger
+	}
+
+	FileInfo struct {
+		Key          string
+		Filename     string
+		Disposition  string
+		Size         int64
+		ContentType  string
+		ETag         string
+		LastModified time.Time
+		MaxAge       time.Duration
+	}
+
+	CreateSignedUrlDto struct {
+		// ExpiresIn is the lifetime of the URL in seconds, default 1 hour, max 7 days.
+		ExpiresIn int  `json:"expires_in" binding:"omitempty,min=60,max=604800"`
+		Download  bool `json:"download"`
+	}
+	SignedUrlResponse struct {
+		Url       string    `json:"url"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+)
+
+// OpenImage implements FileService.
+func (f *fileService) OpenImage(ctx context.Context, userId, imageId uint, download bool) (io.ReadSeekCloser, *FileInfo, error) {
+	imageModel, err := f.image.FindById(ctx, nil, imageId)
+	if err != nil {
+		f.logger.Error(err)
+		return nil, nil, errs.HandleSqlErr(err)
+	}
+	if err := f.checkOwner(ctx, userId, imageModel.UserID); err != nil {
+		return nil, nil, err
+	}
+	if err := checkScanStatus(imageModel.ScanStatus); err != nil {
+		return nil, nil, err
+	}
+	disposition := DispositionInline
+	if download {
+		disposition = DispositionAttachment
+	}
+	return openFile(ctx, f.storage, f.logger, imageModel.Path, path.Base(imageModel.Path), disposition, protectedFileMaxAge)
+}
+
+// CreateImageSignedUrl implements FileService.
+func (f *fileService) CreateImageSignedUrl(ctx context.Context, userId, imageId uint, body CreateSignedUrlDto) (*SignedUrlResponse, error) {
+	imageModel, err := f.image.FindById(ctx, nil, imageId)
+	if err != nil {
+		f.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := f.checkOwner(ctx, userId, imageModel.UserID); err != nil {
+		return nil, err
+	}
+	if err := checkScanStatus(imageModel.ScanStatus); err != nil {
+		return nil, err
+	}
+	expire := defaultSignedUrlExpire
+	if body.ExpiresIn > 0 {
+		expire = time.Duration(body.ExpiresIn) * time.Second
+	}
+	disposition := DispositionInline
+	if body.Download {
+		disposition = DispositionAttachment
+	}
+	return f.signUrl(imageModel.Path, path.Base(imageModel.Path), disposition, time.Now().Add(expire))
+}
+
+// OpenSigned implements FileService.
+func (f *fileService) OpenSigned(ctx context.Context, key string, query url.Values) (io.ReadSeekCloser, *FileInfo, error) {
+	key, err := repository.CleanStorageKey(key)
+	if err != nil {
+		return nil, nil, errs.ErrNotFound
+	}
+	if err := f.signer.Verify("/"+key, query, time.Now()); err != nil {
+		if errors.Is(err, helpers.ErrSignedUrlExpired) {
+			return nil, nil, errs.ErrSignedUrlExpired
+		}
+		return nil, nil, errs.ErrSignedUrlInvalid
+	}
+	// already validated by Verify
+	expires, _ := strconv.ParseInt(query.Get("expires"), 10, 64)
+	filename := query.Get("name")
+	if filename == "" {
+		filename = path.Base(key)
+	}
+	disposition := query.Get("disposition")
+	if disposition != DispositionAttachment {
+		disposition = DispositionInline
+	}
+	return openFile(ctx, f.storage, f.logger, key, filename, disposition, time.Until(time.Unix(expires, 0)))
+}
+
+// openFile opens key of storage for serving with the given name and disposition.
+func openFile(ctx context.Context, storage repository.Storage, logger logger.AppLogger, key, filename, disposition string, maxAge time.Duration) (io.ReadSeekCloser, *FileInfo, error) {
+	r, obj, err := storage.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, repository.ErrStorageObjectNotFound) || errors.Is(err, repository.ErrStorageInvalidKey) {
+			return nil, nil, errs.ErrNotFound
+		}
+		logger.Error(err)
+		return nil, nil, errs.ErrInternal
+	}
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	return r, &FileInfo{
+		Key:          obj.Key,
+		Filename:     filename,
+		Disposition:  disposition,
+		Size:         obj.Size,
+		ContentType:  obj.ContentType,
+		ETag:         obj.ETag,
+		LastModified: obj.LastModified,
+		MaxAge:       maxAge,
+	}, nil
+}
+
+func (f *fileService) signUrl(key, filename, disposition string, expires time.Time) (*SignedUrlResponse, error) {
+	key, err := repository.CleanStorageKey(key)
+	if err != nil {
+		return nil, errs.ErrNotFound
+	}
+	q := f.signer.Sign("/"+key, url.Values{
+		"name":        {filename},
+		"disposition": {disposition},
+	}, expires)
+	u := f.conf.SERVER.HOST_NAME + "/api/v1" + signedFilePath + (&url.URL{Path: "/" + key}).EscapedPath() + "?" + q.Encode()
+	return &SignedUrlResponse{
+		Url:       u,
+		ExpiresAt: expires,
+	}, nil
+}
+
+// checkOwner allows the owner of a file and admins.
+func (f *fileService) checkOwner(ctx context.Context, userId, ownerId uint) error {
+	if userId == ownerId {
+		return nil
+	}
+	userModel, err := f.user.FindById(ctx, nil, userId)
+	if err != nil {
+		f.logger.Error(err)
+		return errs.ErrForbidden
+	}
+	if userModel.RoleID < idx.RoleAdmin {
+		return errs.ErrForbidden
+	}
+	return nil
+}
+
+// checkScanStatus allows only files the scanner found clean.
+func checkScanStatus(status string) error {
+	switch status {
+	case model.ScanStatusClean:
+		return nil
+	case model.ScanStatusInfected:
+		return errs.ErrFileInfected
+	default:
+		return errs.ErrFileNotScanned
+	}
+}
+
+// imageUrl returns the URL of the protected image route, see route.UseFile.
+func imageUrl(imageId uint) string {
+	return fmt.Sprintf("/files/images/%d", imageId)
+}
+
+func NewFileService(
+	conf *config.Config,
+	storage repository.Storage,
+	image repository.Image,
+	user repository.User,
+	signer helpers.URLSigner,
+	logger logger.AppLogger,
+) FileService {
+	return &fileService{
+		conf:    conf,
+		storage: storage,
+		image:   image,
+		user:    user,
+		signer:  signer,
+		logger:  logger,
+	}
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path"
+	"time"
+
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	FileCollectReasonDeleted      = "deleted"      // moved to the deleted folder longer than the retention ago
+	FileCollectReasonUnreferenced = "unreferenced" // no blob or image points at the file
+	FileCollectReasonReleased     = "released"     // blob without references
+	FileCollectReasonExpired      = "expired"      // chunk of a resumable upload past its Upload-Expires
+)
+
+// collectedPaths are scanned for files nothing references anymore.
+var collectedPaths = []string{imagePath, profileImagePath, productImagePath, attachmentPath}
+
+type (
+	FileCollector interface {
+		// Collect removes unreferenced files and deleted files older than the
+		// retention window, with dryRun nothing is removed and only reported.
+		Collect(ctx context.Context, dryRun bool) (*FileCollectReport, error)
+	}
+	fileCollector struct {
+		conf    *config.Config
+		storage repository.Storage
+		blob    repository.Blob
+		upload  repository.Upload
+		dbTx    repository.DatabaseTransaction
+		logger  logger.AppLogger
+	}
+
+	FileCollectReport struct {
+		DryRun    bool              `json:"dry_run"`
+		Retention string            `json:"retention"`
+		StartedAt time.Time         `json:"started_at"`
+		Count     int               `json:"count"`
+		Bytes     int64             `json:"bytes"`
+		Items     []FileCollectItem `json:"items"`
+	}
+	FileCollectItem struct {
+		Key          string    `json:"key"`
+		Size         int64     `json:"size"`
+		LastModified time.Time `json:"last_modified"`
+		Reason       string    `json:"reason"`
+	}
+)
+
+// Collect implements FileCollector.
+func (f *fileCollector) Collect(ctx context.Context, dryRun bool) (*FileCollectReport, error) {
+	report := &FileCollectReport{
+		DryRun:    dryRun,
+		Retention: f.conf.FILE_GC.RETENTION.String(),
+		StartedAt: time.Now(),
+		Items:     []FileCollectItem{},
+	}
+	before := report.StartedAt.Add(-f.conf.FILE_GC.RETENTION)
+
+	// files moved away by ImageFileService.DeleteFile
+	deleted, err := f.storage.List(ctx, deletedProductImagePath)
+	if err != nil {
+		f.logger.Error(err)
+		return nil, errs.ErrInternal
+	}
+	for _, obj := range deleted {
+		if obj.LastModified.Before(before) {
+			f.remove(ctx, report, obj, FileCollectReasonDeleted)
+		}
+	}
+
+	// blobs whose last reference was released, see ImageFileService.ReleaseBlob
+	blobs, err := f.blob.FindUnreferenced(ctx, nil)
+	if err != nil {
+		f.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	for _, b := range blobs {
+		if !b.UpdatedAt.Before(before) {
+			continue
+		}
+		obj := repository.StorageObject{Key: b.Key, Size: b.Size, LastModified: b.UpdatedAt}
+		if dryRun {
+			f.record(report, obj, FileCollectReasonReleased)
+			continue
+		}
+		f.removeReleased(ctx, report, obj)
+	}
+
+	// resumable uploads abandoned by the client, not subject to the retention window
+	uploads, err := f.upload.FindExpired(ctx, nil, report.StartedAt)
+	if err != nil {
+		f.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	for _, u := range uploads {
+		objects, err := f.storage.List(ctx, path.Join(uploadPath, u.UUID))
+		if err != nil {
+			f.logger.Error(err)
+			return nil, errs.ErrInternal
+		}
+		if !dryRun {
+			if err := f.upload.Delete(ctx, nil, u.ID); err != nil {
+				f.logger.Error(err)
+				continue
+			}
+		}
+		for _, obj := range objects {
+			f.remove(ctx, report, obj, FileCollectReasonExpired)
+		}
+	}
+
+	// files no record points at, e.g. left behind by a rolled back upload
+	for _, prefix := range collectedPaths {
+		objects, err := f.storage.List(ctx, prefix)
+		if err != nil {
+			f.logger.Error(err)
+			return nil, errs.ErrInternal
+		}
+		for _, obj := range objects {
+			if !obj.LastModified.Before(before) {
+				continue
+			}
+			referenced, err := f.blob.IsKeyReferenced(ctx, nil, obj.Key)
+			if err != nil {
+				f.logger.Error(err)
+				return nil, errs.HandleSqlErr(err)
+			}
+			if !referenced {
+				f.remove(ctx, report, obj, FileCollectReasonUnreferenced)
+			}
+		}
+	}
+
+	f.logger.Info("file garbage collection finished",
+		zap.Bool("dry_run", dryRun),
+		zap.Int("count", report.Count),
+		zap.Int64("bytes", report.Bytes),
+	)
+	return report, nil
+}
+
+// removeReleased deletes the file and row of a blob without references. The
+// row stays locked until both are gone, so an upload of the same content waits
+// and stores the file again instead of reusing the one being deleted.
+func (f *fileCollector) removeReleased(ctx context.Context, report *FileCollectReport, obj repository.StorageObject) {
+	tx := f.dbTx.BeginTx()
+	defer f.dbTx.RollbackTx(tx) //nolint:errcheck
+	m, err := f.blob.FindByKey(ctx, tx, obj.Key)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			f.logger.Error(err)
+		}
+		return
+	}
+	if m.RefCount > 0 {
+		// referenced again in the meantime
+		return
+	}
+	if err := f.storage.Delete(ctx, m.Key); err != nil && !errors.Is(err, repository.ErrStorageObjectNotFound) {
+		f.logger.Error(err, zap.String("key", m.Key))
+		return
+	}
+	if _, err := f.blob.Delete(ctx, tx, m.ID); err != nil {
+		f.logger.Error(err)
+		return
+	}
+	if err := f.dbTx.CommitTx(tx); err != nil {
+		// the row is kept, a later run finds the file gone
+		f.logger.Error(err)
+		return
+	}
+	f.record(report, obj, FileCollectReasonReleased)
+}
+
+func (f *fileCollector) remove(ctx context.Context, report *FileCollectReport, obj repository.StorageObject, reason string) {
+	if !report.DryRun {
+		if err := f.storage.Delete(ctx, obj.Key); err != nil && !errors.Is(err, repository.ErrStorageObjectNotFound) {
+			f.logger.Error(err, zap.String("key", obj.Key))
+			return
+		}
+	}
+	f.record(report, obj, reason)
+}
+
+func (f *fileCollector) record(report *FileCollectReport, obj repository.StorageObject, reason string) {
+	report.Count++
+	report.Bytes += obj.Size
+	report.Items = append(report.Items, FileCollectItem{
+		Key:          obj.Key,
+		Size:         obj.Size,
+		LastModified: obj.LastModified,
+		Reason:       reason,
+	})
+}
+
+func NewFileCollector(
+	conf *config.Config,
+	storage repository.Storage,
+	blob repository.Blob,
+	upload repository.Upload,
+	dbTx repository.DatabaseTransaction,
+	logger logger.AppLogger,
+) FileCollector {
+	return &fileCollector{
+		conf:    conf,
+		storage: storage,
+		blob:    blob,
+		upload:  upload,
+		dbTx:    dbTx,
+		logger:  logger,
+	}
+}
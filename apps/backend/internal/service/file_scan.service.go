@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path"
+	"time"
+
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// infected files are kept here for inspection, never served and never collected
+	quarantinePath = "quarantine"
+	// images rescanned per run of RescanPending
+	rescanBatchSize = 100
+)
+
+type (
+	FileScanService interface {
+		// ScanImage scans the file of m and fills its scan fields, m is not saved.
+		// An infected file loses its blob reference and is moved to quarantine,
+		// m.Path follows it, and so do the other images and attachments with the
+		// same content hash. A failing scanner leaves m pending, not an error.
+		ScanImage(ctx context.Context, tx *gorm.DB, m *model.Image) error
+		// ScanAttachment is ScanImage for attachments, m.Key follows the file.
+		ScanAttachment(ctx context.Context, tx *gorm.DB, m *model.Attachment) error
+		// RescanPending retries the images and attachments the scanner failed on.
+		RescanPending(ctx context.Context) (int, error)
+	}
+	fileScanService struct {
+		scanner      repository.Scanner
+		storage      repository.Storage
+		image        repository.Image
+		attachment   repository.Attachment
+		dbTx         repository.DatabaseTransaction
+		imageService ImageFileService
+		logger       logger.AppLogger
+	}
+
+	fileScan struct {
+		status    string
+		signature string
+		key       string
+		scannedAt sql.NullTime
+	}
+)
+
+// ScanImage implements FileScanService.
+func (f *fileScanService) ScanImage(ctx context.Context, tx *gorm.DB, m *model.Image) error {
+	key := m.Path
+	res, err := f.scan(ctx, tx, key)
+	if err != nil {
+		return err
+	}
+	m.Path = res.key
+	m.ScanStatus = res.status
+	m.ScanSignature = res.signature
+	m.ScannedAt = res.scannedAt
+	if m.ScanStatus != model.ScanStatusInfected {
+		return nil
+	}
+	images, err := f.image.QuarantineByHash(ctx, tx, key, m)
+	if err != nil {
+		f.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	attachments, err := f.attachment.QuarantineByHash(ctx, tx, key, &model.Attachment{
+		Key: m.Path, Hash: m.Hash, ScanStatus: m.ScanStatus, ScanSignature: m.ScanSignature, ScannedAt: m.ScannedAt,
+	})
+	if err != nil {
+		f.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return f.releaseCopies(ctx, tx, key, images+attachments)
+}
+
+// ScanAttachment implements FileScanService.
+func (f *fileScanService) ScanAttachment(ctx context.Context, tx *gorm.DB, m *model.Attachment) error {
+	key := m.Key
+	res, err := f.scan(ctx, tx, key)
+	if err != nil {
+		return err
+	}
+	m.Key = res.key
+	m.ScanStatus = res.status
+	m.ScanSignature = res.signature
+	m.ScannedAt = res.scannedAt
+	if m.ScanStatus != model.ScanStatusInfected {
+		return nil
+	}
+	attachments, err := f.attachment.QuarantineByHash(ctx, tx, key, m)
+	if err != nil {
+		f.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	images, err := f.image.QuarantineByHash(ctx, tx, key, &model.Image{
+		Path: m.Key, Hash: m.Hash, ScanStatus: m.ScanStatus, ScanSignature: m.ScanSignature, ScannedAt: m.ScannedAt,
+	})
+	if err != nil {
+		f.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return f.releaseCopies(ctx, tx, key, images+attachments)
+}
+
+// releaseCopies gives back the blob references of n records found infected
+// along with another one of the same content, quarantined files hold none.
+func (f *fileScanService) releaseCopies(ctx context.Context, tx *gorm.DB, key string, n int64) error {
+	for range n {
+		if _, err := f.imageService.ReleaseBlob(ctx, tx, key); err != nil {
+			f.logger.Error(err)
+			return errs.HandleSqlErr(err)
+		}
+	}
+	return nil
+}
+
+// RescanPending implements FileScanService.
+func (f *fileScanService) RescanPending(ctx context.Context) (int, error) {
+	images, err := f.image.FindByScanStatus(ctx, nil, model.ScanStatusPending, rescanBatchSize)
+	if err != nil {
+		f.logger.Error(err)
+		return 0, errs.HandleSqlErr(err)
+	}
+	attachments, err := f.attachment.FindByScanStatus(ctx, nil, model.ScanStatusPending, rescanBatchSize)
+	if err != nil {
+		f.logger.Error(err)
+		return 0, errs.HandleSqlErr(err)
+	}
+	scanned := 0
+	for _, m := range images {
+		status, err := f.rescan(ctx, func(tx *gorm.DB) (string, error) {
+			if err := f.ScanImage(ctx, tx, &m); err != nil || m.ScanStatus == model.ScanStatusPending {
+				return m.ScanStatus, err
+			}
+			return m.ScanStatus, f.image.UpdateScan(ctx, tx, &m)
+		})
+		if err != nil {
+			f.logger.Error(err, zap.Uint("image_id", m.ID))
+			continue
+		}
+		if status != model.ScanStatusPending {
+			scanned++
+		}
+	}
+	for _, m := range attachments {
+		status, err := f.rescan(ctx, func(tx *gorm.DB) (string, error) {
+			if err := f.ScanAttachment(ctx, tx, &m); err != nil || m.ScanStatus == model.ScanStatusPending {
+				return m.ScanStatus, err
+			}
+			return m.ScanStatus, f.attachment.UpdateScan(ctx, tx, &m)
+		})
+		if err != nil {
+			f.logger.Error(err, zap.Uint("attachment_id", m.ID))
+			continue
+		}
+		if status != model.ScanStatusPending {
+			scanned++
+		}
+	}
+	return scanned, nil
+}
+
+// rescan runs scan in its own transaction and returns the new scan status.
+func (f *fileScanService) rescan(ctx context.Context, scan func(tx *gorm.DB) (string, error)) (string, error) {
+	tx := f.dbTx.BeginTx()
+	defer f.dbTx.RollbackTx(tx) //nolint:errcheck
+	status, err := scan(tx)
+	if err != nil {
+		return "", err
+	}
+	return status, f.dbTx.CommitTx(tx)
+}
+
+func (f *fileScanService) scan(ctx context.Context, tx *gorm.DB, key string) (*fileScan, error) {
+	res := &fileScan{status: model.ScanStatusPending, key: key}
+
+	r, _, err := f.storage.Get(ctx, key)
+	if err != nil {
+		f.logger.Error(err, zap.String("key", key))
+		if errors.Is(err, repository.ErrStorageObjectNotFound) {
+			return nil, errs.ErrNotFound
+		}
+		return nil, errs.ErrInternal
+	}
+	result, err := f.scanner.Scan(ctx, r)
+	r.Close() //nolint:errcheck
+	if err != nil {
+		// retried by RescanPending
+		f.logger.Error(err, zap.String("key", key))
+		return res, nil
+	}
+	res.scannedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	if !result.Infected {
+		res.status = model.ScanStatusClean
+		return res, nil
+	}
+
+	f.logger.Error("infected file quarantined", zap.String("key", key), zap.String("signature", result.Signature))
+	res.status = model.ScanStatusInfected
+	res.signature = result.Signature
+	orphaned, err := f.imageService.ReleaseBlob(ctx, tx, key)
+	if err != nil {
+		f.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if res.key, err = f.quarantine(ctx, key, orphaned); err != nil {
+		f.logger.Error(err, zap.String("key", key))
+		return nil, errs.ErrInternal
+	}
+	return res, nil
+}
+
+// quarantine copies key into the quarantine folder, the original is removed
+// unless another record still references it.
+func (f *fileScanService) quarantine(ctx context.Context, key string, remove bool) (string, error) {
+	r, obj, err := f.storage.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close() //nolint:errcheck
+	dst := path.Join(quarantinePath, key)
+	if _, err := f.storage.Put(ctx, dst, r, obj.Size, obj.ContentType); err != nil {
+		return "", err
+	}
+	if remove {
+		if err := f.storage.Delete(ctx, key); err != nil && !errors.Is(err, repository.ErrStorageObjectNotFound) {
+			return "", err
+		}
+	}
+	return dst, nil
+}
+
+func NewFileScanService(
+	scanner repository.Scanner,
+	storage repository.Storage,
+	image repository.Image,
+	attachment repository.Attachment,
+	dbTx repository.DatabaseTransaction,
+	imageService ImageFileService,
+	logger logger.AppLogger,
+) FileScanService {
+	return &fileScanService{
+		scanner:      scanner,
+		storage:      storage,
+		image:        image,
+		attachment:   attachment,
+		dbTx:         dbTx,
+		imageService: imageService,
+		logger:       logger,
+	}
+}
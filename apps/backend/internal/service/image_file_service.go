@@ -1,6 +1,10 @@
 package service
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"image"
 	_ "image/gif"
 	_ "image/jpeg"
@@ -8,11 +12,14 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
-	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/google/uuid"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"gorm.io/gorm"
 )
 
 type (
@@ -21,11 +28,28 @@ type (
 		GetUuidFileNameFromFileHeader(fh *multipart.FileHeader) string
 		GetImageStatsFromFileHeader(fh *multipart.FileHeader) (*imageStats, error)
 		GetMimeTypeFromFileHeader(fh *multipart.FileHeader) (string, error)
-		SaveFileFromFileHeader(fh *multipart.FileHeader, filePath string) error
-		DeleteFile(path string) error
+		GetHashFromFileHeader(fh *multipart.FileHeader) (string, error)
+		SaveFileFromFileHeader(ctx context.Context, fh *multipart.FileHeader, key string) error
+		// SaveBlobFromFileHeader stores fh under prefix named by its SHA-256, content that
+		// is already stored is reused. The returned blob holds one more reference,
+		// give it back with ReleaseBlob when the record using it is deleted.
+		SaveBlobFromFileHeader(ctx context.Context, tx *gorm.DB, fh *multipart.FileHeader, prefix string) (*model.Blob, error)
+		// SaveBlobFromStorage is SaveBlobFromFileHeader for a file already in storage,
+		// e.g. a finished resumable upload. src itself is left untouched.
+		SaveBlobFromStorage(ctx context.Context, tx *gorm.DB, src *UploadedFile, prefix string) (*model.Blob, error)
+		GetImageStatsFromStorage(ctx context.Context, key string) (*imageStats, error)
+		// ReleaseBlob drops one reference of the blob stored at key. A blob without
+		// references keeps its row and file for FileCollector to remove under the
+		// row lock, orphaned only reports files stored before content hashing,
+		// which the caller deletes after commit.
+		ReleaseBlob(ctx context.Context, tx *gorm.DB, key string) (orphaned bool, err error)
+		DeleteFile(ctx context.Context, key string) error
 	}
-	imageFileService struct{}
-	imageStats       struct {
+	imageFileService struct {
+		storage repository.Storage
+		blob    repository.Blob
+	}
+	imageStats struct {
 		width, height int
 		size          float64
 		mimeType      string
@@ -33,13 +57,17 @@ type (
 )
 
 // DeleteImage implements ImageFileService.
-func (s *imageFileService) DeleteFile(path string) error {
-	// ensure dir
-	if err := s.ensureFileDir(filepath.Join(deletedProductImagePath, filepath.Base(path))); err != nil {
+func (s *imageFileService) DeleteFile(ctx context.Context, key string) error {
+	f, obj, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+	// not remove ,but move it to /deleted
+	if _, err := s.storage.Put(ctx, path.Join(deletedProductImagePath, path.Base(key)), f, obj.Size, obj.ContentType); err != nil {
 		return err
 	}
-	// not remove ,but rename it to /deleted
-	return os.Rename(path, filepath.Join(deletedProductImagePath, filepath.Base(path)))
+	return s.storage.Delete(ctx, key)
 }
 
 // IsImageFromFileHeader implements ImageFileService.
@@ -92,6 +120,33 @@ func (s *imageFileService) GetImageStatsFromFileHeader(fh *multipart.FileHeader)
 	}, nil
 }
 
+func (s *imageFileService) GetImageStatsFromStorage(ctx context.Context, key string) (*imageStats, error) {
+	f, obj, err := s.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+	// the header is enough, the file may be large
+	conf, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 512)
+	n, err := f.Read(buf)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return &imageStats{
+		width:    conf.Width,
+		height:   conf.Height,
+		size:     float64(obj.Size),
+		mimeType: http.DetectContentType(buf[:n]),
+	}, nil
+}
+
 func (s *imageFileService) GetMimeTypeFromFileHeader(fh *multipart.FileHeader) (string, error) {
 	f, err := fh.Open()
 	if err != nil {
@@ -106,37 +161,118 @@ func (s *imageFileService) GetMimeTypeFromFileHeader(fh *multipart.FileHeader) (
 	return http.DetectContentType(buf), nil
 }
 
-func (s *imageFileService) ensureFileDir(filePath string) error {
-	dir := filepath.Dir(filePath)
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		if err := os.MkdirAll(dir, 0700); err != nil {
+func (s *imageFileService) GetHashFromFileHeader(fh *multipart.FileHeader) (string, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() //nolint:errcheck
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (s *imageFileService) SaveBlobFromFileHeader(ctx context.Context, tx *gorm.DB, fh *multipart.FileHeader, prefix string) (*model.Blob, error) {
+	hash, err := s.GetHashFromFileHeader(fh)
+	if err != nil {
+		return nil, err
+	}
+	mimeType, err := s.GetMimeTypeFromFileHeader(fh)
+	if err != nil {
+		return nil, err
+	}
+	m := &model.Blob{
+		Hash:        hash,
+		Key:         path.Join(prefix, hash+strings.ToLower(filepath.Ext(fh.Filename))),
+		Size:        fh.Size,
+		ContentType: mimeType,
+	}
+	return m, s.saveBlob(ctx, tx, m, func(key string) error {
+		return s.SaveFileFromFileHeader(ctx, fh, key)
+	})
+}
+
+func (s *imageFileService) SaveBlobFromStorage(ctx context.Context, tx *gorm.DB, src *UploadedFile, prefix string) (*model.Blob, error) {
+	m := &model.Blob{
+		Hash:        src.Hash,
+		Key:         path.Join(prefix, src.Hash+strings.ToLower(filepath.Ext(src.Filename))),
+		Size:        src.Size,
+		ContentType: src.ContentType,
+	}
+	return m, s.saveBlob(ctx, tx, m, func(key string) error {
+		f, obj, err := s.storage.Get(ctx, src.Key)
+		if err != nil {
+			return err
+		}
+		defer f.Close() //nolint:errcheck
+		_, err = s.storage.Put(ctx, key, f, obj.Size, src.ContentType)
+		return err
+	})
+}
+
+// saveBlob acquires a reference on m, write stores the content when no file
+// with the same hash exists yet.
+func (s *imageFileService) saveBlob(ctx context.Context, tx *gorm.DB, m *model.Blob, write func(key string) error) error {
+	existing, err := s.blob.FindByHash(ctx, tx, m.Hash)
+	switch {
+	case err == nil:
+		m.Key = existing.Key
+		_, err := s.storage.Stat(ctx, m.Key)
+		if errors.Is(err, repository.ErrStorageObjectNotFound) {
+			// the row outlived its file, store it again
+			err = write(m.Key)
+		}
+		if err != nil {
+			return err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := write(m.Key); err != nil {
 			return err
 		}
+	default:
+		return err
 	}
-	return nil
+	return s.blob.Acquire(ctx, tx, m)
 }
 
-func (s *imageFileService) SaveFileFromFileHeader(fh *multipart.FileHeader, filePath string) error {
-	f, err := fh.Open()
+func (s *imageFileService) ReleaseBlob(ctx context.Context, tx *gorm.DB, key string) (orphaned bool, err error) {
+	m, err := s.blob.FindByKey(ctx, tx, key)
 	if err != nil {
-		return err
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// stored before content hashing, nothing else can reference it
+			return true, nil
+		}
+		return false, err
 	}
-	defer f.Close() //nolint:errcheck
+	// deleting the file after commit could remove the file of a concurrent
+	// upload of the same content, stored again at the same key
+	if _, err := s.blob.AddRef(ctx, tx, m.ID, -1); err != nil {
+		return false, err
+	}
+	return false, nil
+}
 
-	if err := s.ensureFileDir(filePath); err != nil {
+func (s *imageFileService) SaveFileFromFileHeader(ctx context.Context, fh *multipart.FileHeader, key string) error {
+	mimeType, err := s.GetMimeTypeFromFileHeader(fh)
+	if err != nil {
 		return err
 	}
-
-	out, err := os.Create(filePath)
+	f, err := fh.Open()
 	if err != nil {
 		return err
 	}
-	defer out.Close() //nolint:errcheck
+	defer f.Close() //nolint:errcheck
 
-	_, err = io.Copy(out, f)
+	_, err = s.storage.Put(ctx, key, f, fh.Size, mimeType)
 	return err
 }
 
-func NewImageFileService() ImageFileService {
-	return &imageFileService{}
+func NewImageFileService(storage repository.Storage, blob repository.Blob) ImageFileService {
+	return &imageFileService{
+		storage: storage,
+		blob:    blob,
+	}
 }
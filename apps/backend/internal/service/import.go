@@ -0,0 +1,313 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/suttapak/starter/errs"
+)
+
+const (
+	// importMaxRows limits the data rows of an import file.
+	importMaxRows = 5000
+	// importHeaderGroup holds the translated headers of the import columns in
+	// i18n/active.*.json, e.g. excel_header_import.username.
+	importHeaderGroup = "excel_header_import"
+)
+
+// importValidate checks import rows against the binding tags of the request
+// DTOs, the same rules gin applies to a JSON body. Errors name the json field.
+var importValidate = newImportValidator()
+
+type (
+	// ImportResult is the outcome of an import, every row is committed or none.
+	ImportResult struct {
+		// Rows counts the data rows, blank rows left out.
+		Rows int `json:"rows"`
+		// Imported counts the rows committed, zero on errors or a dry run.
+		Imported  int  `json:"imported"`
+		Committed bool `json:"committed"`
+		// Invited counts the imported users mailed a link to set their password.
+		Invited int              `json:"invited,omitempty"`
+		Errors  []ImportRowError `json:"errors"`
+	}
+	// ImportRowError is a problem of a row, Row 1 is the header.
+	ImportRowError struct {
+		Row int `json:"row"`
+		// Column is the key of the column, empty when it is the whole row.
+		Column  string `json:"column"`
+		Message string `json:"message"`
+	}
+
+	// ImportReport is an Excel file of an import, the annotated upload or an
+	// empty template.
+	ImportReport struct {
+		Filename string
+		Content  *bytes.Buffer
+	}
+
+	// importColumn is a field of the row DTO, named by its json tag.
+	importColumn struct {
+		key      string
+		field    int
+		required bool
+		// aliases are the normalized headers the column is found by.
+		aliases []string
+	}
+	// importSheet is an upload decoded into rows of T.
+	importSheet[T any] struct {
+		// all are the rows of the upload, the header and blank rows included.
+		all    [][]string
+		rows   []importRow[T]
+		errors []ImportRowError
+	}
+	importRow[T any] struct {
+		line  int
+		value T
+		// valid is false when the row has decode or validation errors.
+		valid bool
+	}
+)
+
+func newImportValidator() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return v
+}
+
+// readImportRows reads the first sheet of an XLSX file or the records of a
+// CSV file, told apart by the zip signature of XLSX.
+func readImportRows(r io.Reader, excel Excel) ([][]string, error) {
+	br := bufio.NewReader(r)
+	if sig, _ := br.Peek(4); bytes.Equal(sig, []byte("PK\x03\x04")) {
+		rows, err := excel.Read(br)
+		if err != nil {
+			return nil, errs.ErrImportFileInvalid
+		}
+		return rows, nil
+	}
+	// spreadsheet programs start UTF-8 CSV files with a BOM
+	if bom, _ := br.Peek(3); bytes.Equal(bom, []byte("\xef\xbb\xbf")) {
+		br.Discard(3) //nolint:errcheck
+	}
+	cr := csv.NewReader(br)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, errs.ErrImportFileInvalid
+	}
+	return rows, nil
+}
+
+// importColumns lists the fields of T that a cell can fill, found by their
+// json name or a translation of excel_header_import.<name>.
+func importColumns[T any](translations func(id string) []string) []importColumn {
+	t := reflect.TypeFor[T]()
+	var cols []importColumn
+	for i := range t.NumField() {
+		f := t.Field(i)
+		key, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || key == "" || key == "-" || !importKindSupported(f.Type) {
+			continue
+		}
+		col := importColumn{
+			key:      key,
+			field:    i,
+			required: slices.Contains(strings.Split(f.Tag.Get("binding"), ","), "required"),
+			aliases:  []string{importNormalize(key)},
+		}
+		for _, alias := range translations(importHeaderGroup + "." + key) {
+			if alias := importNormalize(alias); !slices.Contains(col.aliases, alias) {
+				col.aliases = append(col.aliases, alias)
+			}
+		}
+		cols = append(cols, col)
+	}
+	return cols
+}
+
+// decodeImport maps the header of rows to cols and decodes every other
+// non-blank row into a T, checked with importValidate. A row gets the errors of
+// both, less the validation errors of the cells it could not read. A missing
+// required column fails the header and no row is decoded.
+func decodeImport[T any](rows [][]string, cols []importColumn) (*importSheet[T], error) {
+	if len(rows) == 0 {
+		return nil, errs.ErrImportFileInvalid
+	}
+	sheet := &importSheet[T]{all: rows}
+	index := make([]int, len(cols))
+	for i, col := range cols {
+		index[i] = slices.IndexFunc(rows[0], func(h string) bool {
+			return slices.Contains(col.aliases, importNormalize(h))
+		})
+		if index[i] < 0 && col.required {
+			sheet.errors = append(sheet.errors, ImportRowError{Row: 1, Column: col.key, Message: "ไม่พบคอลัมน์นี้ในแถวหัวตาราง"})
+		}
+	}
+	if len(sheet.errors) > 0 {
+		return sheet, nil
+	}
+	for i, cells := range rows[1:] {
+		if !slices.ContainsFunc(cells, func(v string) bool { return strings.TrimSpace(v) != "" }) {
+			continue
+		}
+		if len(sheet.rows) == importMaxRows {
+			return nil, errs.ErrImportTooManyRows
+		}
+		row := importRow[T]{line: i + 2, valid: true}
+		v := reflect.ValueOf(&row.value).Elem()
+		var unread []string
+		for c, col := range cols {
+			if index[c] < 0 || index[c] >= len(cells) {
+				continue
+			}
+			if err := importSet(v.Field(col.field), strings.TrimSpace(cells[index[c]])); err != nil {
+				row.valid = false
+				unread = append(unread, col.key)
+				sheet.errors = append(sheet.errors, ImportRowError{Row: row.line, Column: col.key, Message: err.Error()})
+			}
+		}
+		if err := importValidate.Struct(&row.value); err != nil {
+			row.valid = false
+			for _, e := range importValidationErrors(row.line, err) {
+				// the field of an unread cell is left zero, its error is told already
+				if !slices.Contains(unread, e.Column) {
+					sheet.errors = append(sheet.errors, e)
+				}
+			}
+		}
+		sheet.rows = append(sheet.rows, row)
+	}
+	return sheet, nil
+}
+
+// importSet parses s into f, a blank cell leaves the zero value. Lists are
+// comma separated.
+func importSet(f reflect.Value, s string) error {
+	if s == "" {
+		return nil
+	}
+	if f.Kind() == reflect.Pointer {
+		p := reflect.New(f.Type().Elem())
+		if err := importSet(p.Elem(), s); err != nil {
+			return err
+		}
+		f.Set(p)
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.ToLower(s))
+		if err != nil {
+			return errors.New("ต้องเป็น true หรือ false")
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, f.Type().Bits())
+		if err != nil {
+			return errors.New("ต้องเป็นจำนวนเต็ม")
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, f.Type().Bits())
+		if err != nil {
+			return errors.New("ต้องเป็นจำนวนเต็มที่ไม่ติดลบ")
+		}
+		f.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), f.Type().Bits())
+		if err != nil {
+			return errors.New("ต้องเป็นตัวเลข")
+		}
+		f.SetFloat(n)
+	case reflect.Slice:
+		parts := strings.Split(s, ",")
+		list := reflect.MakeSlice(f.Type(), 0, len(parts))
+		for _, part := range parts {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			item := reflect.New(f.Type().Elem()).Elem()
+			if err := importSet(item, part); err != nil {
+				return err
+			}
+			list = reflect.Append(list, item)
+		}
+		f.Set(list)
+	}
+	return nil
+}
+
+// importKindSupported tells whether a cell can fill a field of type t.
+func importKindSupported(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func importValidationErrors(line int, err error) []ImportRowError {
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return []ImportRowError{{Row: line, Message: err.Error()}}
+	}
+	res := make([]ImportRowError, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		res = append(res, ImportRowError{Row: line, Column: fe.Field(), Message: importFieldMessage(fe)})
+	}
+	return res
+}
+
+func importFieldMessage(fe validator.FieldError) string {
+	unit := ""
+	if fe.Kind() == reflect.String {
+		unit = " ตัวอักษร"
+	}
+	switch fe.Tag() {
+	case "required", "required_without", "required_with", "required_if":
+		return "จำเป็นต้องระบุ"
+	case "min", "gte":
+		return fmt.Sprintf("ต้องไม่น้อยกว่า %s%s", fe.Param(), unit)
+	case "max", "lte":
+		return fmt.Sprintf("ต้องไม่เกิน %s%s", fe.Param(), unit)
+	case "email":
+		return "รูปแบบอีเมลไม่ถูกต้อง"
+	case "oneof":
+		return "ต้องเป็นค่าใดค่าหนึ่งของ " + strings.ReplaceAll(fe.Param(), " ", ", ")
+	}
+	return "ไม่ถูกต้อง (" + fe.Tag() + ")"
+}
+
+// importNormalize makes "Full Name", "full_name" and "FULL-NAME " the same header.
+func importNormalize(header string) string {
+	header = strings.ToLower(strings.TrimSpace(header))
+	return strings.Join(strings.FieldsFunc(header, func(r rune) bool {
+		return r == ' ' || r == '_' || r == '-'
+	}), "_")
+}
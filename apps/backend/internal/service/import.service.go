@@ -0,0 +1,387 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"slices"
+
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/i18n"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+// ImportTarget is what the rows of an import become.
+type ImportTarget string
+
+const (
+	ImportTargetUser    ImportTarget = "users"
+	ImportTargetProduct ImportTarget = "products"
+)
+
+// importSavepoint lets a failed row roll back alone, so the rows after it are
+// still checked before the whole import is rolled back.
+const importSavepoint = "import_row"
+
+// importSecretKeys are the columns a report never echoes, such as the password
+// column of a user template from before imported users set their own.
+var importSecretKeys = []string{"password"}
+
+type (
+	// ImportService creates records from the rows of an uploaded XLSX or CSV
+	// file. Columns are matched by header, the json name of a field of the
+	// request DTO or a translation of it in excel_header_import, and every row
+	// is validated by the binding tags of the DTO. Every row is saved in one
+	// transaction, committed only when no row fails.
+	ImportService interface {
+		// Users registers a user for every row as ImportUserRequest with a random
+		// password, and once committed mails each a link to set their own with
+		// Auth.SendPasswordEmail. dryRun checks the rows, saving included, and
+		// rolls back.
+		Users(ctx context.Context, r io.Reader, dryRun bool) (*ImportResult, error)
+		// Products creates a product of the team for every row as CreateProductRequest.
+		// The codes of the rows are reserved before the rows are saved, an
+		// import that is not committed leaves them unused.
+		Products(ctx context.Context, teamId uint, r io.Reader, dryRun bool) (*ImportResult, error)
+		// UserReport and ProductReport dry run the upload and return it with
+		// the errors of every row next to it, the rows with errors in red.
+		UserReport(ctx context.Context, r io.Reader) (*ImportReport, error)
+		ProductReport(ctx context.Context, teamId uint, r io.Reader) (*ImportReport, error)
+		// Template is an empty sheet with the headers of target in the language
+		// of ctx.
+		Template(ctx context.Context, target ImportTarget) (*ImportReport, error)
+	}
+	importService struct {
+		user    UserService
+		auth    Auth
+		product ProductService
+		code    CodeService
+		dbTx    repository.DatabaseTransaction
+		excel   Excel
+		i18n    i18n.I18N
+		logger  logger.AppLogger
+	}
+
+	// ImportUserRequest is a row of a user import. Imported users get a random
+	// password and set their own from a mail.
+	ImportUserRequest struct {
+		Username string `json:"username" binding:"required"`
+		Email    string `json:"email" binding:"required"`
+		FullName string `json:"full_name" binding:"required"`
+	}
+)
+
+// Users implements ImportService.
+func (s *importService) Users(ctx context.Context, r io.Reader, dryRun bool) (*ImportResult, error) {
+	var created []uint
+	res, _, err := runImport(ctx, s, r, dryRun, nil, s.saveUser(&created))
+	if err != nil || !res.Committed {
+		return res, err
+	}
+	// a failed mail leaves the user to ask for another with ForgotPassword
+	for _, userId := range created {
+		if err := s.auth.SendPasswordEmail(ctx, userId); err != nil {
+			s.logger.Error(err)
+			continue
+		}
+		res.Invited++
+	}
+	return res, nil
+}
+
+// Products implements ImportService.
+func (s *importService) Products(ctx context.Context, teamId uint, r io.Reader, dryRun bool) (*ImportResult, error) {
+	reserve, save := s.saveProducts(teamId, dryRun)
+	res, _, err := runImport(ctx, s, r, dryRun, reserve, save)
+	return res, err
+}
+
+// UserReport implements ImportService.
+func (s *importService) UserReport(ctx context.Context, r io.Reader) (*ImportReport, error) {
+	res, sheet, err := runImport(ctx, s, r, true, nil, s.saveUser(nil))
+	if err != nil {
+		return nil, err
+	}
+	return s.report(ctx, ImportTargetUser, sheet.all, res)
+}
+
+// ProductReport implements ImportService.
+func (s *importService) ProductReport(ctx context.Context, teamId uint, r io.Reader) (*ImportReport, error) {
+	reserve, save := s.saveProducts(teamId, true)
+	res, sheet, err := runImport(ctx, s, r, true, reserve, save)
+	if err != nil {
+		return nil, err
+	}
+	return s.report(ctx, ImportTargetProduct, sheet.all, res)
+}
+
+// Template implements ImportService.
+func (s *importService) Template(ctx context.Context, target ImportTarget) (*ImportReport, error) {
+	var cols []importColumn
+	switch target {
+	case ImportTargetUser:
+		cols = importColumns[ImportUserRequest](s.i18n.Translations)
+	case ImportTargetProduct:
+		cols = importColumns[CreateProductRequest](s.i18n.Translations)
+	default:
+		return nil, errs.ErrBadRequest
+	}
+	local, _ := i18n.GetLocal(ctx)
+	sheet := ExcelSheet{Name: string(target), FreezeHeader: true}
+	for _, col := range cols {
+		header := col.key
+		if translations := s.i18n.Translations(importHeaderGroup + "." + col.key); len(translations) > 0 {
+			header = s.i18n.GetMessage(local, importHeaderGroup+"."+col.key)
+		}
+		sheet.Columns = append(sheet.Columns, ExcelColumn{Header: header, Type: ExcelText})
+	}
+	content, err := s.excel.Report(&ExcelReport{Sheets: []ExcelSheet{sheet}})
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.ErrInternal
+	}
+	return &ImportReport{Filename: string(target) + "-template.xlsx", Content: content}, nil
+}
+
+// saveUser registers the user of a row with a random password, adding the id
+// to created when set.
+func (s *importService) saveUser(created *[]uint) func(ctx context.Context, tx *gorm.DB, body *ImportUserRequest) error {
+	return func(ctx context.Context, tx *gorm.DB, body *ImportUserRequest) error {
+		password, err := importPassword()
+		if err != nil {
+			s.logger.Error(err)
+			return errs.ErrInternal
+		}
+		userId, err := s.user.CreateInTx(ctx, tx, UserRegisterDto{
+			Username: body.Username,
+			Email:    body.Email,
+			Password: password,
+			FullName: body.FullName,
+		})
+		if err != nil {
+			return err
+		}
+		if created != nil {
+			*created = append(*created, userId)
+		}
+		return nil
+	}
+}
+
+// importPassword is the password of an imported user, random and never told.
+func importPassword() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// saveProducts reserves the codes of the products with one call to the
+// sequence, outside the transaction of the import, and saves every row with
+// the next of them.
+func (s *importService) saveProducts(teamId uint, dryRun bool) (reserve func(ctx context.Context, count int) error, save func(ctx context.Context, tx *gorm.DB, body *CreateProductRequest) error) {
+	var codes []string
+	reserve = func(ctx context.Context, count int) error {
+		var err error
+		if codes, err = s.code.ReserveProductCodes(ctx, teamId, uint(count), dryRun); err != nil {
+			s.logger.Error(err)
+			return errs.HandleSqlErr(err)
+		}
+		return nil
+	}
+	save = func(ctx context.Context, tx *gorm.DB, body *CreateProductRequest) error {
+		code := codes[0]
+		codes = codes[1:]
+		_, err := s.product.CreateInTx(ctx, tx, teamId, code, *body)
+		return err
+	}
+	return reserve, save
+}
+
+// runImport decodes the upload of r and saves its valid rows with save, each
+// under a savepoint of one transaction. The transaction is committed when no
+// row has an error and dryRun is false, and rolled back otherwise. reserve,
+// when set, is called with the number of valid rows before the transaction.
+func runImport[T any](ctx context.Context, s *importService, r io.Reader, dryRun bool, reserve func(ctx context.Context, count int) error, save func(ctx context.Context, tx *gorm.DB, row *T) error) (*ImportResult, *importSheet[T], error) {
+	rows, err := readImportRows(r, s.excel)
+	if err != nil {
+		return nil, nil, err
+	}
+	sheet, err := decodeImport[T](rows, importColumns[T](s.i18n.Translations))
+	if err != nil {
+		return nil, nil, err
+	}
+	res := &ImportResult{Rows: len(sheet.rows), Errors: sheet.errors}
+	if len(sheet.rows) == 0 && len(res.Errors) == 0 {
+		return nil, nil, errs.ErrImportFileInvalid
+	}
+	if reserve != nil {
+		valid := 0
+		for _, row := range sheet.rows {
+			if row.valid {
+				valid++
+			}
+		}
+		if err := reserve(ctx, valid); err != nil {
+			return nil, nil, err
+		}
+	}
+	tx := s.dbTx.BeginTx()
+	defer s.dbTx.RollbackTx(tx) //nolint:errcheck
+	for i := range sheet.rows {
+		row := &sheet.rows[i]
+		if !row.valid {
+			continue
+		}
+		if err := tx.SavePoint(importSavepoint).Error; err != nil {
+			s.logger.Error(err)
+			return nil, nil, errs.HandleSqlErr(err)
+		}
+		if err := save(ctx, tx, &row.value); err != nil {
+			if err := tx.RollbackTo(importSavepoint).Error; err != nil {
+				s.logger.Error(err)
+				return nil, nil, errs.HandleSqlErr(err)
+			}
+			res.Errors = append(res.Errors, ImportRowError{Row: row.line, Message: importErrorMessage(err)})
+		}
+	}
+	// in the order of the sheet, the header first
+	slices.SortStableFunc(res.Errors, func(a, b ImportRowError) int { return cmp.Compare(a.Row, b.Row) })
+	if len(res.Errors) > 0 || dryRun {
+		return res, sheet, nil
+	}
+	if err := s.dbTx.CommitTx(tx); err != nil {
+		s.logger.Error(err)
+		return nil, nil, errs.HandleSqlErr(err)
+	}
+	res.Imported, res.Committed = len(sheet.rows), true
+	return res, sheet, nil
+}
+
+// report is the uploaded sheet, rows, with an errors column and the list of
+// errors on a second sheet.
+func (s *importService) report(ctx context.Context, target ImportTarget, rows [][]string, res *ImportResult) (*ImportReport, error) {
+	local, _ := i18n.GetLocal(ctx)
+	message := func(key string) string {
+		return s.i18n.GetMessage(local, importHeaderGroup+"."+key)
+	}
+	messages := make(map[int]string)
+	for _, e := range res.Errors {
+		text := e.Message
+		if e.Column != "" {
+			text = e.Column + ": " + text
+		}
+		if messages[e.Row] != "" {
+			text = messages[e.Row] + "\n" + text
+		}
+		messages[e.Row] = text
+	}
+
+	data := ExcelSheet{Name: string(target), FreezeHeader: true, AutoFilter: true}
+	width := 0
+	for _, cells := range rows {
+		width = max(width, len(cells))
+	}
+	// the columns of the upload that are echoed, secrets left out
+	var echoed []int
+	for i := range width {
+		name := ""
+		if i < len(rows[0]) {
+			name = rows[0][i]
+		}
+		if s.secretColumn(name) {
+			continue
+		}
+		echoed = append(echoed, i)
+		data.Columns = append(data.Columns, ExcelColumn{Header: name, Type: ExcelText})
+	}
+	data.Columns = append(data.Columns, ExcelColumn{Header: message("errors"), Type: ExcelText, Width: excelMaxColWidth})
+	// every line of the upload is kept, blank ones too, so row numbers match
+	for i, cells := range rows[1:] {
+		values := make([]any, len(echoed)+1)
+		for c, col := range echoed {
+			if col < len(cells) {
+				values[c] = cells[col]
+			}
+		}
+		if text, ok := messages[i+2]; ok {
+			values[len(echoed)] = text
+			data.Highlight = append(data.Highlight, i)
+		}
+		data.Rows = append(data.Rows, values)
+	}
+
+	list := ExcelSheet{
+		Name: "errors",
+		Columns: []ExcelColumn{
+			{Header: message("row"), Type: ExcelNumber, Format: "0"},
+			{Header: message("column"), Type: ExcelText},
+			{Header: message("message"), Type: ExcelText},
+		},
+		FreezeHeader: true,
+	}
+	for _, e := range res.Errors {
+		list.Rows = append(list.Rows, []any{e.Row, e.Column, e.Message})
+	}
+	content, err := s.excel.Report(&ExcelReport{Sheets: []ExcelSheet{data, list}})
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.ErrInternal
+	}
+	return &ImportReport{Filename: string(target) + "-import-report.xlsx", Content: content}, nil
+}
+
+// secretColumn reports whether header names one of importSecretKeys, by its
+// key or a translation like the import columns.
+func (s *importService) secretColumn(header string) bool {
+	header = importNormalize(header)
+	for _, key := range importSecretKeys {
+		if header == importNormalize(key) {
+			return true
+		}
+		for _, alias := range s.i18n.Translations(importHeaderGroup + "." + key) {
+			if header == importNormalize(alias) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// importErrorMessage is the message of the AppError of a service, that of
+// ErrInternal for any other error.
+func importErrorMessage(err error) string {
+	var appErr errs.AppError
+	if !errors.As(err, &appErr) {
+		errors.As(errs.ErrInternal, &appErr)
+	}
+	return appErr.Message
+}
+
+func NewImportService(
+	user UserService,
+	auth Auth,
+	product ProductService,
+	code CodeService,
+	dbTx repository.DatabaseTransaction,
+	excel Excel,
+	i18n i18n.I18N,
+	logger logger.AppLogger,
+) ImportService {
+	return &importService{
+		user:    user,
+		auth:    auth,
+		product: product,
+		code:    code,
+		dbTx:    dbTx,
+		excel:   excel,
+		i18n:    i18n,
+		logger:  logger,
+	}
+}
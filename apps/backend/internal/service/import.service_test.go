@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/i18n"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+// importDbFake hands out a dry run session, savepoints are built but never
+// sent to a database.
+type importDbFake struct {
+	db *gorm.DB
+}
+
+func (d importDbFake) BeginTx() *gorm.DB            { return d.db.Session(&gorm.Session{}) }
+func (d importDbFake) CommitTx(tx *gorm.DB) error   { return nil }
+func (d importDbFake) RollbackTx(tx *gorm.DB) error { return nil }
+
+type importI18nFake struct{}
+
+func (importI18nFake) GetMessage(local i18n.Local, id string) string { return id }
+func (importI18nFake) Translations(id string) []string               { return importHeaders(id) }
+
+// importSequenceFake is the product sequence of one team, counting the calls
+// that take numbers.
+type importSequenceFake struct {
+	repository.AutoIncrementSequence
+	sequence model.AutoIncrementSequence
+	reserved []uint
+}
+
+func (f *importSequenceFake) FindSequence(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId uint, entityId uint) (*model.AutoIncrementSequence, error) {
+	if f.sequence.Sequence == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	m := f.sequence
+	return &m, nil
+}
+
+func (f *importSequenceFake) ReserveSequence(ctx context.Context, tx *gorm.DB, entityType model.EntityType, teamId uint, entityId uint, count uint, period string) (uint, error) {
+	f.reserved = append(f.reserved, count)
+	f.sequence.Sequence += count
+	f.sequence.Period = period
+	return f.sequence.Sequence, nil
+}
+
+type importSchemeFake struct {
+	repository.NumberingScheme
+}
+
+func (importSchemeFake) FindByType(ctx context.Context, tx *gorm.DB, teamId uint, entityType model.EntityType) (*model.NumberingScheme, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+// importUserFake records the users registered.
+type importUserFake struct {
+	UserService
+	users []UserRegisterDto
+}
+
+func (f *importUserFake) CreateInTx(ctx context.Context, tx *gorm.DB, body UserRegisterDto) (uint, error) {
+	if body.Username == "taken" {
+		return 0, errs.ErrDuplicateUsername
+	}
+	f.users = append(f.users, body)
+	return uint(len(f.users)), nil
+}
+
+// importAuthFake records the users mailed a link to set their password.
+type importAuthFake struct {
+	Auth
+	mailed []uint
+}
+
+func (f *importAuthFake) SendPasswordEmail(ctx context.Context, userId uint) error {
+	f.mailed = append(f.mailed, userId)
+	return nil
+}
+
+// importProductFake records the code of every product created.
+type importProductFake struct {
+	ProductService
+	codes []string
+}
+
+func (f *importProductFake) CreateInTx(ctx context.Context, tx *gorm.DB, teamId uint, code string, body CreateProductRequest) (uint, error) {
+	f.codes = append(f.codes, code)
+	return uint(len(f.codes)), nil
+}
+
+type importTest struct {
+	*importService
+	sequence *importSequenceFake
+	user     *importUserFake
+	auth     *importAuthFake
+	product  *importProductFake
+}
+
+func newImportTest(t *testing.T, sequence uint) importTest {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               gormLogger.Discard,
+	})
+	require.NoError(t, err)
+	log := logger.NewLoggerMock()
+	it := importTest{
+		sequence: &importSequenceFake{sequence: model.AutoIncrementSequence{Sequence: sequence}},
+		user:     &importUserFake{},
+		auth:     &importAuthFake{},
+		product:  &importProductFake{},
+	}
+	code := NewCodeService(log, it.sequence, importSchemeFake{}, &config.Config{SERVER: config.SERVER{LOCATION: time.UTC}})
+	s := NewImportService(it.user, it.auth, it.product, code, importDbFake{db: db}, NewExcelService(), importI18nFake{}, log)
+	it.importService = s.(*importService)
+	return it
+}
+
+func TestImportProductsReservesCodes(t *testing.T) {
+	csv := "name,price,uom\n" +
+		"Pen,10,ด้าม\n" +
+		",5,ด้าม\n" + // invalid, takes no code
+		"Pencil,5,ด้าม\n" +
+		"Eraser,3,ก้อน\n"
+
+	t.Run("import", func(t *testing.T) {
+		it := newImportTest(t, 7)
+
+		res, err := it.Products(context.Background(), 3, strings.NewReader(csv), false)
+		require.NoError(t, err)
+		assert.Len(t, res.Errors, 1)
+		assert.Equal(t, []uint{3}, it.sequence.reserved, "the codes are reserved with one call")
+		assert.Equal(t, []string{"PN-030008", "PN-030009", "PN-030010"}, it.product.codes)
+		assert.Equal(t, uint(10), it.sequence.sequence.Sequence)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		it := newImportTest(t, 7)
+
+		_, err := it.Products(context.Background(), 3, strings.NewReader(csv), true)
+		require.NoError(t, err)
+		assert.Empty(t, it.sequence.reserved, "a dry run takes no codes")
+		assert.Equal(t, []string{"PN-030008", "PN-030009", "PN-030010"}, it.product.codes)
+		assert.Equal(t, uint(7), it.sequence.sequence.Sequence)
+	})
+
+	t.Run("first import", func(t *testing.T) {
+		it := newImportTest(t, 0)
+
+		_, err := it.Products(context.Background(), 3, strings.NewReader(csv), false)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{3}, it.sequence.reserved)
+		assert.Equal(t, []string{"PN-030001", "PN-030002", "PN-030003"}, it.product.codes)
+	})
+}
+
+func TestImportUsersRandomPassword(t *testing.T) {
+	csv := "username,email,full name\n" +
+		"jane,jane@example.com,Jane Doe\n" +
+		"john,john@example.com,John Doe\n"
+
+	t.Run("import", func(t *testing.T) {
+		it := newImportTest(t, 0)
+
+		res, err := it.Users(context.Background(), strings.NewReader(csv), false)
+		require.NoError(t, err)
+		assert.True(t, res.Committed)
+		require.Len(t, it.user.users, 2)
+		assert.GreaterOrEqual(t, len(it.user.users[0].Password), 32)
+		assert.NotEqual(t, it.user.users[0].Password, it.user.users[1].Password)
+		assert.Equal(t, []uint{1, 2}, it.auth.mailed, "every user is mailed a link")
+		assert.Equal(t, 2, res.Invited)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		it := newImportTest(t, 0)
+
+		res, err := it.Users(context.Background(), strings.NewReader(csv), true)
+		require.NoError(t, err)
+		assert.False(t, res.Committed)
+		assert.Empty(t, it.auth.mailed)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		it := newImportTest(t, 0)
+
+		res, err := it.Users(context.Background(), strings.NewReader(csv+"taken,taken@example.com,Taken\n"), false)
+		require.NoError(t, err)
+		assert.False(t, res.Committed)
+		assert.Empty(t, it.auth.mailed, "nothing is mailed when the import is rolled back")
+	})
+}
+
+func TestImportUserReportHidesPasswords(t *testing.T) {
+	// a template from before imported users set their own password
+	csv := "username,email,รหัสผ่าน,full name,Password\n" +
+		"jane,jane@example.com,s3cret-one,Jane Doe,s3cret-two\n" +
+		"taken,taken@example.com,s3cret-three,,s3cret-four\n"
+	it := newImportTest(t, 0)
+
+	report, err := it.UserReport(context.Background(), strings.NewReader(csv))
+	require.NoError(t, err)
+	rows, err := NewExcelService().Read(report.Content)
+	require.NoError(t, err)
+
+	require.Len(t, rows, 3)
+	assert.Equal(t, []string{"username", "email", "full name", importHeaderGroup + ".errors"}, rows[0])
+	assert.Equal(t, []string{"jane", "jane@example.com", "Jane Doe"}, rows[1])
+	assert.Equal(t, []string{"taken", "taken@example.com", "", "full_name: จำเป็นต้องระบุ"}, rows[2])
+	for _, row := range rows {
+		for _, cell := range row {
+			assert.NotContains(t, cell, "s3cret")
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// importHeaders stands in for the translations of i18n/active.*.json.
+func importHeaders(id string) []string {
+	return map[string][]string{
+		"excel_header_import.name":          {"ชื่อสินค้า", "Name"},
+		"excel_header_import.price":         {"ราคา", "Price"},
+		"excel_header_import.category_ids":  {"รหัสหมวดหมู่", "Category IDs"},
+		"excel_header_import.reorder_point": {"จุดสั่งซื้อ", "Reorder Point"},
+		"excel_header_import.password":      {"รหัสผ่าน", "Password"},
+	}[id]
+}
+
+func TestDecodeImportProducts(t *testing.T) {
+	csv := "\xef\xbb\xbfชื่อสินค้า,Price,uom,category ids,Ignored\n" +
+		"Pen,\"1,250.50\",ด้าม,\"1, 2\",x\n" +
+		",,,,\n" +
+		",abc,ด้าม,,\n" +
+		"Pencil,-1,,,\n"
+	rows, err := readImportRows(strings.NewReader(csv), NewExcelService())
+	require.NoError(t, err)
+
+	sheet, err := decodeImport[CreateProductRequest](rows, importColumns[CreateProductRequest](importHeaders))
+	require.NoError(t, err)
+	require.Len(t, sheet.rows, 3, "the blank row is left out")
+
+	pen := sheet.rows[0]
+	assert.True(t, pen.valid)
+	assert.Equal(t, 2, pen.line)
+	assert.Equal(t, CreateProductRequest{Name: "Pen", Price: 1250.5, UOM: "ด้าม", CategoryIds: []uint{1, 2}}, pen.value)
+
+	assert.False(t, sheet.rows[1].valid)
+	assert.False(t, sheet.rows[2].valid)
+	assert.Equal(t, []ImportRowError{
+		{Row: 4, Column: "price", Message: "ต้องเป็นตัวเลข"},
+		{Row: 4, Column: "name", Message: "จำเป็นต้องระบุ"},
+		{Row: 5, Column: "price", Message: "ต้องไม่น้อยกว่า 0"},
+		{Row: 5, Column: "uom", Message: "จำเป็นต้องระบุ"},
+	}, sheet.errors)
+}
+
+func TestDecodeImportMissingColumn(t *testing.T) {
+	rows := [][]string{{"username", "full name"}, {"jane", "Jane Doe"}}
+
+	sheet, err := decodeImport[ImportUserRequest](rows, importColumns[ImportUserRequest](importHeaders))
+	require.NoError(t, err)
+	assert.Empty(t, sheet.rows)
+	assert.Equal(t, []ImportRowError{{Row: 1, Column: "email", Message: "ไม่พบคอลัมน์นี้ในแถวหัวตาราง"}}, sheet.errors)
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"strconv"
 	"time"
@@ -18,6 +20,7 @@ const (
 	ExpireTime             = time.Hour * 24
 	RefreshTokenExpireTime = time.Hour * 24 * 7
 	EmailExpireTime        = time.Hour * 24 * 30
+	PasswordExpireTime     = time.Hour * 24 * 3
 )
 
 type (
@@ -28,6 +31,12 @@ type (
 		GetUserIdFormToken(ctx context.Context, token string) (uId uint, err error)
 		GetUserIdFormRefreshToken(ctx context.Context, token string) (uId uint, err error)
 		GetUserIdFromExternalToken(ctx context.Context, token string) (uId uint, err error)
+		// GeneratePasswordToken signs a link to set the password of the user,
+		// bound to passwordHash so it is good once, until the password changes.
+		GeneratePasswordToken(ctx context.Context, userId uint, passwordHash string) (token string, err error)
+		// GetUserIdFromPasswordToken returns the user of a GeneratePasswordToken
+		// token and the passwordFingerprint of the hash it was signed for.
+		GetUserIdFromPasswordToken(ctx context.Context, token string) (uId uint, fingerprint string, err error)
 		GenerateTeamToken(ctx context.Context, teamId, userId uint) (token string, err error)
 		GetTeamFormToken(ctx context.Context, token string) (res *TeamJwtBody, err error)
 	}
@@ -173,6 +182,51 @@ func (j *jwtService) parserToken(ctx context.Context, tokenString, secret string
 }
 
 // CreateVerifyEmailToken implements JWTService.
+// GeneratePasswordToken implements JWTService.
+func (j *jwtService) GeneratePasswordToken(ctx context.Context, userId uint, passwordHash string) (token string, err error) {
+	claims := jwt.MapClaims{
+		"sub": strconv.Itoa(int(userId)),
+		"pwd": passwordFingerprint(passwordHash),
+		"exp": time.Now().Add(PasswordExpireTime).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	key := []byte(j.conf.JWT.EMAIL_SECRET)
+	return t.SignedString(key)
+}
+
+// GetUserIdFromPasswordToken implements JWTService.
+func (j *jwtService) GetUserIdFromPasswordToken(ctx context.Context, token string) (uId uint, fingerprint string, err error) {
+	t, err := j.parserToken(ctx, token, j.conf.JWT.EMAIL_SECRET)
+	if err != nil {
+		j.logger.Error(err)
+		return 0, "", errs.ErrPasswordLink
+	}
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok {
+		j.logger.Error("parse claims not ok")
+		return 0, "", errs.ErrPasswordLink
+	}
+	// verify email links carry no fingerprint and set no password
+	pwd, _ := claims["pwd"].(string)
+	uIdStr, err := claims.GetSubject()
+	if err != nil || pwd == "" {
+		return 0, "", errs.ErrPasswordLink
+	}
+	u, err := strconv.Atoi(uIdStr)
+	if err != nil {
+		return 0, "", errs.ErrPasswordLink
+	}
+	return uint(u), pwd, nil
+}
+
+// passwordFingerprint stands for a password hash in a token without telling
+// it, the token is good while the fingerprint of the current hash matches.
+func passwordFingerprint(hash string) string {
+	sum := sha256.Sum256([]byte(hash))
+	return hex.EncodeToString(sum[:16])
+}
+
 func (j *jwtService) GenerateExternalToken(ctx context.Context, userId uint) (token string, err error) {
 	uId := strconv.Itoa(int(userId))
 	claims := jwt.MapClaims{
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/logger"
+)
+
+func TestPasswordToken(t *testing.T) {
+	ctx := context.Background()
+	conf := &config.Config{JWT: config.JWT{SECRET: "secret", EMAIL_SECRET: "email"}}
+	j := NewJWT(logger.NewLoggerMock(), conf, nil)
+
+	token, err := j.GeneratePasswordToken(ctx, 42, "$2a$10$hash")
+	require.NoError(t, err)
+	userId, fingerprint, err := j.GetUserIdFromPasswordToken(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, uint(42), userId)
+	assert.Equal(t, passwordFingerprint("$2a$10$hash"), fingerprint)
+	assert.NotEqual(t, passwordFingerprint("$2a$10$other"), fingerprint, "a changed password uses the link up")
+	assert.NotContains(t, token, "hash")
+
+	t.Run("verify email token", func(t *testing.T) {
+		token, err := j.GenerateExternalToken(ctx, 42)
+		require.NoError(t, err)
+		_, _, err = j.GetUserIdFromPasswordToken(ctx, token)
+		assert.Equal(t, errs.ErrPasswordLink, err)
+	})
+
+	t.Run("other key", func(t *testing.T) {
+		other := NewJWT(logger.NewLoggerMock(), &config.Config{JWT: config.JWT{EMAIL_SECRET: "other"}}, nil)
+		_, _, err := other.GetUserIdFromPasswordToken(ctx, token)
+		assert.Equal(t, errs.ErrPasswordLink, err)
+	})
+}
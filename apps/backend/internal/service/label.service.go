@@ -0,0 +1,284 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+// type of a ScanResponse
+const (
+	ScanTypeProduct = "product"
+	ScanTypeLot     = "lot"
+)
+
+const (
+	// labelSheetMax limits the labels of one sheet request.
+	labelSheetMax = 1000
+	// a 3 x 8 grid fits the common 70 x 37 mm A4 label sheets
+	labelSheetColumns = 3
+	labelSheetRows    = 8
+)
+
+type (
+	// LabelService renders the product and lot codes of CodeService as
+	// barcodes and label sheets, and resolves scanned codes back.
+	LabelService interface {
+		ProductBarcode(ctx context.Context, teamId, productId uint, f *filter.BarcodeFilter) (*BarcodeImage, error)
+		LotBarcode(ctx context.Context, teamId, productId, lotId uint, f *filter.BarcodeFilter) (*BarcodeImage, error)
+		// Sheet returns a PDF of A4 pages with the labels of body.
+		Sheet(ctx context.Context, teamId uint, body LabelSheetRequest) (*LabelSheet, error)
+		// Scan resolves a scanned code to the product with that code, or else to
+		// the only lot with it.
+		Scan(ctx context.Context, teamId uint, f *filter.ScanFilter) (*ScanResponse, error)
+	}
+	labelService struct {
+		product repository.Product
+		lot     repository.ProductLot
+		barcode Barcode
+		conf    *config.Config
+		logger  logger.AppLogger
+	}
+
+	LabelSheetRequest struct {
+		// Symbology is code128 or qr, code128 when empty.
+		Symbology string `json:"symbology" binding:"omitempty,oneof=code128 qr"`
+		// Columns and Rows are the label grid of a page, 3 x 8 when empty.
+		Columns int `json:"columns" binding:"omitempty,min=1,max=6"`
+		Rows    int `json:"rows" binding:"omitempty,min=1,max=20"`
+		// Skip leaves the first labels of the first page empty.
+		Skip   int            `json:"skip" binding:"min=0"`
+		Labels []LabelRequest `json:"labels" binding:"required,min=1,dive"`
+	}
+	// LabelRequest prints the code of the lot, or of the product when
+	// ProductLotID is empty.
+	LabelRequest struct {
+		ProductID    uint `json:"product_id" binding:"required"`
+		ProductLotID uint `json:"product_lot_id"`
+		// Copies is 1 when empty.
+		Copies int `json:"copies" binding:"omitempty,min=1,max=1000"`
+	}
+
+	BarcodeImage struct {
+		Filename    string
+		ContentType string
+		Content     *bytes.Buffer
+	}
+	LabelSheet struct {
+		Filename string
+		Content  *bytes.Buffer
+	}
+
+	ScanResponse struct {
+		// Type is product or lot, Lot is set for a lot.
+		Type        string              `json:"type"`
+		ProductID   uint                `json:"product_id"`
+		ProductCode string              `json:"product_code"`
+		ProductName string              `json:"product_name"`
+		UOM         string              `json:"uom"`
+		Lot         *ProductLotResponse `json:"lot"`
+	}
+)
+
+// ProductBarcode implements LabelService.
+func (l *labelService) ProductBarcode(ctx context.Context, teamId, productId uint, f *filter.BarcodeFilter) (*BarcodeImage, error) {
+	product, err := l.product.FindById(ctx, nil, teamId, productId)
+	if err != nil {
+		l.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return l.encode(product.Code, f)
+}
+
+// LotBarcode implements LabelService.
+func (l *labelService) LotBarcode(ctx context.Context, teamId, productId, lotId uint, f *filter.BarcodeFilter) (*BarcodeImage, error) {
+	lot, err := l.findLot(ctx, teamId, productId, lotId)
+	if err != nil {
+		return nil, err
+	}
+	return l.encode(lot.Code, f)
+}
+
+// Sheet implements LabelService.
+func (l *labelService) Sheet(ctx context.Context, teamId uint, body LabelSheetRequest) (*LabelSheet, error) {
+	layout := LabelLayout{
+		Symbology: Code128,
+		Columns:   labelSheetColumns,
+		Rows:      labelSheetRows,
+		Skip:      body.Skip,
+	}
+	if body.Symbology != "" {
+		layout.Symbology = BarcodeSymbology(body.Symbology)
+	}
+	if body.Columns != 0 {
+		layout.Columns = body.Columns
+	}
+	if body.Rows != 0 {
+		layout.Rows = body.Rows
+	}
+	if layout.Skip >= layout.Columns*layout.Rows {
+		return nil, errs.ErrBadRequest
+	}
+	total := 0
+	for _, item := range body.Labels {
+		total += max(item.Copies, 1)
+	}
+	if total > labelSheetMax {
+		return nil, errs.ErrLabelSheetTooLarge
+	}
+
+	products := map[uint]*model.Product{}
+	labels := make([]Label, 0, total)
+	for _, item := range body.Labels {
+		product, ok := products[item.ProductID]
+		if !ok {
+			var err error
+			product, err = l.product.FindById(ctx, nil, teamId, item.ProductID)
+			if err != nil {
+				l.logger.Error(err)
+				return nil, errs.HandleSqlErr(err)
+			}
+			products[item.ProductID] = product
+		}
+		label := Label{Content: product.Code, Lines: []string{product.Code}}
+		if item.ProductLotID != 0 {
+			lot, err := l.findLot(ctx, teamId, item.ProductID, item.ProductLotID)
+			if err != nil {
+				return nil, err
+			}
+			label = Label{Content: lot.Code, Lines: []string{lot.Code, product.Code}}
+			if lot.ExpiryDate.Valid {
+				label.Lines = append(label.Lines, "EXP "+lot.ExpiryDate.Time.Format("2006-01-02"))
+			}
+		}
+		for range max(item.Copies, 1) {
+			labels = append(labels, label)
+		}
+	}
+	content, err := l.barcode.LabelSheet(labels, layout)
+	if err != nil {
+		var appErr errs.AppError
+		if errors.As(err, &appErr) {
+			return nil, err
+		}
+		l.logger.Error(err)
+		return nil, errs.ErrInternal
+	}
+	return &LabelSheet{Filename: "labels.pdf", Content: content}, nil
+}
+
+// Scan implements LabelService.
+func (l *labelService) Scan(ctx context.Context, teamId uint, f *filter.ScanFilter) (*ScanResponse, error) {
+	code := strings.TrimSpace(f.Code)
+	product, err := l.product.FindByCode(ctx, nil, teamId, code)
+	if err == nil {
+		return &ScanResponse{
+			Type:        ScanTypeProduct,
+			ProductID:   product.ID,
+			ProductCode: product.Code,
+			ProductName: product.Name,
+			UOM:         product.UOM,
+		}, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		l.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	lots, err := l.lot.FindAllByCode(ctx, nil, teamId, code)
+	if err != nil {
+		l.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	switch {
+	case len(lots) == 0:
+		return nil, errs.ErrScanCodeNotFound
+	case len(lots) > 1:
+		return nil, errs.ErrScanCodeAmbiguous
+	}
+	lot := lots[0]
+	res := &ScanResponse{
+		Type:      ScanTypeLot,
+		ProductID: lot.ProductID,
+		Lot:       toProductLotResponse(&lot, today(l.conf.SERVER.LOCATION)),
+	}
+	if lot.Product != nil {
+		res.ProductCode = lot.Product.Code
+		res.ProductName = lot.Product.Name
+		res.UOM = lot.Product.UOM
+	}
+	return res, nil
+}
+
+func (l *labelService) findLot(ctx context.Context, teamId, productId, lotId uint) (*model.ProductLot, error) {
+	lot, err := l.lot.FindInTeam(ctx, nil, teamId, lotId)
+	if err != nil {
+		l.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrProductLotNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	if lot.ProductID != productId {
+		return nil, errs.ErrProductLotNotFound
+	}
+	return lot, nil
+}
+
+func (l *labelService) encode(code string, f *filter.BarcodeFilter) (*BarcodeImage, error) {
+	symbology, format := Code128, PNG
+	width, height := 400, 100
+	if f.Symbology != "" {
+		symbology = BarcodeSymbology(f.Symbology)
+	}
+	if symbology == QRCode {
+		width = 300
+	}
+	if f.Format != "" {
+		format = BarcodeFormat(f.Format)
+	}
+	if f.Width != 0 {
+		width = f.Width
+	}
+	if f.Height != 0 {
+		height = f.Height
+	}
+	content, err := l.barcode.Encode(code, symbology, format, width, height)
+	if err != nil {
+		var appErr errs.AppError
+		if errors.As(err, &appErr) {
+			return nil, err
+		}
+		l.logger.Error(err)
+		return nil, errs.ErrInternal
+	}
+	res := &BarcodeImage{Filename: code + ".png", ContentType: "image/png", Content: content}
+	if format == SVG {
+		res.Filename, res.ContentType = code+".svg", "image/svg+xml"
+	}
+	return res, nil
+}
+
+func NewLabelService(
+	product repository.Product,
+	lot repository.ProductLot,
+	barcode Barcode,
+	conf *config.Config,
+	logger logger.AppLogger,
+) LabelService {
+	return &labelService{
+		product: product,
+		lot:     lot,
+		barcode: barcode,
+		conf:    conf,
+		logger:  logger,
+	}
+}
@@ -9,5 +9,38 @@ var Module = fx.Options(
 	fx.Provide(NewUser),
 	fx.Provide(NewCodeService),
 	fx.Provide(NewExcelService),
+	fx.Provide(NewBarcodeService),
 	fx.Provide(NewImageFileService),
+	fx.Provide(NewFileService),
+	fx.Provide(NewFileCollector),
+	fx.Provide(NewUploadPolicy),
+	fx.Provide(NewUploadService),
+	fx.Provide(NewFileScanService),
+	fx.Provide(NewAttachmentService),
+	fx.Provide(NewProductCategoryService),
+	fx.Provide(NewProductService),
+	fx.Provide(NewUomService),
+	fx.Provide(NewPartnerService),
+	fx.Provide(NewValuationService),
+	fx.Provide(NewLabelService),
+	fx.Provide(NewNumberingService),
+	fx.Provide(NewSequenceService),
+	fx.Provide(NewProductLotService),
+	fx.Provide(NewTransactionService),
+	fx.Provide(NewApprovalStepService),
+	fx.Provide(NewStockLedgerService),
+	fx.Provide(NewWarehouseService),
+	fx.Provide(NewStockTransferService),
+	fx.Provide(NewStockCountService),
+	fx.Provide(NewStockAlertService),
+	fx.Provide(NewImportService),
+	fx.Invoke(useUploadHooks),
 )
+
+// useUploadHooks registers what finished resumable uploads can become.
+func useUploadHooks(upload UploadService, user UserService, attachment AttachmentService) {
+	upload.RegisterHook(UploadTargetImage, UploadKindImage, user.CreateImageFromUpload)
+	upload.RegisterHook(UploadTargetProfileImage, UploadKindImage, user.CreateProfileImageFromUpload)
+	upload.RegisterHook(UploadTargetAttachment, UploadKindFile, attachment.CreateFromUpload)
+	upload.RegisterCheck(UploadTargetAttachment, attachment.CheckUpload)
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+type (
+	// NumberingService manages the numbering schemes CodeService numbers
+	// documents with. A template is literal text with the tokens {YYYY}, {YY},
+	// {MM}, {DD}, {YYMM}, {YYMMDD}, {YYYYMM}, {YYYYMMDD}, {FY}, {FYY}, {TYPE},
+	// {TEAM:n}, {ENTITY:n} and {SEQ:n}, where :n zero-pads to n digits.
+	NumberingService interface {
+		// FindAll returns the scheme of every numbered entity type, built-in
+		// unless the team set one.
+		FindAll(ctx context.Context, teamId uint) ([]NumberingSchemeResponse, error)
+		Save(ctx context.Context, teamId uint, entityType model.EntityType, body NumberingSchemeRequest) (*NumberingSchemeResponse, error)
+		// Delete goes back to the built-in scheme.
+		Delete(ctx context.Context, teamId uint, entityType model.EntityType) error
+		// Preview returns the next codes of the scheme without taking them.
+		Preview(ctx context.Context, teamId uint, entityType model.EntityType, f *filter.NumberingPreviewFilter) (*NumberingPreviewResponse, error)
+	}
+	numberingService struct {
+		scheme   repository.NumberingScheme
+		sequence repository.AutoIncrementSequence
+		conf     *config.Config
+		logger   logger.AppLogger
+	}
+
+	NumberingSchemeRequest struct {
+		Template    string `json:"template" binding:"required,max=64"`
+		ResetPeriod string `json:"reset_period" binding:"required,oneof=never daily monthly yearly fiscal_year"`
+		// FiscalYearStart is the month fiscal years start in, January when empty.
+		FiscalYearStart int `json:"fiscal_year_start" binding:"omitempty,min=1,max=12"`
+		// Gapless counts numbers in the transaction of the document, so a failed
+		// document leaves no gap, at the cost of numbering one document at a time.
+		Gapless bool `json:"gapless"`
+	}
+
+	NumberingSchemeResponse struct {
+		EntityType      model.EntityType `json:"entity_type"`
+		Template        string           `json:"template"`
+		ResetPeriod     string           `json:"reset_period"`
+		FiscalYearStart int              `json:"fiscal_year_start"`
+		Gapless         bool             `json:"gapless"`
+		// Builtin is true until the team sets a scheme.
+		Builtin bool `json:"builtin"`
+	}
+	NumberingPreviewResponse struct {
+		EntityType  model.EntityType `json:"entity_type"`
+		Template    string           `json:"template"`
+		ResetPeriod string           `json:"reset_period"`
+		// Period is the current reset period, empty when it never resets.
+		Period string   `json:"period"`
+		Codes  []string `json:"codes"`
+	}
+)
+
+// FindAll implements NumberingService.
+func (n *numberingService) FindAll(ctx context.Context, teamId uint) ([]NumberingSchemeResponse, error) {
+	schemes, err := n.schemes(ctx, teamId)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]NumberingSchemeResponse, 0, len(numberedEntityTypes))
+	for _, entityType := range numberedEntityTypes {
+		res = append(res, toNumberingSchemeResponse(entityType, schemes[entityType]))
+	}
+	return res, nil
+}
+
+// Save implements NumberingService.
+func (n *numberingService) Save(ctx context.Context, teamId uint, entityType model.EntityType, body NumberingSchemeRequest) (*NumberingSchemeResponse, error) {
+	if !slices.Contains(numberedEntityTypes, entityType) {
+		return nil, errs.ErrNumberingEntityType
+	}
+	m := model.NumberingScheme{
+		TeamID:          teamId,
+		EntityType:      entityType,
+		Template:        body.Template,
+		ResetPeriod:     body.ResetPeriod,
+		FiscalYearStart: max(body.FiscalYearStart, 1),
+		Gapless:         body.Gapless,
+	}
+	tpl, err := parseNumberingTemplate(m.Template)
+	if err != nil {
+		return nil, err
+	}
+	if err := tpl.validate(entityType, m.ResetPeriod); err != nil {
+		return nil, err
+	}
+	schemes, err := n.schemes(ctx, teamId)
+	if err != nil {
+		return nil, err
+	}
+	expanded := tpl.expand(entityType)
+	for other, scheme := range schemes {
+		if other == entityType {
+			continue
+		}
+		otherTpl, err := parseNumberingTemplate(scheme.Template)
+		if err != nil {
+			continue
+		}
+		if otherTpl.expand(other) == expanded {
+			return nil, errs.ErrNumberingTemplateDuplicate
+		}
+	}
+	if err := n.scheme.Save(ctx, nil, &m); err != nil {
+		n.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	res := toNumberingSchemeResponse(entityType, m)
+	return &res, nil
+}
+
+// Delete implements NumberingService.
+func (n *numberingService) Delete(ctx context.Context, teamId uint, entityType model.EntityType) error {
+	if !slices.Contains(numberedEntityTypes, entityType) {
+		return errs.ErrNumberingEntityType
+	}
+	if err := n.scheme.Delete(ctx, nil, teamId, entityType); err != nil {
+		n.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return nil
+}
+
+// Preview implements NumberingService.
+func (n *numberingService) Preview(ctx context.Context, teamId uint, entityType model.EntityType, f *filter.NumberingPreviewFilter) (*NumberingPreviewResponse, error) {
+	if !slices.Contains(numberedEntityTypes, entityType) {
+		return nil, errs.ErrNumberingEntityType
+	}
+	scheme, err := findNumberingScheme(ctx, n.scheme, teamId, entityType)
+	if err != nil {
+		n.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if f.Template != "" {
+		scheme = model.NumberingScheme{
+			Template:        f.Template,
+			ResetPeriod:     f.ResetPeriod,
+			FiscalYearStart: max(f.FiscalYearStart, 1),
+		}
+		if scheme.ResetPeriod == "" {
+			scheme.ResetPeriod = model.ResetNever
+		}
+	}
+	tpl, err := parseNumberingTemplate(scheme.Template)
+	if err != nil {
+		return nil, err
+	}
+	// saved and builtin schemes passed validate already, or predate a rule of it
+	if f.Template != "" {
+		if err := tpl.validate(entityType, scheme.ResetPeriod); err != nil {
+			return nil, err
+		}
+	}
+	now := time.Now()
+	period := numberingPeriod(scheme, now, n.conf.SERVER.LOCATION)
+	seqTeam, seqEntity := sequenceOwner(entityType, teamId, f.EntityID)
+	next := uint(1)
+	sequence, err := n.sequence.FindSequence(ctx, nil, entityType, seqTeam, seqEntity)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		n.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err == nil && sequence.Period == period {
+		next = sequence.Sequence + 1
+	}
+	count := f.Count
+	if count == 0 {
+		count = 5
+	}
+	res := &NumberingPreviewResponse{
+		EntityType:  entityType,
+		Template:    scheme.Template,
+		ResetPeriod: scheme.ResetPeriod,
+		Period:      period,
+		Codes:       make([]string, 0, count),
+	}
+	for i := range uint(count) {
+		res.Codes = append(res.Codes, tpl.render(numberingValues{
+			At:              now,
+			Location:        n.conf.SERVER.LOCATION,
+			FiscalYearStart: scheme.FiscalYearStart,
+			EntityType:      entityType,
+			TeamID:          teamId,
+			EntityID:        f.EntityID,
+			Sequence:        next + i,
+		}))
+	}
+	return res, nil
+}
+
+// schemes returns the scheme of every numbered entity type of the team.
+func (n *numberingService) schemes(ctx context.Context, teamId uint) (map[model.EntityType]model.NumberingScheme, error) {
+	saved, err := n.scheme.FindByTeam(ctx, nil, teamId)
+	if err != nil {
+		n.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	schemes := make(map[model.EntityType]model.NumberingScheme, len(numberedEntityTypes))
+	for _, entityType := range numberedEntityTypes {
+		schemes[entityType] = builtinNumberingSchemes[entityType]
+	}
+	for _, m := range saved {
+		schemes[m.EntityType] = m
+	}
+	return schemes, nil
+}
+
+// findNumberingScheme returns the scheme of the team for the entity type, the
+// built-in one when it has none.
+func findNumberingScheme(ctx context.Context, schemes repository.NumberingScheme, teamId uint, entityType model.EntityType) (model.NumberingScheme, error) {
+	m, err := schemes.FindByType(ctx, nil, teamId, entityType)
+	if err == nil {
+		return *m, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.NumberingScheme{}, err
+	}
+	builtin, ok := builtinNumberingSchemes[entityType]
+	if !ok {
+		return model.NumberingScheme{}, errs.ErrNumberingEntityType
+	}
+	return builtin, nil
+}
+
+func toNumberingSchemeResponse(entityType model.EntityType, m model.NumberingScheme) NumberingSchemeResponse {
+	return NumberingSchemeResponse{
+		EntityType:      entityType,
+		Template:        m.Template,
+		ResetPeriod:     m.ResetPeriod,
+		FiscalYearStart: max(m.FiscalYearStart, 1),
+		Gapless:         m.Gapless,
+		Builtin:         m.ID == 0,
+	}
+}
+
+func NewNumberingService(
+	scheme repository.NumberingScheme,
+	sequence repository.AutoIncrementSequence,
+	conf *config.Config,
+	logger logger.AppLogger,
+) NumberingService {
+	return &numberingService{
+		scheme:   scheme,
+		sequence: sequence,
+		conf:     conf,
+		logger:   logger,
+	}
+}
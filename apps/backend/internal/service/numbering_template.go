@@ -0,0 +1,290 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/internal/model"
+)
+
+// numberingTemplateMax limits the length of a numbering template.
+const numberingTemplateMax = 64
+
+// numberedEntityTypes are the entity types CodeService numbers, in the order
+// they are listed.
+var numberedEntityTypes = []model.EntityType{
+	model.EntityTypeTransactionSale,
+	model.EntityTypeTransactionReturn,
+	model.EntityTypeTransactionPurchase,
+	model.EntityTypeStockTransfer,
+	model.EntityTypeStockCount,
+	model.EntityTypeStockAdjustment,
+	model.EntityTypeProduct,
+	model.EntityTypeLot,
+	model.EntityTypePartner,
+}
+
+// builtinNumberingSchemes keep the codes of teams without a scheme as they
+// were before schemes existed.
+var builtinNumberingSchemes = map[model.EntityType]model.NumberingScheme{
+	model.EntityTypeTransactionSale:     {Template: "{TYPE}-{YYMMDD}{TEAM}{SEQ:3}", ResetPeriod: model.ResetDaily},
+	model.EntityTypeTransactionReturn:   {Template: "{TYPE}-{YYMMDD}{TEAM}{SEQ:3}", ResetPeriod: model.ResetDaily},
+	model.EntityTypeTransactionPurchase: {Template: "{TYPE}-{YYMMDD}{TEAM}{SEQ:3}", ResetPeriod: model.ResetDaily},
+	model.EntityTypeStockTransfer:       {Template: "{TYPE}-{YYMMDD}{TEAM}{SEQ:3}", ResetPeriod: model.ResetDaily},
+	model.EntityTypeStockCount:          {Template: "{TYPE}-{YYMMDD}{TEAM}{SEQ:3}", ResetPeriod: model.ResetDaily},
+	model.EntityTypeStockAdjustment:     {Template: "{TYPE}-{YYMMDD}{TEAM}{SEQ:3}", ResetPeriod: model.ResetDaily},
+	model.EntityTypeProduct:             {Template: "PN-{TEAM:2}{SEQ:4}", ResetPeriod: model.ResetNever},
+	// {ENTITY} and {SEQ} run together, so lots of different products can share
+	// a code; kept for the codes already issued, lot codes are unique per product.
+	model.EntityTypeLot:     {Template: "LN-{ENTITY:2}{SEQ:4}", ResetPeriod: model.ResetNever},
+	model.EntityTypePartner: {Template: "BP-{TEAM:2}{SEQ:4}", ResetPeriod: model.ResetNever},
+}
+
+// numbering tokens, the date tokens are of the time of the code
+const (
+	tokenYear4      = "YYYY"
+	tokenYear2      = "YY"
+	tokenMonth      = "MM"
+	tokenDay        = "DD"
+	tokenYearMonth  = "YYMM"
+	tokenDate       = "YYMMDD"
+	tokenYear4Month = "YYYYMM"
+	tokenDate4      = "YYYYMMDD"
+	tokenFiscal4    = "FY"  // fiscal year, named by the calendar year it ends in
+	tokenFiscal2    = "FYY" // last two digits of FY
+	tokenType       = "TYPE"
+	tokenTeam       = "TEAM"   // team id, {TEAM:n} zero-pads it to n digits
+	tokenEntity     = "ENTITY" // id the sequence counts for, the product of a lot
+	tokenSequence   = "SEQ"    // {SEQ:n} zero-pads it to n digits, longer numbers are kept whole
+)
+
+type (
+	// numberingPart is literal text, or a token when token is set.
+	numberingPart struct {
+		literal string
+		token   string
+		width   int
+	}
+	numberingTemplate []numberingPart
+
+	numberingValues struct {
+		At time.Time
+		// Location is the business location the date tokens are of.
+		Location        *time.Location
+		FiscalYearStart int
+		EntityType      model.EntityType
+		TeamID          uint
+		EntityID        uint
+		Sequence        uint
+	}
+)
+
+// parseNumberingTemplate reads literal text and {TOKEN} or {TOKEN:width}
+// tokens, literal text is printable ASCII so codes fit any barcode.
+func parseNumberingTemplate(s string) (numberingTemplate, error) {
+	if s == "" || len(s) > numberingTemplateMax {
+		return nil, errs.ErrNumberingTemplateInvalid
+	}
+	var tpl numberingTemplate
+	for s != "" {
+		open := strings.IndexAny(s, "{}")
+		if open < 0 {
+			open = len(s)
+		}
+		if open > 0 {
+			for _, r := range s[:open] {
+				if r < ' ' || r > '~' {
+					return nil, errs.ErrNumberingTemplateInvalid
+				}
+			}
+			tpl = append(tpl, numberingPart{literal: s[:open]})
+			s = s[open:]
+			continue
+		}
+		end := strings.IndexByte(s, '}')
+		if s[0] != '{' || end < 0 {
+			return nil, errs.ErrNumberingTemplateInvalid
+		}
+		part, err := parseNumberingToken(s[1:end])
+		if err != nil {
+			return nil, err
+		}
+		tpl = append(tpl, part)
+		s = s[end+1:]
+	}
+	return tpl, nil
+}
+
+func parseNumberingToken(s string) (numberingPart, error) {
+	name, width, hasWidth := strings.Cut(s, ":")
+	part := numberingPart{token: name}
+	switch name {
+	case tokenYear4, tokenYear2, tokenMonth, tokenDay, tokenYearMonth, tokenDate, tokenYear4Month, tokenDate4,
+		tokenFiscal4, tokenFiscal2, tokenType:
+		if hasWidth {
+			return part, errs.ErrNumberingTemplateInvalid
+		}
+	case tokenTeam, tokenEntity, tokenSequence:
+		if hasWidth {
+			n, err := strconv.Atoi(width)
+			if err != nil || n < 1 || n > 12 {
+				return part, errs.ErrNumberingTemplateInvalid
+			}
+			part.width = n
+		}
+	default:
+		return part, errs.ErrNumberingTemplateInvalid
+	}
+	return part, nil
+}
+
+// validate checks the template counts with exactly one {SEQ} and tells apart
+// the periods the sequence restarts in, so its codes never repeat. Lots count
+// per product, their template must name it with {ENTITY} apart from {SEQ}:
+// {ENTITY:2}{SEQ:4} gives 1230456 for product 123 lot 456 and product 12 lot
+// 30456, widths only pad and never cut.
+func (t numberingTemplate) validate(entityType model.EntityType, resetPeriod string) error {
+	var sequences, entities int
+	var year, month, day, fiscal bool
+	for i, p := range t {
+		switch p.token {
+		case tokenEntity:
+			entities++
+		case tokenSequence:
+			sequences++
+			if i > 0 && t[i-1].token == tokenEntity || i+1 < len(t) && t[i+1].token == tokenEntity {
+				return errs.ErrNumberingTemplateLotEntity
+			}
+		case tokenYear4, tokenYear2:
+			year = true
+		case tokenMonth:
+			month = true
+		case tokenDay:
+			day = true
+		case tokenYearMonth, tokenYear4Month:
+			year, month = true, true
+		case tokenDate, tokenDate4:
+			year, month, day = true, true, true
+		case tokenFiscal4, tokenFiscal2:
+			fiscal = true
+		}
+	}
+	if entityType == model.EntityTypeLot && entities == 0 {
+		return errs.ErrNumberingTemplateLotEntity
+	}
+	ok := sequences == 1
+	switch resetPeriod {
+	case model.ResetDaily:
+		ok = ok && year && month && day
+	case model.ResetMonthly:
+		ok = ok && year && month
+	case model.ResetYearly:
+		ok = ok && year
+	case model.ResetFiscalYear:
+		ok = ok && fiscal
+	}
+	if !ok {
+		return errs.ErrNumberingTemplateInvalid
+	}
+	return nil
+}
+
+// expand returns the template with {TYPE} replaced, templates of a team must
+// differ once expanded.
+func (t numberingTemplate) expand(entityType model.EntityType) string {
+	var b strings.Builder
+	for _, p := range t {
+		switch p.token {
+		case "":
+			b.WriteString(p.literal)
+		case tokenType:
+			b.WriteString(strings.ToUpper(string(entityType)))
+		default:
+			b.WriteByte('{')
+			b.WriteString(p.token)
+			if p.width > 0 {
+				fmt.Fprintf(&b, ":%d", p.width)
+			}
+			b.WriteByte('}')
+		}
+	}
+	return b.String()
+}
+
+func (t numberingTemplate) render(v numberingValues) string {
+	at := v.At.In(v.Location)
+	var b strings.Builder
+	for _, p := range t {
+		switch p.token {
+		case "":
+			b.WriteString(p.literal)
+		case tokenYear4:
+			b.WriteString(at.Format("2006"))
+		case tokenYear2:
+			b.WriteString(at.Format("06"))
+		case tokenMonth:
+			b.WriteString(at.Format("01"))
+		case tokenDay:
+			b.WriteString(at.Format("02"))
+		case tokenYearMonth:
+			b.WriteString(at.Format("0601"))
+		case tokenDate:
+			b.WriteString(at.Format("060102"))
+		case tokenYear4Month:
+			b.WriteString(at.Format("200601"))
+		case tokenDate4:
+			b.WriteString(at.Format("20060102"))
+		case tokenFiscal4:
+			fmt.Fprintf(&b, "%04d", fiscalYear(at, v.FiscalYearStart))
+		case tokenFiscal2:
+			fmt.Fprintf(&b, "%02d", fiscalYear(at, v.FiscalYearStart)%100)
+		case tokenType:
+			b.WriteString(strings.ToUpper(string(v.EntityType)))
+		case tokenTeam:
+			fmt.Fprintf(&b, "%0*d", p.width, v.TeamID)
+		case tokenEntity:
+			fmt.Fprintf(&b, "%0*d", p.width, v.EntityID)
+		case tokenSequence:
+			fmt.Fprintf(&b, "%0*d", p.width, v.Sequence)
+		}
+	}
+	return b.String()
+}
+
+// fiscalYear is the year the fiscal year of at ends in, fiscal years start on
+// the first day of month start.
+func fiscalYear(at time.Time, start int) int {
+	if start > 1 && int(at.Month()) >= start {
+		return at.Year() + 1
+	}
+	return at.Year()
+}
+
+// numberingPeriod is the key of the reset period of at, the sequence restarts
+// when it changes. Days, months and years are of the business day in loc.
+func numberingPeriod(scheme model.NumberingScheme, at time.Time, loc *time.Location) string {
+	at = at.In(loc)
+	switch scheme.ResetPeriod {
+	case model.ResetDaily:
+		return at.Format(time.DateOnly)
+	case model.ResetMonthly:
+		return at.Format("2006-01")
+	case model.ResetYearly:
+		return at.Format("2006")
+	case model.ResetFiscalYear:
+		return fmt.Sprintf("FY%04d", fiscalYear(at, scheme.FiscalYearStart))
+	}
+	return ""
+}
+
+// sequenceOwner is the team and entity a sequence counts for, lots count per
+// product whatever their team.
+func sequenceOwner(entityType model.EntityType, teamId, entityId uint) (uint, uint) {
+	if entityType == model.EntityTypeLot {
+		return 0, entityId
+	}
+	return teamId, 0
+}
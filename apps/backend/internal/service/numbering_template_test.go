@@ -0,0 +1,42 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/suttapak/starter/internal/model"
+)
+
+func TestNumberingPeriodLocalMidnight(t *testing.T) {
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	require.NoError(t, err)
+	// midnight of 1 April in Bangkok is 17:00 of 31 March in UTC
+	before := time.Date(2026, 3, 31, 16, 59, 59, 0, time.UTC)
+	after := before.Add(time.Second)
+
+	tests := []struct {
+		resetPeriod string
+		before      string
+		after       string
+	}{
+		{resetPeriod: model.ResetDaily, before: "2026-03-31", after: "2026-04-01"},
+		{resetPeriod: model.ResetMonthly, before: "2026-03", after: "2026-04"},
+		{resetPeriod: model.ResetFiscalYear, before: "FY2026", after: "FY2027"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.resetPeriod, func(t *testing.T) {
+			scheme := model.NumberingScheme{ResetPeriod: tt.resetPeriod, FiscalYearStart: 4}
+			assert.Equal(t, tt.before, numberingPeriod(scheme, before, bangkok))
+			assert.Equal(t, tt.after, numberingPeriod(scheme, after, bangkok))
+		})
+	}
+
+	tpl, err := parseNumberingTemplate("{TYPE}-{YYMMDD}{SEQ:3}")
+	require.NoError(t, err)
+	v := numberingValues{At: after, Location: bangkok, EntityType: model.EntityTypeTransactionSale, Sequence: 1}
+	assert.Equal(t, "SO-260401001", tpl.render(v))
+	v.Location = time.UTC
+	assert.Equal(t, "SO-260331001", tpl.render(v))
+}
@@ -0,0 +1,525 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+// partnerSheetHeader are the columns of the partner sheet, an uploaded sheet
+// needs type and name in any order. The address and contact columns are the
+// default address and primary contact.
+var partnerSheetHeader = []string{
+	"code", "type", "name", "tax_id", "email", "phone",
+	"address", "district", "province", "postal_code", "country",
+	"contact_name", "contact_position", "contact_email", "contact_phone", "note",
+}
+
+var partnerTypes = []string{model.PartnerTypeSupplier, model.PartnerTypeCustomer, model.PartnerTypeBoth}
+
+type (
+	// PartnerService manages the suppliers and customers of a team.
+	PartnerService interface {
+		// Create generates the code of the partner with CodeService.GeneratePartnerCode
+		// unless given.
+		Create(ctx context.Context, teamId uint, body PartnerRequest) (*PartnerResponse, error)
+		// Update replaces the partner with its addresses and contacts, an empty
+		// code keeps the current one.
+		Update(ctx context.Context, teamId, partnerId uint, body PartnerRequest) (*PartnerResponse, error)
+		// Delete fails with ErrPartnerInUse while a transaction references the partner.
+		Delete(ctx context.Context, teamId, partnerId uint) error
+		FindById(ctx context.Context, teamId, partnerId uint) (*PartnerResponse, error)
+		FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.PartnerFilter) ([]PartnerResponse, error)
+		// Export returns the matching partners as a partner sheet.
+		Export(ctx context.Context, teamId uint, f *filter.PartnerFilter) (*PartnerSheet, error)
+		// Import creates the partners of an uploaded partner sheet and updates the
+		// partners whose code it names, in one transaction.
+		Import(ctx context.Context, teamId uint, r io.Reader) (*PartnerImportResponse, error)
+
+		// Counterparty returns partnerId when it is a partner of the team that can
+		// be the counterparty of transactionType, nil when partnerId is zero.
+		Counterparty(ctx context.Context, tx *gorm.DB, teamId, partnerId uint, transactionType model.EntityType) (*uint, error)
+	}
+	partnerService struct {
+		partner repository.Partner
+		code    CodeService
+		excel   Excel
+		dbTx    repository.DatabaseTransaction
+		logger  logger.AppLogger
+	}
+
+	PartnerRequest struct {
+		// Code is generated when empty.
+		Code      string                  `json:"code" binding:"max=50"`
+		Type      string                  `json:"type" binding:"required,oneof=supplier customer both"`
+		Name      string                  `json:"name" binding:"required,max=255"`
+		TaxID     string                  `json:"tax_id" binding:"max=20"`
+		Email     string                  `json:"email" binding:"omitempty,email"`
+		Phone     string                  `json:"phone" binding:"max=50"`
+		Note      string                  `json:"note"`
+		Addresses []PartnerAddressRequest `json:"addresses" binding:"dive"`
+		Contacts  []PartnerContactRequest `json:"contacts" binding:"dive"`
+	}
+	// PartnerAddressRequest is an address, the first default one is the
+	// default address, or the first address when none is.
+	PartnerAddressRequest struct {
+		Label      string `json:"label" binding:"max=50"`
+		Address    string `json:"address" binding:"required"`
+		District   string `json:"district"`
+		Province   string `json:"province"`
+		PostalCode string `json:"postal_code" binding:"max=10"`
+		Country    string `json:"country"`
+		IsDefault  bool   `json:"is_default"`
+	}
+	// PartnerContactRequest is a contact, primary like PartnerAddressRequest is default.
+	PartnerContactRequest struct {
+		Name      string `json:"name" binding:"required,max=255"`
+		Position  string `json:"position"`
+		Email     string `json:"email" binding:"omitempty,email"`
+		Phone     string `json:"phone" binding:"max=50"`
+		IsPrimary bool   `json:"is_primary"`
+	}
+
+	PartnerResponse struct {
+		CommonModel
+		TeamID    uint                     `json:"team_id"`
+		Code      string                   `json:"code"`
+		Type      string                   `json:"type"`
+		Name      string                   `json:"name"`
+		TaxID     string                   `json:"tax_id"`
+		Email     string                   `json:"email"`
+		Phone     string                   `json:"phone"`
+		Note      string                   `json:"note"`
+		Addresses []PartnerAddressResponse `json:"addresses"`
+		Contacts  []PartnerContactResponse `json:"contacts"`
+	}
+	PartnerAddressResponse struct {
+		CommonModel
+		Label      string `json:"label"`
+		Address    string `json:"address"`
+		District   string `json:"district"`
+		Province   string `json:"province"`
+		PostalCode string `json:"postal_code"`
+		Country    string `json:"country"`
+		IsDefault  bool   `json:"is_default"`
+	}
+	PartnerContactResponse struct {
+		CommonModel
+		Name      string `json:"name"`
+		Position  string `json:"position"`
+		Email     string `json:"email"`
+		Phone     string `json:"phone"`
+		IsPrimary bool   `json:"is_primary"`
+	}
+	PartnerImportResponse struct {
+		Created int `json:"created"`
+		Updated int `json:"updated"`
+	}
+
+	PartnerSheet struct {
+		Filename string
+		Content  *bytes.Buffer
+	}
+)
+
+// Create implements PartnerService.
+func (p *partnerService) Create(ctx context.Context, teamId uint, body PartnerRequest) (*PartnerResponse, error) {
+	m := toPartnerModel(teamId, body)
+	tx := p.dbTx.BeginTx()
+	defer p.dbTx.RollbackTx(tx) //nolint:errcheck
+	if m.Code == "" {
+		code, err := p.code.GeneratePartnerCode(ctx, tx, teamId)
+		if err != nil {
+			p.logger.Error(err)
+			return nil, errs.HandleSqlErr(err)
+		}
+		m.Code = code
+	}
+	if err := p.partner.Create(ctx, tx, m); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := p.dbTx.CommitTx(tx); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return p.FindById(ctx, teamId, m.ID)
+}
+
+// Update implements PartnerService.
+func (p *partnerService) Update(ctx context.Context, teamId, partnerId uint, body PartnerRequest) (*PartnerResponse, error) {
+	tx := p.dbTx.BeginTx()
+	defer p.dbTx.RollbackTx(tx) //nolint:errcheck
+	current, err := p.partner.FindById(ctx, tx, teamId, partnerId)
+	if err != nil {
+		p.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrPartnerNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	m := toPartnerModel(teamId, body)
+	m.ID = current.ID
+	if m.Code == "" {
+		m.Code = current.Code
+	}
+	if err := p.partner.Update(ctx, tx, m); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := p.dbTx.CommitTx(tx); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return p.FindById(ctx, teamId, partnerId)
+}
+
+// Delete implements PartnerService.
+func (p *partnerService) Delete(ctx context.Context, teamId, partnerId uint) error {
+	if _, err := p.FindById(ctx, teamId, partnerId); err != nil {
+		return err
+	}
+	used, err := p.partner.InUse(ctx, nil, partnerId)
+	if err != nil {
+		p.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	if used {
+		return errs.ErrPartnerInUse
+	}
+	tx := p.dbTx.BeginTx()
+	defer p.dbTx.RollbackTx(tx) //nolint:errcheck
+	if err := p.partner.Delete(ctx, tx, teamId, partnerId); err != nil {
+		p.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errs.ErrPartnerNotFound
+		}
+		return errs.HandleSqlErr(err)
+	}
+	if err := p.dbTx.CommitTx(tx); err != nil {
+		p.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return nil
+}
+
+// FindById implements PartnerService.
+func (p *partnerService) FindById(ctx context.Context, teamId, partnerId uint) (*PartnerResponse, error) {
+	m, err := p.partner.FindById(ctx, nil, teamId, partnerId)
+	if err != nil {
+		p.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrPartnerNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return toPartnerResponse(m), nil
+}
+
+// FindAll implements PartnerService.
+func (p *partnerService) FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.PartnerFilter) ([]PartnerResponse, error) {
+	partners, err := p.partner.FindAll(ctx, nil, teamId, pg, f)
+	if err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	helpers.Paging(pg)
+	res := make([]PartnerResponse, 0, len(partners))
+	for i := range partners {
+		res = append(res, *toPartnerResponse(&partners[i]))
+	}
+	return res, nil
+}
+
+// Export implements PartnerService.
+func (p *partnerService) Export(ctx context.Context, teamId uint, f *filter.PartnerFilter) (*PartnerSheet, error) {
+	partners, err := p.partner.FindEvery(ctx, nil, teamId, f)
+	if err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	df := &ExcelDataFrame{Header: partnerSheetHeader}
+	for _, m := range partners {
+		var address model.PartnerAddress
+		if i := slices.IndexFunc(m.Addresses, func(a model.PartnerAddress) bool { return a.IsDefault }); i >= 0 {
+			address = m.Addresses[i]
+		}
+		var contact model.PartnerContact
+		if i := slices.IndexFunc(m.Contacts, func(c model.PartnerContact) bool { return c.IsPrimary }); i >= 0 {
+			contact = m.Contacts[i]
+		}
+		df.Rows = append(df.Rows, []any{
+			m.Code, m.Type, m.Name, m.TaxID, m.Email, m.Phone,
+			address.Address, address.District, address.Province, address.PostalCode, address.Country,
+			contact.Name, contact.Position, contact.Email, contact.Phone, m.Note,
+		})
+	}
+	content, err := p.excel.Build(df)
+	if err != nil {
+		p.logger.Error(err)
+		return nil, errs.ErrInternal
+	}
+	return &PartnerSheet{Filename: "partners.xlsx", Content: content}, nil
+}
+
+// Import implements PartnerService.
+func (p *partnerService) Import(ctx context.Context, teamId uint, r io.Reader) (*PartnerImportResponse, error) {
+	rows, err := p.excel.Read(r)
+	if err != nil {
+		return nil, errs.ErrPartnerSheetInvalid
+	}
+	if len(rows) < 2 {
+		return nil, errs.ErrPartnerSheetInvalid
+	}
+	col := make(map[string]int)
+	for i, name := range rows[0] {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"type", "name"} {
+		if _, ok := col[name]; !ok {
+			return nil, errs.ErrPartnerSheetInvalid
+		}
+	}
+	cell := func(row []string, name string) string {
+		i, ok := col[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	var codes []string
+	for _, row := range rows[1:] {
+		code := cell(row, "code")
+		if code == "" {
+			continue
+		}
+		if slices.Contains(codes, code) {
+			return nil, errs.ErrPartnerSheetInvalid
+		}
+		codes = append(codes, code)
+	}
+
+	tx := p.dbTx.BeginTx()
+	defer p.dbTx.RollbackTx(tx) //nolint:errcheck
+	existing, err := p.partner.FindByCodes(ctx, tx, teamId, codes)
+	if err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	res := &PartnerImportResponse{}
+	for _, row := range rows[1:] {
+		if slices.IndexFunc(row, func(v string) bool { return strings.TrimSpace(v) != "" }) < 0 {
+			continue
+		}
+		partnerType := strings.ToLower(cell(row, "type"))
+		name := cell(row, "name")
+		if !slices.Contains(partnerTypes, partnerType) || name == "" {
+			return nil, errs.ErrPartnerSheetInvalid
+		}
+		m := &model.Partner{TeamID: teamId, Code: cell(row, "code")}
+		if i := slices.IndexFunc(existing, func(e model.Partner) bool { return e.Code == m.Code }); m.Code != "" && i >= 0 {
+			if m, err = p.partner.FindById(ctx, tx, teamId, existing[i].ID); err != nil {
+				p.logger.Error(err)
+				return nil, errs.HandleSqlErr(err)
+			}
+		}
+		m.Type = partnerType
+		m.Name = name
+		m.TaxID = cell(row, "tax_id")
+		m.Email = cell(row, "email")
+		m.Phone = cell(row, "phone")
+		m.Note = cell(row, "note")
+		if address := cell(row, "address"); address != "" {
+			i := slices.IndexFunc(m.Addresses, func(a model.PartnerAddress) bool { return a.IsDefault })
+			if i < 0 {
+				m.Addresses = append(m.Addresses, model.PartnerAddress{IsDefault: true})
+				i = len(m.Addresses) - 1
+			}
+			m.Addresses[i].Address = address
+			m.Addresses[i].District = cell(row, "district")
+			m.Addresses[i].Province = cell(row, "province")
+			m.Addresses[i].PostalCode = cell(row, "postal_code")
+			m.Addresses[i].Country = cell(row, "country")
+		}
+		if contact := cell(row, "contact_name"); contact != "" {
+			i := slices.IndexFunc(m.Contacts, func(c model.PartnerContact) bool { return c.IsPrimary })
+			if i < 0 {
+				m.Contacts = append(m.Contacts, model.PartnerContact{IsPrimary: true})
+				i = len(m.Contacts) - 1
+			}
+			m.Contacts[i].Name = contact
+			m.Contacts[i].Position = cell(row, "contact_position")
+			m.Contacts[i].Email = cell(row, "contact_email")
+			m.Contacts[i].Phone = cell(row, "contact_phone")
+		}
+		if m.ID != 0 {
+			if err := p.partner.Update(ctx, tx, m); err != nil {
+				p.logger.Error(err)
+				return nil, errs.HandleSqlErr(err)
+			}
+			res.Updated++
+			continue
+		}
+		if m.Code == "" {
+			if m.Code, err = p.code.GeneratePartnerCode(ctx, tx, teamId); err != nil {
+				p.logger.Error(err)
+				return nil, errs.HandleSqlErr(err)
+			}
+		}
+		if err := p.partner.Create(ctx, tx, m); err != nil {
+			p.logger.Error(err)
+			return nil, errs.HandleSqlErr(err)
+		}
+		res.Created++
+	}
+	if err := p.dbTx.CommitTx(tx); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return res, nil
+}
+
+// Counterparty implements PartnerService.
+func (p *partnerService) Counterparty(ctx context.Context, tx *gorm.DB, teamId, partnerId uint, transactionType model.EntityType) (*uint, error) {
+	if partnerId == 0 {
+		return nil, nil
+	}
+	m, err := p.partner.FindById(ctx, tx, teamId, partnerId)
+	if err != nil {
+		p.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrPartnerNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	want := model.PartnerTypeCustomer
+	if transactionType == model.EntityTypeTransactionPurchase {
+		want = model.PartnerTypeSupplier
+	}
+	if m.Type != want && m.Type != model.PartnerTypeBoth {
+		return nil, errs.ErrPartnerTypeMismatch
+	}
+	return &m.ID, nil
+}
+
+func toPartnerModel(teamId uint, body PartnerRequest) *model.Partner {
+	m := &model.Partner{
+		TeamID: teamId,
+		Code:   body.Code,
+		Type:   body.Type,
+		Name:   body.Name,
+		TaxID:  body.TaxID,
+		Email:  body.Email,
+		Phone:  body.Phone,
+		Note:   body.Note,
+	}
+	hasDefault := false
+	for _, a := range body.Addresses {
+		m.Addresses = append(m.Addresses, model.PartnerAddress{
+			Label:      a.Label,
+			Address:    a.Address,
+			District:   a.District,
+			Province:   a.Province,
+			PostalCode: a.PostalCode,
+			Country:    a.Country,
+			IsDefault:  a.IsDefault && !hasDefault,
+		})
+		hasDefault = hasDefault || a.IsDefault
+	}
+	if !hasDefault && len(m.Addresses) > 0 {
+		m.Addresses[0].IsDefault = true
+	}
+	hasPrimary := false
+	for _, c := range body.Contacts {
+		m.Contacts = append(m.Contacts, model.PartnerContact{
+			Name:      c.Name,
+			Position:  c.Position,
+			Email:     c.Email,
+			Phone:     c.Phone,
+			IsPrimary: c.IsPrimary && !hasPrimary,
+		})
+		hasPrimary = hasPrimary || c.IsPrimary
+	}
+	if !hasPrimary && len(m.Contacts) > 0 {
+		m.Contacts[0].IsPrimary = true
+	}
+	return m
+}
+
+func toPartnerResponse(m *model.Partner) *PartnerResponse {
+	res := &PartnerResponse{
+		CommonModel: CommonModel{
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		},
+		TeamID:    m.TeamID,
+		Code:      m.Code,
+		Type:      m.Type,
+		Name:      m.Name,
+		TaxID:     m.TaxID,
+		Email:     m.Email,
+		Phone:     m.Phone,
+		Note:      m.Note,
+		Addresses: make([]PartnerAddressResponse, 0, len(m.Addresses)),
+		Contacts:  make([]PartnerContactResponse, 0, len(m.Contacts)),
+	}
+	for _, a := range m.Addresses {
+		res.Addresses = append(res.Addresses, PartnerAddressResponse{
+			CommonModel: CommonModel{
+				ID:        a.ID,
+				CreatedAt: a.CreatedAt,
+				UpdatedAt: a.UpdatedAt,
+			},
+			Label:      a.Label,
+			Address:    a.Address,
+			District:   a.District,
+			Province:   a.Province,
+			PostalCode: a.PostalCode,
+			Country:    a.Country,
+			IsDefault:  a.IsDefault,
+		})
+	}
+	for _, c := range m.Contacts {
+		res.Contacts = append(res.Contacts, PartnerContactResponse{
+			CommonModel: CommonModel{
+				ID:        c.ID,
+				CreatedAt: c.CreatedAt,
+				UpdatedAt: c.UpdatedAt,
+			},
+			Name:      c.Name,
+			Position:  c.Position,
+			Email:     c.Email,
+			Phone:     c.Phone,
+			IsPrimary: c.IsPrimary,
+		})
+	}
+	return res
+}
+
+func NewPartnerService(
+	partner repository.Partner,
+	code CodeService,
+	excel Excel,
+	dbTx repository.DatabaseTransaction,
+	logger logger.AppLogger,
+) PartnerService {
+	return &partnerService{
+		partner: partner,
+		code:    code,
+		excel:   excel,
+		dbTx:    dbTx,
+		logger:  logger,
+	}
+}
@@ -0,0 +1,554 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path"
+	"slices"
+
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type (
+	ProductService interface {
+		// Create generates the code of the product with CodeService.GenerateProductCode.
+		// The base unit is body.BaseUomID, or the unit named body.UOM which is
+		// added to the catalog of the team when missing.
+		Create(ctx context.Context, teamId uint, body CreateProductRequest) (*ProductResponse, error)
+		// CreateInTx is Create within tx for a row of an import, and returns the
+		// id of the product. code is taken beforehand, such as one of
+		// CodeService.ReserveProductCodes.
+		CreateInTx(ctx context.Context, tx *gorm.DB, teamId uint, code string, body CreateProductRequest) (uint, error)
+		// Update resolves the base unit like Create, it fails with
+		// ErrProductBaseUomLocked when changing it after stock was received.
+		Update(ctx context.Context, teamId, productId uint, body UpdateProductRequest) (*ProductResponse, error)
+		// Delete removes the product with its images.
+		Delete(ctx context.Context, teamId, productId uint) error
+		FindById(ctx context.Context, teamId, productId uint) (*ProductResponse, error)
+		FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.ProductFilter) ([]ProductResponse, error)
+		// UploadImages appends files to the images of the product. Infected files are
+		// quarantined and not linked, the others are kept and ErrFileInfected returned.
+		UploadImages(ctx context.Context, userId, teamId, productId uint, files []*multipart.FileHeader) (*ProductResponse, error)
+		// ReorderImages sorts the images by the order of body.ProductImageIds,
+		// which must list every image of the product once.
+		ReorderImages(ctx context.Context, teamId, productId uint, body ReorderProductImageRequest) (*ProductResponse, error)
+		DeleteImage(ctx context.Context, teamId, productId, productImageId uint) error
+		OpenImage(ctx context.Context, teamId, productId, productImageId uint, download bool) (io.ReadSeekCloser, *FileInfo, error)
+	}
+	productService struct {
+		product      repository.Product
+		category     repository.ProductCategory
+		lot          repository.ProductLot
+		uom          repository.Uom
+		uomService   UomService
+		image        repository.Image
+		storage      repository.Storage
+		dbTx         repository.DatabaseTransaction
+		code         CodeService
+		imageService ImageFileService
+		policy       UploadPolicy
+		scan         FileScanService
+		logger       logger.AppLogger
+		help         helpers.Helper
+	}
+
+	CreateProductRequest struct {
+		Name        string  `json:"name" binding:"required,max=255"`
+		Description string  `json:"description"`
+		Price       float64 `json:"price" binding:"min=0"`
+		// UOM names the base unit when BaseUomID is empty.
+		UOM         string              `json:"uom" binding:"required_without=BaseUomID,max=50"`
+		BaseUomID   uint                `json:"base_uom_id"`
+		Uoms        []ProductUomRequest `json:"uoms" binding:"dive"`
+		CategoryIds []uint              `json:"category_ids"`
+		// ReorderPoint is the stock in the base unit a low stock alert is raised at, zero for none.
+		ReorderPoint float64 `json:"reorder_point" binding:"min=0"`
+	}
+	UpdateProductRequest struct {
+		Name        string  `json:"name" binding:"required,max=255"`
+		Description string  `json:"description"`
+		Price       float64 `json:"price" binding:"min=0"`
+		// UOM names the base unit when BaseUomID is empty.
+		UOM         string              `json:"uom" binding:"required_without=BaseUomID,max=50"`
+		BaseUomID   uint                `json:"base_uom_id"`
+		Uoms        []ProductUomRequest `json:"uoms" binding:"dive"`
+		CategoryIds []uint              `json:"category_ids"`
+		// ReorderPoint is the stock in the base unit a low stock alert is raised at, zero for none.
+		ReorderPoint float64 `json:"reorder_point" binding:"min=0"`
+	}
+	ReorderProductImageRequest struct {
+		ProductImageIds []uint `json:"product_image_ids" binding:"required"`
+	}
+
+	ProductResponse struct {
+		CommonModel
+		TeamID                 uint                             `json:"team_id"`
+		Code                   string                           `json:"code"`
+		Name                   string                           `json:"name"`
+		Description            string                           `json:"description"`
+		Price                  float64                          `json:"price"`
+		UOM                    string                           `json:"uom"`
+		BaseUomID              *uint                            `json:"base_uom_id"`
+		BaseUom                *UomResponse                     `json:"base_uom"`
+		Uoms                   []ProductUomResponse             `json:"uoms"`
+		ReorderPoint           float64                          `json:"reorder_point"`
+		ProductImage           []ProductImage                   `json:"product_image"`
+		ProductProductCategory []ProductProductCategoryResponse `json:"product_product_category"`
+	}
+	ProductImage struct {
+		CommonModel
+		ProductID uint  `json:"product_id"`
+		ImageID   uint  `json:"image_id"`
+		SortOrder int   `json:"sort_order"`
+		Image     Image `json:"image"`
+	}
+	ProductProductCategoryResponse struct {
+		CommonModel
+		ProductID  uint                    `json:"product_id"`
+		CategoryID uint                    `json:"category_id"`
+		Category   ProductCategoryResponse `json:"category"`
+	}
+)
+
+// Create implements ProductService.
+func (p *productService) Create(ctx context.Context, teamId uint, body CreateProductRequest) (*ProductResponse, error) {
+	tx := p.dbTx.BeginTx()
+	defer p.dbTx.RollbackTx(tx) //nolint:errcheck
+	productId, err := p.create(ctx, tx, teamId, body, p.code.GenerateProductCode)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.dbTx.CommitTx(tx); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return p.FindById(ctx, teamId, productId)
+}
+
+// CreateInTx implements ProductService.
+func (p *productService) CreateInTx(ctx context.Context, tx *gorm.DB, teamId uint, code string, body CreateProductRequest) (uint, error) {
+	return p.create(ctx, tx, teamId, body, func(context.Context, *gorm.DB, uint) (string, error) {
+		return code, nil
+	})
+}
+
+// create saves the product of body in tx with a code of generate.
+func (p *productService) create(ctx context.Context, tx *gorm.DB, teamId uint, body CreateProductRequest, generate func(ctx context.Context, tx *gorm.DB, teamId uint) (string, error)) (uint, error) {
+	if err := p.checkCategories(ctx, teamId, body.CategoryIds); err != nil {
+		return 0, err
+	}
+	code, err := generate(ctx, tx, teamId)
+	if err != nil {
+		p.logger.Error(err)
+		return 0, errs.HandleSqlErr(err)
+	}
+	base, err := p.uomService.BaseUom(ctx, tx, teamId, body.BaseUomID, body.UOM)
+	if err != nil {
+		return 0, err
+	}
+	uoms, err := p.uomService.ProductUoms(ctx, tx, teamId, base.ID, body.Uoms)
+	if err != nil {
+		return 0, err
+	}
+	m := &model.Product{
+		TeamID:       teamId,
+		Code:         code,
+		Name:         body.Name,
+		Description:  body.Description,
+		Price:        body.Price,
+		UOM:          base.Name,
+		BaseUomID:    &base.ID,
+		ReorderPoint: body.ReorderPoint,
+	}
+	if err := p.product.Create(ctx, tx, m); err != nil {
+		p.logger.Error(err)
+		return 0, errs.HandleSqlErr(err)
+	}
+	if err := p.uom.ReplaceProductUoms(ctx, tx, m.ID, uoms); err != nil {
+		p.logger.Error(err)
+		return 0, errs.HandleSqlErr(err)
+	}
+	if err := p.product.ReplaceCategories(ctx, tx, m.ID, body.CategoryIds); err != nil {
+		p.logger.Error(err)
+		return 0, errs.HandleSqlErr(err)
+	}
+	return m.ID, nil
+}
+
+// Update implements ProductService.
+func (p *productService) Update(ctx context.Context, teamId, productId uint, body UpdateProductRequest) (*ProductResponse, error) {
+	if err := p.checkCategories(ctx, teamId, body.CategoryIds); err != nil {
+		return nil, err
+	}
+	tx := p.dbTx.BeginTx()
+	defer p.dbTx.RollbackTx(tx) //nolint:errcheck
+	current, err := p.product.FindById(ctx, tx, teamId, productId)
+	if err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	base, err := p.uomService.BaseUom(ctx, tx, teamId, body.BaseUomID, body.UOM)
+	if err != nil {
+		return nil, err
+	}
+	// stock and documents are kept in the base unit
+	if current.BaseUomID != nil && *current.BaseUomID != base.ID {
+		received, err := p.lot.ExistsByProduct(ctx, tx, productId)
+		if err != nil {
+			p.logger.Error(err)
+			return nil, errs.HandleSqlErr(err)
+		}
+		if received {
+			return nil, errs.ErrProductBaseUomLocked
+		}
+	}
+	uoms, err := p.uomService.ProductUoms(ctx, tx, teamId, base.ID, body.Uoms)
+	if err != nil {
+		return nil, err
+	}
+	m := &model.Product{
+		CommonModel:  model.CommonModel{ID: productId},
+		TeamID:       teamId,
+		Name:         body.Name,
+		Description:  body.Description,
+		Price:        body.Price,
+		UOM:          base.Name,
+		BaseUomID:    &base.ID,
+		ReorderPoint: body.ReorderPoint,
+	}
+	if err := p.product.Update(ctx, tx, m); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := p.uom.ReplaceProductUoms(ctx, tx, productId, uoms); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := p.product.ReplaceCategories(ctx, tx, productId, body.CategoryIds); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := p.dbTx.CommitTx(tx); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return p.FindById(ctx, teamId, productId)
+}
+
+// Delete implements ProductService.
+func (p *productService) Delete(ctx context.Context, teamId, productId uint) error {
+	tx := p.dbTx.BeginTx()
+	defer p.dbTx.RollbackTx(tx) //nolint:errcheck
+	m, err := p.product.FindById(ctx, tx, teamId, productId)
+	if err != nil {
+		p.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	var orphans []string
+	for _, pi := range m.ProductImage {
+		orphaned, err := p.deleteImage(ctx, tx, &pi)
+		if err != nil {
+			return err
+		}
+		if orphaned {
+			orphans = append(orphans, pi.Image.Path)
+		}
+	}
+	if err := p.product.Delete(ctx, tx, teamId, productId); err != nil {
+		p.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	if err := p.dbTx.CommitTx(tx); err != nil {
+		p.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	p.deleteFiles(ctx, orphans)
+	return nil
+}
+
+// FindById implements ProductService.
+func (p *productService) FindById(ctx context.Context, teamId, productId uint) (*ProductResponse, error) {
+	m, err := p.product.FindById(ctx, nil, teamId, productId)
+	if err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	var res ProductResponse
+	if err := p.help.ParseJson(m, &res); err != nil {
+		p.logger.Error(err)
+		return nil, errs.ErrInternal
+	}
+	return &res, nil
+}
+
+// FindAll implements ProductService.
+func (p *productService) FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.ProductFilter) ([]ProductResponse, error) {
+	models, err := p.product.FindAll(ctx, nil, teamId, pg, f)
+	if err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	helpers.Paging(pg)
+	res := []ProductResponse{}
+	if err := p.help.ParseJson(models, &res); err != nil {
+		p.logger.Error(err)
+		return nil, errs.ErrInternal
+	}
+	return res, nil
+}
+
+// UploadImages implements ProductService.
+func (p *productService) UploadImages(ctx context.Context, userId, teamId, productId uint, files []*multipart.FileHeader) (*ProductResponse, error) {
+	stats := make([]*imageStats, 0, len(files))
+	for _, fh := range files {
+		// size, type, dimensions and quota, before the image is decoded
+		if err := p.policy.CheckFileHeader(ctx, userId, UploadKindImage, fh); err != nil {
+			return nil, err
+		}
+		imgStats, err := p.imageService.GetImageStatsFromFileHeader(fh)
+		if err != nil {
+			p.logger.Error(err)
+			return nil, errs.ErrFileImageCanNotGetStats
+		}
+		stats = append(stats, imgStats)
+	}
+	tx := p.dbTx.BeginTx()
+	defer p.dbTx.RollbackTx(tx) //nolint:errcheck
+	if _, err := p.product.FindById(ctx, tx, teamId, productId); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	order, err := p.product.NextImageOrder(ctx, tx, productId)
+	if err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	infected := false
+	for i, fh := range files {
+		blob, err := p.imageService.SaveBlobFromFileHeader(ctx, tx, fh, productImagePath)
+		if err != nil {
+			p.logger.Error(err)
+			return nil, errs.ErrFileImageCanNotSaveToDisk
+		}
+		imageModel, err := saveImage(ctx, tx, p.image, p.scan, p.logger, userId, blob, stats[i], imageUrl)
+		if err != nil {
+			return nil, err
+		}
+		// an infected image is kept in quarantine only
+		if imageModel.ScanStatus == model.ScanStatusInfected {
+			infected = true
+			continue
+		}
+		pi := &model.ProductImage{ProductID: productId, ImageID: imageModel.ID, SortOrder: order}
+		if err := p.product.CreateImage(ctx, tx, pi); err != nil {
+			p.logger.Error(err)
+			return nil, errs.HandleSqlErr(err)
+		}
+		// served to the whole team, not only to the uploader
+		if err := p.image.UpdateUrl(ctx, tx, imageModel.ID, productImageUrl(teamId, productId, pi.ID)); err != nil {
+			p.logger.Error(err)
+			return nil, errs.HandleSqlErr(err)
+		}
+		order++
+	}
+	if err := p.dbTx.CommitTx(tx); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if infected {
+		return nil, errs.ErrFileInfected
+	}
+	return p.FindById(ctx, teamId, productId)
+}
+
+// ReorderImages implements ProductService.
+func (p *productService) ReorderImages(ctx context.Context, teamId, productId uint, body ReorderProductImageRequest) (*ProductResponse, error) {
+	tx := p.dbTx.BeginTx()
+	defer p.dbTx.RollbackTx(tx) //nolint:errcheck
+	if _, err := p.product.FindById(ctx, tx, teamId, productId); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	images, err := p.product.FindImages(ctx, tx, productId)
+	if err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	current := make([]uint, 0, len(images))
+	for _, pi := range images {
+		current = append(current, pi.ID)
+	}
+	ordered := slices.Clone(body.ProductImageIds)
+	slices.Sort(current)
+	slices.Sort(ordered)
+	if !slices.Equal(current, ordered) {
+		return nil, errs.ErrProductImageOrderInvalid
+	}
+	for i, id := range body.ProductImageIds {
+		if err := p.product.UpdateImageOrder(ctx, tx, id, i); err != nil {
+			p.logger.Error(err)
+			return nil, errs.HandleSqlErr(err)
+		}
+	}
+	if err := p.dbTx.CommitTx(tx); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return p.FindById(ctx, teamId, productId)
+}
+
+// DeleteImage implements ProductService.
+func (p *productService) DeleteImage(ctx context.Context, teamId, productId, productImageId uint) error {
+	tx := p.dbTx.BeginTx()
+	defer p.dbTx.RollbackTx(tx) //nolint:errcheck
+	pi, err := p.findImage(ctx, tx, teamId, productId, productImageId)
+	if err != nil {
+		return err
+	}
+	orphaned, err := p.deleteImage(ctx, tx, pi)
+	if err != nil {
+		return err
+	}
+	if err := p.dbTx.CommitTx(tx); err != nil {
+		p.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	if orphaned {
+		p.deleteFiles(ctx, []string{pi.Image.Path})
+	}
+	return nil
+}
+
+// OpenImage implements ProductService.
+func (p *productService) OpenImage(ctx context.Context, teamId, productId, productImageId uint, download bool) (io.ReadSeekCloser, *FileInfo, error) {
+	pi, err := p.findImage(ctx, nil, teamId, productId, productImageId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkScanStatus(pi.Image.ScanStatus); err != nil {
+		return nil, nil, err
+	}
+	disposition := DispositionInline
+	if download {
+		disposition = DispositionAttachment
+	}
+	return openFile(ctx, p.storage, p.logger, pi.Image.Path, path.Base(pi.Image.Path), disposition, protectedFileMaxAge)
+}
+
+// checkCategories makes sure every category belongs to the team.
+func (p *productService) checkCategories(ctx context.Context, teamId uint, categoryIds []uint) error {
+	if len(categoryIds) == 0 {
+		return nil
+	}
+	ids := slices.Compact(slices.Sorted(slices.Values(categoryIds)))
+	count, err := p.category.CountByIds(ctx, nil, teamId, ids)
+	if err != nil {
+		p.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	if count != int64(len(ids)) {
+		return errs.ErrProductCategoryNotFound
+	}
+	return nil
+}
+
+// findImage returns the product image only if the product belongs to the team.
+func (p *productService) findImage(ctx context.Context, tx *gorm.DB, teamId, productId, productImageId uint) (*model.ProductImage, error) {
+	if _, err := p.product.FindById(ctx, tx, teamId, productId); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	pi, err := p.product.FindImageById(ctx, tx, productId, productImageId)
+	if err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if pi.Image == nil {
+		return nil, errs.ErrNotFound
+	}
+	return pi, nil
+}
+
+// deleteImage removes the product image with its image and releases the blob,
+// orphaned reports whether the file can be deleted after commit.
+func (p *productService) deleteImage(ctx context.Context, tx *gorm.DB, pi *model.ProductImage) (bool, error) {
+	if err := p.product.DeleteImage(ctx, tx, pi.ID); err != nil {
+		p.logger.Error(err)
+		return false, errs.HandleSqlErr(err)
+	}
+	if pi.Image == nil {
+		return false, nil
+	}
+	if err := p.image.Delete(ctx, tx, pi.ImageID); err != nil {
+		p.logger.Error(err)
+		return false, errs.HandleSqlErr(err)
+	}
+	// quarantined files hold no blob reference
+	if pi.Image.ScanStatus == model.ScanStatusInfected {
+		return false, nil
+	}
+	orphaned, err := p.imageService.ReleaseBlob(ctx, tx, pi.Image.Path)
+	if err != nil {
+		p.logger.Error(err)
+		return false, errs.HandleSqlErr(err)
+	}
+	return orphaned, nil
+}
+
+func (p *productService) deleteFiles(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := p.imageService.DeleteFile(ctx, key); err != nil && !errors.Is(err, repository.ErrStorageObjectNotFound) {
+			// left for the file collector
+			p.logger.Error(err, zap.String("key", key))
+		}
+	}
+}
+
+// productImageUrl returns the URL of the product image route, see route.UseProduct.
+func productImageUrl(teamId, productId, productImageId uint) string {
+	return fmt.Sprintf("/teams/%d/products/%d/images/%d", teamId, productId, productImageId)
+}
+
+func NewProductService(
+	product repository.Product,
+	category repository.ProductCategory,
+	lot repository.ProductLot,
+	uom repository.Uom,
+	uomService UomService,
+	image repository.Image,
+	storage repository.Storage,
+	dbTx repository.DatabaseTransaction,
+	code CodeService,
+	imageService ImageFileService,
+	policy UploadPolicy,
+	scan FileScanService,
+	logger logger.AppLogger,
+	help helpers.Helper,
+) ProductService {
+	return &productService{
+		product:      product,
+		category:     category,
+		lot:          lot,
+		uom:          uom,
+		uomService:   uomService,
+		image:        image,
+		storage:      storage,
+		dbTx:         dbTx,
+		code:         code,
+		imageService: imageService,
+		policy:       policy,
+		scan:         scan,
+		logger:       logger,
+		help:         help,
+	}
+}
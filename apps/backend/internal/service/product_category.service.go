@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+)
+
+type (
+	ProductCategoryService interface {
+		Create(ctx context.Context, teamId uint, body CreateProductCategoryRequest) (*ProductCategoryResponse, error)
+		Update(ctx context.Context, teamId, categoryId uint, body UpdateProductCategoryRequest) (*ProductCategoryResponse, error)
+		// Delete unlinks the category from every product of the team.
+		Delete(ctx context.Context, teamId, categoryId uint) error
+		FindById(ctx context.Context, teamId, categoryId uint) (*ProductCategoryResponse, error)
+		FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.ProductCategoryFilter) ([]ProductCategoryResponse, error)
+	}
+	productCategoryService struct {
+		category repository.ProductCategory
+		logger   logger.AppLogger
+		help     helpers.Helper
+	}
+
+	CreateProductCategoryRequest struct {
+		Name string `json:"name" binding:"required,max=255"`
+	}
+	UpdateProductCategoryRequest struct {
+		Name string `json:"name" binding:"required,max=255"`
+	}
+
+	ProductCategoryResponse struct {
+		CommonModel
+		Name string `json:"name"`
+	}
+)
+
+// Create implements ProductCategoryService.
+func (p *productCategoryService) Create(ctx context.Context, teamId uint, body CreateProductCategoryRequest) (*ProductCategoryResponse, error) {
+	m := &model.ProductCategory{TeamID: teamId, Name: body.Name}
+	if err := p.category.Create(ctx, nil, m); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return p.toResponse(m)
+}
+
+// Update implements ProductCategoryService.
+func (p *productCategoryService) Update(ctx context.Context, teamId, categoryId uint, body UpdateProductCategoryRequest) (*ProductCategoryResponse, error) {
+	if err := p.category.Update(ctx, nil, teamId, categoryId, body.Name); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return p.FindById(ctx, teamId, categoryId)
+}
+
+// Delete implements ProductCategoryService.
+func (p *productCategoryService) Delete(ctx context.Context, teamId, categoryId uint) error {
+	if err := p.category.Delete(ctx, nil, teamId, categoryId); err != nil {
+		p.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return nil
+}
+
+// FindById implements ProductCategoryService.
+func (p *productCategoryService) FindById(ctx context.Context, teamId, categoryId uint) (*ProductCategoryResponse, error) {
+	m, err := p.category.FindById(ctx, nil, teamId, categoryId)
+	if err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return p.toResponse(m)
+}
+
+// FindAll implements ProductCategoryService.
+func (p *productCategoryService) FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.ProductCategoryFilter) ([]ProductCategoryResponse, error) {
+	models, err := p.category.FindAll(ctx, nil, teamId, pg, f)
+	if err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	helpers.Paging(pg)
+	res := []ProductCategoryResponse{}
+	if err := p.help.ParseJson(models, &res); err != nil {
+		p.logger.Error(err)
+		return nil, errs.ErrInternal
+	}
+	return res, nil
+}
+
+func (p *productCategoryService) toResponse(m *model.ProductCategory) (*ProductCategoryResponse, error) {
+	var res ProductCategoryResponse
+	if err := p.help.ParseJson(m, &res); err != nil {
+		p.logger.Error(err)
+		return nil, errs.ErrInternal
+	}
+	return &res, nil
+}
+
+func NewProductCategoryService(
+	category repository.ProductCategory,
+	logger logger.AppLogger,
+	help helpers.Helper,
+) ProductCategoryService {
+	return &productCategoryService{
+		category: category,
+		logger:   logger,
+		help:     help,
+	}
+}
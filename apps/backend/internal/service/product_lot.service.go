@@ -0,0 +1,502 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+type (
+	ProductLotService interface {
+		// Receive creates a lot holding body.Quantity, the lot code is generated
+		// with CodeService.GenerateLotCode unless given.
+		Receive(ctx context.Context, teamId, productId uint, body ReceiveProductLotRequest) (*ProductLotResponse, error)
+		// CreateLot saves m in tx, generating its code when empty, and records
+		// its remaining quantity into locationId, the default location when zero.
+		CreateLot(ctx context.Context, tx *gorm.DB, m *model.ProductLot, locationId uint, ref StockRef) error
+		FindAll(ctx context.Context, teamId, productId uint, pg *helpers.Pagination, f *filter.ProductLotFilter) ([]ProductLotResponse, error)
+		FindById(ctx context.Context, teamId, productId, lotId uint) (*ProductLotResponse, error)
+		// Issue takes body.Quantity out of stock with Allocate in its own transaction.
+		Issue(ctx context.Context, teamId, productId uint, body IssueProductLotRequest) ([]LotAllocation, error)
+		// Allocate takes qty of the product out of stock with Pick.
+		Allocate(ctx context.Context, tx *gorm.DB, teamId, productId uint, qty float64, pick StockPick, ref StockRef) ([]LotAllocation, error)
+		// Pick locks and chooses qty of the product in storage locations lot by lot
+		// first-expired-first-out without taking it, expired lots are never used,
+		// named by pick or not. It fails with ErrProductLotOutOfStock when the
+		// usable stock does not hold qty.
+		Pick(ctx context.Context, tx *gorm.DB, teamId, productId uint, qty float64, pick StockPick) ([]LotAllocation, error)
+		// Restock puts allocated quantities back into their lots, e.g. on a return,
+		// in their location or the default location when it is zero.
+		Restock(ctx context.Context, tx *gorm.DB, teamId uint, allocations []LotAllocation, ref StockRef) error
+		// Adjust adds the allocated quantities to their lots in their location,
+		// taking stock out when negative, e.g. to correct it after a count.
+		Adjust(ctx context.Context, tx *gorm.DB, teamId uint, allocations []LotAllocation, ref StockRef) error
+		// Move moves allocated quantities from their location into toLocationId,
+		// the remaining quantity of the lots stays the same.
+		Move(ctx context.Context, tx *gorm.DB, teamId uint, allocations []LotAllocation, toLocationId uint, ref StockRef) error
+	}
+	productLotService struct {
+		lot       repository.ProductLot
+		lotStock  repository.LotStock
+		product   repository.Product
+		dbTx      repository.DatabaseTransaction
+		code      CodeService
+		ledger    StockLedgerService
+		warehouse WarehouseService
+		valuation ValuationService
+		conf      *config.Config
+		logger    logger.AppLogger
+	}
+
+	ReceiveProductLotRequest struct {
+		// Code is the lot code printed by the supplier, generated when empty.
+		Code            string  `json:"code" binding:"omitempty,max=100"`
+		ManufactureDate string  `json:"manufacture_date" binding:"omitempty,datetime=2006-01-02"`
+		ExpiryDate      string  `json:"expiry_date" binding:"omitempty,datetime=2006-01-02"`
+		Quantity        float64 `json:"quantity" binding:"required,gt=0"`
+		// UnitCost is the purchase cost of one base unit of the lot.
+		UnitCost float64 `json:"unit_cost" binding:"min=0"`
+		// LocationID is the storage location receiving the lot, the default when empty.
+		LocationID uint `json:"location_id"`
+	}
+	IssueProductLotRequest struct {
+		Quantity float64 `json:"quantity" binding:"required,gt=0"`
+		// LocationID limits the issue to a storage location.
+		LocationID uint `json:"location_id"`
+	}
+	// StockPick narrows where Pick takes stock from, zero fields do not.
+	StockPick struct {
+		LocationID   uint
+		ProductLotID uint
+	}
+
+	ProductLotResponse struct {
+		CommonModel
+		ProductID       uint       `json:"product_id"`
+		Code            string     `json:"code"`
+		ManufactureDate *time.Time `json:"manufacture_date"`
+		ExpiryDate      *time.Time `json:"expiry_date"`
+		ReceivedQty     float64    `json:"received_qty"`
+		RemainingQty    float64    `json:"remaining_qty"`
+		UnitCost        float64    `json:"unit_cost"`
+		Expired         bool       `json:"expired"`
+	}
+	// LotAllocation is the quantity Allocate took from one lot in one location.
+	LotAllocation struct {
+		ProductID  uint       `json:"product_id"`
+		LotID      uint       `json:"lot_id"`
+		LocationID uint       `json:"location_id"`
+		Code       string     `json:"code"`
+		ExpiryDate *time.Time `json:"expiry_date"`
+		Quantity   float64    `json:"quantity"`
+		// Cost is the cost of goods of the quantity, set by Allocate.
+		Cost float64 `json:"cost"`
+	}
+)
+
+// Receive implements ProductLotService.
+func (p *productLotService) Receive(ctx context.Context, teamId, productId uint, body ReceiveProductLotRequest) (*ProductLotResponse, error) {
+	manufactureDate, err := parseDate(body.ManufactureDate, p.conf.SERVER.LOCATION)
+	if err != nil {
+		return nil, errs.ErrBadRequest
+	}
+	expiryDate, err := parseDate(body.ExpiryDate, p.conf.SERVER.LOCATION)
+	if err != nil {
+		return nil, errs.ErrBadRequest
+	}
+	if manufactureDate.Valid && expiryDate.Valid && expiryDate.Time.Before(manufactureDate.Time) {
+		return nil, errs.ErrProductLotInvalidDates
+	}
+	tx := p.dbTx.BeginTx()
+	defer p.dbTx.RollbackTx(tx) //nolint:errcheck
+	if err := p.checkProduct(ctx, tx, teamId, productId); err != nil {
+		return nil, err
+	}
+	m := &model.ProductLot{
+		TeamID:          teamId,
+		ProductID:       productId,
+		Code:            body.Code,
+		ManufactureDate: manufactureDate,
+		ExpiryDate:      expiryDate,
+		ReceivedQty:     body.Quantity,
+		RemainingQty:    body.Quantity,
+		UnitCost:        body.UnitCost,
+	}
+	if err := p.CreateLot(ctx, tx, m, body.LocationID, StockRef{Reason: model.StockReasonReceive}); err != nil {
+		return nil, err
+	}
+	if err := p.dbTx.CommitTx(tx); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return toProductLotResponse(m, today(p.conf.SERVER.LOCATION)), nil
+}
+
+// CreateLot implements ProductLotService.
+func (p *productLotService) CreateLot(ctx context.Context, tx *gorm.DB, m *model.ProductLot, locationId uint, ref StockRef) error {
+	location, err := p.warehouse.StorageLocation(ctx, tx, m.TeamID, locationId)
+	if err != nil {
+		return err
+	}
+	if m.Code == "" {
+		code, err := p.code.GenerateLotCode(ctx, tx, m.TeamID, m.ProductID)
+		if err != nil {
+			p.logger.Error(err)
+			return errs.HandleSqlErr(err)
+		}
+		m.Code = code
+	}
+	if err := p.lot.Create(ctx, tx, m); err != nil {
+		p.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	stock := &model.LotStock{
+		TeamID:       m.TeamID,
+		ProductID:    m.ProductID,
+		ProductLotID: m.ID,
+		LocationID:   location.ID,
+	}
+	if err := p.lotStock.Add(ctx, tx, stock, m.RemainingQty); err != nil {
+		p.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	if err := p.ledger.Record(ctx, tx, m.TeamID, m.ProductID, m.ID, location.ID, m.RemainingQty, ref); err != nil {
+		return err
+	}
+	return p.valuation.Receive(ctx, tx, m.TeamID, m.ProductID, m.ID, m.RemainingQty, m.UnitCost, ref)
+}
+
+// FindAll implements ProductLotService.
+func (p *productLotService) FindAll(ctx context.Context, teamId, productId uint, pg *helpers.Pagination, f *filter.ProductLotFilter) ([]ProductLotResponse, error) {
+	if err := p.checkProduct(ctx, nil, teamId, productId); err != nil {
+		return nil, err
+	}
+	now := today(p.conf.SERVER.LOCATION)
+	models, err := p.lot.FindAll(ctx, nil, productId, now, pg, f)
+	if err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	helpers.Paging(pg)
+	res := make([]ProductLotResponse, 0, len(models))
+	for i := range models {
+		res = append(res, *toProductLotResponse(&models[i], now))
+	}
+	return res, nil
+}
+
+// FindById implements ProductLotService.
+func (p *productLotService) FindById(ctx context.Context, teamId, productId, lotId uint) (*ProductLotResponse, error) {
+	if err := p.checkProduct(ctx, nil, teamId, productId); err != nil {
+		return nil, err
+	}
+	m, err := p.lot.FindById(ctx, nil, productId, lotId)
+	if err != nil {
+		p.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrProductLotNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return toProductLotResponse(m, today(p.conf.SERVER.LOCATION)), nil
+}
+
+// Issue implements ProductLotService.
+func (p *productLotService) Issue(ctx context.Context, teamId, productId uint, body IssueProductLotRequest) ([]LotAllocation, error) {
+	tx := p.dbTx.BeginTx()
+	defer p.dbTx.RollbackTx(tx) //nolint:errcheck
+	pick := StockPick{LocationID: body.LocationID}
+	allocations, err := p.Allocate(ctx, tx, teamId, productId, body.Quantity, pick, StockRef{Reason: model.StockReasonIssue})
+	if err != nil {
+		return nil, err
+	}
+	if err := p.dbTx.CommitTx(tx); err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return allocations, nil
+}
+
+// Allocate implements ProductLotService.
+func (p *productLotService) Allocate(ctx context.Context, tx *gorm.DB, teamId, productId uint, qty float64, pick StockPick, ref StockRef) ([]LotAllocation, error) {
+	allocations, err := p.Pick(ctx, tx, teamId, productId, qty, pick)
+	if err != nil {
+		return nil, err
+	}
+	for i, a := range allocations {
+		if err := p.addStock(ctx, tx, teamId, a, -a.Quantity); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errs.ErrProductLotOutOfStock
+			}
+			return nil, errs.HandleSqlErr(err)
+		}
+		if err := p.lot.AddRemaining(ctx, tx, a.LotID, -a.Quantity); err != nil {
+			p.logger.Error(err)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errs.ErrProductLotOutOfStock
+			}
+			return nil, errs.HandleSqlErr(err)
+		}
+		if err := p.ledger.Record(ctx, tx, teamId, productId, a.LotID, a.LocationID, -a.Quantity, ref); err != nil {
+			return nil, err
+		}
+		cost, err := p.valuation.Issue(ctx, tx, teamId, productId, a.LotID, a.Quantity, ref)
+		if err != nil {
+			return nil, err
+		}
+		allocations[i].Cost = cost
+	}
+	return allocations, nil
+}
+
+// Pick implements ProductLotService.
+func (p *productLotService) Pick(ctx context.Context, tx *gorm.DB, teamId, productId uint, qty float64, pick StockPick) ([]LotAllocation, error) {
+	if qty <= 0 {
+		return nil, errs.ErrBadRequest
+	}
+	if err := p.checkProduct(ctx, tx, teamId, productId); err != nil {
+		return nil, err
+	}
+	if pick.LocationID != 0 {
+		if _, err := p.warehouse.StorageLocation(ctx, tx, teamId, pick.LocationID); err != nil {
+			return nil, err
+		}
+	}
+	usableOn := today(p.conf.SERVER.LOCATION)
+	stocks, err := p.lotStock.FindPickable(ctx, tx, productId, &usableOn, pick.LocationID, pick.ProductLotID)
+	if err != nil {
+		p.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	allocations := allocateFefo(stocks, qty)
+	if allocations == nil {
+		return nil, errs.ErrProductLotOutOfStock
+	}
+	return allocations, nil
+}
+
+// Restock implements ProductLotService.
+func (p *productLotService) Restock(ctx context.Context, tx *gorm.DB, teamId uint, allocations []LotAllocation, ref StockRef) error {
+	for _, a := range allocations {
+		location, err := p.warehouse.StorageLocation(ctx, tx, teamId, a.LocationID)
+		if err != nil {
+			return err
+		}
+		a.LocationID = location.ID
+		if err := p.addStock(ctx, tx, teamId, a, a.Quantity); err != nil {
+			return errs.HandleSqlErr(err)
+		}
+		if err := p.lot.AddRemaining(ctx, tx, a.LotID, a.Quantity); err != nil {
+			p.logger.Error(err)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errs.ErrReturnMoreThanLotItem
+			}
+			return errs.HandleSqlErr(err)
+		}
+		if err := p.ledger.Record(ctx, tx, teamId, a.ProductID, a.LotID, a.LocationID, a.Quantity, ref); err != nil {
+			return err
+		}
+		if err := p.valuation.Restore(ctx, tx, teamId, a.ProductID, a.LotID, a.Quantity, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Adjust implements ProductLotService.
+func (p *productLotService) Adjust(ctx context.Context, tx *gorm.DB, teamId uint, allocations []LotAllocation, ref StockRef) error {
+	for _, a := range allocations {
+		if err := p.addStock(ctx, tx, teamId, a, a.Quantity); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errs.ErrProductLotOutOfStock
+			}
+			return errs.HandleSqlErr(err)
+		}
+		if err := p.lot.Adjust(ctx, tx, a.LotID, a.Quantity); err != nil {
+			p.logger.Error(err)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errs.ErrProductLotOutOfStock
+			}
+			return errs.HandleSqlErr(err)
+		}
+		if err := p.ledger.Record(ctx, tx, teamId, a.ProductID, a.LotID, a.LocationID, a.Quantity, ref); err != nil {
+			return err
+		}
+		if a.Quantity < 0 {
+			if _, err := p.valuation.Issue(ctx, tx, teamId, a.ProductID, a.LotID, -a.Quantity, ref); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := p.valuation.Restore(ctx, tx, teamId, a.ProductID, a.LotID, a.Quantity, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Move implements ProductLotService.
+func (p *productLotService) Move(ctx context.Context, tx *gorm.DB, teamId uint, allocations []LotAllocation, toLocationId uint, ref StockRef) error {
+	lotIds := make([]uint, 0, len(allocations))
+	for _, a := range allocations {
+		if err := p.addStock(ctx, tx, teamId, a, -a.Quantity); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errs.ErrProductLotOutOfStock
+			}
+			return errs.HandleSqlErr(err)
+		}
+		to := a
+		to.LocationID = toLocationId
+		if err := p.addStock(ctx, tx, teamId, to, a.Quantity); err != nil {
+			return errs.HandleSqlErr(err)
+		}
+		lotIds = append(lotIds, a.LotID)
+	}
+	// lot stocks first, in the order Pick and Allocate lock them
+	if err := p.lot.Lock(ctx, tx, lotIds...); err != nil {
+		p.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	for _, a := range allocations {
+		if err := p.ledger.Record(ctx, tx, teamId, a.ProductID, a.LotID, a.LocationID, -a.Quantity, ref); err != nil {
+			return err
+		}
+		if err := p.ledger.Record(ctx, tx, teamId, a.ProductID, a.LotID, toLocationId, a.Quantity, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addStock adds delta to the stock of the allocated lot in its location.
+func (p *productLotService) addStock(ctx context.Context, tx *gorm.DB, teamId uint, a LotAllocation, delta float64) error {
+	stock := &model.LotStock{
+		TeamID:       teamId,
+		ProductID:    a.ProductID,
+		ProductLotID: a.LotID,
+		LocationID:   a.LocationID,
+	}
+	if err := p.lotStock.Add(ctx, tx, stock, delta); err != nil {
+		p.logger.Error(err)
+		return err
+	}
+	return nil
+}
+
+// checkProduct makes sure the product belongs to the team.
+func (p *productLotService) checkProduct(ctx context.Context, tx *gorm.DB, teamId, productId uint) error {
+	if _, err := p.product.FindById(ctx, tx, teamId, productId); err != nil {
+		p.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return nil
+}
+
+// allocateFefo takes qty from stocks in their order, which FindPickable sorts
+// first-expired-first-out. It returns nil when the stocks do not hold qty.
+func allocateFefo(stocks []model.LotStock, qty float64) []LotAllocation {
+	var allocations []LotAllocation
+	left := qty
+	for _, stock := range stocks {
+		if left <= 0 {
+			break
+		}
+		take := min(stock.Quantity, left)
+		if take <= 0 || stock.ProductLot == nil {
+			continue
+		}
+		allocations = append(allocations, LotAllocation{
+			ProductID:  stock.ProductID,
+			LotID:      stock.ProductLotID,
+			LocationID: stock.LocationID,
+			Code:       stock.ProductLot.Code,
+			ExpiryDate: nullTimePtr(stock.ProductLot.ExpiryDate),
+			Quantity:   take,
+		})
+		left -= take
+	}
+	if left > 0 {
+		return nil
+	}
+	return allocations
+}
+
+func toProductLotResponse(m *model.ProductLot, today time.Time) *ProductLotResponse {
+	return &ProductLotResponse{
+		CommonModel: CommonModel{
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		},
+		ProductID:       m.ProductID,
+		Code:            m.Code,
+		ManufactureDate: nullTimePtr(m.ManufactureDate),
+		ExpiryDate:      nullTimePtr(m.ExpiryDate),
+		ReceivedQty:     m.ReceivedQty,
+		RemainingQty:    m.RemainingQty,
+		UnitCost:        m.UnitCost,
+		Expired:         m.ExpiryDate.Valid && m.ExpiryDate.Time.Before(today),
+	}
+}
+
+// today is the start of the current day in loc, a lot expiring today is
+// still usable.
+func today(loc *time.Location) time.Time {
+	y, m, d := time.Now().In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// parseDate reads a YYYY-MM-DD date of loc, empty is null.
+func parseDate(s string, loc *time.Location) (sql.NullTime, error) {
+	if s == "" {
+		return sql.NullTime{}, nil
+	}
+	t, err := time.ParseInLocation(time.DateOnly, s, loc)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: t, Valid: true}, nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func NewProductLotService(
+	lot repository.ProductLot,
+	lotStock repository.LotStock,
+	product repository.Product,
+	dbTx repository.DatabaseTransaction,
+	code CodeService,
+	ledger StockLedgerService,
+	warehouse WarehouseService,
+	valuation ValuationService,
+	conf *config.Config,
+	logger logger.AppLogger,
+) ProductLotService {
+	return &productLotService{
+		lot:       lot,
+		lotStock:  lotStock,
+		product:   product,
+		dbTx:      dbTx,
+		code:      code,
+		ledger:    ledger,
+		warehouse: warehouse,
+		valuation: valuation,
+		conf:      conf,
+		logger:    logger,
+	}
+}
@@ -0,0 +1,431 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+type (
+	// SequenceService administers the sequences CodeService counts with, every
+	// change is kept in the audit log.
+	SequenceService interface {
+		// FindAll returns the sequence of every numbered entity type of the team,
+		// and the lot sequences of its products.
+		FindAll(ctx context.Context, teamId uint) ([]SequenceResponse, error)
+		// Set makes Next the next number of the current period, as when moving
+		// from another system. Numbers already taken are refused, see Reset.
+		Set(ctx context.Context, userId, teamId uint, entityType model.EntityType, body SequenceSetRequest) (*SequenceResponse, error)
+		// Reset restarts the sequence at 1. Numbers were taken in the current
+		// period, always so when the scheme never resets, their codes would be
+		// given again and Confirm must be set.
+		Reset(ctx context.Context, userId, teamId uint, entityType model.EntityType, body SequenceResetRequest) (*SequenceResponse, error)
+		// Reserve takes a block of numbers for documents numbered outside the
+		// app, such as offline or bulk imports.
+		Reserve(ctx context.Context, userId, teamId uint, entityType model.EntityType, body SequenceReserveRequest) (*SequenceReserveResponse, error)
+		FindAudits(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.SequenceAuditFilter) ([]SequenceAuditResponse, error)
+	}
+	sequenceService struct {
+		sequence repository.AutoIncrementSequence
+		audit    repository.SequenceAudit
+		scheme   repository.NumberingScheme
+		product  repository.Product
+		dbTx     repository.DatabaseTransaction
+		conf     *config.Config
+		logger   logger.AppLogger
+	}
+
+	SequenceSetRequest struct {
+		// EntityID is the product of a lot sequence.
+		EntityID uint   `json:"entity_id"`
+		Next     uint   `json:"next" binding:"required,min=1"`
+		Note     string `json:"note" binding:"max=255"`
+	}
+	SequenceResetRequest struct {
+		EntityID uint `json:"entity_id"`
+		// Confirm accepts giving the codes of the current period again.
+		Confirm bool   `json:"confirm"`
+		Note    string `json:"note" binding:"max=255"`
+	}
+	SequenceReserveRequest struct {
+		EntityID uint   `json:"entity_id"`
+		Count    uint   `json:"count" binding:"required,min=1,max=1000"`
+		Note     string `json:"note" binding:"max=255"`
+	}
+
+	SequenceResponse struct {
+		EntityType model.EntityType `json:"entity_type"`
+		EntityID   uint             `json:"entity_id"`
+		// Sequence is the last number taken in Period.
+		Sequence uint   `json:"sequence"`
+		Period   string `json:"period"`
+		// Next is the number of the next code, NextCode, in the current period.
+		CurrentPeriod string     `json:"current_period"`
+		Next          uint       `json:"next"`
+		NextCode      string     `json:"next_code"`
+		UpdatedAt     *time.Time `json:"updated_at"`
+	}
+	SequenceReserveResponse struct {
+		EntityType model.EntityType `json:"entity_type"`
+		EntityID   uint             `json:"entity_id"`
+		Period     string           `json:"period"`
+		From       uint             `json:"from"`
+		To         uint             `json:"to"`
+		Codes      []string         `json:"codes"`
+	}
+	SequenceAuditResponse struct {
+		CommonModel
+		EntityType       model.EntityType `json:"entity_type"`
+		EntityID         uint             `json:"entity_id"`
+		Action           string           `json:"action"`
+		PreviousSequence uint             `json:"previous_sequence"`
+		PreviousPeriod   string           `json:"previous_period"`
+		Sequence         uint             `json:"sequence"`
+		Period           string           `json:"period"`
+		Note             string           `json:"note"`
+		CreatedByID      uint             `json:"created_by_id"`
+		CreatedByName    string           `json:"created_by_name"`
+	}
+
+	// sequenceKey is a sequence of a team with the scheme numbering it.
+	sequenceKey struct {
+		entityType model.EntityType
+		teamId     uint
+		entityId   uint
+		scheme     model.NumberingScheme
+		template   numberingTemplate
+		location   *time.Location
+	}
+)
+
+// FindAll implements SequenceService.
+func (s *sequenceService) FindAll(ctx context.Context, teamId uint) ([]SequenceResponse, error) {
+	sequences, err := s.sequence.FindAllByTeam(ctx, nil, teamId)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	now := time.Now()
+	res := make([]SequenceResponse, 0, len(numberedEntityTypes)+len(sequences))
+	for _, entityType := range numberedEntityTypes {
+		key, err := s.key(ctx, teamId, entityType, 0)
+		if err != nil {
+			return nil, err
+		}
+		if entityType == model.EntityTypeLot {
+			for _, m := range sequences {
+				if m.EntityType != model.EntityTypeLot {
+					continue
+				}
+				key.entityId = m.EntityID
+				res = append(res, key.response(&m, now))
+			}
+			continue
+		}
+		seqTeam, seqEntity := sequenceOwner(entityType, teamId, 0)
+		i := slices.IndexFunc(sequences, func(m model.AutoIncrementSequence) bool {
+			return m.EntityType == entityType && m.TeamID == seqTeam && m.EntityID == seqEntity
+		})
+		if i < 0 {
+			res = append(res, key.response(nil, now))
+			continue
+		}
+		res = append(res, key.response(&sequences[i], now))
+	}
+	return res, nil
+}
+
+// Set implements SequenceService.
+func (s *sequenceService) Set(ctx context.Context, userId, teamId uint, entityType model.EntityType, body SequenceSetRequest) (*SequenceResponse, error) {
+	key, err := s.changeKey(ctx, teamId, entityType, body.EntityID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	period := numberingPeriod(key.scheme, now, key.location)
+	tx := s.dbTx.BeginTx()
+	defer s.dbTx.RollbackTx(tx) //nolint:errcheck
+	current, err := key.lock(ctx, tx, s.sequence)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if current.Period == period && body.Next <= current.Sequence {
+		return nil, errs.ErrSequenceTaken
+	}
+	seqTeam, seqEntity := key.owner()
+	if err := s.sequence.SetSequence(ctx, tx, entityType, seqTeam, seqEntity, body.Next-1, period); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	audit := key.audit(userId, model.SequenceActionSet, current, body.Next-1, period, body.Note)
+	if err := s.audit.Create(ctx, tx, audit); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := s.dbTx.CommitTx(tx); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	res := key.response(&model.AutoIncrementSequence{Sequence: body.Next - 1, Period: period}, now)
+	return &res, nil
+}
+
+// Reset implements SequenceService.
+func (s *sequenceService) Reset(ctx context.Context, userId, teamId uint, entityType model.EntityType, body SequenceResetRequest) (*SequenceResponse, error) {
+	key, err := s.changeKey(ctx, teamId, entityType, body.EntityID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tx := s.dbTx.BeginTx()
+	defer s.dbTx.RollbackTx(tx) //nolint:errcheck
+	current, err := key.lock(ctx, tx, s.sequence)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	// the codes of the period are unique, a new one restarts clear of them
+	if current.Sequence > 0 && current.Period == numberingPeriod(key.scheme, now, key.location) && !body.Confirm {
+		return nil, errs.ErrSequenceResetConfirm
+	}
+	seqTeam, seqEntity := key.owner()
+	if err := s.sequence.ResetSequence(ctx, tx, entityType, seqTeam, seqEntity); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	audit := key.audit(userId, model.SequenceActionReset, current, 0, current.Period, body.Note)
+	if err := s.audit.Create(ctx, tx, audit); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := s.dbTx.CommitTx(tx); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	res := key.response(&model.AutoIncrementSequence{Period: current.Period}, now)
+	return &res, nil
+}
+
+// Reserve implements SequenceService.
+func (s *sequenceService) Reserve(ctx context.Context, userId, teamId uint, entityType model.EntityType, body SequenceReserveRequest) (*SequenceReserveResponse, error) {
+	key, err := s.changeKey(ctx, teamId, entityType, body.EntityID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	period := numberingPeriod(key.scheme, now, key.location)
+	tx := s.dbTx.BeginTx()
+	defer s.dbTx.RollbackTx(tx) //nolint:errcheck
+	current, err := key.lock(ctx, tx, s.sequence)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	seqTeam, seqEntity := key.owner()
+	last, err := s.sequence.ReserveSequence(ctx, tx, entityType, seqTeam, seqEntity, body.Count, period)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	audit := key.audit(userId, model.SequenceActionReserve, current, last, period, body.Note)
+	if err := s.audit.Create(ctx, tx, audit); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := s.dbTx.CommitTx(tx); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	res := &SequenceReserveResponse{
+		EntityType: entityType,
+		EntityID:   key.entityId,
+		Period:     period,
+		From:       last - body.Count + 1,
+		To:         last,
+		Codes:      make([]string, 0, body.Count),
+	}
+	for sequence := res.From; sequence <= res.To; sequence++ {
+		res.Codes = append(res.Codes, key.render(now, sequence))
+	}
+	return res, nil
+}
+
+// FindAudits implements SequenceService.
+func (s *sequenceService) FindAudits(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.SequenceAuditFilter) ([]SequenceAuditResponse, error) {
+	audits, err := s.audit.FindAll(ctx, nil, teamId, pg, f)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	helpers.Paging(pg)
+	res := make([]SequenceAuditResponse, 0, len(audits))
+	for i := range audits {
+		res = append(res, toSequenceAuditResponse(&audits[i]))
+	}
+	return res, nil
+}
+
+// key resolves the sequence of the entity type, entityId is the product of
+// lots and must be of the team.
+func (s *sequenceService) key(ctx context.Context, teamId uint, entityType model.EntityType, entityId uint) (*sequenceKey, error) {
+	if !slices.Contains(numberedEntityTypes, entityType) {
+		return nil, errs.ErrNumberingEntityType
+	}
+	if entityType != model.EntityTypeLot {
+		entityId = 0
+	} else if entityId != 0 {
+		if _, err := s.product.FindById(ctx, nil, teamId, entityId); err != nil {
+			s.logger.Error(err)
+			return nil, errs.HandleSqlErr(err)
+		}
+	}
+	scheme, err := findNumberingScheme(ctx, s.scheme, teamId, entityType)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	tpl, err := parseNumberingTemplate(scheme.Template)
+	if err != nil {
+		return nil, err
+	}
+	return &sequenceKey{
+		entityType: entityType,
+		teamId:     teamId,
+		entityId:   entityId,
+		scheme:     scheme,
+		template:   tpl,
+		location:   s.conf.SERVER.LOCATION,
+	}, nil
+}
+
+// changeKey is key for a change of the sequence, which of a lot sequence
+// the product tells.
+func (s *sequenceService) changeKey(ctx context.Context, teamId uint, entityType model.EntityType, entityId uint) (*sequenceKey, error) {
+	if entityType == model.EntityTypeLot && entityId == 0 {
+		return nil, errs.ErrSequenceLotProduct
+	}
+	return s.key(ctx, teamId, entityType, entityId)
+}
+
+func (k *sequenceKey) owner() (uint, uint) {
+	return sequenceOwner(k.entityType, k.teamId, k.entityId)
+}
+
+// lock returns the sequence locked until tx ends, empty before its first number.
+func (k *sequenceKey) lock(ctx context.Context, tx *gorm.DB, sequences repository.AutoIncrementSequence) (model.AutoIncrementSequence, error) {
+	if k.entityType == model.EntityTypeLot && k.entityId == 0 {
+		return model.AutoIncrementSequence{}, errs.ErrBadRequest
+	}
+	seqTeam, seqEntity := k.owner()
+	m, err := sequences.FindSequenceForUpdate(ctx, tx, k.entityType, seqTeam, seqEntity)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.AutoIncrementSequence{}, nil
+	}
+	if err != nil {
+		return model.AutoIncrementSequence{}, err
+	}
+	return *m, nil
+}
+
+func (k *sequenceKey) render(at time.Time, sequence uint) string {
+	return k.template.render(numberingValues{
+		At:              at,
+		Location:        k.location,
+		FiscalYearStart: k.scheme.FiscalYearStart,
+		EntityType:      k.entityType,
+		TeamID:          k.teamId,
+		EntityID:        k.entityId,
+		Sequence:        sequence,
+	})
+}
+
+func (k *sequenceKey) audit(userId uint, action string, previous model.AutoIncrementSequence, sequence uint, period, note string) *model.SequenceAudit {
+	return &model.SequenceAudit{
+		TeamID:           k.teamId,
+		EntityType:       k.entityType,
+		EntityID:         k.entityId,
+		Action:           action,
+		PreviousSequence: previous.Sequence,
+		PreviousPeriod:   previous.Period,
+		Sequence:         sequence,
+		Period:           period,
+		Note:             note,
+		CreatedByID:      userId,
+	}
+}
+
+// response shows m, nil before the first number of the sequence.
+func (k *sequenceKey) response(m *model.AutoIncrementSequence, at time.Time) SequenceResponse {
+	res := SequenceResponse{
+		EntityType:    k.entityType,
+		EntityID:      k.entityId,
+		CurrentPeriod: numberingPeriod(k.scheme, at, k.location),
+		Next:          1,
+	}
+	if m != nil {
+		res.Sequence = m.Sequence
+		res.Period = m.Period
+		if !m.UpdatedAt.IsZero() {
+			res.UpdatedAt = &m.UpdatedAt
+		}
+		if m.Period == res.CurrentPeriod {
+			res.Next = m.Sequence + 1
+		}
+	}
+	res.NextCode = k.render(at, res.Next)
+	return res
+}
+
+func toSequenceAuditResponse(m *model.SequenceAudit) SequenceAuditResponse {
+	res := SequenceAuditResponse{
+		CommonModel: CommonModel{
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		},
+		EntityType:       m.EntityType,
+		EntityID:         m.EntityID,
+		Action:           m.Action,
+		PreviousSequence: m.PreviousSequence,
+		PreviousPeriod:   m.PreviousPeriod,
+		Sequence:         m.Sequence,
+		Period:           m.Period,
+		Note:             m.Note,
+		CreatedByID:      m.CreatedByID,
+	}
+	if m.CreatedBy != nil {
+		res.CreatedByName = userDisplayName(m.CreatedBy)
+	}
+	return res
+}
+
+func NewSequenceService(
+	sequence repository.AutoIncrementSequence,
+	audit repository.SequenceAudit,
+	scheme repository.NumberingScheme,
+	product repository.Product,
+	dbTx repository.DatabaseTransaction,
+	conf *config.Config,
+	logger logger.AppLogger,
+) SequenceService {
+	return &sequenceService{
+		sequence: sequence,
+		audit:    audit,
+		scheme:   scheme,
+		product:  product,
+		dbTx:     dbTx,
+		conf:     conf,
+		logger:   logger,
+	}
+}
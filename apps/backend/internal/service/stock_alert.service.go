@@ -0,0 +1,485 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+type (
+	// StockAlertService raises alerts about low stock and expiring lots from the
+	// alert rules of a team and emails a daily digest of them to subscribers.
+	StockAlertService interface {
+		FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.AlertFilter) ([]AlertResponse, error)
+		FindById(ctx context.Context, teamId, alertId uint) (*AlertResponse, error)
+		// Acknowledge keeps the alert out of the digest until it is resolved.
+		Acknowledge(ctx context.Context, userId, teamId, alertId uint) (*AlertResponse, error)
+		// Snooze keeps the alert out of the digest for the days of the request.
+		Snooze(ctx context.Context, teamId, alertId uint, body SnoozeAlertRequest) (*AlertResponse, error)
+
+		// FindRules returns a rule of every alert type, disabled for types the
+		// team has not set up.
+		FindRules(ctx context.Context, teamId uint) ([]AlertRuleResponse, error)
+		SaveRule(ctx context.Context, teamId uint, alertType string, body AlertRuleRequest) (*AlertRuleResponse, error)
+
+		Subscribe(ctx context.Context, userId, teamId uint) (*AlertSubscriptionResponse, error)
+		Unsubscribe(ctx context.Context, userId, teamId uint) (*AlertSubscriptionResponse, error)
+		Subscription(ctx context.Context, userId, teamId uint) (*AlertSubscriptionResponse, error)
+
+		// Evaluate raises the alerts of the enabled rules of the team and resolves
+		// the alerts whose condition no longer holds, zero teamId evaluates every
+		// team with a rule.
+		Evaluate(ctx context.Context, teamId uint) (*AlertEvaluationResponse, error)
+		// SendDigests emails the open alerts of their team to the subscribers
+		// without a digest today, once it is past the digest hour. It returns the
+		// number of emails sent.
+		SendDigests(ctx context.Context, now time.Time) (int, error)
+	}
+	stockAlertService struct {
+		rule       repository.AlertRule
+		alert      repository.Alert
+		subscriber repository.AlertSubscriber
+		dbTx       repository.DatabaseTransaction
+		email      Email
+		conf       *config.Config
+		logger     logger.AppLogger
+	}
+
+	AlertRuleRequest struct {
+		// Days is the window of expiring alerts, e.g. 30 for lots expiring within 30 days.
+		Days    int  `json:"days" binding:"min=0,max=3650"`
+		Enabled bool `json:"enabled"`
+	}
+	SnoozeAlertRequest struct {
+		Days int `json:"days" binding:"required,min=1,max=365"`
+	}
+
+	AlertResponse struct {
+		CommonModel
+		TeamID           uint                `json:"team_id"`
+		RuleID           uint                `json:"rule_id"`
+		Type             string              `json:"type"`
+		Status           string              `json:"status"`
+		ProductID        uint                `json:"product_id"`
+		ProductCode      string              `json:"product_code"`
+		ProductName      string              `json:"product_name"`
+		UOM              string              `json:"uom"`
+		ProductLotID     *uint               `json:"product_lot_id"`
+		ProductLot       *ProductLotResponse `json:"product_lot"`
+		Quantity         float64             `json:"quantity"`
+		Threshold        float64             `json:"threshold"`
+		ExpiryDate       *time.Time          `json:"expiry_date"`
+		LastSeenAt       time.Time           `json:"last_seen_at"`
+		Snoozed          bool                `json:"snoozed"`
+		SnoozedUntil     *time.Time          `json:"snoozed_until"`
+		AcknowledgedByID *uint               `json:"acknowledged_by_id"`
+		AcknowledgedAt   *time.Time          `json:"acknowledged_at"`
+		ResolvedAt       *time.Time          `json:"resolved_at"`
+	}
+	AlertRuleResponse struct {
+		TeamID  uint   `json:"team_id"`
+		Type    string `json:"type"`
+		Days    int    `json:"days"`
+		Enabled bool   `json:"enabled"`
+	}
+	AlertSubscriptionResponse struct {
+		TeamID     uint `json:"team_id"`
+		UserID     uint `json:"user_id"`
+		Subscribed bool `json:"subscribed"`
+	}
+	AlertEvaluationResponse struct {
+		Teams    int   `json:"teams"`
+		Active   int   `json:"active"`   // alerts raised or still holding
+		Resolved int64 `json:"resolved"` // alerts resolved by this evaluation
+	}
+)
+
+// alertTypes are the alert types in the order rules are listed.
+var alertTypes = []string{model.AlertTypeLowStock, model.AlertTypeExpiring, model.AlertTypeExpired}
+
+// FindAll implements StockAlertService.
+func (s *stockAlertService) FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.AlertFilter) ([]AlertResponse, error) {
+	now := time.Now()
+	alerts, err := s.alert.FindAll(ctx, nil, teamId, now, pg, f)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	helpers.Paging(pg)
+	res := make([]AlertResponse, 0, len(alerts))
+	for i := range alerts {
+		res = append(res, *toAlertResponse(&alerts[i], now, s.conf.SERVER.LOCATION))
+	}
+	return res, nil
+}
+
+// FindById implements StockAlertService.
+func (s *stockAlertService) FindById(ctx context.Context, teamId, alertId uint) (*AlertResponse, error) {
+	m, err := s.alert.FindById(ctx, nil, teamId, alertId)
+	if err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrAlertNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return toAlertResponse(m, time.Now(), s.conf.SERVER.LOCATION), nil
+}
+
+// Acknowledge implements StockAlertService.
+func (s *stockAlertService) Acknowledge(ctx context.Context, userId, teamId, alertId uint) (*AlertResponse, error) {
+	now := time.Now()
+	return s.update(ctx, teamId, alertId, map[string]any{
+		"status":             model.AlertStatusAcknowledged,
+		"acknowledged_by_id": userId,
+		"acknowledged_at":    sql.NullTime{Time: now, Valid: true},
+	})
+}
+
+// Snooze implements StockAlertService.
+func (s *stockAlertService) Snooze(ctx context.Context, teamId, alertId uint, body SnoozeAlertRequest) (*AlertResponse, error) {
+	until := time.Now().AddDate(0, 0, body.Days)
+	return s.update(ctx, teamId, alertId, map[string]any{
+		"snoozed_until": sql.NullTime{Time: until, Valid: true},
+	})
+}
+
+// update changes the unresolved alert and returns it.
+func (s *stockAlertService) update(ctx context.Context, teamId, alertId uint, updates map[string]any) (*AlertResponse, error) {
+	if err := s.alert.Update(ctx, nil, teamId, alertId, updates); err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrAlertNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return s.FindById(ctx, teamId, alertId)
+}
+
+// FindRules implements StockAlertService.
+func (s *stockAlertService) FindRules(ctx context.Context, teamId uint) ([]AlertRuleResponse, error) {
+	rules, err := s.rule.FindByTeam(ctx, nil, teamId)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	res := make([]AlertRuleResponse, 0, len(alertTypes))
+	for _, t := range alertTypes {
+		r := AlertRuleResponse{TeamID: teamId, Type: t}
+		if i := slices.IndexFunc(rules, func(m model.AlertRule) bool { return m.Type == t }); i >= 0 {
+			r.Days = rules[i].Days
+			r.Enabled = rules[i].Enabled
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// SaveRule implements StockAlertService.
+func (s *stockAlertService) SaveRule(ctx context.Context, teamId uint, alertType string, body AlertRuleRequest) (*AlertRuleResponse, error) {
+	if !slices.Contains(alertTypes, alertType) {
+		return nil, errs.ErrAlertRuleType
+	}
+	m := &model.AlertRule{
+		TeamID:  teamId,
+		Type:    alertType,
+		Days:    body.Days,
+		Enabled: body.Enabled,
+	}
+	if err := s.rule.Save(ctx, nil, m); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return &AlertRuleResponse{
+		TeamID:  m.TeamID,
+		Type:    m.Type,
+		Days:    m.Days,
+		Enabled: m.Enabled,
+	}, nil
+}
+
+// Subscribe implements StockAlertService.
+func (s *stockAlertService) Subscribe(ctx context.Context, userId, teamId uint) (*AlertSubscriptionResponse, error) {
+	if err := s.subscriber.Subscribe(ctx, nil, teamId, userId); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return s.Subscription(ctx, userId, teamId)
+}
+
+// Unsubscribe implements StockAlertService.
+func (s *stockAlertService) Unsubscribe(ctx context.Context, userId, teamId uint) (*AlertSubscriptionResponse, error) {
+	if err := s.subscriber.Unsubscribe(ctx, nil, teamId, userId); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return s.Subscription(ctx, userId, teamId)
+}
+
+// Subscription implements StockAlertService.
+func (s *stockAlertService) Subscription(ctx context.Context, userId, teamId uint) (*AlertSubscriptionResponse, error) {
+	ok, err := s.subscriber.Exists(ctx, nil, teamId, userId)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return &AlertSubscriptionResponse{TeamID: teamId, UserID: userId, Subscribed: ok}, nil
+}
+
+// Evaluate implements StockAlertService.
+func (s *stockAlertService) Evaluate(ctx context.Context, teamId uint) (*AlertEvaluationResponse, error) {
+	teamIds := []uint{teamId}
+	if teamId == 0 {
+		var err error
+		if teamIds, err = s.rule.FindTeams(ctx, nil); err != nil {
+			s.logger.Error(err)
+			return nil, errs.HandleSqlErr(err)
+		}
+	}
+	res := &AlertEvaluationResponse{}
+	for _, id := range teamIds {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		active, resolved, err := s.evaluateTeam(ctx, id)
+		if err != nil {
+			return res, err
+		}
+		res.Teams++
+		res.Active += active
+		res.Resolved += resolved
+	}
+	return res, nil
+}
+
+// evaluateTeam raises the alerts of the team in one transaction, alerts not
+// raised again are resolved.
+func (s *stockAlertService) evaluateTeam(ctx context.Context, teamId uint) (int, int64, error) {
+	tx := s.dbTx.BeginTx()
+	defer s.dbTx.RollbackTx(tx) //nolint:errcheck
+	rules, err := s.rule.FindByTeam(ctx, tx, teamId)
+	if err != nil {
+		s.logger.Error(err)
+		return 0, 0, errs.HandleSqlErr(err)
+	}
+	seenAt := time.Now()
+	day := today(s.conf.SERVER.LOCATION)
+	var alerts []model.Alert
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+		var candidates []repository.AlertCandidate
+		switch rule.Type {
+		case model.AlertTypeLowStock:
+			candidates, err = s.alert.FindLowStock(ctx, tx, teamId)
+		case model.AlertTypeExpiring:
+			candidates, err = s.alert.FindExpiring(ctx, tx, teamId, day, day.AddDate(0, 0, rule.Days+1))
+		case model.AlertTypeExpired:
+			candidates, err = s.alert.FindExpiring(ctx, tx, teamId, time.Time{}, day)
+		}
+		if err != nil {
+			s.logger.Error(err)
+			return 0, 0, errs.HandleSqlErr(err)
+		}
+		for _, c := range candidates {
+			alerts = append(alerts, model.Alert{
+				TeamID:       teamId,
+				Key:          alertKey(rule.Type, c),
+				RuleID:       rule.ID,
+				Type:         rule.Type,
+				Status:       model.AlertStatusOpen,
+				ProductID:    c.ProductID,
+				ProductLotID: c.ProductLotID,
+				Quantity:     c.Quantity,
+				Threshold:    c.Threshold,
+				ExpiryDate:   c.ExpiryDate,
+				LastSeenAt:   seenAt,
+			})
+		}
+	}
+	if err := s.alert.Raise(ctx, tx, alerts); err != nil {
+		s.logger.Error(err)
+		return 0, 0, errs.HandleSqlErr(err)
+	}
+	resolved, err := s.alert.ResolveUnseen(ctx, tx, teamId, seenAt)
+	if err != nil {
+		s.logger.Error(err)
+		return 0, 0, errs.HandleSqlErr(err)
+	}
+	if err := s.dbTx.CommitTx(tx); err != nil {
+		s.logger.Error(err)
+		return 0, 0, errs.HandleSqlErr(err)
+	}
+	return len(alerts), resolved, nil
+}
+
+// SendDigests implements StockAlertService.
+func (s *stockAlertService) SendDigests(ctx context.Context, now time.Time) (int, error) {
+	now = now.In(s.conf.SERVER.LOCATION)
+	if now.Hour() < s.conf.ALERT.DIGEST_HOUR {
+		return 0, nil
+	}
+	y, m, d := now.Date()
+	dayStart := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	subscribers, err := s.subscriber.FindDue(ctx, nil, dayStart)
+	if err != nil {
+		s.logger.Error(err)
+		return 0, errs.HandleSqlErr(err)
+	}
+	digests := map[uint]*StockAlertDigestDto{}
+	sent := 0
+	for _, sub := range subscribers {
+		if err := ctx.Err(); err != nil {
+			return sent, err
+		}
+		body, ok := digests[sub.TeamID]
+		if !ok {
+			alerts, err := s.alert.FindDigest(ctx, nil, sub.TeamID, now)
+			if err != nil {
+				s.logger.Error(err)
+				return sent, errs.HandleSqlErr(err)
+			}
+			body = s.digest(sub.TeamID, now, alerts)
+			digests[sub.TeamID] = body
+		}
+		// a day without open alerts counts as digested, nothing is sent
+		if body != nil && sub.User != nil {
+			if err := s.email.NewRequest([]string{sub.User.Email}, fmt.Sprintf("แจ้งเตือนสต็อกประจำวันที่ %s", body.Date)).
+				ParseStockAlertDigestTemplate(ctx, body).
+				SendMail(ctx); err != nil {
+				// not marked, the next run retries
+				s.logger.Error(err)
+				continue
+			}
+			sent++
+		}
+		if err := s.subscriber.MarkSent(ctx, nil, sub.ID, now); err != nil {
+			s.logger.Error(err)
+			return sent, errs.HandleSqlErr(err)
+		}
+	}
+	return sent, nil
+}
+
+// digest is the digest email of the alerts of the team, nil without alerts.
+func (s *stockAlertService) digest(teamId uint, now time.Time, alerts []model.Alert) *StockAlertDigestDto {
+	if len(alerts) == 0 {
+		return nil
+	}
+	body := &StockAlertDigestDto{
+		Team: strconv.FormatUint(uint64(teamId), 10),
+		Date: now.Format("02/01/2006"),
+		URL:  fmt.Sprintf("%s/api/v1/teams/%d/alerts", s.conf.SERVER.HOST_NAME, teamId),
+	}
+	for _, a := range alerts {
+		item := StockAlertDigestItemDto{
+			Type:     alertTypeLabel(a.Type),
+			Product:  strconv.FormatUint(uint64(a.ProductID), 10),
+			Quantity: strconv.FormatFloat(a.Quantity, 'f', -1, 64),
+		}
+		if a.Product != nil {
+			item.Product = fmt.Sprintf("%s %s", a.Product.Code, a.Product.Name)
+			item.Quantity += " " + a.Product.UOM
+		}
+		if a.ProductLot != nil {
+			item.Lot = a.ProductLot.Code
+		}
+		if a.Type == model.AlertTypeLowStock {
+			item.Threshold = strconv.FormatFloat(a.Threshold, 'f', -1, 64)
+		}
+		if a.ExpiryDate.Valid {
+			item.ExpiryDate = a.ExpiryDate.Time.Format("02/01/2006")
+		}
+		body.Alerts = append(body.Alerts, item)
+	}
+	return body
+}
+
+// alertKey identifies what an alert of alertType is about, the product for
+// low stock and the lot otherwise.
+func alertKey(alertType string, c repository.AlertCandidate) string {
+	if c.ProductLotID != nil {
+		return fmt.Sprintf("%s:%d", alertType, *c.ProductLotID)
+	}
+	return fmt.Sprintf("%s:%d", alertType, c.ProductID)
+}
+
+func alertTypeLabel(alertType string) string {
+	switch alertType {
+	case model.AlertTypeLowStock:
+		return "สต็อกต่ำกว่าจุดสั่งซื้อ"
+	case model.AlertTypeExpiring:
+		return "ใกล้หมดอายุ"
+	case model.AlertTypeExpired:
+		return "หมดอายุแล้ว"
+	}
+	return alertType
+}
+
+func toAlertResponse(m *model.Alert, now time.Time, loc *time.Location) *AlertResponse {
+	res := &AlertResponse{
+		CommonModel: CommonModel{
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		},
+		TeamID:           m.TeamID,
+		RuleID:           m.RuleID,
+		Type:             m.Type,
+		Status:           m.Status,
+		ProductID:        m.ProductID,
+		ProductLotID:     m.ProductLotID,
+		Quantity:         m.Quantity,
+		Threshold:        m.Threshold,
+		ExpiryDate:       nullTimePtr(m.ExpiryDate),
+		LastSeenAt:       m.LastSeenAt,
+		Snoozed:          m.SnoozedUntil.Valid && m.SnoozedUntil.Time.After(now),
+		SnoozedUntil:     nullTimePtr(m.SnoozedUntil),
+		AcknowledgedByID: m.AcknowledgedByID,
+		AcknowledgedAt:   nullTimePtr(m.AcknowledgedAt),
+		ResolvedAt:       nullTimePtr(m.ResolvedAt),
+	}
+	if m.Product != nil {
+		res.ProductCode = m.Product.Code
+		res.ProductName = m.Product.Name
+		res.UOM = m.Product.UOM
+	}
+	if m.ProductLot != nil {
+		res.ProductLot = toProductLotResponse(m.ProductLot, today(loc))
+	}
+	return res
+}
+
+func NewStockAlertService(
+	rule repository.AlertRule,
+	alert repository.Alert,
+	subscriber repository.AlertSubscriber,
+	dbTx repository.DatabaseTransaction,
+	email Email,
+	conf *config.Config,
+	logger logger.AppLogger,
+) StockAlertService {
+	return &stockAlertService{
+		rule:       rule,
+		alert:      alert,
+		subscriber: subscriber,
+		dbTx:       dbTx,
+		email:      email,
+		conf:       conf,
+		logger:     logger,
+	}
+}
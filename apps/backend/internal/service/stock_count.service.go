@@ -0,0 +1,691 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+// stockCountTransitions lists for every status the statuses it can be reached from.
+var stockCountTransitions = map[string][]string{
+	model.StockCountStatusCounting:  {model.StockCountStatusSubmitted},
+	model.StockCountStatusSubmitted: {model.StockCountStatusCounting},
+	model.StockCountStatusPosted:    {model.StockCountStatusSubmitted},
+	model.StockCountStatusCancelled: {model.StockCountStatusCounting, model.StockCountStatusSubmitted},
+}
+
+// stockCountSheetHeader are the columns of the count sheet, an uploaded sheet
+// needs product_code, lot_code and counted_qty in any order.
+var stockCountSheetHeader = []string{"product_code", "product_name", "lot_code", "expiry_date", "counted_qty", "reason_code", "note"}
+
+type (
+	// StockCountService runs physical counts of a location:
+	//
+	//	counting <-> submitted -> posted
+	//	    |            |
+	//	    +------------+-> cancelled
+	//
+	// Creating a count snapshots the stock of the location. Approving posts the
+	// variance of every counted lot against the snapshot as a StockAdjustment,
+	// so stock moved while counting is kept.
+	StockCountService interface {
+		Create(ctx context.Context, userId, teamId uint, body CreateStockCountRequest) (*StockCountResponse, error)
+		FindById(ctx context.Context, teamId, countId uint) (*StockCountResponse, error)
+		FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.StockCountFilter) ([]StockCountResponse, error)
+		// Count records counted quantities, lots not in the snapshot are added.
+		Count(ctx context.Context, teamId, countId uint, body CountStockRequest) (*StockCountResponse, error)
+		// Import records the counted quantities of an uploaded count sheet.
+		Import(ctx context.Context, teamId, countId uint, r io.Reader) (*StockCountResponse, error)
+		// Sheet is the count sheet of the count to fill in, without the expected quantities.
+		Sheet(ctx context.Context, teamId, countId uint) (*StockCountSheet, error)
+		Submit(ctx context.Context, userId, teamId, countId uint) (*StockCountResponse, error)
+		// Reopen takes a submitted count back to counting.
+		Reopen(ctx context.Context, userId, teamId, countId uint) (*StockCountResponse, error)
+		// Approve posts the variances, every lot with one needs a reason code.
+		Approve(ctx context.Context, userId, teamId, countId uint) (*StockCountResponse, error)
+		Cancel(ctx context.Context, userId, teamId, countId uint) (*StockCountResponse, error)
+
+		FindAdjustmentById(ctx context.Context, teamId, adjustmentId uint) (*StockAdjustmentResponse, error)
+		FindAdjustments(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.StockAdjustmentFilter) ([]StockAdjustmentResponse, error)
+	}
+	stockCountService struct {
+		count      repository.StockCount
+		adjustment repository.StockAdjustment
+		lotStock   repository.LotStock
+		lotRepo    repository.ProductLot
+		product    repository.Product
+		dbTx       repository.DatabaseTransaction
+		code       CodeService
+		lot        ProductLotService
+		warehouse  WarehouseService
+		excel      Excel
+		logger     logger.AppLogger
+	}
+
+	CreateStockCountRequest struct {
+		LocationID uint `json:"location_id" binding:"required"`
+		// ProductIDs limits the count to these products, every product when empty.
+		ProductIDs []uint `json:"product_ids"`
+		Note       string `json:"note"`
+	}
+	CountStockItemRequest struct {
+		ProductLotID uint     `json:"product_lot_id" binding:"required"`
+		CountedQty   *float64 `json:"counted_qty" binding:"required,min=0"`
+		ReasonCode   string   `json:"reason_code" binding:"omitempty,oneof=damaged lost found expired miscount other"`
+		Note         string   `json:"note"`
+	}
+	CountStockRequest struct {
+		Items []CountStockItemRequest `json:"items" binding:"required,min=1,dive"`
+	}
+
+	StockCountSheet struct {
+		Filename string
+		Content  *bytes.Buffer
+	}
+
+	StockCountResponse struct {
+		CommonModel
+		TeamID       uint                     `json:"team_id"`
+		Code         string                   `json:"code"`
+		Status       string                   `json:"status"`
+		LocationID   uint                     `json:"location_id"`
+		LocationCode string                   `json:"location_code"`
+		Note         string                   `json:"note"`
+		CreatedByID  uint                     `json:"created_by_id"`
+		SnapshotAt   *time.Time               `json:"snapshot_at"`
+		ApprovedByID *uint                    `json:"approved_by_id"`
+		ApprovedAt   *time.Time               `json:"approved_at"`
+		Items        []StockCountItemResponse `json:"items"`
+	}
+	StockCountItemResponse struct {
+		CommonModel
+		ProductID    uint     `json:"product_id"`
+		ProductCode  string   `json:"product_code"`
+		ProductName  string   `json:"product_name"`
+		ProductLotID uint     `json:"product_lot_id"`
+		LotCode      string   `json:"lot_code"`
+		ExpectedQty  float64  `json:"expected_qty"`
+		CountedQty   *float64 `json:"counted_qty"`
+		// Variance is counted minus expected, null until counted.
+		Variance   *float64 `json:"variance"`
+		ReasonCode string   `json:"reason_code"`
+		Note       string   `json:"note"`
+	}
+
+	StockAdjustmentResponse struct {
+		CommonModel
+		TeamID       uint                          `json:"team_id"`
+		Code         string                        `json:"code"`
+		StockCountID *uint                         `json:"stock_count_id"`
+		LocationID   uint                          `json:"location_id"`
+		LocationCode string                        `json:"location_code"`
+		Note         string                        `json:"note"`
+		CreatedByID  uint                          `json:"created_by_id"`
+		Items        []StockAdjustmentItemResponse `json:"items"`
+	}
+	StockAdjustmentItemResponse struct {
+		CommonModel
+		ProductID    uint    `json:"product_id"`
+		ProductCode  string  `json:"product_code"`
+		ProductName  string  `json:"product_name"`
+		ProductLotID uint    `json:"product_lot_id"`
+		LotCode      string  `json:"lot_code"`
+		Quantity     float64 `json:"quantity"`
+		ReasonCode   string  `json:"reason_code"`
+		Note         string  `json:"note"`
+	}
+)
+
+// Create implements StockCountService.
+func (s *stockCountService) Create(ctx context.Context, userId, teamId uint, body CreateStockCountRequest) (*StockCountResponse, error) {
+	location, err := s.warehouse.StorageLocation(ctx, nil, teamId, body.LocationID)
+	if err != nil {
+		return nil, err
+	}
+	productIds := slices.Compact(slices.Sorted(slices.Values(body.ProductIDs)))
+	if len(productIds) > 0 {
+		count, err := s.product.CountByIds(ctx, nil, teamId, productIds)
+		if err != nil {
+			s.logger.Error(err)
+			return nil, errs.HandleSqlErr(err)
+		}
+		if count != int64(len(productIds)) {
+			return nil, errs.ErrNotFound
+		}
+	}
+	stocks, err := s.lotStock.FindByLocation(ctx, nil, location.ID, productIds)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	items := make([]model.StockCountItem, 0, len(stocks))
+	for _, stock := range stocks {
+		items = append(items, model.StockCountItem{
+			ProductID:    stock.ProductID,
+			ProductLotID: stock.ProductLotID,
+			ExpectedQty:  stock.Quantity,
+		})
+	}
+	tx := s.dbTx.BeginTx()
+	defer s.dbTx.RollbackTx(tx) //nolint:errcheck
+	code, err := s.code.GenerateTransactionCode(ctx, tx, model.EntityTypeStockCount, teamId)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	m := &model.StockCount{
+		TeamID:      teamId,
+		Code:        code,
+		Status:      model.StockCountStatusCounting,
+		LocationID:  location.ID,
+		Note:        body.Note,
+		CreatedByID: userId,
+		SnapshotAt:  sql.NullTime{Time: time.Now(), Valid: true},
+		Items:       items,
+	}
+	if err := s.count.Create(ctx, tx, m); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := s.dbTx.CommitTx(tx); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return s.FindById(ctx, teamId, m.ID)
+}
+
+// FindById implements StockCountService.
+func (s *stockCountService) FindById(ctx context.Context, teamId, countId uint) (*StockCountResponse, error) {
+	m, err := s.count.FindById(ctx, nil, teamId, countId)
+	if err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrStockCountNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return toStockCountResponse(m), nil
+}
+
+// FindAll implements StockCountService.
+func (s *stockCountService) FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.StockCountFilter) ([]StockCountResponse, error) {
+	counts, err := s.count.FindAll(ctx, nil, teamId, pg, f)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	helpers.Paging(pg)
+	res := make([]StockCountResponse, 0, len(counts))
+	for i := range counts {
+		res = append(res, *toStockCountResponse(&counts[i]))
+	}
+	return res, nil
+}
+
+// Count implements StockCountService.
+func (s *stockCountService) Count(ctx context.Context, teamId, countId uint, body CountStockRequest) (*StockCountResponse, error) {
+	tx := s.dbTx.BeginTx()
+	defer s.dbTx.RollbackTx(tx) //nolint:errcheck
+	items := make([]model.StockCountItem, 0, len(body.Items))
+	for _, b := range body.Items {
+		lot, err := s.lotRepo.FindInTeam(ctx, tx, teamId, b.ProductLotID)
+		if err != nil {
+			s.logger.Error(err)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errs.ErrProductLotNotFound
+			}
+			return nil, errs.HandleSqlErr(err)
+		}
+		items = append(items, model.StockCountItem{
+			ProductID:    lot.ProductID,
+			ProductLotID: lot.ID,
+			CountedQty:   b.CountedQty,
+			ReasonCode:   b.ReasonCode,
+			Note:         b.Note,
+		})
+	}
+	if err := s.saveCounts(ctx, tx, teamId, countId, items); err != nil {
+		return nil, err
+	}
+	if err := s.dbTx.CommitTx(tx); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return s.FindById(ctx, teamId, countId)
+}
+
+// Import implements StockCountService.
+func (s *stockCountService) Import(ctx context.Context, teamId, countId uint, r io.Reader) (*StockCountResponse, error) {
+	rows, err := s.excel.Read(r)
+	if err != nil {
+		return nil, errs.ErrStockCountSheetInvalid
+	}
+	if len(rows) == 0 {
+		return nil, errs.ErrStockCountSheetInvalid
+	}
+	col := make(map[string]int)
+	for i, name := range rows[0] {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"product_code", "lot_code", "counted_qty"} {
+		if _, ok := col[name]; !ok {
+			return nil, errs.ErrStockCountSheetInvalid
+		}
+	}
+	cell := func(row []string, name string) string {
+		i, ok := col[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+	tx := s.dbTx.BeginTx()
+	defer s.dbTx.RollbackTx(tx) //nolint:errcheck
+	var items []model.StockCountItem
+	for _, row := range rows[1:] {
+		// lots left blank are not counted yet
+		counted := cell(row, "counted_qty")
+		if counted == "" {
+			continue
+		}
+		qty, err := strconv.ParseFloat(counted, 64)
+		if err != nil || qty < 0 {
+			return nil, errs.ErrStockCountSheetInvalid
+		}
+		reason := cell(row, "reason_code")
+		if reason != "" && !slices.Contains(adjustReasons, reason) {
+			return nil, errs.ErrStockCountSheetInvalid
+		}
+		lot, err := s.lotRepo.FindByCode(ctx, tx, teamId, cell(row, "product_code"), cell(row, "lot_code"))
+		if err != nil {
+			s.logger.Error(err)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errs.ErrStockCountSheetInvalid
+			}
+			return nil, errs.HandleSqlErr(err)
+		}
+		items = append(items, model.StockCountItem{
+			ProductID:    lot.ProductID,
+			ProductLotID: lot.ID,
+			CountedQty:   &qty,
+			ReasonCode:   reason,
+			Note:         cell(row, "note"),
+		})
+	}
+	if len(items) == 0 {
+		return nil, errs.ErrStockCountSheetInvalid
+	}
+	if err := s.saveCounts(ctx, tx, teamId, countId, items); err != nil {
+		return nil, err
+	}
+	if err := s.dbTx.CommitTx(tx); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return s.FindById(ctx, teamId, countId)
+}
+
+// Sheet implements StockCountService.
+func (s *stockCountService) Sheet(ctx context.Context, teamId, countId uint) (*StockCountSheet, error) {
+	m, err := s.count.FindById(ctx, nil, teamId, countId)
+	if err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrStockCountNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	df := &ExcelDataFrame{Header: stockCountSheetHeader}
+	for _, item := range m.Items {
+		var productCode, productName, lotCode, expiry, counted string
+		if item.Product != nil {
+			productCode, productName = item.Product.Code, item.Product.Name
+		}
+		if item.ProductLot != nil {
+			lotCode = item.ProductLot.Code
+			if item.ProductLot.ExpiryDate.Valid {
+				expiry = item.ProductLot.ExpiryDate.Time.Format(time.DateOnly)
+			}
+		}
+		if item.CountedQty != nil {
+			counted = strconv.FormatFloat(*item.CountedQty, 'f', -1, 64)
+		}
+		df.Rows = append(df.Rows, []any{productCode, productName, lotCode, expiry, counted, item.ReasonCode, item.Note})
+	}
+	content, err := s.excel.Build(df)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.ErrInternal
+	}
+	return &StockCountSheet{Filename: m.Code + ".xlsx", Content: content}, nil
+}
+
+// Submit implements StockCountService.
+func (s *stockCountService) Submit(ctx context.Context, userId, teamId, countId uint) (*StockCountResponse, error) {
+	return s.transition(ctx, teamId, countId, model.StockCountStatusSubmitted, nil, nil)
+}
+
+// Reopen implements StockCountService.
+func (s *stockCountService) Reopen(ctx context.Context, userId, teamId, countId uint) (*StockCountResponse, error) {
+	return s.transition(ctx, teamId, countId, model.StockCountStatusCounting, nil, nil)
+}
+
+// Approve implements StockCountService.
+func (s *stockCountService) Approve(ctx context.Context, userId, teamId, countId uint) (*StockCountResponse, error) {
+	updates := map[string]any{
+		"approved_by_id": userId,
+		"approved_at":    sql.NullTime{Time: time.Now(), Valid: true},
+	}
+	return s.transition(ctx, teamId, countId, model.StockCountStatusPosted, updates, func(ctx context.Context, tx *gorm.DB, m *model.StockCount) error {
+		return s.post(ctx, tx, userId, m)
+	})
+}
+
+// Cancel implements StockCountService.
+func (s *stockCountService) Cancel(ctx context.Context, userId, teamId, countId uint) (*StockCountResponse, error) {
+	return s.transition(ctx, teamId, countId, model.StockCountStatusCancelled, nil, nil)
+}
+
+// FindAdjustmentById implements StockCountService.
+func (s *stockCountService) FindAdjustmentById(ctx context.Context, teamId, adjustmentId uint) (*StockAdjustmentResponse, error) {
+	m, err := s.adjustment.FindById(ctx, nil, teamId, adjustmentId)
+	if err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrStockAdjustmentNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return toStockAdjustmentResponse(m), nil
+}
+
+// FindAdjustments implements StockCountService.
+func (s *stockCountService) FindAdjustments(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.StockAdjustmentFilter) ([]StockAdjustmentResponse, error) {
+	adjustments, err := s.adjustment.FindAll(ctx, nil, teamId, pg, f)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	helpers.Paging(pg)
+	res := make([]StockAdjustmentResponse, 0, len(adjustments))
+	for i := range adjustments {
+		res = append(res, *toStockAdjustmentResponse(&adjustments[i]))
+	}
+	return res, nil
+}
+
+// post creates the adjustment of the counted variances of m and posts it.
+func (s *stockCountService) post(ctx context.Context, tx *gorm.DB, userId uint, m *model.StockCount) error {
+	if _, err := s.warehouse.StorageLocation(ctx, tx, m.TeamID, m.LocationID); err != nil {
+		return err
+	}
+	var items []model.StockAdjustmentItem
+	for _, item := range m.Items {
+		variance, ok := countVariance(item)
+		if !ok || math.Abs(variance) <= repository.StockTolerance {
+			continue
+		}
+		if item.ReasonCode == "" {
+			return errs.ErrStockCountReasonRequired
+		}
+		items = append(items, model.StockAdjustmentItem{
+			ProductID:    item.ProductID,
+			ProductLotID: item.ProductLotID,
+			Quantity:     variance,
+			ReasonCode:   item.ReasonCode,
+			Note:         item.Note,
+		})
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	code, err := s.code.GenerateTransactionCode(ctx, tx, model.EntityTypeStockAdjustment, m.TeamID)
+	if err != nil {
+		s.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	adjustment := &model.StockAdjustment{
+		TeamID:       m.TeamID,
+		Code:         code,
+		StockCountID: &m.ID,
+		LocationID:   m.LocationID,
+		Note:         m.Note,
+		CreatedByID:  userId,
+		Items:        items,
+	}
+	if err := s.adjustment.Create(ctx, tx, adjustment); err != nil {
+		s.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	allocations := make([]LotAllocation, 0, len(items))
+	for _, item := range items {
+		allocations = append(allocations, LotAllocation{
+			ProductID:  item.ProductID,
+			LotID:      item.ProductLotID,
+			LocationID: m.LocationID,
+			Quantity:   item.Quantity,
+		})
+	}
+	ref := StockRef{Reason: model.StockReasonAdjust, RefType: model.EntityTypeStockAdjustment, RefID: &adjustment.ID}
+	return s.lot.Adjust(ctx, tx, m.TeamID, allocations, ref)
+}
+
+// saveCounts records counts while the count is counting.
+func (s *stockCountService) saveCounts(ctx context.Context, tx *gorm.DB, teamId, countId uint, items []model.StockCountItem) error {
+	m, err := s.findForUpdate(ctx, tx, teamId, countId)
+	if err != nil {
+		return err
+	}
+	if m.Status != model.StockCountStatusCounting {
+		return errs.ErrStockCountStatus
+	}
+	if err := s.count.SaveCounts(ctx, tx, m.ID, items); err != nil {
+		s.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return nil
+}
+
+// transition moves the count to status if stockCountTransitions allows it,
+// run is called in the same database transaction before the status changes.
+func (s *stockCountService) transition(
+	ctx context.Context,
+	teamId, countId uint,
+	status string,
+	updates map[string]any,
+	run func(ctx context.Context, tx *gorm.DB, m *model.StockCount) error,
+) (*StockCountResponse, error) {
+	from := stockCountTransitions[status]
+	tx := s.dbTx.BeginTx()
+	defer s.dbTx.RollbackTx(tx) //nolint:errcheck
+	m, err := s.findForUpdate(ctx, tx, teamId, countId)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(from, m.Status) {
+		return nil, errs.ErrStockCountStatus
+	}
+	if run != nil {
+		if err := run(ctx, tx, m); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.count.UpdateStatus(ctx, tx, m.ID, from, status, updates); err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrStockCountStatus
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := s.dbTx.CommitTx(tx); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return s.FindById(ctx, teamId, countId)
+}
+
+func (s *stockCountService) findForUpdate(ctx context.Context, tx *gorm.DB, teamId, countId uint) (*model.StockCount, error) {
+	m, err := s.count.FindByIdForUpdate(ctx, tx, teamId, countId)
+	if err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrStockCountNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return m, nil
+}
+
+// adjustReasons are the reason codes of an adjustment.
+var adjustReasons = []string{
+	model.AdjustReasonDamaged,
+	model.AdjustReasonLost,
+	model.AdjustReasonFound,
+	model.AdjustReasonExpired,
+	model.AdjustReasonMiscount,
+	model.AdjustReasonOther,
+}
+
+// countVariance is counted minus expected, ok is false until the lot is counted.
+func countVariance(item model.StockCountItem) (float64, bool) {
+	if item.CountedQty == nil {
+		return 0, false
+	}
+	return *item.CountedQty - item.ExpectedQty, true
+}
+
+func toStockCountResponse(m *model.StockCount) *StockCountResponse {
+	res := &StockCountResponse{
+		CommonModel: CommonModel{
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		},
+		TeamID:       m.TeamID,
+		Code:         m.Code,
+		Status:       m.Status,
+		LocationID:   m.LocationID,
+		Note:         m.Note,
+		CreatedByID:  m.CreatedByID,
+		SnapshotAt:   nullTimePtr(m.SnapshotAt),
+		ApprovedByID: m.ApprovedByID,
+		ApprovedAt:   nullTimePtr(m.ApprovedAt),
+		Items:        make([]StockCountItemResponse, 0, len(m.Items)),
+	}
+	if m.Location != nil {
+		res.LocationCode = m.Location.Code
+	}
+	for _, item := range m.Items {
+		i := StockCountItemResponse{
+			CommonModel: CommonModel{
+				ID:        item.ID,
+				CreatedAt: item.CreatedAt,
+				UpdatedAt: item.UpdatedAt,
+			},
+			ProductID:    item.ProductID,
+			ProductLotID: item.ProductLotID,
+			ExpectedQty:  item.ExpectedQty,
+			CountedQty:   item.CountedQty,
+			ReasonCode:   item.ReasonCode,
+			Note:         item.Note,
+		}
+		if variance, ok := countVariance(item); ok {
+			i.Variance = &variance
+		}
+		if item.Product != nil {
+			i.ProductCode = item.Product.Code
+			i.ProductName = item.Product.Name
+		}
+		if item.ProductLot != nil {
+			i.LotCode = item.ProductLot.Code
+		}
+		res.Items = append(res.Items, i)
+	}
+	return res
+}
+
+func toStockAdjustmentResponse(m *model.StockAdjustment) *StockAdjustmentResponse {
+	res := &StockAdjustmentResponse{
+		CommonModel: CommonModel{
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		},
+		TeamID:       m.TeamID,
+		Code:         m.Code,
+		StockCountID: m.StockCountID,
+		LocationID:   m.LocationID,
+		Note:         m.Note,
+		CreatedByID:  m.CreatedByID,
+		Items:        make([]StockAdjustmentItemResponse, 0, len(m.Items)),
+	}
+	if m.Location != nil {
+		res.LocationCode = m.Location.Code
+	}
+	for _, item := range m.Items {
+		i := StockAdjustmentItemResponse{
+			CommonModel: CommonModel{
+				ID:        item.ID,
+				CreatedAt: item.CreatedAt,
+				UpdatedAt: item.UpdatedAt,
+			},
+			ProductID:    item.ProductID,
+			ProductLotID: item.ProductLotID,
+			Quantity:     item.Quantity,
+			ReasonCode:   item.ReasonCode,
+			Note:         item.Note,
+		}
+		if item.Product != nil {
+			i.ProductCode = item.Product.Code
+			i.ProductName = item.Product.Name
+		}
+		if item.ProductLot != nil {
+			i.LotCode = item.ProductLot.Code
+		}
+		res.Items = append(res.Items, i)
+	}
+	return res
+}
+
+func NewStockCountService(
+	count repository.StockCount,
+	adjustment repository.StockAdjustment,
+	lotStock repository.LotStock,
+	lotRepo repository.ProductLot,
+	product repository.Product,
+	dbTx repository.DatabaseTransaction,
+	code CodeService,
+	lot ProductLotService,
+	warehouse WarehouseService,
+	excel Excel,
+	logger logger.AppLogger,
+) StockCountService {
+	return &stockCountService{
+		count:      count,
+		adjustment: adjustment,
+		lotStock:   lotStock,
+		lotRepo:    lotRepo,
+		product:    product,
+		dbTx:       dbTx,
+		code:       code,
+		lot:        lot,
+		warehouse:  warehouse,
+		excel:      excel,
+		logger:     logger,
+	}
+}
@@ -0,0 +1,355 @@
+package service
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+// stockMovementSheetColumns are the columns of the stock movement export.
+var stockMovementSheetColumns = []ExcelColumn{
+	{Header: "moved_at", Type: ExcelDate, Format: "yyyy-mm-dd hh:mm"},
+	{Header: "product_code", Type: ExcelText},
+	{Header: "product_name", Type: ExcelText},
+	{Header: "uom", Type: ExcelText},
+	{Header: "lot_code", Type: ExcelText},
+	{Header: "location_code", Type: ExcelText},
+	{Header: "reason", Type: ExcelText},
+	{Header: "ref_type", Type: ExcelText},
+	{Header: "ref_id"},
+	{Header: "quantity", Type: ExcelNumber, Total: ExcelSum},
+	{Header: "balance", Type: ExcelNumber},
+}
+
+type (
+	// StockLedgerService keeps the append-only stock ledger. ProductLot.RemainingQty
+	// and LotStock.Quantity are caches of the ledger sums of the lot and of the lot
+	// in a location, Reconcile finds where they differ and RebuildCache sets the
+	// caches back from the ledger.
+	StockLedgerService interface {
+		// Record appends a movement of qty into locationId, negative when stock
+		// leaves it. tx must hold the lot row locked, as the update of its
+		// remaining quantity does.
+		Record(ctx context.Context, tx *gorm.DB, teamId, productId, lotId, locationId uint, qty float64, ref StockRef) error
+		// Balance returns the stock at f.At per lot, product, location or warehouse,
+		// in the base unit of the product and in each of its alternate units.
+		Balance(ctx context.Context, teamId uint, f *filter.StockBalanceFilter) ([]StockBalanceResponse, error)
+		FindMovements(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.StockMovementFilter) ([]StockMovementResponse, error)
+		// ExportMovements streams every movement matching f to an Excel sheet, in
+		// ledger order.
+		ExportMovements(ctx context.Context, teamId uint, f *filter.StockMovementFilter) (*ExcelStream, error)
+		Reconcile(ctx context.Context, teamId uint) ([]StockDiscrepancyResponse, error)
+		// RebuildCache first opens the ledger of lots received before it existed
+		// with their remaining quantity and moves stock recorded before locations
+		// existed into the default location of its team, then sets every remaining
+		// quantity and lot stock to the ledger sums. teamId 0 means every team.
+		// Run it while no stock is posted.
+		RebuildCache(ctx context.Context, teamId uint) (*StockRebuildResult, error)
+	}
+	stockLedgerService struct {
+		movement  repository.StockMovement
+		lotStock  repository.LotStock
+		warehouse WarehouseService
+		uom       UomService
+		excel     Excel
+		dbTx      repository.DatabaseTransaction
+		conf      *config.Config
+		logger    logger.AppLogger
+	}
+
+	// StockRef tells why stock moved and which document posted it.
+	StockRef struct {
+		Reason  string
+		RefType model.EntityType
+		RefID   *uint
+	}
+
+	StockBalanceResponse struct {
+		ProductID   uint   `json:"product_id"`
+		ProductCode string `json:"product_code"`
+		ProductName string `json:"product_name"`
+		// ProductLotID and LotCode are empty when grouped by product or warehouse.
+		ProductLotID *uint  `json:"product_lot_id"`
+		LotCode      string `json:"lot_code"`
+		// the location fields are empty unless grouped by location or warehouse,
+		// and for stock recorded before locations existed
+		LocationID    *uint  `json:"location_id"`
+		LocationCode  string `json:"location_code"`
+		WarehouseID   *uint  `json:"warehouse_id"`
+		WarehouseCode string `json:"warehouse_code"`
+		// Quantity is in the base unit UOM, Units in the alternate units.
+		Quantity float64               `json:"quantity"`
+		UOM      string                `json:"uom"`
+		Units    []UomQuantityResponse `json:"units"`
+	}
+	StockMovementResponse struct {
+		CommonModel
+		ProductID    uint      `json:"product_id"`
+		ProductCode  string    `json:"product_code"`
+		ProductLotID uint      `json:"product_lot_id"`
+		LotCode      string    `json:"lot_code"`
+		LocationID   *uint     `json:"location_id"`
+		LocationCode string    `json:"location_code"`
+		Quantity     float64   `json:"quantity"`
+		Balance      float64   `json:"balance"`
+		Reason       string    `json:"reason"`
+		RefType      string    `json:"ref_type"`
+		RefID        *uint     `json:"ref_id"`
+		MovedAt      time.Time `json:"moved_at"`
+	}
+	StockDiscrepancyResponse struct {
+		ProductID    uint   `json:"product_id"`
+		ProductLotID uint   `json:"product_lot_id"`
+		LotCode      string `json:"lot_code"`
+		// LocationID is null when the remaining quantity of the lot differs.
+		LocationID *uint   `json:"location_id"`
+		CachedQty  float64 `json:"cached_qty"`
+		LedgerQty  float64 `json:"ledger_qty"`
+	}
+	StockRebuildResult struct {
+		Opened    int64 `json:"opened"`
+		Relocated int64 `json:"relocated"`
+		Updated   int64 `json:"updated"`
+		LotStocks int64 `json:"lot_stocks"`
+	}
+)
+
+// Record implements StockLedgerService.
+func (s *stockLedgerService) Record(ctx context.Context, tx *gorm.DB, teamId, productId, lotId, locationId uint, qty float64, ref StockRef) error {
+	m := &model.StockMovement{
+		TeamID:       teamId,
+		ProductID:    productId,
+		ProductLotID: lotId,
+		LocationID:   &locationId,
+		Quantity:     qty,
+		Reason:       ref.Reason,
+		RefType:      ref.RefType,
+		RefID:        ref.RefID,
+	}
+	if err := s.movement.Create(ctx, tx, m); err != nil {
+		s.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return nil
+}
+
+// Balance implements StockLedgerService.
+func (s *stockLedgerService) Balance(ctx context.Context, teamId uint, f *filter.StockBalanceFilter) ([]StockBalanceResponse, error) {
+	at, err := parseStockTime(f.At, s.conf.SERVER.LOCATION)
+	if err != nil {
+		return nil, errs.ErrBadRequest
+	}
+	balances, err := s.movement.Balance(ctx, nil, teamId, at, f)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	productIds := make([]uint, 0, len(balances))
+	for _, b := range balances {
+		productIds = append(productIds, b.ProductID)
+	}
+	conversions, err := s.uom.Conversions(ctx, nil, productIds)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]StockBalanceResponse, 0, len(balances))
+	for _, b := range balances {
+		r := StockBalanceResponse{
+			ProductID:     b.ProductID,
+			ProductCode:   b.ProductCode,
+			ProductName:   b.ProductName,
+			LotCode:       b.LotCode,
+			LocationID:    b.LocationID,
+			LocationCode:  b.LocationCode,
+			WarehouseID:   b.WarehouseID,
+			WarehouseCode: b.WarehouseCode,
+			Quantity:      b.Quantity,
+			UOM:           b.UOM,
+			Units:         conversions.Express(b.ProductID, b.Quantity),
+		}
+		if f.GroupBy != "product" && f.GroupBy != "warehouse" {
+			r.ProductLotID = &b.ProductLotID
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// FindMovements implements StockLedgerService.
+func (s *stockLedgerService) FindMovements(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.StockMovementFilter) ([]StockMovementResponse, error) {
+	movements, err := s.movement.FindAll(ctx, nil, teamId, pg, f)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	helpers.Paging(pg)
+	res := make([]StockMovementResponse, 0, len(movements))
+	for _, m := range movements {
+		r := StockMovementResponse{
+			CommonModel: CommonModel{
+				ID:        m.ID,
+				CreatedAt: m.CreatedAt,
+				UpdatedAt: m.UpdatedAt,
+			},
+			ProductID:    m.ProductID,
+			ProductLotID: m.ProductLotID,
+			LocationID:   m.LocationID,
+			Quantity:     m.Quantity,
+			Balance:      m.Balance,
+			Reason:       m.Reason,
+			RefType:      string(m.RefType),
+			RefID:        m.RefID,
+			MovedAt:      m.MovedAt,
+		}
+		if m.Product != nil {
+			r.ProductCode = m.Product.Code
+		}
+		if m.ProductLot != nil {
+			r.LotCode = m.ProductLot.Code
+		}
+		if m.Location != nil {
+			r.LocationCode = m.Location.Code
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// ExportMovements implements StockLedgerService.
+func (s *stockLedgerService) ExportMovements(ctx context.Context, teamId uint, f *filter.StockMovementFilter) (*ExcelStream, error) {
+	now := time.Now()
+	sheet := &ExcelSheet{
+		Name:         "movements",
+		Title:        "ความเคลื่อนไหวของสต็อก ณ " + now.Format("2006-01-02 15:04"),
+		Columns:      stockMovementSheetColumns,
+		FreezeHeader: true,
+		AutoFilter:   true,
+	}
+	write := func(w io.Writer) error {
+		err := s.excel.Stream(w, sheet, func(write func(row []any) error) error {
+			return s.movement.Each(ctx, nil, teamId, f, func(m repository.StockMovementRow) error {
+				var refId any
+				if m.RefID != nil {
+					refId = *m.RefID
+				}
+				return write([]any{
+					m.MovedAt.In(s.conf.SERVER.LOCATION), m.ProductCode, m.ProductName, m.UOM, m.LotCode, m.LocationCode,
+					m.Reason, m.RefType, refId, m.Quantity, m.Balance,
+				})
+			})
+		})
+		if err != nil {
+			s.logger.Error(err)
+			return errs.HandleSqlErr(err)
+		}
+		return nil
+	}
+	return &ExcelStream{Filename: "stock-movements-" + now.Format("20060102") + ".xlsx", Write: write}, nil
+}
+
+// Reconcile implements StockLedgerService.
+func (s *stockLedgerService) Reconcile(ctx context.Context, teamId uint) ([]StockDiscrepancyResponse, error) {
+	discrepancies, err := s.movement.Reconcile(ctx, nil, teamId)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	res := make([]StockDiscrepancyResponse, 0, len(discrepancies))
+	for _, d := range discrepancies {
+		res = append(res, StockDiscrepancyResponse(d))
+	}
+	return res, nil
+}
+
+// RebuildCache implements StockLedgerService.
+func (s *stockLedgerService) RebuildCache(ctx context.Context, teamId uint) (*StockRebuildResult, error) {
+	tx := s.dbTx.BeginTx()
+	defer s.dbTx.RollbackTx(tx) //nolint:errcheck
+	now := time.Now()
+	var (
+		res StockRebuildResult
+		err error
+	)
+	if res.Opened, err = s.movement.CreateOpening(ctx, tx, teamId, now); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	teamIds, err := s.movement.FindUnlocatedTeams(ctx, tx, teamId)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	for _, id := range teamIds {
+		location, err := s.warehouse.DefaultLocation(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		relocated, err := s.movement.Relocate(ctx, tx, id, location.ID, now)
+		if err != nil {
+			s.logger.Error(err)
+			return nil, errs.HandleSqlErr(err)
+		}
+		res.Relocated += relocated
+	}
+	if res.Updated, err = s.movement.RebuildCache(ctx, tx, teamId); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if res.LotStocks, err = s.lotStock.Rebuild(ctx, tx, teamId); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := s.dbTx.CommitTx(tx); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return &res, nil
+}
+
+// parseStockTime reads an RFC 3339 time, or a date of loc meaning the end of
+// that day. Empty is now.
+func parseStockTime(s string, loc *time.Location) (time.Time, error) {
+	if s == "" {
+		return time.Now(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseInLocation(time.DateOnly, s, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// the database keeps microseconds
+	return d.AddDate(0, 0, 1).Add(-time.Microsecond), nil
+}
+
+func NewStockLedgerService(
+	movement repository.StockMovement,
+	lotStock repository.LotStock,
+	warehouse WarehouseService,
+	uom UomService,
+	excel Excel,
+	dbTx repository.DatabaseTransaction,
+	conf *config.Config,
+	logger logger.AppLogger,
+) StockLedgerService {
+	return &stockLedgerService{
+		movement:  movement,
+		lotStock:  lotStock,
+		warehouse: warehouse,
+		uom:       uom,
+		excel:     excel,
+		dbTx:      dbTx,
+		conf:      conf,
+		logger:    logger,
+	}
+}
@@ -0,0 +1,437 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+// stockTransferTransitions lists for every status the statuses it can be reached from.
+var stockTransferTransitions = map[string][]string{
+	model.StockTransferStatusInTransit: {model.StockTransferStatusDraft},
+	model.StockTransferStatusReceived:  {model.StockTransferStatusInTransit},
+	model.StockTransferStatusCancelled: {model.StockTransferStatusDraft, model.StockTransferStatusInTransit},
+}
+
+type (
+	// StockTransferService moves stock between the locations of a team:
+	//
+	//	draft -> in_transit -> received
+	//	  |          |
+	//	  +----------+-> cancelled
+	//
+	// Shipping takes the items out of the source location into the transit
+	// location, receiving puts them into the destination. Cancelling a shipped
+	// transfer puts the stock back into the source location.
+	StockTransferService interface {
+		Create(ctx context.Context, userId, teamId uint, body StockTransferRequest) (*StockTransferResponse, error)
+		// Update replaces the locations, note and items of a draft transfer.
+		Update(ctx context.Context, teamId, transferId uint, body StockTransferRequest) (*StockTransferResponse, error)
+		FindById(ctx context.Context, teamId, transferId uint) (*StockTransferResponse, error)
+		FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.StockTransferFilter) ([]StockTransferResponse, error)
+		// Ship picks the items first-expired-first-out unless they name a lot,
+		// splitting them per lot, and moves them into the transit location.
+		Ship(ctx context.Context, teamId, transferId uint) (*StockTransferResponse, error)
+		Receive(ctx context.Context, teamId, transferId uint) (*StockTransferResponse, error)
+		Cancel(ctx context.Context, teamId, transferId uint) (*StockTransferResponse, error)
+	}
+	stockTransferService struct {
+		transfer  repository.StockTransfer
+		product   repository.Product
+		lotRepo   repository.ProductLot
+		dbTx      repository.DatabaseTransaction
+		code      CodeService
+		lot       ProductLotService
+		warehouse WarehouseService
+		logger    logger.AppLogger
+	}
+
+	StockTransferItemRequest struct {
+		ProductID uint `json:"product_id" binding:"required"`
+		// ProductLotID moves a given lot, picked first-expired-first-out when empty.
+		ProductLotID uint    `json:"product_lot_id"`
+		Quantity     float64 `json:"quantity" binding:"required,gt=0"`
+	}
+	StockTransferRequest struct {
+		FromLocationID uint                       `json:"from_location_id" binding:"required"`
+		ToLocationID   uint                       `json:"to_location_id" binding:"required"`
+		Note           string                     `json:"note"`
+		Items          []StockTransferItemRequest `json:"items" binding:"required,min=1,dive"`
+	}
+
+	StockTransferResponse struct {
+		CommonModel
+		TeamID           uint                        `json:"team_id"`
+		Code             string                      `json:"code"`
+		Status           string                      `json:"status"`
+		FromLocationID   uint                        `json:"from_location_id"`
+		FromLocationCode string                      `json:"from_location_code"`
+		ToLocationID     uint                        `json:"to_location_id"`
+		ToLocationCode   string                      `json:"to_location_code"`
+		Note             string                      `json:"note"`
+		CreatedByID      uint                        `json:"created_by_id"`
+		ShippedAt        *time.Time                  `json:"shipped_at"`
+		ReceivedAt       *time.Time                  `json:"received_at"`
+		Items            []StockTransferItemResponse `json:"items"`
+	}
+	StockTransferItemResponse struct {
+		CommonModel
+		ProductID    uint    `json:"product_id"`
+		ProductCode  string  `json:"product_code"`
+		ProductName  string  `json:"product_name"`
+		ProductLotID *uint   `json:"product_lot_id"`
+		LotCode      string  `json:"lot_code"`
+		Quantity     float64 `json:"quantity"`
+	}
+)
+
+// Create implements StockTransferService.
+func (s *stockTransferService) Create(ctx context.Context, userId, teamId uint, body StockTransferRequest) (*StockTransferResponse, error) {
+	items, err := s.toItems(ctx, nil, teamId, body)
+	if err != nil {
+		return nil, err
+	}
+	tx := s.dbTx.BeginTx()
+	defer s.dbTx.RollbackTx(tx) //nolint:errcheck
+	code, err := s.code.GenerateTransactionCode(ctx, tx, model.EntityTypeStockTransfer, teamId)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	m := &model.StockTransfer{
+		TeamID:         teamId,
+		Code:           code,
+		Status:         model.StockTransferStatusDraft,
+		FromLocationID: body.FromLocationID,
+		ToLocationID:   body.ToLocationID,
+		Note:           body.Note,
+		CreatedByID:    userId,
+		Items:          items,
+	}
+	if err := s.transfer.Create(ctx, tx, m); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := s.dbTx.CommitTx(tx); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return s.FindById(ctx, teamId, m.ID)
+}
+
+// Update implements StockTransferService.
+func (s *stockTransferService) Update(ctx context.Context, teamId, transferId uint, body StockTransferRequest) (*StockTransferResponse, error) {
+	tx := s.dbTx.BeginTx()
+	defer s.dbTx.RollbackTx(tx) //nolint:errcheck
+	m, err := s.findForUpdate(ctx, tx, teamId, transferId)
+	if err != nil {
+		return nil, err
+	}
+	if m.Status != model.StockTransferStatusDraft {
+		return nil, errs.ErrStockTransferStatus
+	}
+	items, err := s.toItems(ctx, tx, teamId, body)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.transfer.ReplaceItems(ctx, tx, m.ID, items); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := s.transfer.Update(ctx, tx, m.ID, body.FromLocationID, body.ToLocationID, body.Note); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := s.dbTx.CommitTx(tx); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return s.FindById(ctx, teamId, transferId)
+}
+
+// FindById implements StockTransferService.
+func (s *stockTransferService) FindById(ctx context.Context, teamId, transferId uint) (*StockTransferResponse, error) {
+	m, err := s.transfer.FindById(ctx, nil, teamId, transferId)
+	if err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrStockTransferNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return toStockTransferResponse(m), nil
+}
+
+// FindAll implements StockTransferService.
+func (s *stockTransferService) FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.StockTransferFilter) ([]StockTransferResponse, error) {
+	transfers, err := s.transfer.FindAll(ctx, nil, teamId, pg, f)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	helpers.Paging(pg)
+	res := make([]StockTransferResponse, 0, len(transfers))
+	for i := range transfers {
+		res = append(res, *toStockTransferResponse(&transfers[i]))
+	}
+	return res, nil
+}
+
+// Ship implements StockTransferService.
+func (s *stockTransferService) Ship(ctx context.Context, teamId, transferId uint) (*StockTransferResponse, error) {
+	updates := map[string]any{"shipped_at": sql.NullTime{Time: time.Now(), Valid: true}}
+	return s.transition(ctx, teamId, transferId, model.StockTransferStatusInTransit, updates, func(ctx context.Context, tx *gorm.DB, m *model.StockTransfer) error {
+		if _, err := s.warehouse.StorageLocation(ctx, tx, teamId, m.FromLocationID); err != nil {
+			return err
+		}
+		transit, err := s.warehouse.TransitLocation(ctx, tx, teamId)
+		if err != nil {
+			return err
+		}
+		var items []model.StockTransferItem
+		for _, item := range m.Items {
+			pick := StockPick{LocationID: m.FromLocationID}
+			if item.ProductLotID != nil {
+				pick.ProductLotID = *item.ProductLotID
+			}
+			allocations, err := s.lot.Pick(ctx, tx, teamId, item.ProductID, item.Quantity, pick)
+			if err != nil {
+				return err
+			}
+			if err := s.lot.Move(ctx, tx, teamId, allocations, transit.ID, transferRef(m)); err != nil {
+				return err
+			}
+			for _, a := range allocations {
+				items = append(items, model.StockTransferItem{
+					ProductID:    item.ProductID,
+					ProductLotID: &a.LotID,
+					Quantity:     a.Quantity,
+				})
+			}
+		}
+		if err := s.transfer.ReplaceItems(ctx, tx, m.ID, items); err != nil {
+			s.logger.Error(err)
+			return errs.HandleSqlErr(err)
+		}
+		return nil
+	})
+}
+
+// Receive implements StockTransferService.
+func (s *stockTransferService) Receive(ctx context.Context, teamId, transferId uint) (*StockTransferResponse, error) {
+	updates := map[string]any{"received_at": sql.NullTime{Time: time.Now(), Valid: true}}
+	return s.transition(ctx, teamId, transferId, model.StockTransferStatusReceived, updates, func(ctx context.Context, tx *gorm.DB, m *model.StockTransfer) error {
+		return s.unload(ctx, tx, m, m.ToLocationID)
+	})
+}
+
+// Cancel implements StockTransferService.
+func (s *stockTransferService) Cancel(ctx context.Context, teamId, transferId uint) (*StockTransferResponse, error) {
+	return s.transition(ctx, teamId, transferId, model.StockTransferStatusCancelled, nil, func(ctx context.Context, tx *gorm.DB, m *model.StockTransfer) error {
+		if m.Status != model.StockTransferStatusInTransit {
+			return nil
+		}
+		return s.unload(ctx, tx, m, m.FromLocationID)
+	})
+}
+
+// unload moves the shipped items of m out of the transit location into locationId.
+func (s *stockTransferService) unload(ctx context.Context, tx *gorm.DB, m *model.StockTransfer, locationId uint) error {
+	location, err := s.warehouse.StorageLocation(ctx, tx, m.TeamID, locationId)
+	if err != nil {
+		return err
+	}
+	transit, err := s.warehouse.TransitLocation(ctx, tx, m.TeamID)
+	if err != nil {
+		return err
+	}
+	allocations := make([]LotAllocation, 0, len(m.Items))
+	for _, item := range m.Items {
+		if item.ProductLotID == nil {
+			return errs.ErrDoNotHaveLotItem
+		}
+		allocations = append(allocations, LotAllocation{
+			ProductID:  item.ProductID,
+			LotID:      *item.ProductLotID,
+			LocationID: transit.ID,
+			Quantity:   item.Quantity,
+		})
+	}
+	return s.lot.Move(ctx, tx, m.TeamID, allocations, location.ID, transferRef(m))
+}
+
+// transition moves the transfer to status if stockTransferTransitions allows it,
+// run is called in the same database transaction before the status changes.
+func (s *stockTransferService) transition(
+	ctx context.Context,
+	teamId, transferId uint,
+	status string,
+	updates map[string]any,
+	run func(ctx context.Context, tx *gorm.DB, m *model.StockTransfer) error,
+) (*StockTransferResponse, error) {
+	from := stockTransferTransitions[status]
+	tx := s.dbTx.BeginTx()
+	defer s.dbTx.RollbackTx(tx) //nolint:errcheck
+	m, err := s.findForUpdate(ctx, tx, teamId, transferId)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(from, m.Status) {
+		return nil, errs.ErrStockTransferStatus
+	}
+	if run != nil {
+		if err := run(ctx, tx, m); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.transfer.UpdateStatus(ctx, tx, m.ID, from, status, updates); err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrStockTransferStatus
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := s.dbTx.CommitTx(tx); err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return s.FindById(ctx, teamId, transferId)
+}
+
+func (s *stockTransferService) findForUpdate(ctx context.Context, tx *gorm.DB, teamId, transferId uint) (*model.StockTransfer, error) {
+	m, err := s.transfer.FindByIdForUpdate(ctx, tx, teamId, transferId)
+	if err != nil {
+		s.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrStockTransferNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return m, nil
+}
+
+// toItems validates the locations and the requested items.
+func (s *stockTransferService) toItems(ctx context.Context, tx *gorm.DB, teamId uint, body StockTransferRequest) ([]model.StockTransferItem, error) {
+	if body.FromLocationID == body.ToLocationID {
+		return nil, errs.ErrStockTransferSameLocation
+	}
+	for _, id := range []uint{body.FromLocationID, body.ToLocationID} {
+		if _, err := s.warehouse.StorageLocation(ctx, tx, teamId, id); err != nil {
+			return nil, err
+		}
+	}
+	productIds := make([]uint, 0, len(body.Items))
+	items := make([]model.StockTransferItem, 0, len(body.Items))
+	for _, b := range body.Items {
+		item := model.StockTransferItem{
+			ProductID: b.ProductID,
+			Quantity:  b.Quantity,
+		}
+		if b.ProductLotID != 0 {
+			if _, err := s.lotRepo.FindById(ctx, tx, b.ProductID, b.ProductLotID); err != nil {
+				s.logger.Error(err)
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, errs.ErrProductLotNotFound
+				}
+				return nil, errs.HandleSqlErr(err)
+			}
+			item.ProductLotID = &b.ProductLotID
+		}
+		productIds = append(productIds, b.ProductID)
+		items = append(items, item)
+	}
+	ids := slices.Compact(slices.Sorted(slices.Values(productIds)))
+	count, err := s.product.CountByIds(ctx, tx, teamId, ids)
+	if err != nil {
+		s.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if count != int64(len(ids)) {
+		return nil, errs.ErrNotFound
+	}
+	return items, nil
+}
+
+// transferRef points the stock movements of a transfer at m.
+func transferRef(m *model.StockTransfer) StockRef {
+	return StockRef{Reason: model.StockReasonTransfer, RefType: model.EntityTypeStockTransfer, RefID: &m.ID}
+}
+
+func toStockTransferResponse(m *model.StockTransfer) *StockTransferResponse {
+	res := &StockTransferResponse{
+		CommonModel: CommonModel{
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		},
+		TeamID:         m.TeamID,
+		Code:           m.Code,
+		Status:         m.Status,
+		FromLocationID: m.FromLocationID,
+		ToLocationID:   m.ToLocationID,
+		Note:           m.Note,
+		CreatedByID:    m.CreatedByID,
+		ShippedAt:      nullTimePtr(m.ShippedAt),
+		ReceivedAt:     nullTimePtr(m.ReceivedAt),
+		Items:          make([]StockTransferItemResponse, 0, len(m.Items)),
+	}
+	if m.FromLocation != nil {
+		res.FromLocationCode = m.FromLocation.Code
+	}
+	if m.ToLocation != nil {
+		res.ToLocationCode = m.ToLocation.Code
+	}
+	for _, item := range m.Items {
+		i := StockTransferItemResponse{
+			CommonModel: CommonModel{
+				ID:        item.ID,
+				CreatedAt: item.CreatedAt,
+				UpdatedAt: item.UpdatedAt,
+			},
+			ProductID:    item.ProductID,
+			ProductLotID: item.ProductLotID,
+			Quantity:     item.Quantity,
+		}
+		if item.Product != nil {
+			i.ProductCode = item.Product.Code
+			i.ProductName = item.Product.Name
+		}
+		if item.ProductLot != nil {
+			i.LotCode = item.ProductLot.Code
+		}
+		res.Items = append(res.Items, i)
+	}
+	return res
+}
+
+func NewStockTransferService(
+	transfer repository.StockTransfer,
+	product repository.Product,
+	lotRepo repository.ProductLot,
+	dbTx repository.DatabaseTransaction,
+	code CodeService,
+	lot ProductLotService,
+	warehouse WarehouseService,
+	logger logger.AppLogger,
+) StockTransferService {
+	return &stockTransferService{
+		transfer:  transfer,
+		product:   product,
+		lotRepo:   lotRepo,
+		dbTx:      dbTx,
+		code:      code,
+		lot:       lot,
+		warehouse: warehouse,
+		logger:    logger,
+	}
+}
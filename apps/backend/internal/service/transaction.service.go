@@ -0,0 +1,792 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+// transactionTransitions lists for every status the statuses it can be reached from.
+var transactionTransitions = map[string][]string{
+	model.TransactionStatusPending:   {model.TransactionStatusDraft},
+	model.TransactionStatusApproved:  {model.TransactionStatusPending},
+	model.TransactionStatusRejected:  {model.TransactionStatusPending},
+	model.TransactionStatusCancelled: {model.TransactionStatusDraft, model.TransactionStatusPending},
+}
+
+// transactionEditable are the statuses a transaction can still be changed in.
+var transactionEditable = []string{model.TransactionStatusDraft, model.TransactionStatusPending}
+
+type (
+	// TransactionService runs the stock transactions of a team:
+	//
+	//	draft -> pending -> approved
+	//	           |     -> rejected
+	//	           +-----> cancelled (from draft too)
+	//
+	// A pending transaction is decided by the approval chain of the team, one
+	// ApprovalStep after another. Stock moves only on the final approval,
+	// within the same database transaction.
+	TransactionService interface {
+		Create(ctx context.Context, userId, teamId uint, body CreateTransactionRequest) (*TransactionResponse, error)
+		// Update replaces the note and items of a draft or pending transaction.
+		Update(ctx context.Context, teamId, transactionId uint, body UpdateTransactionRequest) (*TransactionResponse, error)
+		FindById(ctx context.Context, teamId, transactionId uint) (*TransactionResponse, error)
+		FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.TransactionFilter) ([]TransactionResponse, error)
+		// Submit sends the transaction for approval by the approval chain of the team.
+		Submit(ctx context.Context, userId, teamId, transactionId uint) (*TransactionResponse, error)
+		// Approve decides the current step of the approval chain, the last one posts
+		// the stock: sales are allocated from lots first-expired-first-out,
+		// purchases create their lots and returns put stock back into the sold lots,
+		// in the location of the transaction.
+		Approve(ctx context.Context, userId, teamId, transactionId uint, body DecideTransactionRequest) (*TransactionResponse, error)
+		Reject(ctx context.Context, userId, teamId, transactionId uint, body DecideTransactionRequest) (*TransactionResponse, error)
+		Cancel(ctx context.Context, userId, teamId, transactionId uint) (*TransactionResponse, error)
+		// DecideByLink decides the approval an emailed link was signed for, as its approver.
+		DecideByLink(ctx context.Context, approvalId uint, approve bool, query url.Values, body DecideTransactionRequest) (*TransactionApprovalResponse, error)
+		// FindApprovalByLink returns the approval a link was signed for, so the
+		// approver can see what they decide on. The link must not have expired.
+		FindApprovalByLink(ctx context.Context, approvalId uint, approve bool, query url.Values) (*TransactionApprovalResponse, error)
+		// FindApprovals returns the approval history of the transaction.
+		FindApprovals(ctx context.Context, teamId, transactionId uint) ([]TransactionApprovalResponse, error)
+	}
+	transactionService struct {
+		transaction repository.Transaction
+		product     repository.Product
+		dbTx        repository.DatabaseTransaction
+		code        CodeService
+		lot         ProductLotService
+		warehouse   WarehouseService
+		uom         UomService
+		partner     PartnerService
+		approval    repository.TransactionApproval
+		step        repository.ApprovalStep
+		user        repository.User
+		email       Email
+		signer      helpers.URLSigner
+		conf        *config.Config
+		logger      logger.AppLogger
+	}
+
+	TransactionItemRequest struct {
+		ProductID uint `json:"product_id" binding:"required"`
+		// UomID is the unit Quantity and Price are in, the base unit of the
+		// product when empty. Items are stored in the base unit.
+		UomID    uint    `json:"uom_id"`
+		Quantity float64 `json:"quantity" binding:"required,gt=0"`
+		Price    float64 `json:"price" binding:"min=0"`
+		// ProductLotID is the sold lot a return puts stock back into, returns only.
+		ProductLotID uint `json:"product_lot_id"`
+		// LotCode and the dates describe the lot a purchase creates, purchases only.
+		LotCode         string `json:"lot_code" binding:"omitempty,max=100"`
+		ManufactureDate string `json:"manufacture_date" binding:"omitempty,datetime=2006-01-02"`
+		ExpiryDate      string `json:"expiry_date" binding:"omitempty,datetime=2006-01-02"`
+	}
+	CreateTransactionRequest struct {
+		Type string `json:"type" binding:"required,oneof=SO PO CN"`
+		// ParentID is the approved sale a return (CN) references.
+		ParentID uint `json:"parent_id"`
+		// LocationID is the storage location stock is taken from or put into,
+		// any location for sales and the default location for the others when empty.
+		LocationID uint `json:"location_id"`
+		// PartnerID is the supplier of a purchase or the customer of a sale, a
+		// return takes the customer of its sale when empty.
+		PartnerID uint                     `json:"partner_id"`
+		Note      string                   `json:"note"`
+		Items     []TransactionItemRequest `json:"items" binding:"required,min=1,dive"`
+	}
+	UpdateTransactionRequest struct {
+		LocationID uint                     `json:"location_id"`
+		PartnerID  uint                     `json:"partner_id"`
+		Note       string                   `json:"note"`
+		Items      []TransactionItemRequest `json:"items" binding:"required,min=1,dive"`
+	}
+
+	TransactionResponse struct {
+		CommonModel
+		TeamID       uint                      `json:"team_id"`
+		Code         string                    `json:"code"`
+		Type         string                    `json:"type"`
+		Status       string                    `json:"status"`
+		ParentID     *uint                     `json:"parent_id"`
+		LocationID   *uint                     `json:"location_id"`
+		PartnerID    *uint                     `json:"partner_id"`
+		PartnerCode  string                    `json:"partner_code"`
+		PartnerName  string                    `json:"partner_name"`
+		Note         string                    `json:"note"`
+		CreatedByID  uint                      `json:"created_by_id"`
+		ApprovedByID *uint                     `json:"approved_by_id"`
+		ApprovedAt   *time.Time                `json:"approved_at"`
+		Items        []TransactionItemResponse `json:"items"`
+	}
+	TransactionItemResponse struct {
+		CommonModel
+		ProductID       uint       `json:"product_id"`
+		ProductCode     string     `json:"product_code"`
+		ProductName     string     `json:"product_name"`
+		ProductLotID    *uint      `json:"product_lot_id"`
+		LocationID      *uint      `json:"location_id"`
+		LotCode         string     `json:"lot_code"`
+		ManufactureDate *time.Time `json:"manufacture_date"`
+		ExpiryDate      *time.Time `json:"expiry_date"`
+		// Quantity and Price are in the base unit UOM of the product, the Uom
+		// fields give them in the unit they were entered in.
+		Quantity    float64 `json:"quantity"`
+		Price       float64 `json:"price"`
+		UOM         string  `json:"uom"`
+		UomID       *uint   `json:"uom_id"`
+		UomName     string  `json:"uom_name"`
+		UomFactor   float64 `json:"uom_factor"`
+		UomQuantity float64 `json:"uom_quantity"`
+		UomPrice    float64 `json:"uom_price"`
+		// Cost is the cost of goods of an approved sale item.
+		Cost float64 `json:"cost"`
+	}
+)
+
+// Create implements TransactionService.
+func (t *transactionService) Create(ctx context.Context, userId, teamId uint, body CreateTransactionRequest) (*TransactionResponse, error) {
+	transactionType := model.EntityType(body.Type)
+	var parentId *uint
+	partnerId := body.PartnerID
+	if transactionType == model.EntityTypeTransactionReturn {
+		if body.ParentID == 0 {
+			return nil, errs.ErrBadRequest
+		}
+		if partnerId == 0 {
+			parent, err := t.transaction.FindById(ctx, nil, teamId, body.ParentID)
+			if err != nil {
+				t.logger.Error(err)
+				return nil, errs.HandleSqlErr(err)
+			}
+			if parent.PartnerID != nil {
+				partnerId = *parent.PartnerID
+			}
+		}
+		// one return of a sale at a time, the returnable quantity depends on it
+		open, err := t.transaction.CountChildren(ctx, nil, body.ParentID, transactionEditable)
+		if err != nil {
+			t.logger.Error(err)
+			return nil, errs.HandleSqlErr(err)
+		}
+		if open > 0 {
+			return nil, errs.ErrChildTransactionNotApprove
+		}
+		parentId = &body.ParentID
+	}
+	items, err := t.toItems(ctx, nil, teamId, transactionType, parentId, body.Items)
+	if err != nil {
+		return nil, err
+	}
+	locationId, err := t.toLocation(ctx, nil, teamId, body.LocationID)
+	if err != nil {
+		return nil, err
+	}
+	partner, err := t.partner.Counterparty(ctx, nil, teamId, partnerId, transactionType)
+	if err != nil {
+		return nil, err
+	}
+	tx := t.dbTx.BeginTx()
+	defer t.dbTx.RollbackTx(tx) //nolint:errcheck
+	code, err := t.code.GenerateTransactionCode(ctx, tx, transactionType, teamId)
+	if err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	m := &model.Transaction{
+		TeamID:      teamId,
+		Code:        code,
+		Type:        transactionType,
+		Status:      model.TransactionStatusDraft,
+		ParentID:    parentId,
+		LocationID:  locationId,
+		PartnerID:   partner,
+		Note:        body.Note,
+		CreatedByID: userId,
+		Items:       items,
+	}
+	if err := t.transaction.Create(ctx, tx, m); err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := t.dbTx.CommitTx(tx); err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return t.FindById(ctx, teamId, m.ID)
+}
+
+// Update implements TransactionService.
+func (t *transactionService) Update(ctx context.Context, teamId, transactionId uint, body UpdateTransactionRequest) (*TransactionResponse, error) {
+	tx := t.dbTx.BeginTx()
+	defer t.dbTx.RollbackTx(tx) //nolint:errcheck
+	m, err := t.transaction.FindByIdForUpdate(ctx, tx, teamId, transactionId)
+	if err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if !slices.Contains(transactionEditable, m.Status) {
+		return nil, errs.ErrTransactionNotPending
+	}
+	items, err := t.toItems(ctx, tx, teamId, m.Type, m.ParentID, body.Items)
+	if err != nil {
+		return nil, err
+	}
+	locationId, err := t.toLocation(ctx, tx, teamId, body.LocationID)
+	if err != nil {
+		return nil, err
+	}
+	partner, err := t.partner.Counterparty(ctx, tx, teamId, body.PartnerID, m.Type)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.transaction.ReplaceItems(ctx, tx, m.ID, items); err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := t.transaction.UpdateNote(ctx, tx, m.ID, body.Note); err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := t.transaction.UpdateLocation(ctx, tx, m.ID, locationId); err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := t.transaction.UpdatePartner(ctx, tx, m.ID, partner); err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	// the amount may have changed, a pending transaction goes through its chain again
+	var next *model.TransactionApproval
+	if m.Status == model.TransactionStatusPending {
+		m.Items = items
+		if next, err = t.restartApproval(ctx, tx, m); err != nil {
+			return nil, err
+		}
+	}
+	if err := t.dbTx.CommitTx(tx); err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if next != nil {
+		t.notifyApprover(ctx, m, next)
+	}
+	return t.FindById(ctx, teamId, transactionId)
+}
+
+// FindById implements TransactionService.
+func (t *transactionService) FindById(ctx context.Context, teamId, transactionId uint) (*TransactionResponse, error) {
+	m, err := t.transaction.FindById(ctx, nil, teamId, transactionId)
+	if err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return toTransactionResponse(m), nil
+}
+
+// FindAll implements TransactionService.
+func (t *transactionService) FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.TransactionFilter) ([]TransactionResponse, error) {
+	models, err := t.transaction.FindAll(ctx, nil, teamId, pg, f)
+	if err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	helpers.Paging(pg)
+	res := make([]TransactionResponse, 0, len(models))
+	for i := range models {
+		res = append(res, *toTransactionResponse(&models[i]))
+	}
+	return res, nil
+}
+
+// Submit implements TransactionService.
+func (t *transactionService) Submit(ctx context.Context, userId, teamId, transactionId uint) (*TransactionResponse, error) {
+	var (
+		m    *model.Transaction
+		next *model.TransactionApproval
+	)
+	res, err := t.transition(ctx, teamId, transactionId, model.TransactionStatusPending, nil, func(ctx context.Context, tx *gorm.DB, tm *model.Transaction) (err error) {
+		m = tm
+		next, err = t.startApproval(ctx, tx, tm)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if next != nil {
+		t.notifyApprover(ctx, m, next)
+	}
+	return res, nil
+}
+
+// Approve implements TransactionService.
+func (t *transactionService) Approve(ctx context.Context, userId, teamId, transactionId uint, body DecideTransactionRequest) (*TransactionResponse, error) {
+	return t.decide(ctx, userId, teamId, transactionId, true, body.Remark, nil)
+}
+
+// Reject implements TransactionService.
+func (t *transactionService) Reject(ctx context.Context, userId, teamId, transactionId uint, body DecideTransactionRequest) (*TransactionResponse, error) {
+	return t.decide(ctx, userId, teamId, transactionId, false, body.Remark, nil)
+}
+
+// Cancel implements TransactionService.
+func (t *transactionService) Cancel(ctx context.Context, userId, teamId, transactionId uint) (*TransactionResponse, error) {
+	return t.transition(ctx, teamId, transactionId, model.TransactionStatusCancelled, nil, func(ctx context.Context, tx *gorm.DB, m *model.Transaction) error {
+		if err := t.approval.CancelPending(ctx, tx, m.ID); err != nil {
+			t.logger.Error(err)
+			return errs.HandleSqlErr(err)
+		}
+		return nil
+	})
+}
+
+// transition moves the transaction to status if transactionTransitions allows it,
+// run is called in the same database transaction before the status changes.
+func (t *transactionService) transition(
+	ctx context.Context,
+	teamId, transactionId uint,
+	status string,
+	updates map[string]any,
+	run func(ctx context.Context, tx *gorm.DB, m *model.Transaction) error,
+) (*TransactionResponse, error) {
+	from := transactionTransitions[status]
+	tx := t.dbTx.BeginTx()
+	defer t.dbTx.RollbackTx(tx) //nolint:errcheck
+	m, err := t.transaction.FindByIdForUpdate(ctx, tx, teamId, transactionId)
+	if err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if !slices.Contains(from, m.Status) {
+		return nil, errs.ErrTransactionNotPending
+	}
+	if run != nil {
+		if err := run(ctx, tx, m); err != nil {
+			return nil, err
+		}
+	}
+	if err := t.transaction.UpdateStatus(ctx, tx, m.ID, from, status, updates); err != nil {
+		t.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrTransactionNotPending
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := t.dbTx.CommitTx(tx); err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return t.FindById(ctx, teamId, transactionId)
+}
+
+// post moves the stock of m, see Approve.
+func (t *transactionService) post(ctx context.Context, tx *gorm.DB, m *model.Transaction) error {
+	switch m.Type {
+	case model.EntityTypeTransactionSale:
+		return t.postSale(ctx, tx, m)
+	case model.EntityTypeTransactionPurchase:
+		return t.postPurchase(ctx, tx, m)
+	case model.EntityTypeTransactionReturn:
+		return t.postReturn(ctx, tx, m)
+	default:
+		return errs.ErrBadRequest
+	}
+}
+
+// postSale splits every item into one item per allocated lot and location.
+func (t *transactionService) postSale(ctx context.Context, tx *gorm.DB, m *model.Transaction) error {
+	var (
+		items []model.TransactionItem
+		pick  StockPick
+	)
+	if m.LocationID != nil {
+		pick.LocationID = *m.LocationID
+	}
+	for _, item := range m.Items {
+		allocations, err := t.lot.Allocate(ctx, tx, m.TeamID, item.ProductID, item.Quantity, pick, stockRef(model.StockReasonSale, m))
+		if err != nil {
+			return err
+		}
+		for _, a := range allocations {
+			items = append(items, model.TransactionItem{
+				ProductID:    item.ProductID,
+				ProductLotID: &a.LotID,
+				LocationID:   &a.LocationID,
+				Quantity:     a.Quantity,
+				Price:        item.Price,
+				UomID:        item.UomID,
+				UomQuantity:  a.Quantity / uomFactor(item),
+				UomFactor:    uomFactor(item),
+				Cost:         a.Cost,
+				LotCode:      a.Code,
+			})
+		}
+	}
+	if err := t.transaction.ReplaceItems(ctx, tx, m.ID, items); err != nil {
+		t.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	m.Items = items
+	return nil
+}
+
+// postPurchase creates a lot for every item.
+func (t *transactionService) postPurchase(ctx context.Context, tx *gorm.DB, m *model.Transaction) error {
+	location, err := t.postLocation(ctx, tx, m)
+	if err != nil {
+		return err
+	}
+	items := slices.Clone(m.Items)
+	for i := range items {
+		lot := &model.ProductLot{
+			TeamID:          m.TeamID,
+			ProductID:       items[i].ProductID,
+			Code:            items[i].LotCode,
+			ManufactureDate: items[i].ManufactureDate,
+			ExpiryDate:      items[i].ExpiryDate,
+			ReceivedQty:     items[i].Quantity,
+			RemainingQty:    items[i].Quantity,
+			UnitCost:        items[i].Price,
+		}
+		if err := t.lot.CreateLot(ctx, tx, lot, location.ID, stockRef(model.StockReasonPurchase, m)); err != nil {
+			return err
+		}
+		items[i].ProductLotID = &lot.ID
+		items[i].LocationID = &location.ID
+		items[i].LotCode = lot.Code
+		items[i].Product = nil
+		items[i].ProductLot = nil
+	}
+	if err := t.transaction.ReplaceItems(ctx, tx, m.ID, items); err != nil {
+		t.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	m.Items = items
+	return nil
+}
+
+// postReturn puts the returned quantities back into the sold lots.
+func (t *transactionService) postReturn(ctx context.Context, tx *gorm.DB, m *model.Transaction) error {
+	if m.ParentID == nil {
+		return errs.ErrBadRequest
+	}
+	// serializes returns of the same sale
+	parent, err := t.findParent(ctx, tx, m.TeamID, *m.ParentID, true)
+	if err != nil {
+		return err
+	}
+	if err := t.checkReturn(ctx, tx, parent, m.Items); err != nil {
+		return err
+	}
+	location, err := t.postLocation(ctx, tx, m)
+	if err != nil {
+		return err
+	}
+	items := slices.Clone(m.Items)
+	allocations := make([]LotAllocation, 0, len(items))
+	for i := range items {
+		allocations = append(allocations, LotAllocation{
+			ProductID:  items[i].ProductID,
+			LotID:      *items[i].ProductLotID,
+			LocationID: location.ID,
+			Quantity:   items[i].Quantity,
+		})
+		items[i].LocationID = &location.ID
+		items[i].Product = nil
+		items[i].ProductLot = nil
+	}
+	if err := t.lot.Restock(ctx, tx, m.TeamID, allocations, stockRef(model.StockReasonReturn, m)); err != nil {
+		return err
+	}
+	if err := t.transaction.ReplaceItems(ctx, tx, m.ID, items); err != nil {
+		t.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	m.Items = items
+	return nil
+}
+
+// postLocation is where a purchase or return puts stock.
+func (t *transactionService) postLocation(ctx context.Context, tx *gorm.DB, m *model.Transaction) (*model.Location, error) {
+	var locationId uint
+	if m.LocationID != nil {
+		locationId = *m.LocationID
+	}
+	return t.warehouse.StorageLocation(ctx, tx, m.TeamID, locationId)
+}
+
+// toLocation validates the requested location, zero is none.
+func (t *transactionService) toLocation(ctx context.Context, tx *gorm.DB, teamId, locationId uint) (*uint, error) {
+	if locationId == 0 {
+		return nil, nil
+	}
+	if _, err := t.warehouse.StorageLocation(ctx, tx, teamId, locationId); err != nil {
+		return nil, err
+	}
+	return &locationId, nil
+}
+
+// stockRef points the stock movements of a posting at m.
+func stockRef(reason string, m *model.Transaction) StockRef {
+	return StockRef{Reason: reason, RefType: m.Type, RefID: &m.ID}
+}
+
+// toItems validates the requested items for the transaction type.
+func (t *transactionService) toItems(ctx context.Context, tx *gorm.DB, teamId uint, transactionType model.EntityType, parentId *uint, body []TransactionItemRequest) ([]model.TransactionItem, error) {
+	if len(body) == 0 {
+		return nil, errs.ErrBadRequest
+	}
+	productIds := make([]uint, 0, len(body))
+	for _, b := range body {
+		productIds = append(productIds, b.ProductID)
+	}
+	conversions, err := t.uom.Conversions(ctx, tx, productIds)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]model.TransactionItem, 0, len(body))
+	for _, b := range body {
+		factor, ok := conversions.Factor(b.ProductID, b.UomID)
+		if !ok {
+			return nil, errs.ErrProductUomNotFound
+		}
+		item := model.TransactionItem{
+			ProductID:   b.ProductID,
+			Quantity:    b.Quantity * factor,
+			Price:       b.Price / factor,
+			UomQuantity: b.Quantity,
+			UomFactor:   factor,
+		}
+		if b.UomID != 0 {
+			item.UomID = &b.UomID
+		} else if base := conversions.Base(b.ProductID); base != nil {
+			item.UomID = &base.UomID
+		}
+		switch transactionType {
+		case model.EntityTypeTransactionSale:
+			if b.ProductLotID != 0 || b.LotCode != "" || b.ManufactureDate != "" || b.ExpiryDate != "" {
+				return nil, errs.ErrBadRequest
+			}
+		case model.EntityTypeTransactionPurchase:
+			if b.ProductLotID != 0 {
+				return nil, errs.ErrBadRequest
+			}
+			var err error
+			if item.ManufactureDate, err = parseDate(b.ManufactureDate, t.conf.SERVER.LOCATION); err != nil {
+				return nil, errs.ErrBadRequest
+			}
+			if item.ExpiryDate, err = parseDate(b.ExpiryDate, t.conf.SERVER.LOCATION); err != nil {
+				return nil, errs.ErrBadRequest
+			}
+			if item.ManufactureDate.Valid && item.ExpiryDate.Valid && item.ExpiryDate.Time.Before(item.ManufactureDate.Time) {
+				return nil, errs.ErrProductLotInvalidDates
+			}
+			item.LotCode = b.LotCode
+		case model.EntityTypeTransactionReturn:
+			if b.ProductLotID == 0 {
+				return nil, errs.ErrDoNotHaveLotItem
+			}
+			if b.LotCode != "" || b.ManufactureDate != "" || b.ExpiryDate != "" {
+				return nil, errs.ErrBadRequest
+			}
+			item.ProductLotID = &b.ProductLotID
+		default:
+			return nil, errs.ErrBadRequest
+		}
+		items = append(items, item)
+	}
+
+	ids := slices.Compact(slices.Sorted(slices.Values(productIds)))
+	count, err := t.product.CountByIds(ctx, tx, teamId, ids)
+	if err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if count != int64(len(ids)) {
+		return nil, errs.ErrNotFound
+	}
+
+	if transactionType == model.EntityTypeTransactionReturn {
+		if parentId == nil {
+			return nil, errs.ErrBadRequest
+		}
+		parent, err := t.findParent(ctx, tx, teamId, *parentId, false)
+		if err != nil {
+			return nil, err
+		}
+		if err := t.checkReturn(ctx, tx, parent, items); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// findParent returns the sale a return references, it must be approved.
+func (t *transactionService) findParent(ctx context.Context, tx *gorm.DB, teamId, parentId uint, lock bool) (*model.Transaction, error) {
+	find := t.transaction.FindById
+	if lock {
+		find = t.transaction.FindByIdForUpdate
+	}
+	parent, err := find(ctx, tx, teamId, parentId)
+	if err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if parent.Type != model.EntityTypeTransactionSale {
+		return nil, errs.ErrTransactionNotTypeSale
+	}
+	if parent.Status != model.TransactionStatusApproved {
+		return nil, errs.ErrTransactionNotComplete
+	}
+	return parent, nil
+}
+
+// checkReturn makes sure items return only lots the sale issued, and no more
+// than it issued minus what approved returns already took back.
+func (t *transactionService) checkReturn(ctx context.Context, tx *gorm.DB, parent *model.Transaction, items []model.TransactionItem) error {
+	issued := make(map[uint]float64)
+	products := make(map[uint]uint)
+	for _, item := range parent.Items {
+		if item.ProductLotID == nil {
+			continue
+		}
+		issued[*item.ProductLotID] += item.Quantity
+		products[*item.ProductLotID] = item.ProductID
+	}
+	returned, err := t.transaction.SumReturnedByLot(ctx, tx, parent.ID)
+	if err != nil {
+		t.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	for _, item := range items {
+		lotId := *item.ProductLotID
+		if productId, ok := products[lotId]; !ok || productId != item.ProductID {
+			return errs.ErrDoNotHaveLotItem
+		}
+		returned[lotId] += item.Quantity
+		if returned[lotId]-issued[lotId] > repository.StockTolerance {
+			return errs.ErrReturnMoreThanLotItem
+		}
+	}
+	return nil
+}
+
+// uomFactor is the factor of the unit the item was entered in, items saved
+// before units of measure are in the base unit.
+func uomFactor(item model.TransactionItem) float64 {
+	if item.UomFactor <= 0 {
+		return 1
+	}
+	return item.UomFactor
+}
+
+func toTransactionResponse(m *model.Transaction) *TransactionResponse {
+	res := &TransactionResponse{
+		CommonModel: CommonModel{
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		},
+		TeamID:       m.TeamID,
+		Code:         m.Code,
+		Type:         string(m.Type),
+		Status:       m.Status,
+		ParentID:     m.ParentID,
+		LocationID:   m.LocationID,
+		PartnerID:    m.PartnerID,
+		Note:         m.Note,
+		CreatedByID:  m.CreatedByID,
+		ApprovedByID: m.ApprovedByID,
+		ApprovedAt:   nullTimePtr(m.ApprovedAt),
+		Items:        make([]TransactionItemResponse, 0, len(m.Items)),
+	}
+	if m.Partner != nil {
+		res.PartnerCode = m.Partner.Code
+		res.PartnerName = m.Partner.Name
+	}
+	for _, item := range m.Items {
+		i := TransactionItemResponse{
+			CommonModel: CommonModel{
+				ID:        item.ID,
+				CreatedAt: item.CreatedAt,
+				UpdatedAt: item.UpdatedAt,
+			},
+			ProductID:       item.ProductID,
+			ProductLotID:    item.ProductLotID,
+			LocationID:      item.LocationID,
+			LotCode:         item.LotCode,
+			ManufactureDate: nullTimePtr(item.ManufactureDate),
+			ExpiryDate:      nullTimePtr(item.ExpiryDate),
+			Quantity:        item.Quantity,
+			Price:           item.Price,
+			UomID:           item.UomID,
+			UomFactor:       uomFactor(item),
+			UomQuantity:     item.Quantity / uomFactor(item),
+			UomPrice:        item.Price * uomFactor(item),
+			Cost:            item.Cost,
+		}
+		if item.Product != nil {
+			i.ProductCode = item.Product.Code
+			i.ProductName = item.Product.Name
+			i.UOM = item.Product.UOM
+			i.UomName = item.Product.UOM
+		}
+		if item.Uom != nil {
+			i.UomName = item.Uom.Name
+		}
+		if item.ProductLot != nil {
+			i.LotCode = item.ProductLot.Code
+			i.ManufactureDate = nullTimePtr(item.ProductLot.ManufactureDate)
+			i.ExpiryDate = nullTimePtr(item.ProductLot.ExpiryDate)
+		}
+		res.Items = append(res.Items, i)
+	}
+	return res
+}
+
+func NewTransactionService(
+	transaction repository.Transaction,
+	product repository.Product,
+	dbTx repository.DatabaseTransaction,
+	code CodeService,
+	lot ProductLotService,
+	warehouse WarehouseService,
+	uom UomService,
+	partner PartnerService,
+	approval repository.TransactionApproval,
+	step repository.ApprovalStep,
+	user repository.User,
+	email Email,
+	signer helpers.URLSigner,
+	conf *config.Config,
+	logger logger.AppLogger,
+) TransactionService {
+	return &transactionService{
+		transaction: transaction,
+		product:     product,
+		dbTx:        dbTx,
+		code:        code,
+		lot:         lot,
+		warehouse:   warehouse,
+		uom:         uom,
+		partner:     partner,
+		approval:    approval,
+		step:        step,
+		user:        user,
+		email:       email,
+		signer:      signer,
+		conf:        conf,
+		logger:      logger,
+	}
+}
@@ -0,0 +1,353 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/model"
+	"gorm.io/gorm"
+)
+
+// actions of the emailed approval links, the last segment of their path
+const (
+	ApprovalActionApprove = "approve"
+	ApprovalActionReject  = "reject"
+)
+
+type (
+	DecideTransactionRequest struct {
+		Remark string `json:"remark" form:"remark" binding:"max=500"`
+	}
+
+	TransactionApprovalResponse struct {
+		CommonModel
+		TeamID          uint       `json:"team_id"`
+		TransactionID   uint       `json:"transaction_id"`
+		TransactionCode string     `json:"transaction_code"`
+		Level           int        `json:"level"`
+		ApproverID      uint       `json:"approver_id"`
+		ApproverName    string     `json:"approver_name"`
+		Status          string     `json:"status"`
+		Remark          string     `json:"remark"`
+		DecidedAt       *time.Time `json:"decided_at"`
+	}
+)
+
+// DecideByLink implements TransactionService.
+func (t *transactionService) DecideByLink(ctx context.Context, approvalId uint, approve bool, query url.Values, body DecideTransactionRequest) (*TransactionApprovalResponse, error) {
+	a, err := t.FindApprovalByLink(ctx, approvalId, approve, query)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := t.decide(ctx, a.ApproverID, a.TeamID, a.TransactionID, approve, body.Remark, &a.ID); err != nil {
+		return nil, err
+	}
+	return t.findApproval(ctx, approvalId)
+}
+
+// FindApprovalByLink implements TransactionService.
+func (t *transactionService) FindApprovalByLink(ctx context.Context, approvalId uint, approve bool, query url.Values) (*TransactionApprovalResponse, error) {
+	if err := t.signer.Verify(approvalLinkPath(approvalId, approve), query, time.Now()); err != nil {
+		if errors.Is(err, helpers.ErrSignedUrlExpired) {
+			return nil, errs.ErrApprovalLinkExpired
+		}
+		return nil, errs.ErrApprovalLinkInvalid
+	}
+	return t.findApproval(ctx, approvalId)
+}
+
+// FindApprovals implements TransactionService.
+func (t *transactionService) FindApprovals(ctx context.Context, teamId, transactionId uint) ([]TransactionApprovalResponse, error) {
+	m, err := t.transaction.FindById(ctx, nil, teamId, transactionId)
+	if err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	approvals, err := t.approval.FindByTransaction(ctx, nil, m.ID)
+	if err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	res := make([]TransactionApprovalResponse, 0, len(approvals))
+	for i := range approvals {
+		res = append(res, *toTransactionApprovalResponse(&approvals[i], m.Code))
+	}
+	return res, nil
+}
+
+func (t *transactionService) findApproval(ctx context.Context, approvalId uint) (*TransactionApprovalResponse, error) {
+	a, err := t.approval.FindById(ctx, nil, approvalId)
+	if err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	m, err := t.transaction.FindById(ctx, nil, a.TeamID, a.TransactionID)
+	if err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if a.Approver, err = t.user.FindById(ctx, nil, a.ApproverID); err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return toTransactionApprovalResponse(a, m.Code), nil
+}
+
+// decide records the decision of userId on the current step of the approval
+// chain of a pending transaction. approvalId, when set, must be that step.
+// Approving the last step posts the stock, rejecting any step ends the chain.
+// Without a chain anyone allowed to approve decides, the decision is recorded as level 0.
+func (t *transactionService) decide(ctx context.Context, userId, teamId, transactionId uint, approve bool, remark string, approvalId *uint) (*TransactionResponse, error) {
+	tx := t.dbTx.BeginTx()
+	defer t.dbTx.RollbackTx(tx) //nolint:errcheck
+	m, err := t.transaction.FindByIdForUpdate(ctx, tx, teamId, transactionId)
+	if err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if m.Status != model.TransactionStatusPending {
+		if approvalId != nil {
+			return nil, errs.ErrApprovalNotCurrent
+		}
+		return nil, errs.ErrTransactionNotPending
+	}
+	pending, err := t.approval.FindPending(ctx, tx, m.ID)
+	if err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if len(pending) == 0 {
+		if approvalId != nil {
+			return nil, errs.ErrApprovalNotCurrent
+		}
+		pending = []model.TransactionApproval{{
+			TeamID:        m.TeamID,
+			TransactionID: m.ID,
+			ApproverID:    userId,
+			Status:        model.ApprovalStatusPending,
+		}}
+		if err := t.approval.Create(ctx, tx, pending); err != nil {
+			t.logger.Error(err)
+			return nil, errs.HandleSqlErr(err)
+		}
+	}
+	current := pending[0]
+	if approvalId != nil && *approvalId != current.ID {
+		return nil, errs.ErrApprovalNotCurrent
+	}
+	if current.ApproverID != userId {
+		return nil, errs.ErrForbidden
+	}
+
+	now := sql.NullTime{Time: time.Now(), Valid: true}
+	status := model.ApprovalStatusRejected
+	if approve {
+		status = model.ApprovalStatusApproved
+	}
+	if err := t.approval.Decide(ctx, tx, current.ID, status, remark, now); err != nil {
+		t.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrApprovalNotCurrent
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+
+	var next *model.TransactionApproval
+	switch {
+	case approve && len(pending) > 1:
+		next = &pending[1]
+	case approve:
+		if err := t.post(ctx, tx, m); err != nil {
+			return nil, err
+		}
+		status = model.TransactionStatusApproved
+	default:
+		if err := t.approval.CancelPending(ctx, tx, m.ID); err != nil {
+			t.logger.Error(err)
+			return nil, errs.HandleSqlErr(err)
+		}
+		status = model.TransactionStatusRejected
+	}
+	if next == nil {
+		updates := map[string]any{
+			"approved_by_id": userId,
+			"approved_at":    now,
+		}
+		if err := t.transaction.UpdateStatus(ctx, tx, m.ID, transactionTransitions[status], status, updates); err != nil {
+			t.logger.Error(err)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errs.ErrTransactionNotPending
+			}
+			return nil, errs.HandleSqlErr(err)
+		}
+	}
+	if err := t.dbTx.CommitTx(tx); err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+
+	if next != nil {
+		t.notifyApprover(ctx, m, next)
+	} else {
+		t.notifyRequester(ctx, m, userId, approve, remark)
+	}
+	return t.FindById(ctx, teamId, transactionId)
+}
+
+// startApproval copies the steps of the approval chain applying to m as pending
+// approvals and returns the first one, nil when the team has no chain for m.
+func (t *transactionService) startApproval(ctx context.Context, tx *gorm.DB, m *model.Transaction) (*model.TransactionApproval, error) {
+	steps, err := t.step.FindChain(ctx, tx, m.TeamID, m.Type, transactionTotal(m))
+	if err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if len(steps) == 0 {
+		return nil, nil
+	}
+	approvals := make([]model.TransactionApproval, 0, len(steps))
+	for _, s := range steps {
+		approvals = append(approvals, model.TransactionApproval{
+			TeamID:        m.TeamID,
+			TransactionID: m.ID,
+			Level:         s.Level,
+			ApproverID:    s.ApproverID,
+			Status:        model.ApprovalStatusPending,
+		})
+	}
+	if err := t.approval.Create(ctx, tx, approvals); err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return &approvals[0], nil
+}
+
+// restartApproval cancels the undecided approvals of m and starts its chain again.
+func (t *transactionService) restartApproval(ctx context.Context, tx *gorm.DB, m *model.Transaction) (*model.TransactionApproval, error) {
+	if err := t.approval.CancelPending(ctx, tx, m.ID); err != nil {
+		t.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return t.startApproval(ctx, tx, m)
+}
+
+// notifyApprover emails the approver of a with links to approve or reject m.
+// The decision does not depend on the email, failures are only logged.
+func (t *transactionService) notifyApprover(ctx context.Context, m *model.Transaction, a *model.TransactionApproval) {
+	approver, err := t.user.FindById(ctx, nil, a.ApproverID)
+	if err != nil {
+		t.logger.Error(err)
+		return
+	}
+	requester, err := t.user.FindById(ctx, nil, m.CreatedByID)
+	if err != nil {
+		t.logger.Error(err)
+		return
+	}
+	expires := time.Now().Add(t.conf.APPROVAL.LINK_EXPIRE)
+	body := &RequestApproveTransactionDto{
+		Team:        fmt.Sprintf("%d", m.TeamID),
+		Code:        m.Code,
+		User:        userDisplayName(requester),
+		RequestDate: m.CreatedAt.Format("02/01/2006 15:04"),
+		TotalPrice:  fmt.Sprintf("%.2f", transactionTotal(m)),
+		Remark:      m.Note,
+		ApproveURL:  t.approvalLink(a.ID, true, expires),
+		RejectURL:   t.approvalLink(a.ID, false, expires),
+	}
+	if err := t.email.NewRequest([]string{approver.Email}, fmt.Sprintf("ขออนุมัติรายการ %s", m.Code)).
+		ParseRequestApproveTransactionTemplate(ctx, body).
+		SendMail(ctx); err != nil {
+		t.logger.Error(err)
+	}
+}
+
+// notifyRequester emails the creator of m the final decision of deciderId.
+func (t *transactionService) notifyRequester(ctx context.Context, m *model.Transaction, deciderId uint, approve bool, remark string) {
+	requester, err := t.user.FindById(ctx, nil, m.CreatedByID)
+	if err != nil {
+		t.logger.Error(err)
+		return
+	}
+	decider, err := t.user.FindById(ctx, nil, deciderId)
+	if err != nil {
+		t.logger.Error(err)
+		return
+	}
+	body := &RejectAndApproveTransactionDto{
+		Code:        m.Code,
+		User:        userDisplayName(decider),
+		RequestDate: m.CreatedAt.Format("02/01/2006 15:04"),
+		TotalPrice:  fmt.Sprintf("%.2f", transactionTotal(m)),
+		Remark:      remark,
+		ApproveURL:  fmt.Sprintf("%s/api/v1/teams/%d/transactions/%d", t.conf.SERVER.HOST_NAME, m.TeamID, m.ID),
+	}
+	mail := t.email.NewRequest([]string{requester.Email}, fmt.Sprintf("ผลการพิจารณารายการ %s", m.Code))
+	if approve {
+		mail = mail.ParseApproveTransactionTemplate(ctx, body)
+	} else {
+		mail = mail.ParseRejectTransactionTemplate(ctx, body)
+	}
+	if err := mail.SendMail(ctx); err != nil {
+		t.logger.Error(err)
+	}
+}
+
+// approvalLink returns the signed link deciding approval approvalId, valid until expires.
+func (t *transactionService) approvalLink(approvalId uint, approve bool, expires time.Time) string {
+	path := approvalLinkPath(approvalId, approve)
+	q := t.signer.Sign(path, nil, expires)
+	return t.conf.SERVER.HOST_NAME + "/api/v1" + path + "?" + q.Encode()
+}
+
+func approvalLinkPath(approvalId uint, approve bool) string {
+	action := ApprovalActionReject
+	if approve {
+		action = ApprovalActionApprove
+	}
+	return fmt.Sprintf("/approvals/%d/%s", approvalId, action)
+}
+
+// transactionTotal is the amount approval thresholds are compared with.
+func transactionTotal(m *model.Transaction) float64 {
+	var total float64
+	for _, item := range m.Items {
+		total += item.Quantity * item.Price
+	}
+	return total
+}
+
+func userDisplayName(u *model.User) string {
+	if u.FullName != "" {
+		return u.FullName
+	}
+	return u.Username
+}
+
+func toTransactionApprovalResponse(a *model.TransactionApproval, transactionCode string) *TransactionApprovalResponse {
+	res := &TransactionApprovalResponse{
+		CommonModel: CommonModel{
+			ID:        a.ID,
+			CreatedAt: a.CreatedAt,
+			UpdatedAt: a.UpdatedAt,
+		},
+		TeamID:          a.TeamID,
+		TransactionID:   a.TransactionID,
+		TransactionCode: transactionCode,
+		Level:           a.Level,
+		ApproverID:      a.ApproverID,
+		Status:          a.Status,
+		Remark:          a.Remark,
+		DecidedAt:       nullTimePtr(a.DecidedAt),
+	}
+	if a.Approver != nil {
+		res.ApproverName = userDisplayName(a.Approver)
+	}
+	return res
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+type (
+	// UomService manages the unit of measure catalog of a team and converts
+	// quantities between the units of a product and its base unit.
+	UomService interface {
+		Create(ctx context.Context, teamId uint, body UomRequest) (*UomResponse, error)
+		// Update renames the unit, products based on it show the new name.
+		Update(ctx context.Context, teamId, uomId uint, body UomRequest) (*UomResponse, error)
+		// Delete fails with ErrUomInUse while a product or a transaction uses the unit.
+		Delete(ctx context.Context, teamId, uomId uint) error
+		FindById(ctx context.Context, teamId, uomId uint) (*UomResponse, error)
+		FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.UomFilter) ([]UomResponse, error)
+
+		// BaseUom returns the unit uomId of the team, or when uomId is zero the
+		// unit named name, added to the catalog if the team has none.
+		BaseUom(ctx context.Context, tx *gorm.DB, teamId, uomId uint, name string) (*model.Uom, error)
+		// ProductUoms validates the alternate units of a product based on baseUomId.
+		ProductUoms(ctx context.Context, tx *gorm.DB, teamId, baseUomId uint, body []ProductUomRequest) ([]model.ProductUom, error)
+		// Conversions returns the units of the products.
+		Conversions(ctx context.Context, tx *gorm.DB, productIds []uint) (UomConversions, error)
+	}
+	uomService struct {
+		uom    repository.Uom
+		dbTx   repository.DatabaseTransaction
+		logger logger.AppLogger
+	}
+
+	// UomConversions holds the units of products by product, base unit first.
+	UomConversions map[uint][]repository.UomConversion
+
+	UomRequest struct {
+		Name        string `json:"name" binding:"required,max=50"`
+		Description string `json:"description"`
+	}
+	ProductUomRequest struct {
+		UomID uint `json:"uom_id" binding:"required"`
+		// Factor is the number of base units in one of the unit.
+		Factor float64 `json:"factor" binding:"required,gt=0"`
+	}
+
+	UomResponse struct {
+		CommonModel
+		TeamID      uint   `json:"team_id"`
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	ProductUomResponse struct {
+		CommonModel
+		ProductID uint        `json:"product_id"`
+		UomID     uint        `json:"uom_id"`
+		Factor    float64     `json:"factor"`
+		Uom       UomResponse `json:"uom"`
+	}
+	// UomQuantityResponse is a quantity expressed in a unit of the product.
+	UomQuantityResponse struct {
+		UomID    uint    `json:"uom_id"`
+		UomName  string  `json:"uom_name"`
+		Factor   float64 `json:"factor"`
+		Quantity float64 `json:"quantity"`
+	}
+)
+
+// Create implements UomService.
+func (u *uomService) Create(ctx context.Context, teamId uint, body UomRequest) (*UomResponse, error) {
+	m := &model.Uom{
+		TeamID:      teamId,
+		Name:        body.Name,
+		Description: body.Description,
+	}
+	if err := u.uom.Create(ctx, nil, m); err != nil {
+		u.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return toUomResponse(m), nil
+}
+
+// Update implements UomService.
+func (u *uomService) Update(ctx context.Context, teamId, uomId uint, body UomRequest) (*UomResponse, error) {
+	tx := u.dbTx.BeginTx()
+	defer u.dbTx.RollbackTx(tx) //nolint:errcheck
+	if err := u.uom.Update(ctx, tx, teamId, uomId, body.Name, body.Description); err != nil {
+		u.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUomNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := u.dbTx.CommitTx(tx); err != nil {
+		u.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return u.FindById(ctx, teamId, uomId)
+}
+
+// Delete implements UomService.
+func (u *uomService) Delete(ctx context.Context, teamId, uomId uint) error {
+	if _, err := u.FindById(ctx, teamId, uomId); err != nil {
+		return err
+	}
+	used, err := u.uom.InUse(ctx, nil, uomId)
+	if err != nil {
+		u.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	if used {
+		return errs.ErrUomInUse
+	}
+	if err := u.uom.Delete(ctx, nil, teamId, uomId); err != nil {
+		u.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return nil
+}
+
+// FindById implements UomService.
+func (u *uomService) FindById(ctx context.Context, teamId, uomId uint) (*UomResponse, error) {
+	m, err := u.uom.FindById(ctx, nil, teamId, uomId)
+	if err != nil {
+		u.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUomNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return toUomResponse(m), nil
+}
+
+// FindAll implements UomService.
+func (u *uomService) FindAll(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.UomFilter) ([]UomResponse, error) {
+	uoms, err := u.uom.FindAll(ctx, nil, teamId, pg, f)
+	if err != nil {
+		u.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	helpers.Paging(pg)
+	res := make([]UomResponse, 0, len(uoms))
+	for i := range uoms {
+		res = append(res, *toUomResponse(&uoms[i]))
+	}
+	return res, nil
+}
+
+// BaseUom implements UomService.
+func (u *uomService) BaseUom(ctx context.Context, tx *gorm.DB, teamId, uomId uint, name string) (*model.Uom, error) {
+	if uomId != 0 {
+		m, err := u.uom.FindById(ctx, tx, teamId, uomId)
+		if err != nil {
+			u.logger.Error(err)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errs.ErrUomNotFound
+			}
+			return nil, errs.HandleSqlErr(err)
+		}
+		return m, nil
+	}
+	if name == "" {
+		return nil, errs.ErrUomNotFound
+	}
+	m := &model.Uom{TeamID: teamId, Name: name}
+	if err := u.uom.CreateIfNotExists(ctx, tx, m); err != nil {
+		u.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return m, nil
+}
+
+// ProductUoms implements UomService.
+func (u *uomService) ProductUoms(ctx context.Context, tx *gorm.DB, teamId, baseUomId uint, body []ProductUomRequest) ([]model.ProductUom, error) {
+	if len(body) == 0 {
+		return nil, nil
+	}
+	ids := make([]uint, 0, len(body))
+	uoms := make([]model.ProductUom, 0, len(body))
+	for _, b := range body {
+		if b.UomID == baseUomId || slices.Contains(ids, b.UomID) {
+			return nil, errs.ErrProductUomInvalid
+		}
+		ids = append(ids, b.UomID)
+		uoms = append(uoms, model.ProductUom{UomID: b.UomID, Factor: b.Factor})
+	}
+	count, err := u.uom.CountByIds(ctx, tx, teamId, ids)
+	if err != nil {
+		u.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if count != int64(len(ids)) {
+		return nil, errs.ErrUomNotFound
+	}
+	return uoms, nil
+}
+
+// Conversions implements UomService.
+func (u *uomService) Conversions(ctx context.Context, tx *gorm.DB, productIds []uint) (UomConversions, error) {
+	ids := slices.Compact(slices.Sorted(slices.Values(productIds)))
+	conversions, err := u.uom.FindConversions(ctx, tx, ids)
+	if err != nil {
+		u.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	res := make(UomConversions, len(ids))
+	for _, c := range conversions {
+		res[c.ProductID] = append(res[c.ProductID], c)
+	}
+	return res, nil
+}
+
+// Factor returns the number of base units of the product in one uomId, zero
+// uomId is the base unit. ok is false when the product has no such unit.
+func (c UomConversions) Factor(productId, uomId uint) (factor float64, ok bool) {
+	if uomId == 0 {
+		return 1, true
+	}
+	for _, conversion := range c[productId] {
+		if conversion.UomID == uomId {
+			return conversion.Factor, true
+		}
+	}
+	return 0, false
+}
+
+// Base returns the base unit of the product, nil for products without one.
+func (c UomConversions) Base(productId uint) *repository.UomConversion {
+	for _, conversion := range c[productId] {
+		if conversion.IsBase {
+			return &conversion
+		}
+	}
+	return nil
+}
+
+// Express returns base quantity qty of the product in each of its alternate units.
+func (c UomConversions) Express(productId uint, qty float64) []UomQuantityResponse {
+	res := []UomQuantityResponse{}
+	for _, conversion := range c[productId] {
+		if conversion.IsBase {
+			continue
+		}
+		res = append(res, UomQuantityResponse{
+			UomID:    conversion.UomID,
+			UomName:  conversion.UomName,
+			Factor:   conversion.Factor,
+			Quantity: qty / conversion.Factor,
+		})
+	}
+	return res
+}
+
+func toUomResponse(m *model.Uom) *UomResponse {
+	return &UomResponse{
+		CommonModel: CommonModel{
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		},
+		TeamID:      m.TeamID,
+		Name:        m.Name,
+		Description: m.Description,
+	}
+}
+
+func NewUomService(
+	uom repository.Uom,
+	dbTx repository.DatabaseTransaction,
+	logger logger.AppLogger,
+) UomService {
+	return &uomService{
+		uom:    uom,
+		dbTx:   dbTx,
+		logger: logger,
+	}
+}
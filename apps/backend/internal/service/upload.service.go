@@ -0,0 +1,531 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// tus 1.0, https://tus.io/protocols/resumable-upload
+const (
+	TusVersion    = "1.0.0"
+	TusExtensions = "creation,termination,expiration"
+	// TusChunkContentType is the only Content-Type accepted for PATCH requests.
+	TusChunkContentType = "application/offset+octet-stream"
+
+	// storage prefix of unfinished uploads, one folder per upload
+	uploadPath = "uploads"
+)
+
+// targets of an upload, given as the "target" Upload-Metadata key
+const (
+	UploadTargetImage        = "image"
+	UploadTargetProfileImage = "profile_image"
+)
+
+type (
+	// UploadHook turns a finished upload into a record, it runs inside tx and
+	// returns the id of the created record. file is removed after the hook
+	// returns, so the hook has to copy what it keeps.
+	UploadHook func(ctx context.Context, tx *gorm.DB, file *UploadedFile) (resultId uint, err error)
+	// UploadCheck refuses an upload before any chunk is accepted, e.g. when the
+	// user may not write to the record the hook would create.
+	UploadCheck func(ctx context.Context, userId uint, metadata map[string]string) error
+
+	UploadService interface {
+		// RegisterHook sets the hook run for uploads created with target, kind
+		// selects the UploadPolicy the content has to pass.
+		RegisterHook(target string, kind UploadKind, hook UploadHook)
+		// RegisterCheck adds a check run when an upload for target is created.
+		RegisterCheck(target string, check UploadCheck)
+		Create(ctx context.Context, userId uint, dto CreateUploadDto) (*UploadResponse, error)
+		GetUpload(ctx context.Context, userId uint, uploadId string) (*UploadResponse, error)
+		// WriteChunk appends r at offset, the upload is handed to its hook once
+		// the last byte has been written.
+		WriteChunk(ctx context.Context, userId uint, uploadId string, offset int64, r io.Reader) (*UploadResponse, error)
+		Terminate(ctx context.Context, userId uint, uploadId string) error
+	}
+	uploadService struct {
+		conf    *config.Config
+		storage repository.Storage
+		upload  repository.Upload
+		dbTx    repository.DatabaseTransaction
+		policy  UploadPolicy
+		logger  logger.AppLogger
+
+		mu      sync.RWMutex
+		targets map[string]uploadTarget
+	}
+	uploadTarget struct {
+		kind  UploadKind
+		hook  UploadHook
+		check UploadCheck
+	}
+
+	CreateUploadDto struct {
+		Length   int64
+		Metadata string // raw Upload-Metadata header
+	}
+
+	UploadResponse struct {
+		CommonModel
+		UUID      string    `json:"uuid"`
+		Length    int64     `json:"length"`
+		Offset    int64     `json:"offset"`
+		Metadata  string    `json:"metadata"`
+		Filename  string    `json:"filename"`
+		Target    string    `json:"target"`
+		ExpiresAt time.Time `json:"expires_at"`
+		Completed bool      `json:"completed"`
+		ResultID  uint      `json:"result_id"`
+	}
+
+	// UploadedFile is a finished upload assembled into a single storage object.
+	UploadedFile struct {
+		UserID      uint
+		UploadUUID  string
+		Filename    string
+		Metadata    map[string]string
+		Key         string
+		Hash        string // hex SHA-256
+		Size        int64
+		ContentType string
+	}
+)
+
+// RegisterHook implements UploadService.
+func (u *uploadService) RegisterHook(target string, kind UploadKind, hook UploadHook) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	t := u.targets[target]
+	t.kind, t.hook = kind, hook
+	u.targets[target] = t
+}
+
+// RegisterCheck implements UploadService.
+func (u *uploadService) RegisterCheck(target string, check UploadCheck) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	t := u.targets[target]
+	t.check = check
+	u.targets[target] = t
+}
+
+// Create implements UploadService.
+func (u *uploadService) Create(ctx context.Context, userId uint, dto CreateUploadDto) (*UploadResponse, error) {
+	if err := u.policy.CheckSize(dto.Length); err != nil {
+		return nil, err
+	}
+	if err := u.policy.CheckQuota(ctx, userId, dto.Length); err != nil {
+		return nil, err
+	}
+	meta, err := ParseUploadMetadata(dto.Metadata)
+	if err != nil {
+		return nil, errs.ErrUploadInvalidMetadata
+	}
+	target := meta["target"]
+	if target == "" {
+		target = UploadTargetImage
+	}
+	t, ok := u.target(target)
+	if !ok {
+		return nil, errs.ErrUploadTargetNotSupported
+	}
+	if t.check != nil {
+		if err := t.check(ctx, userId, meta); err != nil {
+			return nil, err
+		}
+	}
+	m := &model.Upload{
+		UUID:      uuid.NewString(),
+		UserID:    userId,
+		Length:    dto.Length,
+		Metadata:  dto.Metadata,
+		Filename:  path.Base(meta["filename"]),
+		Target:    target,
+		ExpiresAt: time.Now().Add(u.conf.TUS.EXPIRE),
+	}
+	if err := u.upload.Create(ctx, nil, m); err != nil {
+		u.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if m.Length == 0 {
+		// nothing to wait for
+		return u.finish(ctx, m)
+	}
+	return u.toResponse(m), nil
+}
+
+// GetUpload implements UploadService.
+func (u *uploadService) GetUpload(ctx context.Context, userId uint, uploadId string) (*UploadResponse, error) {
+	m, err := u.find(ctx, userId, uploadId)
+	if err != nil {
+		return nil, err
+	}
+	return u.toResponse(m), nil
+}
+
+// WriteChunk implements UploadService.
+func (u *uploadService) WriteChunk(ctx context.Context, userId uint, uploadId string, offset int64, r io.Reader) (*UploadResponse, error) {
+	m, err := u.find(ctx, userId, uploadId)
+	if err != nil {
+		return nil, err
+	}
+	if m.CompletedAt.Valid {
+		if offset != m.Length {
+			return nil, errs.ErrUploadOffsetMismatch
+		}
+		return u.toResponse(m), nil
+	}
+	if offset != m.Offset {
+		return nil, errs.ErrUploadOffsetMismatch
+	}
+
+	if remaining := m.Length - m.Offset; remaining > 0 {
+		if offset == 0 {
+			// refuse the wrong type before the first byte is stored
+			t, ok := u.target(m.Target)
+			if !ok {
+				return nil, errs.ErrUploadTargetNotSupported
+			}
+			br := bufio.NewReaderSize(r, 512)
+			head, err := br.Peek(int(min(remaining, 512)))
+			if err != nil && !errors.Is(err, io.EOF) {
+				u.logger.Error(err)
+				return nil, errs.ErrBadRequest
+			}
+			if _, err := u.policy.CheckContentType(t.kind, head); err != nil {
+				return nil, err
+			}
+			r = br
+		}
+		// a random suffix keeps concurrent requests for the same offset apart,
+		// only the one moving the offset forward is recorded
+		key := path.Join(uploadPath, m.UUID, fmt.Sprintf("%020d-%s", offset, uuid.NewString()[:8]))
+		obj, err := u.storage.Put(ctx, key, io.LimitReader(r, remaining), -1, TusChunkContentType)
+		if err != nil {
+			u.logger.Error(err)
+			return nil, errs.ErrInternal
+		}
+		if n, _ := r.Read(make([]byte, 1)); n > 0 {
+			u.deleteObject(ctx, key)
+			return nil, errs.ErrUploadTooLarge
+		}
+		if obj.Size > 0 {
+			m.ExpiresAt = time.Now().Add(u.conf.TUS.EXPIRE)
+			part := &model.UploadPart{Offset: offset, Size: obj.Size, Key: obj.Key}
+			if err := u.upload.AppendPart(ctx, nil, m, part); err != nil {
+				u.deleteObject(ctx, key)
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, errs.ErrUploadOffsetMismatch
+				}
+				u.logger.Error(err)
+				return nil, errs.HandleSqlErr(err)
+			}
+			m.Offset += obj.Size
+		} else {
+			u.deleteObject(ctx, key)
+		}
+	}
+	if m.Offset < m.Length {
+		return u.toResponse(m), nil
+	}
+	// reload the parts, some may have been written by earlier requests
+	if m, err = u.upload.FindByUUID(ctx, nil, m.UUID); err != nil {
+		u.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return u.finish(ctx, m)
+}
+
+// Terminate implements UploadService.
+func (u *uploadService) Terminate(ctx context.Context, userId uint, uploadId string) error {
+	m, err := u.upload.FindByUUID(ctx, nil, uploadId)
+	if err != nil {
+		u.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errs.ErrUploadNotFound
+		}
+		return errs.HandleSqlErr(err)
+	}
+	if m.UserID != userId {
+		return errs.ErrUploadNotFound
+	}
+	if err := u.upload.Delete(ctx, nil, m.ID); err != nil {
+		u.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	u.deleteFolder(ctx, m.UUID)
+	return nil
+}
+
+// finish assembles the parts of m into one object and runs the hook of its
+// target. The upload row stays locked meanwhile, a retried or concurrent final
+// PATCH waits and returns the result of the first instead of running the hook again.
+func (u *uploadService) finish(ctx context.Context, m *model.Upload) (*UploadResponse, error) {
+	t, ok := u.target(m.Target)
+	if !ok {
+		return nil, errs.ErrUploadTargetNotSupported
+	}
+	meta, err := ParseUploadMetadata(m.Metadata)
+	if err != nil {
+		return nil, errs.ErrUploadInvalidMetadata
+	}
+	tx := u.dbTx.BeginTx()
+	defer u.dbTx.RollbackTx(tx) //nolint:errcheck
+	locked, err := u.upload.Lock(ctx, tx, m.ID)
+	if err != nil {
+		u.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUploadNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	if locked.CompletedAt.Valid {
+		m.CompletedAt, m.ResultID = locked.CompletedAt, locked.ResultID
+		return u.toResponse(m), nil
+	}
+	file, err := u.assemble(ctx, m)
+	if err != nil {
+		u.logger.Error(err)
+		return nil, errs.ErrInternal
+	}
+	file.Metadata = meta
+	// the parts are kept until the hook succeeded, the client may retry the last PATCH
+	defer u.deleteObject(ctx, file.Key)
+
+	if err := u.checkPolicy(ctx, t.kind, file); err != nil {
+		return nil, err
+	}
+	resultId, err := t.hook(ctx, tx, file)
+	if err != nil {
+		u.logger.Error(err, zap.String("upload", m.UUID), zap.String("target", m.Target))
+		return nil, err
+	}
+	if err := u.upload.Complete(ctx, tx, m.ID, resultId); err != nil {
+		u.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if err := u.dbTx.CommitTx(tx); err != nil {
+		u.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	u.deleteFolder(ctx, m.UUID)
+
+	m.CompletedAt.Time, m.CompletedAt.Valid = time.Now(), true
+	m.ResultID = resultId
+	return u.toResponse(m), nil
+}
+
+// assemble concatenates the parts of m into a single object and hashes it on the way.
+func (u *uploadService) assemble(ctx context.Context, m *model.Upload) (*UploadedFile, error) {
+	keys := make([]string, 0, len(m.Parts))
+	for _, p := range m.Parts {
+		keys = append(keys, p.Key)
+	}
+	parts := &partsReader{ctx: ctx, storage: u.storage, keys: keys}
+	defer parts.Close() //nolint:errcheck
+	h := sha256.New()
+	br := bufio.NewReader(io.TeeReader(parts, h))
+	head, err := br.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	contentType := http.DetectContentType(head)
+	obj, err := u.storage.Put(ctx, path.Join(uploadPath, m.UUID, "data"), br, m.Length, contentType)
+	if err != nil {
+		return nil, err
+	}
+	if obj.Size != m.Length {
+		u.deleteObject(ctx, obj.Key)
+		return nil, fmt.Errorf("upload %s: assembled %d of %d bytes", m.UUID, obj.Size, m.Length)
+	}
+	return &UploadedFile{
+		UserID:      m.UserID,
+		UploadUUID:  m.UUID,
+		Filename:    m.Filename,
+		Key:         obj.Key,
+		Hash:        hex.EncodeToString(h.Sum(nil)),
+		Size:        obj.Size,
+		ContentType: contentType,
+	}, nil
+}
+
+func (u *uploadService) find(ctx context.Context, userId uint, uploadId string) (*model.Upload, error) {
+	m, err := u.upload.FindByUUID(ctx, nil, uploadId)
+	if err != nil {
+		u.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrUploadNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	if m.UserID != userId {
+		return nil, errs.ErrUploadNotFound
+	}
+	if !m.CompletedAt.Valid && m.ExpiresAt.Before(time.Now()) {
+		return nil, errs.ErrUploadExpired
+	}
+	return m, nil
+}
+
+// checkPolicy repeats the checks that need the whole file, the quota may have
+// been used up by other uploads since this one was created.
+func (u *uploadService) checkPolicy(ctx context.Context, kind UploadKind, file *UploadedFile) error {
+	if err := u.policy.CheckQuota(ctx, file.UserID, file.Size); err != nil {
+		return err
+	}
+	if kind != UploadKindImage {
+		return nil
+	}
+	f, _, err := u.storage.Get(ctx, file.Key)
+	if err != nil {
+		u.logger.Error(err)
+		return errs.ErrInternal
+	}
+	defer f.Close() //nolint:errcheck
+	return u.policy.CheckImageDimensions(f)
+}
+
+func (u *uploadService) target(name string) (uploadTarget, bool) {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	t, ok := u.targets[name]
+	return t, ok && t.hook != nil
+}
+
+func (u *uploadService) deleteFolder(ctx context.Context, uploadUUID string) {
+	objects, err := u.storage.List(ctx, path.Join(uploadPath, uploadUUID))
+	if err != nil {
+		u.logger.Error(err)
+		return
+	}
+	for _, obj := range objects {
+		u.deleteObject(ctx, obj.Key)
+	}
+}
+
+func (u *uploadService) deleteObject(ctx context.Context, key string) {
+	if err := u.storage.Delete(ctx, key); err != nil && !errors.Is(err, repository.ErrStorageObjectNotFound) {
+		u.logger.Error(err, zap.String("key", key))
+	}
+}
+
+func (u *uploadService) toResponse(m *model.Upload) *UploadResponse {
+	return &UploadResponse{
+		CommonModel: CommonModel{
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		},
+		UUID:      m.UUID,
+		Length:    m.Length,
+		Offset:    m.Offset,
+		Metadata:  m.Metadata,
+		Filename:  m.Filename,
+		Target:    m.Target,
+		ExpiresAt: m.ExpiresAt,
+		Completed: m.CompletedAt.Valid,
+		ResultID:  m.ResultID,
+	}
+}
+
+// ParseUploadMetadata decodes an Upload-Metadata header, comma separated
+// pairs of a key and an optional base64 encoded value.
+func ParseUploadMetadata(header string) (map[string]string, error) {
+	meta := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, " ")
+		if key == "" {
+			return nil, fmt.Errorf("upload metadata: empty key")
+		}
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("upload metadata %q: %w", key, err)
+		}
+		meta[key] = string(b)
+	}
+	return meta, nil
+}
+
+// partsReader reads the objects at keys one after another, opening each only when needed.
+type partsReader struct {
+	ctx     context.Context
+	storage repository.Storage
+	keys    []string
+	cur     io.ReadCloser
+}
+
+func (p *partsReader) Read(b []byte) (int, error) {
+	for {
+		if p.cur == nil {
+			if len(p.keys) == 0 {
+				return 0, io.EOF
+			}
+			f, _, err := p.storage.Get(p.ctx, p.keys[0])
+			if err != nil {
+				return 0, err
+			}
+			p.cur, p.keys = f, p.keys[1:]
+		}
+		n, err := p.cur.Read(b)
+		if errors.Is(err, io.EOF) {
+			p.cur.Close() //nolint:errcheck
+			p.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (p *partsReader) Close() error {
+	if p.cur == nil {
+		return nil
+	}
+	return p.cur.Close()
+}
+
+func NewUploadService(
+	conf *config.Config,
+	storage repository.Storage,
+	upload repository.Upload,
+	dbTx repository.DatabaseTransaction,
+	policy UploadPolicy,
+	logger logger.AppLogger,
+) UploadService {
+	return &uploadService{
+		conf:    conf,
+		storage: storage,
+		upload:  upload,
+		dbTx:    dbTx,
+		policy:  policy,
+		logger:  logger,
+		targets: map[string]uploadTarget{},
+	}
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"image"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"slices"
+
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+)
+
+// UploadKind selects which content types UploadPolicy accepts.
+type UploadKind string
+
+const (
+	UploadKindImage UploadKind = "image"
+	UploadKindFile  UploadKind = "file"
+)
+
+type (
+	// UploadPolicy is checked before an upload is written to storage. Types are
+	// sniffed from the content, the file name and Content-Type header are ignored.
+	UploadPolicy interface {
+		// CheckFileHeader runs every check against a multipart file.
+		CheckFileHeader(ctx context.Context, userId uint, kind UploadKind, fh *multipart.FileHeader) error
+		CheckSize(size int64) error
+		CheckQuota(ctx context.Context, userId uint, size int64) error
+		// CheckContentType sniffs head, the first 512 bytes of the file, and returns its MIME type.
+		CheckContentType(kind UploadKind, head []byte) (string, error)
+		// CheckImageDimensions reads only the image header, never the pixels.
+		CheckImageDimensions(r io.Reader) error
+		MaxBytes() int64
+	}
+	uploadPolicy struct {
+		conf       *config.Config
+		image      repository.Image
+		attachment repository.Attachment
+		logger     logger.AppLogger
+	}
+)
+
+// CheckFileHeader implements UploadPolicy.
+func (p *uploadPolicy) CheckFileHeader(ctx context.Context, userId uint, kind UploadKind, fh *multipart.FileHeader) error {
+	if err := p.CheckSize(fh.Size); err != nil {
+		return err
+	}
+	if err := p.CheckQuota(ctx, userId, fh.Size); err != nil {
+		return err
+	}
+	f, err := fh.Open()
+	if err != nil {
+		p.logger.Error(err)
+		return errs.ErrFileUploadNotFound
+	}
+	defer f.Close() //nolint:errcheck
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		p.logger.Error(err)
+		return errs.ErrFileUploadNotFound
+	}
+	if _, err := p.CheckContentType(kind, head[:n]); err != nil {
+		return err
+	}
+	if kind != UploadKindImage {
+		return nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		p.logger.Error(err)
+		return errs.ErrInternal
+	}
+	return p.CheckImageDimensions(f)
+}
+
+// CheckSize implements UploadPolicy.
+func (p *uploadPolicy) CheckSize(size int64) error {
+	if size < 0 {
+		return errs.ErrUploadInvalidLength
+	}
+	if max := p.MaxBytes(); max > 0 && size > max {
+		return errs.ErrUploadTooLarge
+	}
+	return nil
+}
+
+// CheckQuota implements UploadPolicy.
+func (p *uploadPolicy) CheckQuota(ctx context.Context, userId uint, size int64) error {
+	quota := p.conf.UPLOAD.USER_QUOTA
+	if quota <= 0 {
+		return nil
+	}
+	images, err := p.image.SumSizeByUserId(ctx, nil, userId)
+	if err != nil {
+		p.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	attachments, err := p.attachment.SumSizeByUploaderId(ctx, nil, userId)
+	if err != nil {
+		p.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	if images+attachments+size > quota {
+		return errs.ErrUploadQuotaExceeded
+	}
+	return nil
+}
+
+// CheckContentType implements UploadPolicy.
+func (p *uploadPolicy) CheckContentType(kind UploadKind, head []byte) (string, error) {
+	contentType := http.DetectContentType(head)
+	if kind == UploadKindFile && len(p.conf.UPLOAD.FILE_TYPES) == 0 {
+		return contentType, nil
+	}
+	if !slices.Contains(p.allowedTypes(kind), contentType) {
+		return "", errs.ErrUploadTypeNotAllowed
+	}
+	return contentType, nil
+}
+
+// CheckImageDimensions implements UploadPolicy.
+func (p *uploadPolicy) CheckImageDimensions(r io.Reader) error {
+	conf, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return errs.ErrFileUploadNotImage
+	}
+	c := p.conf.UPLOAD
+	if (c.MAX_WIDTH > 0 && conf.Width > c.MAX_WIDTH) || (c.MAX_HEIGHT > 0 && conf.Height > c.MAX_HEIGHT) {
+		return errs.ErrUploadImageTooLarge
+	}
+	return nil
+}
+
+// MaxBytes implements UploadPolicy.
+func (p *uploadPolicy) MaxBytes() int64 {
+	return p.conf.UPLOAD.MAX_BYTES
+}
+
+func (p *uploadPolicy) allowedTypes(kind UploadKind) []string {
+	switch kind {
+	case UploadKindImage:
+		return p.conf.UPLOAD.IMAGE_TYPES
+	case UploadKindFile:
+		return p.conf.UPLOAD.FILE_TYPES
+	default:
+		return nil
+	}
+}
+
+func NewUploadPolicy(
+	conf *config.Config,
+	image repository.Image,
+	attachment repository.Attachment,
+	logger logger.AppLogger,
+) UploadPolicy {
+	return &uploadPolicy{
+		conf:       conf,
+		image:      image,
+		attachment: attachment,
+		logger:     logger,
+	}
+}
@@ -3,20 +3,23 @@ package service
 import (
 	"context"
 	"mime/multipart"
-	"path/filepath"
 	"strings"
 
 	"github.com/suttapak/starter/errs"
 	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/idx"
 	"github.com/suttapak/starter/internal/model"
 	"github.com/suttapak/starter/internal/repository"
 	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
 )
 
+// storage keys, relative to the root of repository.Storage
 const (
-	profileImagePath        = "public/static/profile/images"
-	productImagePath        = "public/static/product/images"
-	deletedProductImagePath = "public/static/product/deleted"
+	imagePath               = "images"
+	profileImagePath        = "profile/images"
+	productImagePath        = "product/images"
+	deletedProductImagePath = "product/deleted"
 )
 
 type (
@@ -27,11 +30,21 @@ type (
 		// Create profile image receive a image form file header and
 		// get image info such as size, width, height, and type and user id who create
 		CreateProfileImage(ctx context.Context, userId uint, fileHeader *multipart.FileHeader) (res *UserResponse, err error)
+		// CreateImageFromUpload and CreateProfileImageFromUpload are UploadHook,
+		// they store a finished resumable upload as an image of its uploader.
+		CreateImageFromUpload(ctx context.Context, tx *gorm.DB, file *UploadedFile) (uint, error)
+		CreateProfileImageFromUpload(ctx context.Context, tx *gorm.DB, file *UploadedFile) (uint, error)
+		// CreateInTx registers a user like Auth.Register within tx, such as for
+		// a row of an import, and returns the id of the user.
+		CreateInTx(ctx context.Context, tx *gorm.DB, body UserRegisterDto) (uint, error)
 	}
 	userService struct {
 		user         repository.User
 		image        repository.Image
+		dbTx         repository.DatabaseTransaction
 		imageService ImageFileService
+		policy       UploadPolicy
+		scan         FileScanService
 		logger       logger.AppLogger
 		help         helpers.Helper
 	}
@@ -71,6 +84,10 @@ type (
 
 // CreateProfileImage implements UserService.
 func (a *userService) CreateProfileImage(ctx context.Context, userId uint, fileHeader *multipart.FileHeader) (res *UserResponse, err error) {
+	// size, type, dimensions and quota, before the image is decoded
+	if err := a.policy.CheckFileHeader(ctx, userId, UploadKindImage, fileHeader); err != nil {
+		return nil, err
+	}
 	// check is image?
 	isImage, err := a.imageService.IsImageFromFileHeader(fileHeader)
 	if !isImage || err != nil {
@@ -83,37 +100,144 @@ func (a *userService) CreateProfileImage(ctx context.Context, userId uint, fileH
 		a.logger.Error(err)
 		return nil, errs.ErrFileImageCanNotGetStats
 	}
-	// create file path
-	imageName := a.imageService.GetUuidFileNameFromFileHeader(fileHeader)
-	filePath := filepath.Join(profileImagePath, imageName)
-	// save file
-	if err := a.imageService.SaveFileFromFileHeader(fileHeader, filePath); err != nil {
+	tx := a.dbTx.BeginTx()
+	defer a.dbTx.RollbackTx(tx) //nolint:errcheck
+	// save file, the same content uploaded before is reused
+	blob, err := a.imageService.SaveBlobFromFileHeader(ctx, tx, fileHeader, profileImagePath)
+	if err != nil {
+		a.logger.Error(err)
+		return nil, errs.ErrFileImageCanNotSaveToDisk
+	}
+	imageModel, err := a.saveImage(ctx, tx, userId, blob, imgStats)
+	if err != nil {
+		return nil, err
+	}
+	infected := imageModel.ScanStatus == model.ScanStatusInfected
+	// save user image, an infected one is kept in quarantine only
+	if !infected {
+		if err := a.user.CreateImageProfile(ctx, tx, userId, imageModel.ID); err != nil {
+			a.logger.Error(err)
+			return nil, errs.HandleSqlErr(err)
+		}
+	}
+	if err := a.dbTx.CommitTx(tx); err != nil {
+		a.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	if infected {
+		return nil, errs.ErrFileInfected
+	}
+	// create profile image response
+	res, err = a.GetUserByUserId(ctx, userId)
+	return
+}
+
+// CreateImageFromUpload implements UserService.
+func (a *userService) CreateImageFromUpload(ctx context.Context, tx *gorm.DB, file *UploadedFile) (uint, error) {
+	imageModel, err := a.saveImageFromUpload(ctx, tx, file, imagePath)
+	if err != nil {
+		return 0, err
+	}
+	return imageModel.ID, nil
+}
+
+// CreateProfileImageFromUpload implements UserService.
+func (a *userService) CreateProfileImageFromUpload(ctx context.Context, tx *gorm.DB, file *UploadedFile) (uint, error) {
+	imageModel, err := a.saveImageFromUpload(ctx, tx, file, profileImagePath)
+	if err != nil {
+		return 0, err
+	}
+	if imageModel.ScanStatus == model.ScanStatusInfected {
+		return imageModel.ID, nil
+	}
+	if err := a.user.CreateImageProfile(ctx, tx, file.UserID, imageModel.ID); err != nil {
+		a.logger.Error(err)
+		return 0, errs.HandleSqlErr(err)
+	}
+	return imageModel.ID, nil
+}
+
+func (a *userService) saveImageFromUpload(ctx context.Context, tx *gorm.DB, file *UploadedFile, prefix string) (*model.Image, error) {
+	imgStats, err := a.imageService.GetImageStatsFromStorage(ctx, file.Key)
+	if err != nil {
+		a.logger.Error(err)
+		return nil, errs.ErrFileUploadNotImage
+	}
+	blob, err := a.imageService.SaveBlobFromStorage(ctx, tx, file, prefix)
+	if err != nil {
 		a.logger.Error(err)
 		return nil, errs.ErrFileImageCanNotSaveToDisk
 	}
+	return a.saveImage(ctx, tx, file.UserID, blob, imgStats)
+}
 
+func (a *userService) saveImage(ctx context.Context, tx *gorm.DB, userId uint, blob *model.Blob, imgStats *imageStats) (*model.Image, error) {
+	return saveImage(ctx, tx, a.image, a.scan, a.logger, userId, blob, imgStats, imageUrl)
+}
+
+// saveImage records blob as an image of userId and points its url at the route
+// serving it. The file is scanned first, check ScanStatus of the result before using it.
+func saveImage(
+	ctx context.Context,
+	tx *gorm.DB,
+	image repository.Image,
+	scan FileScanService,
+	logger logger.AppLogger,
+	userId uint,
+	blob *model.Blob,
+	imgStats *imageStats,
+	url func(imageId uint) string,
+) (*model.Image, error) {
 	m := model.Image{
-		Path:   filePath,
-		Url:    imageName,
+		Path:   blob.Key,
+		Hash:   blob.Hash,
 		Size:   imgStats.size,
 		Width:  uint(imgStats.width),
 		Height: uint(imgStats.height),
 		Type:   imgStats.mimeType,
 		UserID: userId,
 	}
-	imageModel, err := a.image.Save(ctx, nil, userId, &m)
+	if err := scan.ScanImage(ctx, tx, &m); err != nil {
+		return nil, err
+	}
+	imageModel, err := image.Save(ctx, tx, userId, &m)
 	if err != nil {
-		a.logger.Error(err)
+		logger.Error(err)
 		return nil, errs.HandleSqlErr(err)
 	}
-	// save user image
-	if err := a.user.CreateImageProfile(ctx, nil, userId, imageModel.ID); err != nil {
-		a.logger.Error(err)
+	imageModel.Url = url(imageModel.ID)
+	if err := image.UpdateUrl(ctx, tx, imageModel.ID, imageModel.Url); err != nil {
+		logger.Error(err)
 		return nil, errs.HandleSqlErr(err)
 	}
-	// create profile image response
-	res, err = a.GetUserByUserId(ctx, userId)
-	return
+	return imageModel, nil
+}
+
+// CreateInTx implements UserService.
+func (u *userService) CreateInTx(ctx context.Context, tx *gorm.DB, body UserRegisterDto) (uint, error) {
+	if _, duplicate, err := u.user.CheckUsername(ctx, tx, body.Username); duplicate || err != nil {
+		return 0, errs.ErrDuplicateUsername
+	}
+	if _, duplicate, err := u.user.CheckEmail(ctx, tx, body.Email); duplicate || err != nil {
+		return 0, errs.ErrDuplicateEmail
+	}
+	password, err := u.help.HashPassword(body.Password)
+	if err != nil {
+		u.logger.Error(err)
+		return 0, errs.ErrHashPassword
+	}
+	registered, err := u.user.Register(ctx, tx, model.User{
+		Username: body.Username,
+		Password: password,
+		Email:    body.Email,
+		FullName: body.FullName,
+		RoleID:   idx.RoleUser,
+	})
+	if err != nil {
+		u.logger.Error(err)
+		return 0, errs.ErrRegisterUsername
+	}
+	return registered.ID, nil
 }
 
 // CheckUserIsVerifyEmail implements Auth.
@@ -158,14 +282,20 @@ func (u *userService) GetUserByUserId(ctx context.Context, uId uint) (res *UserR
 func NewUser(
 	user repository.User,
 	image repository.Image,
+	dbTx repository.DatabaseTransaction,
 	imageService ImageFileService,
+	policy UploadPolicy,
+	scan FileScanService,
 	logger logger.AppLogger,
 	help helpers.Helper,
 ) UserService {
 	return &userService{
 		user:         user,
 		image:        image,
+		dbTx:         dbTx,
 		imageService: imageService,
+		policy:       policy,
+		scan:         scan,
 		logger:       logger,
 		help:         help,
 	}
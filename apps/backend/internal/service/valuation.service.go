@@ -0,0 +1,364 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+// valuationSheetColumns are the columns of the valuation report sheet.
+var valuationSheetColumns = []ExcelColumn{
+	{Header: "product_code", Type: ExcelText},
+	{Header: "product_name", Type: ExcelText},
+	{Header: "uom", Type: ExcelText},
+	{Header: "quantity", Type: ExcelNumber},
+	{Header: "unit_cost", Type: ExcelCurrency},
+	{Header: "value", Type: ExcelCurrency, Total: ExcelSum},
+}
+
+type (
+	// ValuationService values stock in the same database transaction as the
+	// stock ledger records it. Every receipt opens a cost layer and adds to the
+	// moving average of its product, issues are costed with the costing method
+	// of the team: FIFO from the oldest layers, or at the moving average. Both
+	// keep the quantities of the layers and the average, so a change of method
+	// applies to later issues. Every valuation is an entry of the cost journal,
+	// the value of stock at a time and the cost of goods sold are sums of it.
+	ValuationService interface {
+		// Receive values qty received into the lot at unitCost per base unit.
+		Receive(ctx context.Context, tx *gorm.DB, teamId, productId, lotId uint, qty, unitCost float64, ref StockRef) error
+		// Restore values qty put back into the lot, e.g. by a return, at the unit
+		// cost of the lot.
+		Restore(ctx context.Context, tx *gorm.DB, teamId, productId, lotId uint, qty float64, ref StockRef) error
+		// Issue values qty taken out of the lot and returns its cost.
+		Issue(ctx context.Context, tx *gorm.DB, teamId, productId, lotId uint, qty float64, ref StockRef) (float64, error)
+
+		FindSetting(ctx context.Context, teamId uint) (*InventorySettingResponse, error)
+		SaveSetting(ctx context.Context, teamId uint, body InventorySettingRequest) (*InventorySettingResponse, error)
+		// Balance returns the quantity and value of the stock per product at f.At.
+		Balance(ctx context.Context, teamId uint, f *filter.ValuationFilter) ([]ValuationResponse, error)
+		// Cogs returns the cost of goods sold per product, net of returns.
+		Cogs(ctx context.Context, teamId uint, f *filter.CogsFilter) ([]CogsResponse, error)
+		// Export returns Balance as an Excel sheet.
+		Export(ctx context.Context, teamId uint, f *filter.ValuationFilter) (*ValuationSheet, error)
+	}
+	valuationService struct {
+		valuation repository.Valuation
+		excel     Excel
+		conf      *config.Config
+		logger    logger.AppLogger
+	}
+
+	InventorySettingRequest struct {
+		CostingMethod string `json:"costing_method" binding:"required,oneof=fifo average"`
+	}
+
+	InventorySettingResponse struct {
+		TeamID        uint   `json:"team_id"`
+		CostingMethod string `json:"costing_method"`
+	}
+	ValuationResponse struct {
+		ProductID   uint   `json:"product_id"`
+		ProductCode string `json:"product_code"`
+		ProductName string `json:"product_name"`
+		// Quantity is in the base unit UOM, UnitCost is the value of one.
+		UOM      string  `json:"uom"`
+		Quantity float64 `json:"quantity"`
+		UnitCost float64 `json:"unit_cost"`
+		Value    float64 `json:"value"`
+	}
+	CogsResponse struct {
+		ProductID   uint    `json:"product_id"`
+		ProductCode string  `json:"product_code"`
+		ProductName string  `json:"product_name"`
+		UOM         string  `json:"uom"`
+		Quantity    float64 `json:"quantity"`
+		Amount      float64 `json:"amount"`
+	}
+
+	ValuationSheet struct {
+		Filename string
+		Content  *bytes.Buffer
+	}
+)
+
+// Receive implements ValuationService.
+func (v *valuationService) Receive(ctx context.Context, tx *gorm.DB, teamId, productId, lotId uint, qty, unitCost float64, ref StockRef) error {
+	if qty <= 0 {
+		return nil
+	}
+	method, err := v.costingMethod(ctx, tx, teamId)
+	if err != nil {
+		return err
+	}
+	cost, err := v.valuation.LockProductCost(ctx, tx, teamId, productId)
+	if err != nil {
+		v.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	now := time.Now()
+	layer := &model.CostLayer{
+		TeamID:       teamId,
+		ProductID:    productId,
+		ProductLotID: lotId,
+		Quantity:     qty,
+		RemainingQty: qty,
+		UnitCost:     unitCost,
+		ReceivedAt:   now,
+	}
+	if err := v.valuation.CreateLayer(ctx, tx, layer); err != nil {
+		v.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	amount := qty * unitCost
+	if err := v.valuation.UpdateProductCost(ctx, tx, cost.ID, cost.Quantity+qty, cost.Value+amount); err != nil {
+		v.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return v.entry(ctx, tx, teamId, productId, lotId, method, qty, amount, now, ref)
+}
+
+// Restore implements ValuationService.
+func (v *valuationService) Restore(ctx context.Context, tx *gorm.DB, teamId, productId, lotId uint, qty float64, ref StockRef) error {
+	unitCost, err := v.valuation.LotCost(ctx, tx, lotId)
+	if err != nil {
+		v.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return v.Receive(ctx, tx, teamId, productId, lotId, qty, unitCost, ref)
+}
+
+// Issue implements ValuationService.
+func (v *valuationService) Issue(ctx context.Context, tx *gorm.DB, teamId, productId, lotId uint, qty float64, ref StockRef) (float64, error) {
+	if qty <= 0 {
+		return 0, nil
+	}
+	method, err := v.costingMethod(ctx, tx, teamId)
+	if err != nil {
+		return 0, err
+	}
+	cost, err := v.valuation.LockProductCost(ctx, tx, teamId, productId)
+	if err != nil {
+		v.logger.Error(err)
+		return 0, errs.HandleSqlErr(err)
+	}
+	var average float64
+	if cost.Quantity > 0 {
+		average = cost.Value / cost.Quantity
+	}
+	// the layers are consumed whatever the method, they hold the FIFO cost
+	layers, err := v.valuation.FindOpenLayers(ctx, tx, productId)
+	if err != nil {
+		v.logger.Error(err)
+		return 0, errs.HandleSqlErr(err)
+	}
+	left, fifo := qty, 0.0
+	for _, layer := range layers {
+		if left <= repository.StockTolerance {
+			break
+		}
+		take := math.Min(left, layer.RemainingQty)
+		if err := v.valuation.ConsumeLayer(ctx, tx, layer.ID, take); err != nil {
+			v.logger.Error(err)
+			return 0, errs.HandleSqlErr(err)
+		}
+		fifo += take * layer.UnitCost
+		left -= take
+	}
+	// stock without layers, e.g. negative adjustments, goes at the average
+	if left > repository.StockTolerance {
+		fifo += left * average
+	}
+	amount := qty * average
+	if method == model.CostingMethodFifo {
+		amount = fifo
+	}
+	value := cost.Value - amount
+	if cost.Quantity-qty <= repository.StockTolerance {
+		// the last units take the remaining value, no rounding is left behind
+		amount, value = cost.Value, 0
+	}
+	if err := v.valuation.UpdateProductCost(ctx, tx, cost.ID, cost.Quantity-qty, value); err != nil {
+		v.logger.Error(err)
+		return 0, errs.HandleSqlErr(err)
+	}
+	if err := v.entry(ctx, tx, teamId, productId, lotId, method, -qty, -amount, time.Now(), ref); err != nil {
+		return 0, err
+	}
+	return amount, nil
+}
+
+// FindSetting implements ValuationService.
+func (v *valuationService) FindSetting(ctx context.Context, teamId uint) (*InventorySettingResponse, error) {
+	method, err := v.costingMethod(ctx, nil, teamId)
+	if err != nil {
+		return nil, err
+	}
+	return &InventorySettingResponse{TeamID: teamId, CostingMethod: method}, nil
+}
+
+// SaveSetting implements ValuationService.
+func (v *valuationService) SaveSetting(ctx context.Context, teamId uint, body InventorySettingRequest) (*InventorySettingResponse, error) {
+	m := &model.InventorySetting{TeamID: teamId, CostingMethod: body.CostingMethod}
+	if err := v.valuation.SaveSetting(ctx, nil, m); err != nil {
+		v.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return &InventorySettingResponse{TeamID: teamId, CostingMethod: m.CostingMethod}, nil
+}
+
+// Balance implements ValuationService.
+func (v *valuationService) Balance(ctx context.Context, teamId uint, f *filter.ValuationFilter) ([]ValuationResponse, error) {
+	at, err := parseStockTime(f.At, v.conf.SERVER.LOCATION)
+	if err != nil {
+		return nil, errs.ErrBadRequest
+	}
+	balances, err := v.valuation.Balance(ctx, nil, teamId, at, f)
+	if err != nil {
+		v.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	res := make([]ValuationResponse, 0, len(balances))
+	for _, b := range balances {
+		var unitCost float64
+		if b.Quantity > repository.StockTolerance {
+			unitCost = b.Value / b.Quantity
+		}
+		res = append(res, ValuationResponse{
+			ProductID:   b.ProductID,
+			ProductCode: b.ProductCode,
+			ProductName: b.ProductName,
+			UOM:         b.UOM,
+			Quantity:    b.Quantity,
+			UnitCost:    unitCost,
+			Value:       b.Value,
+		})
+	}
+	return res, nil
+}
+
+// Cogs implements ValuationService.
+func (v *valuationService) Cogs(ctx context.Context, teamId uint, f *filter.CogsFilter) ([]CogsResponse, error) {
+	loc := v.conf.SERVER.LOCATION
+	y, m, _ := time.Now().In(loc).Date()
+	from := time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	to := from.AddDate(0, 1, 0)
+	var err error
+	if f.From != "" {
+		if from, err = time.ParseInLocation(time.DateOnly, f.From, loc); err != nil {
+			return nil, errs.ErrBadRequest
+		}
+	}
+	if f.To != "" {
+		if to, err = time.ParseInLocation(time.DateOnly, f.To, loc); err != nil {
+			return nil, errs.ErrBadRequest
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		return nil, errs.ErrBadRequest
+	}
+	balances, err := v.valuation.Cogs(ctx, nil, teamId, from, to, f)
+	if err != nil {
+		v.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	res := make([]CogsResponse, 0, len(balances))
+	for _, b := range balances {
+		res = append(res, CogsResponse{
+			ProductID:   b.ProductID,
+			ProductCode: b.ProductCode,
+			ProductName: b.ProductName,
+			UOM:         b.UOM,
+			Quantity:    b.Quantity,
+			Amount:      b.Amount,
+		})
+	}
+	return res, nil
+}
+
+// Export implements ValuationService.
+func (v *valuationService) Export(ctx context.Context, teamId uint, f *filter.ValuationFilter) (*ValuationSheet, error) {
+	balances, err := v.Balance(ctx, teamId, f)
+	if err != nil {
+		return nil, err
+	}
+	at := time.Now()
+	if f.At != "" {
+		at, _ = parseStockTime(f.At, v.conf.SERVER.LOCATION)
+	}
+	sheet := ExcelSheet{
+		Name:          "valuation",
+		Title:         "มูลค่าสินค้าคงเหลือ ณ วันที่ " + at.Format(time.DateOnly),
+		Columns:       valuationSheetColumns,
+		FreezeHeader:  true,
+		FreezeColumns: 1,
+		AutoFilter:    true,
+	}
+	for _, b := range balances {
+		sheet.Rows = append(sheet.Rows, []any{b.ProductCode, b.ProductName, b.UOM, b.Quantity, b.UnitCost, b.Value})
+	}
+	content, err := v.excel.Report(&ExcelReport{Sheets: []ExcelSheet{sheet}})
+	if err != nil {
+		v.logger.Error(err)
+		return nil, errs.ErrInternal
+	}
+	return &ValuationSheet{Filename: "valuation-" + at.Format("20060102") + ".xlsx", Content: content}, nil
+}
+
+// costingMethod is the costing method of the team, FIFO unless set.
+func (v *valuationService) costingMethod(ctx context.Context, tx *gorm.DB, teamId uint) (string, error) {
+	m, err := v.valuation.FindSetting(ctx, tx, teamId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.CostingMethodFifo, nil
+		}
+		v.logger.Error(err)
+		return "", errs.HandleSqlErr(err)
+	}
+	return m.CostingMethod, nil
+}
+
+func (v *valuationService) entry(ctx context.Context, tx *gorm.DB, teamId, productId, lotId uint, method string, qty, amount float64, at time.Time, ref StockRef) error {
+	m := &model.CostEntry{
+		TeamID:       teamId,
+		ProductID:    productId,
+		ProductLotID: lotId,
+		Method:       method,
+		Quantity:     qty,
+		Amount:       amount,
+		Reason:       ref.Reason,
+		RefType:      ref.RefType,
+		RefID:        ref.RefID,
+		PostedAt:     at,
+	}
+	if err := v.valuation.CreateEntry(ctx, tx, m); err != nil {
+		v.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return nil
+}
+
+func NewValuationService(
+	valuation repository.Valuation,
+	excel Excel,
+	conf *config.Config,
+	logger logger.AppLogger,
+) ValuationService {
+	return &valuationService{
+		valuation: valuation,
+		excel:     excel,
+		conf:      conf,
+		logger:    logger,
+	}
+}
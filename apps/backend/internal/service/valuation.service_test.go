@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/suttapak/starter/domain/config"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+// valuationFake keeps the cost layers, moving average and journal of one
+// product in memory, the way repository.Valuation keeps them in Postgres.
+type valuationFake struct {
+	method   string
+	cost     model.ProductCost
+	layers   []model.CostLayer
+	lotCosts map[uint]float64
+	entries  []model.CostEntry
+}
+
+func newValuationFake(method string) *valuationFake {
+	return &valuationFake{method: method, cost: model.ProductCost{CommonModel: model.CommonModel{ID: 1}}, lotCosts: map[uint]float64{}}
+}
+
+func (f *valuationFake) FindSetting(ctx context.Context, tx *gorm.DB, teamId uint) (*model.InventorySetting, error) {
+	if f.method == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &model.InventorySetting{TeamID: teamId, CostingMethod: f.method}, nil
+}
+
+func (f *valuationFake) SaveSetting(ctx context.Context, tx *gorm.DB, setting *model.InventorySetting) error {
+	f.method = setting.CostingMethod
+	return nil
+}
+
+func (f *valuationFake) LockProductCost(ctx context.Context, tx *gorm.DB, teamId, productId uint) (*model.ProductCost, error) {
+	cost := f.cost
+	return &cost, nil
+}
+
+func (f *valuationFake) UpdateProductCost(ctx context.Context, tx *gorm.DB, productCostId uint, qty, value float64) error {
+	f.cost.Quantity, f.cost.Value = qty, value
+	return nil
+}
+
+func (f *valuationFake) CreateLayer(ctx context.Context, tx *gorm.DB, layer *model.CostLayer) error {
+	layer.ID = uint(len(f.layers) + 1)
+	f.layers = append(f.layers, *layer)
+	if _, ok := f.lotCosts[layer.ProductLotID]; !ok {
+		f.lotCosts[layer.ProductLotID] = layer.UnitCost
+	}
+	return nil
+}
+
+func (f *valuationFake) FindOpenLayers(ctx context.Context, tx *gorm.DB, productId uint) ([]model.CostLayer, error) {
+	var open []model.CostLayer
+	for _, layer := range f.layers {
+		if layer.RemainingQty > 0 {
+			open = append(open, layer)
+		}
+	}
+	return open, nil
+}
+
+func (f *valuationFake) ConsumeLayer(ctx context.Context, tx *gorm.DB, layerId uint, qty float64) error {
+	f.layers[layerId-1].RemainingQty -= qty
+	return nil
+}
+
+func (f *valuationFake) LotCost(ctx context.Context, tx *gorm.DB, lotId uint) (float64, error) {
+	return f.lotCosts[lotId], nil
+}
+
+func (f *valuationFake) CreateEntry(ctx context.Context, tx *gorm.DB, entry *model.CostEntry) error {
+	f.entries = append(f.entries, *entry)
+	return nil
+}
+
+func (f *valuationFake) Balance(ctx context.Context, tx *gorm.DB, teamId uint, at time.Time, _ *filter.ValuationFilter) ([]repository.ValuationBalance, error) {
+	return nil, nil
+}
+
+func (f *valuationFake) Cogs(ctx context.Context, tx *gorm.DB, teamId uint, from, to time.Time, _ *filter.CogsFilter) ([]repository.CogsBalance, error) {
+	return nil, nil
+}
+
+func (f *valuationFake) remaining() []float64 {
+	res := make([]float64, 0, len(f.layers))
+	for _, layer := range f.layers {
+		res = append(res, layer.RemainingQty)
+	}
+	return res
+}
+
+func newValuationTest(t *testing.T, method string, receipts ...[2]float64) (ValuationService, *valuationFake) {
+	t.Helper()
+	fake := newValuationFake(method)
+	v := NewValuationService(fake, nil, &config.Config{}, logger.NewLoggerMock())
+	for i, r := range receipts {
+		require.NoError(t, v.Receive(context.Background(), nil, 1, 1, uint(i+1), r[0], r[1], StockRef{}))
+	}
+	return v, fake
+}
+
+func TestValuationIssuePartialLayer(t *testing.T) {
+	v, fake := newValuationTest(t, model.CostingMethodFifo, [2]float64{10, 2})
+
+	amount, err := v.Issue(context.Background(), nil, 1, 1, 1, 4, StockRef{})
+	require.NoError(t, err)
+	assert.Equal(t, 8.0, amount)
+	assert.Equal(t, []float64{6}, fake.remaining())
+	assert.Equal(t, 6.0, fake.cost.Quantity)
+	assert.Equal(t, 12.0, fake.cost.Value)
+	last := fake.entries[len(fake.entries)-1]
+	assert.Equal(t, -4.0, last.Quantity)
+	assert.Equal(t, -8.0, last.Amount)
+}
+
+func TestValuationIssueAcrossLayers(t *testing.T) {
+	tests := []struct {
+		method string
+		amount float64
+	}{
+		{method: model.CostingMethodFifo, amount: 5*1 + 2*3},
+		// the layers are consumed all the same, the average prices the issue
+		{method: model.CostingMethodAverage, amount: 7 * 2},
+		// teams without a setting cost with FIFO
+		{method: "", amount: 5*1 + 2*3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			v, fake := newValuationTest(t, tt.method, [2]float64{5, 1}, [2]float64{5, 3})
+
+			amount, err := v.Issue(context.Background(), nil, 1, 1, 1, 7, StockRef{})
+			require.NoError(t, err)
+			assert.Equal(t, tt.amount, amount)
+			assert.Equal(t, []float64{0, 3}, fake.remaining())
+			assert.Equal(t, 3.0, fake.cost.Quantity)
+			assert.Equal(t, 20-tt.amount, fake.cost.Value)
+		})
+	}
+}
+
+func TestValuationRestoreExhaustedLayer(t *testing.T) {
+	v, fake := newValuationTest(t, model.CostingMethodFifo, [2]float64{5, 2}, [2]float64{5, 4})
+	ctx := context.Background()
+
+	_, err := v.Issue(ctx, nil, 1, 1, 1, 5, StockRef{})
+	require.NoError(t, err)
+	require.Equal(t, []float64{0, 5}, fake.remaining())
+
+	// a return to the exhausted lot opens a layer at the cost of the lot, it
+	// is the oldest open one and goes out first
+	require.NoError(t, v.Restore(ctx, nil, 1, 1, 1, 2, StockRef{}))
+	assert.Equal(t, []float64{0, 5, 2}, fake.remaining())
+	assert.Equal(t, 2.0, fake.layers[2].UnitCost)
+	assert.Equal(t, 7.0, fake.cost.Quantity)
+	assert.Equal(t, 24.0, fake.cost.Value)
+
+	amount, err := v.Issue(ctx, nil, 1, 1, 1, 6, StockRef{})
+	require.NoError(t, err)
+	assert.Equal(t, 5*4+1*2.0, amount)
+	assert.Equal(t, []float64{0, 0, 1}, fake.remaining())
+}
+
+func TestValuationIssueTolerance(t *testing.T) {
+	ctx := context.Background()
+	// less than repository.StockTolerance
+	const hair = repository.StockTolerance / 2
+
+	t.Run("rounding left after the layers is not costed", func(t *testing.T) {
+		v, fake := newValuationTest(t, model.CostingMethodFifo, [2]float64{5, 2}, [2]float64{5, 4})
+
+		amount, err := v.Issue(ctx, nil, 1, 1, 1, 5+hair, StockRef{})
+		require.NoError(t, err)
+		assert.Equal(t, 10.0, amount)
+		assert.Equal(t, []float64{0, 5}, fake.remaining(), "the next layer is left whole")
+	})
+
+	t.Run("stock beyond the layers goes at the average", func(t *testing.T) {
+		v, fake := newValuationTest(t, model.CostingMethodFifo, [2]float64{5, 2})
+		// 3 more units at 3 without layers, e.g. found by a count
+		fake.cost.Quantity, fake.cost.Value = 8, 19
+
+		amount, err := v.Issue(ctx, nil, 1, 1, 1, 6, StockRef{})
+		require.NoError(t, err)
+		assert.Equal(t, 5*2+19.0/8, amount)
+		assert.Equal(t, []float64{0}, fake.remaining())
+	})
+
+	t.Run("the last units take the remaining value", func(t *testing.T) {
+		v, fake := newValuationTest(t, model.CostingMethodAverage, [2]float64{3, 1.0 / 3})
+		value := fake.cost.Value
+
+		amount, err := v.Issue(ctx, nil, 1, 1, 1, 3-hair, StockRef{})
+		require.NoError(t, err)
+		assert.Equal(t, value, amount)
+		assert.Zero(t, fake.cost.Value)
+		assert.InDelta(t, hair, fake.cost.Quantity, 1e-12)
+	})
+}
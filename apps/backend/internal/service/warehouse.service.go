@@ -0,0 +1,405 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/suttapak/starter/errs"
+	"github.com/suttapak/starter/helpers"
+	"github.com/suttapak/starter/internal/filter"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"github.com/suttapak/starter/logger"
+	"gorm.io/gorm"
+)
+
+// codes of the warehouse and locations every team gets on first use
+const (
+	DefaultWarehouseCode = "MAIN"
+	DefaultLocationCode  = "DEFAULT"
+	TransitLocationCode  = "TRANSIT"
+)
+
+type (
+	// WarehouseService manages the warehouses of a team and their locations.
+	WarehouseService interface {
+		CreateWarehouse(ctx context.Context, teamId uint, body CreateWarehouseRequest) (*WarehouseResponse, error)
+		UpdateWarehouse(ctx context.Context, teamId, warehouseId uint, body UpdateWarehouseRequest) (*WarehouseResponse, error)
+		// DeleteWarehouse deletes the warehouse with its locations, it fails with
+		// ErrLocationInUse while one of them holds stock or is a system location.
+		DeleteWarehouse(ctx context.Context, teamId, warehouseId uint) error
+		FindWarehouseById(ctx context.Context, teamId, warehouseId uint) (*WarehouseResponse, error)
+		FindWarehouses(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.WarehouseFilter) ([]WarehouseResponse, error)
+
+		CreateLocation(ctx context.Context, teamId, warehouseId uint, body LocationRequest) (*LocationResponse, error)
+		UpdateLocation(ctx context.Context, teamId, locationId uint, body LocationRequest) (*LocationResponse, error)
+		DeleteLocation(ctx context.Context, teamId, locationId uint) error
+		FindLocationById(ctx context.Context, teamId, locationId uint) (*LocationResponse, error)
+
+		// DefaultLocation returns the default storage location of the team,
+		// creating the MAIN warehouse with its locations on first use.
+		DefaultLocation(ctx context.Context, tx *gorm.DB, teamId uint) (*model.Location, error)
+		// TransitLocation returns the location holding shipped transfers of the team.
+		TransitLocation(ctx context.Context, tx *gorm.DB, teamId uint) (*model.Location, error)
+		// StorageLocation returns the location when it is a storage location of
+		// the team, or the default location when locationId is zero.
+		StorageLocation(ctx context.Context, tx *gorm.DB, teamId, locationId uint) (*model.Location, error)
+	}
+	warehouseService struct {
+		warehouse repository.Warehouse
+		location  repository.Location
+		lotStock  repository.LotStock
+		dbTx      repository.DatabaseTransaction
+		logger    logger.AppLogger
+	}
+
+	CreateWarehouseRequest struct {
+		Code    string `json:"code" binding:"required,max=50"`
+		Name    string `json:"name" binding:"required,max=255"`
+		Address string `json:"address"`
+	}
+	UpdateWarehouseRequest struct {
+		Name    string `json:"name" binding:"required,max=255"`
+		Address string `json:"address"`
+	}
+	LocationRequest struct {
+		Code string `json:"code" binding:"required,max=50"`
+		Name string `json:"name" binding:"max=255"`
+	}
+
+	WarehouseResponse struct {
+		CommonModel
+		TeamID    uint               `json:"team_id"`
+		Code      string             `json:"code"`
+		Name      string             `json:"name"`
+		Address   string             `json:"address"`
+		Locations []LocationResponse `json:"locations"`
+	}
+	LocationResponse struct {
+		CommonModel
+		WarehouseID   uint   `json:"warehouse_id"`
+		WarehouseCode string `json:"warehouse_code,omitempty"`
+		Code          string `json:"code"`
+		Name          string `json:"name"`
+		Type          string `json:"type"`
+		IsDefault     bool   `json:"is_default"`
+	}
+)
+
+// CreateWarehouse implements WarehouseService.
+func (w *warehouseService) CreateWarehouse(ctx context.Context, teamId uint, body CreateWarehouseRequest) (*WarehouseResponse, error) {
+	m := &model.Warehouse{
+		TeamID:  teamId,
+		Code:    body.Code,
+		Name:    body.Name,
+		Address: body.Address,
+	}
+	if err := w.warehouse.Create(ctx, nil, m); err != nil {
+		w.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return w.FindWarehouseById(ctx, teamId, m.ID)
+}
+
+// UpdateWarehouse implements WarehouseService.
+func (w *warehouseService) UpdateWarehouse(ctx context.Context, teamId, warehouseId uint, body UpdateWarehouseRequest) (*WarehouseResponse, error) {
+	if err := w.warehouse.Update(ctx, nil, teamId, warehouseId, body.Name, body.Address); err != nil {
+		w.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrWarehouseNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return w.FindWarehouseById(ctx, teamId, warehouseId)
+}
+
+// DeleteWarehouse implements WarehouseService.
+func (w *warehouseService) DeleteWarehouse(ctx context.Context, teamId, warehouseId uint) error {
+	tx := w.dbTx.BeginTx()
+	defer w.dbTx.RollbackTx(tx) //nolint:errcheck
+	locations, err := w.location.FindByWarehouse(ctx, tx, teamId, warehouseId)
+	if err != nil {
+		w.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	if err := w.checkUnused(ctx, tx, locations...); err != nil {
+		return err
+	}
+	if err := w.warehouse.Delete(ctx, tx, teamId, warehouseId); err != nil {
+		w.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errs.ErrWarehouseNotFound
+		}
+		return errs.HandleSqlErr(err)
+	}
+	if err := w.dbTx.CommitTx(tx); err != nil {
+		w.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return nil
+}
+
+// FindWarehouseById implements WarehouseService.
+func (w *warehouseService) FindWarehouseById(ctx context.Context, teamId, warehouseId uint) (*WarehouseResponse, error) {
+	m, err := w.warehouse.FindById(ctx, nil, teamId, warehouseId)
+	if err != nil {
+		w.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrWarehouseNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return toWarehouseResponse(m), nil
+}
+
+// FindWarehouses implements WarehouseService.
+func (w *warehouseService) FindWarehouses(ctx context.Context, teamId uint, pg *helpers.Pagination, f *filter.WarehouseFilter) ([]WarehouseResponse, error) {
+	// a team sees its default warehouse before receiving any stock
+	if _, err := w.DefaultLocation(ctx, nil, teamId); err != nil {
+		return nil, err
+	}
+	warehouses, err := w.warehouse.FindAll(ctx, nil, teamId, pg, f)
+	if err != nil {
+		w.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	helpers.Paging(pg)
+	res := make([]WarehouseResponse, 0, len(warehouses))
+	for i := range warehouses {
+		res = append(res, *toWarehouseResponse(&warehouses[i]))
+	}
+	return res, nil
+}
+
+// CreateLocation implements WarehouseService.
+func (w *warehouseService) CreateLocation(ctx context.Context, teamId, warehouseId uint, body LocationRequest) (*LocationResponse, error) {
+	if _, err := w.warehouse.FindById(ctx, nil, teamId, warehouseId); err != nil {
+		w.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrWarehouseNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	m := &model.Location{
+		TeamID:      teamId,
+		WarehouseID: warehouseId,
+		Code:        body.Code,
+		Name:        body.Name,
+		Type:        model.LocationTypeStorage,
+	}
+	if err := w.location.Create(ctx, nil, m); err != nil {
+		w.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	return w.FindLocationById(ctx, teamId, m.ID)
+}
+
+// UpdateLocation implements WarehouseService.
+func (w *warehouseService) UpdateLocation(ctx context.Context, teamId, locationId uint, body LocationRequest) (*LocationResponse, error) {
+	if err := w.location.Update(ctx, nil, teamId, locationId, body.Code, body.Name); err != nil {
+		w.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrLocationNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return w.FindLocationById(ctx, teamId, locationId)
+}
+
+// DeleteLocation implements WarehouseService.
+func (w *warehouseService) DeleteLocation(ctx context.Context, teamId, locationId uint) error {
+	tx := w.dbTx.BeginTx()
+	defer w.dbTx.RollbackTx(tx) //nolint:errcheck
+	m, err := w.location.FindById(ctx, tx, teamId, locationId)
+	if err != nil {
+		w.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errs.ErrLocationNotFound
+		}
+		return errs.HandleSqlErr(err)
+	}
+	if err := w.checkUnused(ctx, tx, *m); err != nil {
+		return err
+	}
+	if err := w.location.Delete(ctx, tx, teamId, locationId); err != nil {
+		w.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	if err := w.dbTx.CommitTx(tx); err != nil {
+		w.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	return nil
+}
+
+// FindLocationById implements WarehouseService.
+func (w *warehouseService) FindLocationById(ctx context.Context, teamId, locationId uint) (*LocationResponse, error) {
+	m, err := w.location.FindById(ctx, nil, teamId, locationId)
+	if err != nil {
+		w.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrLocationNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	return toLocationResponse(m), nil
+}
+
+// DefaultLocation implements WarehouseService.
+func (w *warehouseService) DefaultLocation(ctx context.Context, tx *gorm.DB, teamId uint) (*model.Location, error) {
+	m, err := w.location.FindDefault(ctx, tx, teamId)
+	if err == nil {
+		return m, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		w.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	def, _, err := w.createDefaults(ctx, tx, teamId)
+	return def, err
+}
+
+// TransitLocation implements WarehouseService.
+func (w *warehouseService) TransitLocation(ctx context.Context, tx *gorm.DB, teamId uint) (*model.Location, error) {
+	m, err := w.location.FindTransit(ctx, tx, teamId)
+	if err == nil {
+		return m, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		w.logger.Error(err)
+		return nil, errs.HandleSqlErr(err)
+	}
+	_, transit, err := w.createDefaults(ctx, tx, teamId)
+	return transit, err
+}
+
+// StorageLocation implements WarehouseService.
+func (w *warehouseService) StorageLocation(ctx context.Context, tx *gorm.DB, teamId, locationId uint) (*model.Location, error) {
+	if locationId == 0 {
+		return w.DefaultLocation(ctx, tx, teamId)
+	}
+	m, err := w.location.FindById(ctx, tx, teamId, locationId)
+	if err != nil {
+		w.logger.Error(err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errs.ErrLocationNotFound
+		}
+		return nil, errs.HandleSqlErr(err)
+	}
+	if m.Type != model.LocationTypeStorage {
+		return nil, errs.ErrLocationNotFound
+	}
+	return m, nil
+}
+
+// createDefaults creates the MAIN warehouse of the team with its default and
+// transit locations, keeping the ones another request created first.
+func (w *warehouseService) createDefaults(ctx context.Context, tx *gorm.DB, teamId uint) (*model.Location, *model.Location, error) {
+	warehouse := &model.Warehouse{
+		TeamID: teamId,
+		Code:   DefaultWarehouseCode,
+		Name:   "คลังหลัก",
+	}
+	if err := w.warehouse.CreateIfNotExists(ctx, tx, warehouse); err != nil {
+		w.logger.Error(err)
+		return nil, nil, errs.HandleSqlErr(err)
+	}
+	def := &model.Location{
+		TeamID:      teamId,
+		WarehouseID: warehouse.ID,
+		Code:        DefaultLocationCode,
+		Name:        "ตำแหน่งเริ่มต้น",
+		Type:        model.LocationTypeStorage,
+		IsDefault:   true,
+	}
+	if err := w.location.CreateIfNotExists(ctx, tx, def); err != nil {
+		w.logger.Error(err)
+		return nil, nil, errs.HandleSqlErr(err)
+	}
+	transit := &model.Location{
+		TeamID:      teamId,
+		WarehouseID: warehouse.ID,
+		Code:        TransitLocationCode,
+		Name:        "ระหว่างขนส่ง",
+		Type:        model.LocationTypeTransit,
+	}
+	if err := w.location.CreateIfNotExists(ctx, tx, transit); err != nil {
+		w.logger.Error(err)
+		return nil, nil, errs.HandleSqlErr(err)
+	}
+	return def, transit, nil
+}
+
+// checkUnused refuses locations holding stock and the system locations.
+func (w *warehouseService) checkUnused(ctx context.Context, tx *gorm.DB, locations ...model.Location) error {
+	ids := make([]uint, 0, len(locations))
+	for _, l := range locations {
+		if l.IsDefault || l.Type == model.LocationTypeTransit {
+			return errs.ErrLocationInUse
+		}
+		ids = append(ids, l.ID)
+	}
+	sum, err := w.lotStock.SumByLocation(ctx, tx, ids...)
+	if err != nil {
+		w.logger.Error(err)
+		return errs.HandleSqlErr(err)
+	}
+	if sum > 0 {
+		return errs.ErrLocationInUse
+	}
+	return nil
+}
+
+func toWarehouseResponse(m *model.Warehouse) *WarehouseResponse {
+	res := &WarehouseResponse{
+		CommonModel: CommonModel{
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		},
+		TeamID:    m.TeamID,
+		Code:      m.Code,
+		Name:      m.Name,
+		Address:   m.Address,
+		Locations: make([]LocationResponse, 0, len(m.Locations)),
+	}
+	for i := range m.Locations {
+		res.Locations = append(res.Locations, *toLocationResponse(&m.Locations[i]))
+	}
+	return res
+}
+
+func toLocationResponse(m *model.Location) *LocationResponse {
+	res := &LocationResponse{
+		CommonModel: CommonModel{
+			ID:        m.ID,
+			CreatedAt: m.CreatedAt,
+			UpdatedAt: m.UpdatedAt,
+		},
+		WarehouseID: m.WarehouseID,
+		Code:        m.Code,
+		Name:        m.Name,
+		Type:        m.Type,
+		IsDefault:   m.IsDefault,
+	}
+	if m.Warehouse != nil {
+		res.WarehouseCode = m.Warehouse.Code
+	}
+	return res
+}
+
+func NewWarehouseService(
+	warehouse repository.Warehouse,
+	location repository.Location,
+	lotStock repository.LotStock,
+	dbTx repository.DatabaseTransaction,
+	logger logger.AppLogger,
+) WarehouseService {
+	return &warehouseService{
+		warehouse: warehouse,
+		location:  location,
+		lotStock:  lotStock,
+		dbTx:      dbTx,
+		logger:    logger,
+	}
+}
@@ -4,3 +4,9 @@ import "embed"
 
 //go:embed mail/*
 var EmailTemplateFS embed.FS
+
+// PageTemplateFS holds the pages served to browsers rather than API clients,
+// e.g. the confirmation of an emailed approval link.
+//
+//go:embed page/*
+var PageTemplateFS embed.FS
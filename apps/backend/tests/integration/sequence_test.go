@@ -0,0 +1,155 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/suttapak/starter/internal/model"
+	"github.com/suttapak/starter/internal/repository"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDSN is the database of docker-compose.test.yaml, TEST_DB_DSN overrides it.
+// The tests skip when it is unreachable unless TEST_DB_REQUIRED is set, as by
+// make test-integration and CI.
+const testDSN = "host=localhost user=test_user password=test_password dbname=test_db port=5433 sslmode=disable TimeZone=Asia/Bangkok connect_timeout=3"
+
+func openTestDb(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DB_DSN")
+	if dsn == "" {
+		dsn = testDSN
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		if os.Getenv("TEST_DB_REQUIRED") != "" {
+			t.Fatalf("test database not available: %v", err)
+		}
+		t.Skipf("test database not available: %v", err)
+	}
+	require.NoError(t, db.AutoMigrate(&model.AutoIncrementSequence{}))
+	return db
+}
+
+// testSequence is an entity type of its own for every test, so runs never
+// share a sequence.
+func testSequence(t *testing.T, db *gorm.DB) model.EntityType {
+	t.Helper()
+	entityType := model.EntityType(fmt.Sprintf("test-%s-%d", t.Name(), time.Now().UnixNano()))
+	t.Cleanup(func() {
+		db.Where("entity_type = ?", entityType).Delete(&model.AutoIncrementSequence{})
+	})
+	return entityType
+}
+
+func TestGetNextSequenceConcurrent(t *testing.T) {
+	db := openTestDb(t)
+	entityType := testSequence(t, db)
+	repo := repository.NewAutoIncrementSequence(db)
+	ctx := context.Background()
+
+	const workers, calls = 32, 25
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		numbers []uint
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range calls {
+				n, err := repo.GetNextSequence(ctx, nil, entityType, 1, 0, "2026-01-01")
+				if !assert.NoError(t, err) {
+					return
+				}
+				mu.Lock()
+				numbers = append(numbers, n)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(numbers)
+	require.Len(t, numbers, workers*calls)
+	for i, n := range numbers {
+		require.Equal(t, uint(i+1), n, "numbers must be unique and contiguous")
+	}
+}
+
+func TestGetNextSequencePeriod(t *testing.T) {
+	db := openTestDb(t)
+	entityType := testSequence(t, db)
+	repo := repository.NewAutoIncrementSequence(db)
+	ctx := context.Background()
+
+	for want, period := range []string{"2026-01-01", "2026-01-01", "2026-01-01"} {
+		n, err := repo.GetNextSequence(ctx, nil, entityType, 1, 0, period)
+		require.NoError(t, err)
+		assert.Equal(t, uint(want+1), n)
+	}
+	n, err := repo.GetNextSequence(ctx, nil, entityType, 1, 0, "2026-01-02")
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), n, "a new period restarts the sequence")
+
+	// other teams and entities count apart
+	n, err = repo.GetNextSequence(ctx, nil, entityType, 2, 0, "2026-01-02")
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), n)
+	n, err = repo.GetNextSequence(ctx, nil, entityType, 1, 7, "2026-01-02")
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), n)
+}
+
+func TestGetNextSequenceGapless(t *testing.T) {
+	db := openTestDb(t)
+	entityType := testSequence(t, db)
+	repo := repository.NewAutoIncrementSequence(db)
+	ctx := context.Background()
+
+	const workers = 24
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		committed []uint
+	)
+	for i := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tx := db.Begin()
+			n, err := repo.GetNextSequence(ctx, tx, entityType, 1, 0, "")
+			if !assert.NoError(t, err) {
+				tx.Rollback()
+				return
+			}
+			// every third document fails, its number goes to the next one
+			if i%3 == 0 {
+				tx.Rollback()
+				return
+			}
+			if assert.NoError(t, tx.Commit().Error) {
+				mu.Lock()
+				committed = append(committed, n)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(committed)
+	require.Len(t, committed, workers-workers/3)
+	for i, n := range committed {
+		require.Equal(t, uint(i+1), n, "committed numbers must have no gaps")
+	}
+}
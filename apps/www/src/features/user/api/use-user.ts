@@ -23,7 +23,7 @@ export const useGetUserMe = () => {
 export const useGetImageProfile = () => {
   const { data, ...rest } = useGetUserMe();
   const image = data?.data.data.profile_image?.at(-1)?.image;
-  const src = image ? `/api/v1/${image.path}` : undefined;
+  const src = image ? `/api/v1${image.url}` : undefined;
 
   return { image, src, ...rest };
 };